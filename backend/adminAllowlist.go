@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Networks allowed to reach management endpoints, from ADMIN_ALLOWED_CIDRS.
+// Nil means no restriction.
+var adminAllowedNets []*net.IPNet
+
+// Parse ADMIN_ALLOWED_CIDRS ("10.0.0.0/8,192.168.1.10"); bare IPs are
+// treated as single-host networks
+func LoadAdminAllowlist() ([]*net.IPNet, error) {
+	value := os.Getenv("ADMIN_ALLOWED_CIDRS")
+	if value == "" {
+		return nil, nil
+	}
+
+	nets, err := parseCIDRList("ADMIN_ALLOWED_CIDRS", value)
+	if err != nil {
+		return nil, err
+	}
+
+	appLog.Info("Management endpoints restricted to trusted networks", "networks", len(nets))
+	return nets, nil
+}
+
+// Parse a comma separated list of CIDRs; bare IPs become single-host networks
+func parseCIDRList(name, value string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address in %s: %s", name, entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR in %s: %s", name, entry)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// Check whether an address is inside the admin allowlist
+func adminAddressAllowed(ip net.IP) bool {
+	if adminAllowedNets == nil {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range adminAllowedNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware limiting management endpoints to trusted networks. It uses the
+// connection's remote address rather than forwarded headers, which callers
+// can forge.
+func requireAdminNetwork() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		remoteIP := net.ParseIP(c.RemoteIP())
+		if !adminAddressAllowed(remoteIP) {
+			appLog.Warn("Rejected management request", "path", c.Request.URL.Path, "client", c.RemoteIP())
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Management endpoints are not reachable from this network"})
+			return
+		}
+		c.Next()
+	}
+}
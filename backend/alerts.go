@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	DEFAULT_ALERT_CHECK_INTERVAL = 30 * time.Second
+	DEFAULT_ALERT_WINDOW         = 5 * time.Minute
+	DEFAULT_ALERT_MIN_REQUESTS   = 20
+	DEFAULT_SOURCE_LOST_GRACE    = 60 * time.Second
+	DEFAULT_DISK_ALERT_PERCENT   = 90
+	ALERT_TOP_PATHS              = 5
+)
+
+// Thresholds for the built-in alert rules and system checks
+type AlertConfig struct {
+	ErrorRatePercent int           `json:"errorRatePercent"` // 0 disables the error rate rule
+	Window           time.Duration `json:"-"`
+	MinRequests      int           `json:"minRequests"`
+	CheckInterval    time.Duration `json:"-"`
+	SourceLostGrace  time.Duration `json:"-"`
+	DiskAlertPercent int           `json:"diskAlertPercent"` // 0 disables the disk check
+}
+
+func GetAlertConfig() AlertConfig {
+	return AlertConfig{
+		ErrorRatePercent: GetEnvInt("ALERT_ERROR_RATE_PERCENT", 0),
+		Window:           time.Duration(GetEnvInt("ALERT_WINDOW_MINUTES", int(DEFAULT_ALERT_WINDOW/time.Minute))) * time.Minute,
+		MinRequests:      GetEnvInt("ALERT_MIN_REQUESTS", DEFAULT_ALERT_MIN_REQUESTS),
+		CheckInterval:    time.Duration(GetEnvInt("ALERT_CHECK_INTERVAL_SECONDS", int(DEFAULT_ALERT_CHECK_INTERVAL/time.Second))) * time.Second,
+		SourceLostGrace:  time.Duration(GetEnvInt("SOURCE_LOST_GRACE_SECONDS", int(DEFAULT_SOURCE_LOST_GRACE/time.Second))) * time.Second,
+		DiskAlertPercent: GetEnvInt("DISK_ALERT_PERCENT", DEFAULT_DISK_ALERT_PERCENT),
+	}
+}
+
+// Request and 5xx counts for one service over the alert window
+type serviceErrorWindow struct {
+	Tenant     string
+	Service    string
+	Requests   int
+	Errors     int
+	PathErrors map[string]int
+	// Per-minute requests and errors, oldest first
+	MinuteRequests []int
+	MinuteErrors   []int
+}
+
+type PathErrorCount struct {
+	Path   string `json:"path"`
+	Errors int    `json:"errors"`
+}
+
+// Evaluates alert rules and watches system health, remembering what has
+// fired so each condition notifies once when raised and once when resolved
+type AlertMonitor struct {
+	config      AlertConfig
+	firing      map[string]bool
+	missingFrom map[string]time.Time
+	stop        chan struct{}
+
+	// Tenant and service of each firing error rate alert, to resolve it
+	// once the service has no traffic left in the window
+	errorRateServices map[string]*serviceErrorWindow
+}
+
+var alertMonitor *AlertMonitor
+
+func startAlertMonitor(config AlertConfig) *AlertMonitor {
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = DEFAULT_ALERT_CHECK_INTERVAL
+	}
+	if config.Window <= 0 {
+		config.Window = DEFAULT_ALERT_WINDOW
+	}
+
+	m := &AlertMonitor{
+		config:      config,
+		firing:      make(map[string]bool),
+		missingFrom: make(map[string]time.Time),
+		stop:        make(chan struct{}),
+
+		errorRateServices: make(map[string]*serviceErrorWindow),
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				m.check(now)
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+
+	if config.ErrorRatePercent > 0 {
+		appLog.Info("Error rate alerts enabled", "percent5xx", config.ErrorRatePercent,
+			"window", config.Window, "minRequests", config.MinRequests)
+	}
+	return m
+}
+
+func (m *AlertMonitor) Stop() {
+	close(m.stop)
+}
+
+func (m *AlertMonitor) check(now time.Time) {
+	if m.config.ErrorRatePercent > 0 {
+		m.checkErrorRates(now)
+	}
+	m.checkLogSources(now)
+	if m.config.DiskAlertPercent > 0 {
+		m.checkDiskUsage()
+	}
+}
+
+// Raise or resolve a condition, returning true when its state changed
+func (m *AlertMonitor) transition(key string, active bool) bool {
+	if m.firing[key] == active {
+		return false
+	}
+	if active {
+		m.firing[key] = true
+	} else {
+		delete(m.firing, key)
+	}
+	return true
+}
+
+func (m *AlertMonitor) checkErrorRates(now time.Time) {
+	windows := logParser.errorWindows(now, m.config.Window)
+
+	// Services with no traffic left in the window have recovered too: give
+	// them an empty window so they are resolved like the others
+	seen := make(map[string]bool)
+	for _, w := range windows {
+		seen["errorRate:"+w.Tenant+"/"+w.Service] = true
+	}
+	minutes := max(int(m.config.Window/time.Minute), 1)
+	for key, w := range m.errorRateServices {
+		if !seen[key] {
+			windows = append(windows, &serviceErrorWindow{
+				Tenant:         w.Tenant,
+				Service:        w.Service,
+				MinuteRequests: make([]int, minutes),
+				MinuteErrors:   make([]int, minutes),
+			})
+		}
+	}
+
+	for _, w := range windows {
+		key := "errorRate:" + w.Tenant + "/" + w.Service
+
+		rate := 0.0
+		if w.Requests > 0 {
+			rate = float64(w.Errors) / float64(w.Requests) * 100
+		}
+		active := w.Requests >= m.config.MinRequests && rate >= float64(m.config.ErrorRatePercent)
+		if !m.transition(key, active) {
+			continue
+		}
+		if active {
+			m.errorRateServices[key] = &serviceErrorWindow{Tenant: w.Tenant, Service: w.Service}
+		} else {
+			delete(m.errorRateServices, key)
+		}
+
+		series := make([]float64, len(w.MinuteRequests))
+		for i := range series {
+			if w.MinuteRequests[i] > 0 {
+				series[i] = math.Round(float64(w.MinuteErrors[i])/float64(w.MinuteRequests[i])*1000) / 10
+			}
+		}
+		details := map[string]interface{}{
+			"errorRate":       math.Round(rate*10) / 10,
+			"threshold":       m.config.ErrorRatePercent,
+			"requests":        w.Requests,
+			"errors":          w.Errors,
+			"windowMinutes":   int(m.config.Window / time.Minute),
+			"errorRateSeries": series,
+			"topPaths":        topErrorPaths(w.PathErrors, ALERT_TOP_PATHS),
+		}
+
+		n := Notification{
+			Kind:    NOTIFY_KIND_ALERT,
+			Service: w.Service,
+			Tenant:  w.Tenant,
+			Details: details,
+		}
+		if active {
+			n.Event = "errorRateHigh"
+			n.Severity = SEVERITY_CRITICAL
+			n.Title = fmt.Sprintf("High error rate on %s", w.Service)
+			n.Message = fmt.Sprintf("%.1f%% of %d requests returned 5xx in the last %s", rate, w.Requests, m.config.Window)
+		} else {
+			n.Event = "errorRateResolved"
+			n.Severity = SEVERITY_INFO
+			n.Title = fmt.Sprintf("Error rate recovered on %s", w.Service)
+			n.Message = fmt.Sprintf("%.1f%% of %d requests returned 5xx in the last %s", rate, w.Requests, m.config.Window)
+		}
+		raiseAlert(n)
+	}
+}
+
+// Notify about log files that have been missing longer than the grace period
+// (a rotation briefly removes the file, so it isn't reported right away)
+func (m *AlertMonitor) checkLogSources(now time.Time) {
+	for _, file := range logParser.WatchedFiles() {
+		if isRemoteSource(file) {
+			continue // Reported as unreachable in /api/sources
+		}
+		key := "sourceLost:" + file
+		if _, err := os.Stat(file); err == nil {
+			delete(m.missingFrom, file)
+			if m.transition(key, false) {
+				notifySystemEvent("logSourceRecovered", SEVERITY_INFO, "Log source recovered",
+					fmt.Sprintf("%s is available again", file), map[string]interface{}{"file": file})
+			}
+			continue
+		}
+
+		since, ok := m.missingFrom[file]
+		if !ok {
+			m.missingFrom[file] = now
+			continue
+		}
+		if now.Sub(since) >= m.config.SourceLostGrace && m.transition(key, true) {
+			notifySystemEvent("logSourceLost", SEVERITY_CRITICAL, "Log source lost",
+				fmt.Sprintf("%s has been missing since %s", file, since.Format(time.RFC3339)),
+				map[string]interface{}{"file": file, "missingSince": since.Format(time.RFC3339)})
+		}
+	}
+}
+
+// Notify when a filesystem holding log files is nearly full
+func (m *AlertMonitor) checkDiskUsage() {
+	dirs := make(map[string]bool)
+	for _, file := range logParser.WatchedFiles() {
+		if !isRemoteSource(file) {
+			dirs[filepath.Dir(file)] = true
+		}
+	}
+
+	for dir := range dirs {
+		percent, err := diskUsagePercent(dir)
+		if err != nil {
+			continue
+		}
+		key := "diskFull:" + dir
+		active := percent >= float64(m.config.DiskAlertPercent)
+		if !m.transition(key, active) {
+			continue
+		}
+		details := map[string]interface{}{
+			"path":        dir,
+			"usedPercent": math.Round(percent*10) / 10,
+			"threshold":   m.config.DiskAlertPercent,
+		}
+		if active {
+			notifySystemEvent("diskNearlyFull", SEVERITY_WARNING, "Disk nearly full",
+				fmt.Sprintf("Filesystem holding %s is %.1f%% full", dir, percent), details)
+		} else {
+			notifySystemEvent("diskSpaceRecovered", SEVERITY_INFO, "Disk space recovered",
+				fmt.Sprintf("Filesystem holding %s is %.1f%% full", dir, percent), details)
+		}
+	}
+}
+
+// Deliver an alert to the notifiers and to WebSocket clients on the alerts
+// channel that may see the alert's tenant
+func raiseAlert(n Notification) {
+	notify(n)
+
+	wsClientsMux.RLock()
+	clientList := make([]*WebSocketClient, 0, len(wsClients))
+	for client := range wsClients {
+		if client.IsHealthy() && (client.tenant == "" || client.tenant == n.Tenant) {
+			clientList = append(clientList, client)
+		}
+	}
+	wsClientsMux.RUnlock()
+
+	for _, client := range clientList {
+		client.SendEvent(WS_CHANNEL_ALERTS, "alert", n)
+	}
+}
+
+// Count requests and 5xx responses per service over the window ending now
+func (lp *LogParser) errorWindows(now time.Time, window time.Duration) []*serviceErrorWindow {
+	minutes := int(window / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+	start := now.Add(-window)
+
+	snapshot := lp.logsSnapshot.Load()
+	byService := make(map[string]*serviceErrorWindow)
+	for i := 0; i < snapshot.Len(); i++ {
+		entry := snapshot.At(i)
+		timestamp, err := time.Parse(time.RFC3339, entry.Timestamp)
+		if err != nil || timestamp.Before(start) || timestamp.After(now) {
+			continue
+		}
+
+		key := entry.Tenant + "/" + entry.ServiceName
+		w, ok := byService[key]
+		if !ok {
+			w = &serviceErrorWindow{
+				Tenant:         entry.Tenant,
+				Service:        entry.ServiceName,
+				PathErrors:     make(map[string]int),
+				MinuteRequests: make([]int, minutes),
+				MinuteErrors:   make([]int, minutes),
+			}
+			byService[key] = w
+		}
+
+		bucket := int(timestamp.Sub(start) / time.Minute)
+		if bucket >= minutes {
+			bucket = minutes - 1
+		}
+		w.Requests++
+		w.MinuteRequests[bucket]++
+		if entry.Status >= 500 {
+			w.Errors++
+			w.MinuteErrors[bucket]++
+			w.PathErrors[entry.PathTemplate]++
+		}
+	}
+
+	result := make([]*serviceErrorWindow, 0, len(byService))
+	for _, w := range byService {
+		result = append(result, w)
+	}
+	return result
+}
+
+func topErrorPaths(counts map[string]int, limit int) []PathErrorCount {
+	paths := make([]PathErrorCount, 0, len(counts))
+	for path, errors := range counts {
+		paths = append(paths, PathErrorCount{Path: path, Errors: errors})
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if paths[i].Errors != paths[j].Errors {
+			return paths[i].Errors > paths[j].Errors
+		}
+		return paths[i].Path < paths[j].Path
+	})
+	if len(paths) > limit {
+		paths = paths[:limit]
+	}
+	return paths
+}
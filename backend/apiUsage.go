@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rate limit and daily quota for an API key; zero means unlimited
+type TokenLimits struct {
+	RequestsPerMinute int `json:"requestsPerMinute"`
+	DailyQuota        int `json:"dailyQuota"`
+}
+
+// Usage counters for one API key
+type TokenUsage struct {
+	Key           string      `json:"key"` // Short fingerprint, never the token itself
+	Name          string      `json:"name"`
+	Limits        TokenLimits `json:"limits"`
+	CurrentMinute int         `json:"currentMinute"`
+	Today         int         `json:"today"`
+	Total         int64       `json:"total"`
+	Rejected      int64       `json:"rejected"`
+	LastUsed      string      `json:"lastUsed,omitempty"`
+
+	minuteStart time.Time
+	day         string
+	lastUsed    time.Time
+}
+
+// Per-API-key rate limiting with fixed one-minute windows and UTC-day quotas
+type APIUsage struct {
+	mu       sync.Mutex
+	defaults TokenLimits
+	usage    map[string]*TokenUsage
+}
+
+var apiUsage = &APIUsage{usage: make(map[string]*TokenUsage)}
+
+// Read the default limits applied to every API key
+func GetDefaultTokenLimits() TokenLimits {
+	return TokenLimits{
+		RequestsPerMinute: GetEnvInt("API_RATE_LIMIT_PER_MINUTE", 0),
+		DailyQuota:        GetEnvInt("API_DAILY_QUOTA", 0),
+	}
+}
+
+func (u *APIUsage) SetDefaults(limits TokenLimits) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.defaults = limits
+}
+
+// Identify a bearer token as a known API key: the global API_AUTH_TOKEN or
+// a tenant token. Unknown tokens aren't tracked so the map can't grow unbounded.
+func (u *APIUsage) identify(token string) (string, TokenLimits, bool) {
+	u.mu.Lock()
+	limits := u.defaults
+	u.mu.Unlock()
+
+	if wsAuth.Enabled() && subtle.ConstantTimeCompare([]byte(token), []byte(wsAuth.apiToken)) == 1 {
+		return "api", limits, true
+	}
+	if tenant, ok := tenancy.TenantForToken(token); ok {
+		for _, t := range tenancy.Tenants {
+			if t.Name != tenant {
+				continue
+			}
+			if t.RateLimitPerMinute > 0 {
+				limits.RequestsPerMinute = t.RateLimitPerMinute
+			}
+			if t.DailyQuota > 0 {
+				limits.DailyQuota = t.DailyQuota
+			}
+		}
+		return "tenant:" + tenant, limits, true
+	}
+	return "", TokenLimits{}, false
+}
+
+// Record a request for a key. Returns false with the time to wait when the
+// rate limit or daily quota is exhausted.
+func (u *APIUsage) allow(token, name string, limits TokenLimits, now time.Time) (*TokenUsage, bool, time.Duration) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:4])
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	usage, ok := u.usage[key]
+	if !ok {
+		usage = &TokenUsage{Key: key}
+		u.usage[key] = usage
+	}
+	usage.Name = name
+	usage.Limits = limits
+
+	if now.Sub(usage.minuteStart) >= time.Minute {
+		usage.minuteStart = now.Truncate(time.Minute)
+		usage.CurrentMinute = 0
+	}
+	day := now.UTC().Format("2006-01-02")
+	if usage.day != day {
+		usage.day = day
+		usage.Today = 0
+	}
+
+	if limits.DailyQuota > 0 && usage.Today >= limits.DailyQuota {
+		usage.Rejected++
+		tomorrow := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		snapshot := *usage
+		return &snapshot, false, tomorrow.Sub(now)
+	}
+	if limits.RequestsPerMinute > 0 && usage.CurrentMinute >= limits.RequestsPerMinute {
+		usage.Rejected++
+		snapshot := *usage
+		return &snapshot, false, usage.minuteStart.Add(time.Minute).Sub(now)
+	}
+
+	usage.CurrentMinute++
+	usage.Today++
+	usage.Total++
+	usage.lastUsed = now
+	snapshot := *usage
+	return &snapshot, true, 0
+}
+
+// Get usage counters for every key seen, sorted by name
+func (u *APIUsage) Snapshot() []TokenUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := time.Now()
+	result := make([]TokenUsage, 0, len(u.usage))
+	for _, usage := range u.usage {
+		entry := *usage
+		if now.Sub(entry.minuteStart) >= time.Minute {
+			entry.CurrentMinute = 0
+		}
+		if entry.day != now.UTC().Format("2006-01-02") {
+			entry.Today = 0
+		}
+		if !entry.lastUsed.IsZero() {
+			entry.LastUsed = entry.lastUsed.Format(time.RFC3339)
+		}
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// Middleware enforcing per-key rate limits and quotas on /api routes
+func rateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c.Request)
+		if token == "" {
+			c.Next()
+			return
+		}
+		name, limits, ok := apiUsage.identify(token)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		usage, allowed, retryAfter := apiUsage.allow(token, name, limits, time.Now())
+		if limits.RequestsPerMinute > 0 {
+			remaining := limits.RequestsPerMinute - usage.CurrentMinute
+			if remaining < 0 {
+				remaining = 0
+			}
+			c.Header("X-RateLimit-Limit", strconv.Itoa(limits.RequestsPerMinute))
+			c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		}
+		if limits.DailyQuota > 0 {
+			c.Header("X-Quota-Limit", strconv.Itoa(limits.DailyQuota))
+			c.Header("X-Quota-Remaining", strconv.Itoa(limits.DailyQuota-usage.Today))
+		}
+
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit or quota exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func getAPIUsage(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"defaults": GetDefaultTokenLimits(),
+		"tokens":   apiUsage.Snapshot(),
+	})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context key holding the authenticated user's name
+const AUTH_USER_KEY = "authUser"
+
+// Check whether any user authentication method is configured
+func authEnabled() bool {
+	return basicAuth.Enabled() || oidcAuth.Enabled() || tenancy.Enabled()
+}
+
+// Paths that stay reachable without credentials
+func authExempt(c *gin.Context) bool {
+	if c.Request.Method == http.MethodOptions {
+		return true // CORS preflight never carries credentials
+	}
+	path := c.Request.URL.Path
+	if path == "/health" || strings.HasPrefix(path, "/health/") || strings.HasPrefix(path, "/auth/") {
+		return true
+	}
+	// Signed tickets are verified by the handlers that accept them
+	if hasTicket(c) {
+		return true
+	}
+	// The stream is protected by its own short-lived tokens when enabled
+	return path == "/ws" && wsAuth.Enabled()
+}
+
+// Middleware accepting any configured method: a verified client certificate,
+// a tenant token, the API token, basic auth credentials, or an OIDC token (bearer header,
+// session cookie, or access_token on /ws).
+// A no-op when no method is configured.
+func authMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authEnabled() || authExempt(c) {
+			c.Next()
+			return
+		}
+
+		// A verified client certificate already authenticates the caller
+		if c.Request.TLS != nil && len(c.Request.TLS.VerifiedChains) > 0 {
+			user := c.Request.TLS.VerifiedChains[0][0].Subject.CommonName
+			if user == "" {
+				user = "client-certificate"
+			}
+			c.Set(AUTH_USER_KEY, user)
+			c.Next()
+			return
+		}
+
+		// Tenant tokens authenticate and scope the request to one tenant
+		if token := bearerToken(c.Request); token != "" {
+			if tenant, ok := tenancy.TenantForToken(token); ok {
+				c.Set(AUTH_USER_KEY, "tenant:"+tenant)
+				c.Set(TENANT_KEY, tenant)
+				c.Next()
+				return
+			}
+		}
+
+		// The API token is accepted for scripted access alongside user logins
+		if wsAuth.Enabled() && wsAuth.authorizeAPIRequest(c.Request) {
+			c.Set(AUTH_USER_KEY, "api")
+			c.Next()
+			return
+		}
+
+		if basicAuth.Enabled() {
+			if user, _, ok := c.Request.BasicAuth(); ok && basicAuth.authorizeRequest(c.Request) {
+				c.Set(AUTH_USER_KEY, user)
+				c.Next()
+				return
+			}
+		}
+		if oidcAuth.Enabled() {
+			if identity, ok := oidcAuth.authorizeRequest(c.Request); ok {
+				c.Set(AUTH_USER_KEY, identity.Name)
+				c.Next()
+				return
+			}
+		}
+
+		if basicAuth.Enabled() {
+			c.Header("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", basicAuth.realm))
+		} else {
+			c.Header("WWW-Authenticate", "Bearer")
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	}
+}
+
+// Get a bearer token from the Authorization header or, for WebSocket
+// upgrades where browsers can't set headers, ?access_token=
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	if r.URL.Path == "/ws" {
+		return r.URL.Query().Get("access_token")
+	}
+	return ""
+}
+
+// Register the OIDC browser login routes when OIDC is configured
+func registerAuthRoutes(r *gin.Engine) {
+	if !oidcAuth.Enabled() {
+		return
+	}
+	r.GET("/auth/login", oidcAuth.handleLogin)
+	r.GET("/auth/callback", oidcAuth.handleCallback)
+	r.POST("/auth/logout", oidcAuth.handleLogout)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const DEFAULT_BASIC_AUTH_REALM = "Traefik Log Dashboard"
+
+// Built-in HTTP basic auth with bcrypt password hashes. Users come from
+// BASIC_AUTH_USERS ("user:hash,user2:hash") and/or an htpasswd-style
+// BASIC_AUTH_USERS_FILE; auth is disabled when neither is set.
+type BasicAuth struct {
+	realm string
+	users map[string][]byte
+
+	// Digests of recently verified passwords, so bcrypt only runs once per
+	// credential instead of on every request
+	verifiedMu sync.RWMutex
+	verified   map[string][sha256.Size]byte
+}
+
+var basicAuth *BasicAuth
+
+// Hash compared against for unknown users so they take as long as known ones
+var dummyBcryptHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+
+func NewBasicAuth() (*BasicAuth, error) {
+	auth := &BasicAuth{
+		realm:    os.Getenv("BASIC_AUTH_REALM"),
+		users:    make(map[string][]byte),
+		verified: make(map[string][sha256.Size]byte),
+	}
+	if auth.realm == "" {
+		auth.realm = DEFAULT_BASIC_AUTH_REALM
+	}
+
+	if users := os.Getenv("BASIC_AUTH_USERS"); users != "" {
+		for _, entry := range strings.Split(users, ",") {
+			if err := auth.addUser(entry); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if path := os.Getenv("BASIC_AUTH_USERS_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open basic auth users file: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if err := auth.addUser(line); err != nil {
+				return nil, err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read basic auth users file: %w", err)
+		}
+	}
+
+	if auth.Enabled() {
+		appLog.Info("Basic auth enabled", "users", len(auth.users))
+	}
+	return auth, nil
+}
+
+// Add a "user:bcrypt-hash" entry
+func (a *BasicAuth) addUser(entry string) error {
+	entry = strings.TrimSpace(entry)
+	idx := strings.Index(entry, ":")
+	if idx <= 0 || idx == len(entry)-1 {
+		return fmt.Errorf("invalid basic auth entry, expected user:bcrypt-hash")
+	}
+
+	user, hash := entry[:idx], []byte(entry[idx+1:])
+	if _, err := bcrypt.Cost(hash); err != nil {
+		return fmt.Errorf("invalid bcrypt hash for basic auth user %s: %w", user, err)
+	}
+	a.users[user] = hash
+	return nil
+}
+
+func (a *BasicAuth) Enabled() bool {
+	return a != nil && len(a.users) > 0
+}
+
+// Check a username/password pair
+func (a *BasicAuth) Authenticate(user, password string) bool {
+	digest := sha256.Sum256([]byte(password))
+
+	a.verifiedMu.RLock()
+	cached, ok := a.verified[user]
+	a.verifiedMu.RUnlock()
+	if ok && subtle.ConstantTimeCompare(cached[:], digest[:]) == 1 {
+		return true
+	}
+
+	hash, known := a.users[user]
+	if !known {
+		bcrypt.CompareHashAndPassword(dummyBcryptHash, []byte(password))
+		return false
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return false
+	}
+
+	a.verifiedMu.Lock()
+	a.verified[user] = digest
+	a.verifiedMu.Unlock()
+	return true
+}
+
+// Check the request's basic auth credentials
+func (a *BasicAuth) authorizeRequest(r *http.Request) bool {
+	user, password, ok := r.BasicAuth()
+	return ok && a.Authenticate(user, password)
+}
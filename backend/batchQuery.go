@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	MAX_BATCH_QUERIES         = 50
+	MAX_TIMESERIES_BUCKETS    = 1440
+	DEFAULT_AGGREGATE_LIMIT   = 10
+	DEFAULT_TIMESERIES_WINDOW = time.Minute
+)
+
+// BatchQuery describes one dashboard panel query executed as part of a batch
+type BatchQuery struct {
+	ID       string  `json:"id"`
+	Type     string  `json:"type"` // "stats", "aggregate", "timeseries"
+	Filters  Filters `json:"filters"`
+	GroupBy  string  `json:"groupBy,omitempty"`  // aggregate: facet field to group by
+	Limit    int     `json:"limit,omitempty"`    // aggregate: max number of buckets
+	Interval string  `json:"interval,omitempty"` // timeseries: bucket width, e.g. "1m"
+}
+
+type BatchResult struct {
+	ID    string      `json:"id"`
+	Type  string      `json:"type"`
+	Data  interface{} `json:"data,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+type AggregateBucket struct {
+	Key             string  `json:"key"`
+	Count           int     `json:"count"`
+	Requests4xx     int     `json:"requests4xx"`
+	Requests5xx     int     `json:"requests5xx"`
+	AvgResponseTime float64 `json:"avgResponseTime"`
+	TotalBytes      int64   `json:"totalBytes"`
+}
+
+type TimeSeriesPoint struct {
+	Timestamp       string  `json:"timestamp"`
+	Count           int     `json:"count"`
+	Requests4xx     int     `json:"requests4xx"`
+	Requests5xx     int     `json:"requests5xx"`
+	AvgResponseTime float64 `json:"avgResponseTime"`
+}
+
+// Run all queries against a single consistent view of the logs
+func (lp *LogParser) RunBatch(queries []BatchQuery) []BatchResult {
+	results := make([]BatchResult, len(queries))
+
+	// Validate everything first so one bad query doesn't leave the rest half run
+	for i := range queries {
+		results[i] = BatchResult{ID: queries[i].ID, Type: queries[i].Type}
+		if err := queries[i].validate(); err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+
+	snapshot := lp.logsSnapshot.Load()
+	for i, query := range queries {
+		if results[i].Error != "" {
+			continue
+		}
+		switch query.Type {
+		case "stats":
+			if query.Filters.IsEmpty() {
+				results[i].Data = lp.GetStats()
+			} else {
+				results[i].Data = lp.filteredStats(snapshot, query.Filters)
+			}
+		case "aggregate":
+			results[i].Data = lp.aggregate(snapshot, query)
+		case "timeseries":
+			results[i].Data = lp.timeseries(snapshot, query)
+		}
+	}
+
+	return results
+}
+
+// Check the query descriptor and compile its filters
+func (q *BatchQuery) validate() error {
+	switch q.Type {
+	case "stats":
+	case "aggregate":
+		if !validFacetField(q.GroupBy) {
+			return fmt.Errorf("unsupported groupBy field: %q", q.GroupBy)
+		}
+		if q.Limit <= 0 {
+			q.Limit = DEFAULT_AGGREGATE_LIMIT
+		}
+	case "timeseries":
+		if q.Interval != "" {
+			interval, err := time.ParseDuration(q.Interval)
+			if err != nil || interval < time.Second || interval%time.Second != 0 {
+				return fmt.Errorf("invalid interval: %q", q.Interval)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported query type: %q", q.Type)
+	}
+	return q.Filters.Compile()
+}
+
+// Group matching logs in a snapshot by a facet field
+func (lp *LogParser) aggregate(snapshot *LogSnapshot, query BatchQuery) []AggregateBucket {
+	buckets := make(map[string]*AggregateBucket)
+	responseTimes := make(map[string]float64)
+
+	lp.eachMatch(snapshot, query.Filters, math.MaxUint64, func(log *LogEntry) bool {
+		key, ok := facetValue(log, query.GroupBy)
+		if !ok {
+			return true
+		}
+		bucket, exists := buckets[key]
+		if !exists {
+			bucket = &AggregateBucket{Key: key}
+			buckets[key] = bucket
+		}
+		bucket.Count++
+		bucket.TotalBytes += int64(log.Size)
+		responseTimes[key] += log.ResponseTime
+		switch log.Status / 100 {
+		case 4:
+			bucket.Requests4xx++
+		case 5:
+			bucket.Requests5xx++
+		}
+		return true
+	})
+
+	result := make([]AggregateBucket, 0, len(buckets))
+	for key, bucket := range buckets {
+		bucket.AvgResponseTime = math.Round(responseTimes[key]/float64(bucket.Count)*100) / 100
+		result = append(result, *bucket)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count == result[j].Count {
+			return result[i].Key < result[j].Key
+		}
+		return result[i].Count > result[j].Count
+	})
+	if len(result) > query.Limit {
+		result = result[:query.Limit]
+	}
+	return result
+}
+
+// Bucket matching logs in a snapshot by time
+func (lp *LogParser) timeseries(snapshot *LogSnapshot, query BatchQuery) []TimeSeriesPoint {
+	interval := DEFAULT_TIMESERIES_WINDOW
+	if query.Interval != "" {
+		interval, _ = time.ParseDuration(query.Interval)
+	}
+
+	buckets := make(map[int64]*TimeSeriesPoint)
+	responseTimes := make(map[int64]float64)
+	var first, last int64
+
+	lp.eachMatch(snapshot, query.Filters, math.MaxUint64, func(log *LogEntry) bool {
+		timestamp, err := time.Parse(time.RFC3339, log.Timestamp)
+		if err != nil {
+			return true
+		}
+		key := timestamp.Truncate(interval).Unix()
+		point, exists := buckets[key]
+		if !exists {
+			point = &TimeSeriesPoint{}
+			buckets[key] = point
+			if len(buckets) == 1 || key < first {
+				first = key
+			}
+			if len(buckets) == 1 || key > last {
+				last = key
+			}
+		}
+		point.Count++
+		responseTimes[key] += log.ResponseTime
+		switch log.Status / 100 {
+		case 4:
+			point.Requests4xx++
+		case 5:
+			point.Requests5xx++
+		}
+		return true
+	})
+
+	if len(buckets) == 0 {
+		return []TimeSeriesPoint{}
+	}
+
+	// Keep only the most recent buckets so sparse data can't explode the response
+	step := int64(interval / time.Second)
+	if (last-first)/step+1 > MAX_TIMESERIES_BUCKETS {
+		first = last - (MAX_TIMESERIES_BUCKETS-1)*step
+	}
+
+	points := make([]TimeSeriesPoint, 0, (last-first)/step+1)
+	for key := first; key <= last; key += step {
+		point := TimeSeriesPoint{}
+		if bucket, ok := buckets[key]; ok {
+			point = *bucket
+			point.AvgResponseTime = math.Round(responseTimes[key]/float64(bucket.Count)*100) / 100
+		}
+		point.Timestamp = time.Unix(key, 0).UTC().Format(time.RFC3339)
+		points = append(points, point)
+	}
+	return points
+}
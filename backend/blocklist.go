@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DEFAULT_ABUSE_WINDOW             = 5 * time.Minute
+	DEFAULT_BLOCKLIST_BAN_DURATION   = 60 * time.Minute
+	DEFAULT_BLOCKLIST_CHECK_INTERVAL = 30 * time.Second
+
+	BLOCKLIST_FORMAT_PLUGIN = "plugin" // Traefik dynamic config using a deny-list plugin
+	BLOCKLIST_FORMAT_TEXT   = "text"   // One address per line
+)
+
+// Abuse detection thresholds, counted per client IP over the window
+type AbuseRules struct {
+	MaxRequests     int           `json:"maxRequests"`     // 0 disables the request flood rule
+	MaxClientErrors int           `json:"maxClientErrors"` // 0 disables the 4xx rule (scanners, brute force)
+	Window          time.Duration `json:"-"`
+}
+
+type BannedIP struct {
+	IP        string    `json:"ip"`
+	Reason    string    `json:"reason"`
+	Requests  int       `json:"requests"`
+	Errors    int       `json:"clientErrors"`
+	BannedAt  time.Time `json:"bannedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Bans clients tripping the abuse rules and keeps a Traefik-consumable file
+// listing them up to date, so detection feeds straight into enforcement
+type Blocklist struct {
+	path           string
+	format         string
+	middlewareName string
+	pluginName     string
+	rules          AbuseRules
+	banDuration    time.Duration
+	checkInterval  time.Duration
+	exempt         []*net.IPNet
+
+	mu      sync.Mutex
+	banned  map[string]*BannedIP
+	writeMu sync.Mutex
+	written string
+	stop    chan struct{}
+}
+
+var blocklist *Blocklist
+
+// Create the blocklist from BLOCKLIST_FILE; nil when unset
+func NewBlocklist() (*Blocklist, error) {
+	path := os.Getenv("BLOCKLIST_FILE")
+	if path == "" {
+		return nil, nil
+	}
+
+	format := strings.ToLower(GetEnvString("BLOCKLIST_FORMAT", BLOCKLIST_FORMAT_PLUGIN))
+	if format != BLOCKLIST_FORMAT_PLUGIN && format != BLOCKLIST_FORMAT_TEXT {
+		return nil, fmt.Errorf("BLOCKLIST_FORMAT must be %q or %q", BLOCKLIST_FORMAT_PLUGIN, BLOCKLIST_FORMAT_TEXT)
+	}
+
+	rules := AbuseRules{
+		MaxRequests:     GetEnvInt("ABUSE_MAX_REQUESTS", 0),
+		MaxClientErrors: GetEnvInt("ABUSE_MAX_CLIENT_ERRORS", 0),
+		Window:          time.Duration(GetEnvInt("ABUSE_WINDOW_MINUTES", int(DEFAULT_ABUSE_WINDOW/time.Minute))) * time.Minute,
+	}
+	if rules.MaxRequests <= 0 && rules.MaxClientErrors <= 0 {
+		return nil, fmt.Errorf("BLOCKLIST_FILE needs ABUSE_MAX_REQUESTS or ABUSE_MAX_CLIENT_ERRORS")
+	}
+	if rules.Window < time.Minute {
+		return nil, fmt.Errorf("ABUSE_WINDOW_MINUTES must be at least 1")
+	}
+
+	banDuration := time.Duration(GetEnvInt("BLOCKLIST_BAN_MINUTES", int(DEFAULT_BLOCKLIST_BAN_DURATION/time.Minute))) * time.Minute
+	if banDuration < time.Minute {
+		return nil, fmt.Errorf("BLOCKLIST_BAN_MINUTES must be at least 1")
+	}
+
+	exempt, err := parseCIDRList("BLOCKLIST_EXEMPT_CIDRS", os.Getenv("BLOCKLIST_EXEMPT_CIDRS"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Blocklist{
+		path:           path,
+		format:         format,
+		middlewareName: GetEnvString("BLOCKLIST_MIDDLEWARE_NAME", "dashboard-blocklist"),
+		pluginName:     GetEnvString("BLOCKLIST_PLUGIN_NAME", "denyip"),
+		rules:          rules,
+		banDuration:    banDuration,
+		checkInterval:  time.Duration(GetEnvInt("BLOCKLIST_CHECK_INTERVAL_SECONDS", int(DEFAULT_BLOCKLIST_CHECK_INTERVAL/time.Second))) * time.Second,
+		exempt:         exempt,
+		banned:         make(map[string]*BannedIP),
+		stop:           make(chan struct{}),
+	}, nil
+}
+
+func (b *Blocklist) Start() {
+	appLog.Info("Writing abuse blocklist", "path", b.path, "format", b.format, "banDuration", b.banDuration)
+	// Write an empty list right away so Traefik can reference the middleware
+	b.check(time.Now())
+	go func() {
+		ticker := time.NewTicker(b.checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				b.check(now)
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (b *Blocklist) Stop() {
+	close(b.stop)
+}
+
+func (b *Blocklist) isExempt(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return true
+	}
+	if parsed.IsLoopback() {
+		return true
+	}
+	for _, ipNet := range b.exempt {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ban new offenders, expire old bans and rewrite the file when the list changed
+func (b *Blocklist) check(now time.Time) {
+	counts := logParser.clientWindows(now, b.rules.Window)
+
+	var added []*BannedIP
+	b.mu.Lock()
+	for ip, ban := range b.banned {
+		if now.After(ban.ExpiresAt) {
+			delete(b.banned, ip)
+		}
+	}
+	for ip, c := range counts {
+		if _, ok := b.banned[ip]; ok || b.isExempt(ip) {
+			continue
+		}
+		reason := ""
+		switch {
+		case b.rules.MaxRequests > 0 && c.requests >= b.rules.MaxRequests:
+			reason = fmt.Sprintf("%d requests in %s", c.requests, b.rules.Window)
+		case b.rules.MaxClientErrors > 0 && c.clientErrors >= b.rules.MaxClientErrors:
+			reason = fmt.Sprintf("%d 4xx responses in %s", c.clientErrors, b.rules.Window)
+		default:
+			continue
+		}
+		ban := &BannedIP{
+			IP:        ip,
+			Reason:    reason,
+			Requests:  c.requests,
+			Errors:    c.clientErrors,
+			BannedAt:  now,
+			ExpiresAt: now.Add(b.banDuration),
+		}
+		b.banned[ip] = ban
+		added = append(added, ban)
+	}
+	b.mu.Unlock()
+
+	for _, ban := range added {
+		appLog.Warn("Banned client", "ip", ban.IP, "reason", ban.Reason)
+		raiseAlert(Notification{
+			Kind:     NOTIFY_KIND_ALERT,
+			Event:    "ipBanned",
+			Severity: SEVERITY_WARNING,
+			Title:    fmt.Sprintf("Banned %s", ban.IP),
+			Message:  fmt.Sprintf("%s was added to the blocklist until %s (%s)", ban.IP, ban.ExpiresAt.Format(time.RFC3339), ban.Reason),
+			Details: map[string]interface{}{
+				"ip":           ban.IP,
+				"reason":       ban.Reason,
+				"requests":     ban.Requests,
+				"clientErrors": ban.Errors,
+				"expiresAt":    ban.ExpiresAt.Format(time.RFC3339),
+			},
+		})
+	}
+
+	if err := b.write(); err != nil {
+		appLog.Error("Failed to write blocklist", "path", b.path, "error", err)
+	}
+}
+
+// Unban an address early; returns false when it wasn't banned
+func (b *Blocklist) Unban(ip string) bool {
+	b.mu.Lock()
+	_, ok := b.banned[ip]
+	delete(b.banned, ip)
+	b.mu.Unlock()
+
+	if ok {
+		appLog.Info("Unbanned client", "ip", ip)
+		if err := b.write(); err != nil {
+			appLog.Error("Failed to write blocklist", "path", b.path, "error", err)
+		}
+	}
+	return ok
+}
+
+func (b *Blocklist) Banned() []BannedIP {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result := make([]BannedIP, 0, len(b.banned))
+	for _, ban := range b.banned {
+		result = append(result, *ban)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].IP < result[j].IP })
+	return result
+}
+
+// Render the current list in the configured format
+func (b *Blocklist) render() string {
+	bans := b.Banned()
+
+	var sb strings.Builder
+	if b.format == BLOCKLIST_FORMAT_TEXT {
+		for _, ban := range bans {
+			sb.WriteString(ban.IP)
+			sb.WriteString("\n")
+		}
+		return sb.String()
+	}
+
+	sb.WriteString("# Generated by traefik-log-dashboard; changes will be overwritten\n")
+	sb.WriteString("http:\n  middlewares:\n")
+	fmt.Fprintf(&sb, "    %s:\n      plugin:\n        %s:\n", b.middlewareName, b.pluginName)
+	if len(bans) == 0 {
+		sb.WriteString("          ipDenyList: []\n")
+		return sb.String()
+	}
+	sb.WriteString("          ipDenyList:\n")
+	for _, ban := range bans {
+		fmt.Fprintf(&sb, "            - %q\n", ban.IP)
+	}
+	return sb.String()
+}
+
+// Replace the file atomically so Traefik's file provider never reads a
+// partial list; unchanged content isn't rewritten
+func (b *Blocklist) write() error {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+
+	content := b.render()
+	if content == b.written {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(b.path), ".blocklist-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), b.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	b.written = content
+	return nil
+}
+
+// Requests and 4xx responses from one client over the abuse window
+type clientWindow struct {
+	requests     int
+	clientErrors int
+}
+
+// Count requests per client IP over the window ending now
+func (lp *LogParser) clientWindows(now time.Time, window time.Duration) map[string]*clientWindow {
+	start := now.Add(-window)
+
+	snapshot := lp.logsSnapshot.Load()
+	byIP := make(map[string]*clientWindow)
+	for i := 0; i < snapshot.Len(); i++ {
+		entry := snapshot.At(i)
+		if entry.ClientIP == "" {
+			continue
+		}
+		timestamp, err := time.Parse(time.RFC3339, entry.Timestamp)
+		if err != nil || timestamp.Before(start) || timestamp.After(now) {
+			continue
+		}
+		w, ok := byIP[entry.ClientIP]
+		if !ok {
+			w = &clientWindow{}
+			byIP[entry.ClientIP] = w
+		}
+		w.requests++
+		if entry.Status >= 400 && entry.Status < 500 {
+			w.clientErrors++
+		}
+	}
+	return byIP
+}
+
+func getBlocklist(c *gin.Context) {
+	if blocklist == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false, "banned": []BannedIP{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":       true,
+		"file":          blocklist.path,
+		"format":        blocklist.format,
+		"rules":         blocklist.rules,
+		"windowMinutes": int(blocklist.rules.Window / time.Minute),
+		"banDuration":   blocklist.banDuration.String(),
+		"banned":        blocklist.Banned(),
+	})
+}
+
+func unbanIP(c *gin.Context) {
+	if blocklist == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Blocklist is not enabled"})
+		return
+	}
+	ip := c.Param("ip")
+	if !blocklist.Unban(ip) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Address is not banned"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"unbanned": ip})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	MIN_MAX_LOGS = 100
+	MAX_MAX_LOGS = 1000000
+
+	DEFAULT_LISTENER_BUFFER = 100
+	DEFAULT_WS_SEND_BUFFER  = 256
+	MIN_WS_SEND_BUFFER      = 16 // Room for control frames next to dropped log frames
+	MAX_CHANNEL_BUFFER      = 100000
+)
+
+// Channel sizes for each WebSocket client. Larger buffers ride out bursts
+// without dropping entries at the cost of memory per client; changes apply to
+// clients that connect afterwards.
+type BufferSizes struct {
+	Listener int `json:"listener"` // Entries (and raw lines) queued from the parser
+	WSSend   int `json:"wsSend"`   // Frames queued for the connection
+}
+
+var (
+	bufferSizesMu sync.RWMutex
+	bufferSizes   = BufferSizes{Listener: DEFAULT_LISTENER_BUFFER, WSSend: DEFAULT_WS_SEND_BUFFER}
+)
+
+// Read an integer setting, failing on values that don't parse or are out of range
+func envIntInRange(key string, defaultValue, minValue, maxValue int) (int, error) {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < minValue || n > maxValue {
+		return 0, fmt.Errorf("%s must be between %d and %d, got %q", key, minValue, maxValue, value)
+	}
+	return n, nil
+}
+
+// Load MAX_LOGS_IN_MEMORY
+func GetMaxLogsFromEnv() (int, error) {
+	return envIntInRange("MAX_LOGS_IN_MEMORY", DEFAULT_MAX_LOGS, MIN_MAX_LOGS, MAX_MAX_LOGS)
+}
+
+// Load LOG_LISTENER_BUFFER and WS_SEND_BUFFER
+func GetBufferSizesFromEnv() (BufferSizes, error) {
+	listener, err := envIntInRange("LOG_LISTENER_BUFFER", DEFAULT_LISTENER_BUFFER, 1, MAX_CHANNEL_BUFFER)
+	if err != nil {
+		return BufferSizes{}, err
+	}
+	send, err := envIntInRange("WS_SEND_BUFFER", DEFAULT_WS_SEND_BUFFER, MIN_WS_SEND_BUFFER, MAX_CHANNEL_BUFFER)
+	if err != nil {
+		return BufferSizes{}, err
+	}
+	return BufferSizes{Listener: listener, WSSend: send}, nil
+}
+
+func validateBufferSizes(sizes BufferSizes) error {
+	if sizes.Listener < 1 || sizes.Listener > MAX_CHANNEL_BUFFER {
+		return fmt.Errorf("buffers.listener must be between 1 and %d", MAX_CHANNEL_BUFFER)
+	}
+	if sizes.WSSend < MIN_WS_SEND_BUFFER || sizes.WSSend > MAX_CHANNEL_BUFFER {
+		return fmt.Errorf("buffers.wsSend must be between %d and %d", MIN_WS_SEND_BUFFER, MAX_CHANNEL_BUFFER)
+	}
+	return nil
+}
+
+func GetBufferSizes() BufferSizes {
+	bufferSizesMu.RLock()
+	defer bufferSizesMu.RUnlock()
+	return bufferSizes
+}
+
+func SetBufferSizes(sizes BufferSizes) {
+	bufferSizesMu.Lock()
+	defer bufferSizesMu.Unlock()
+	bufferSizes = sizes
+}
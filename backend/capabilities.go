@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// REST API version, bumped on incompatible changes to existing endpoints
+const API_VERSION = 1
+
+// What this backend offers, so the frontend can show only the features that
+// work instead of probing endpoints
+type Capabilities struct {
+	APIVersion  int                   `json:"apiVersion"`
+	WebSocket   WSCapabilities        `json:"websocket"`
+	Persistence PersistenceCapability `json:"persistence"`
+	History     HistoryCapability     `json:"history"`
+	Sources     SourceCapabilities    `json:"sources"`
+	Auth        AuthCapabilities      `json:"auth"`
+	Alerting    AlertingCapabilities  `json:"alerting"`
+	Geo         GeoCapabilities       `json:"geo"`
+	Exporters   ExporterCapabilities  `json:"exporters"`
+	Endpoints   EndpointCapabilities  `json:"endpoints"`
+}
+
+type WSCapabilities struct {
+	ProtocolVersion    int      `json:"protocolVersion"`
+	MinProtocolVersion int      `json:"minProtocolVersion"`
+	Capabilities       []string `json:"capabilities"`
+	Encodings          []string `json:"encodings"`
+}
+
+// Logs are only kept in memory; there is no store to query older ranges from
+type PersistenceCapability struct {
+	Enabled bool `json:"enabled"`
+}
+
+// The range queries can cover: what is still in the in-memory buffer
+type HistoryCapability struct {
+	MaxEntries  int    `json:"maxEntries"`
+	Entries     int    `json:"entries"`
+	OldestEntry string `json:"oldestEntry,omitempty"`
+}
+
+type SourceCapabilities struct {
+	LogFiles bool `json:"logFiles"`
+	OTLP     bool `json:"otlp"`
+}
+
+type AuthCapabilities struct {
+	Required       bool `json:"required"` // Some user authentication is configured
+	BasicAuth      bool `json:"basicAuth"`
+	OIDC           bool `json:"oidc"`
+	APIToken       bool `json:"apiToken"` // /ws needs a token from /api/ws/token
+	Tenancy        bool `json:"tenancy"`
+	AdminAllowlist bool `json:"adminAllowlist"`
+}
+
+type AlertingCapabilities struct {
+	ErrorRateAlerts bool     `json:"errorRateAlerts"` // System checks always run
+	Notifiers       []string `json:"notifiers"`
+}
+
+type GeoCapabilities struct {
+	MaxMind bool `json:"maxmind"` // Database loaded
+	Online  bool `json:"online"`  // Online lookups as fallback or sole provider
+}
+
+type ExporterCapabilities struct {
+	Prometheus  bool `json:"prometheus"`
+	Loki        bool `json:"loki"`
+	InfluxDB    bool `json:"influxdb"`
+	StatsD      bool `json:"statsd"`
+	OTLPMetrics bool `json:"otlpMetrics"`
+	MQTT        bool `json:"mqtt"`
+}
+
+type EndpointCapabilities struct {
+	Blocklist  bool `json:"blocklist"`
+	Debug      bool `json:"debug"`
+	TraefikAPI bool `json:"traefikAPI"` // /api/traefik/* router and service definitions
+	Docker     bool `json:"docker"`     // /api/docker/* containers behind services
+}
+
+func GetCapabilities() Capabilities {
+	capabilities := Capabilities{
+		APIVersion: API_VERSION,
+		WebSocket: WSCapabilities{
+			ProtocolVersion:    WS_PROTOCOL_VERSION,
+			MinProtocolVersion: WS_MIN_PROTOCOL_VERSION,
+			Capabilities:       sortedKeys(wsServerCapabilities),
+			Encodings:          wsSubprotocols,
+		},
+		History: HistoryCapability{MaxEntries: logParser.EffectiveMaxLogs()},
+		Sources: SourceCapabilities{
+			LogFiles: logFilesEnabled,
+			OTLP:     otlpReceiver != nil && otlpReceiver.GetConfig().Enabled,
+		},
+		Auth: AuthCapabilities{
+			Required:       authEnabled(),
+			BasicAuth:      basicAuth.Enabled(),
+			OIDC:           oidcAuth.Enabled(),
+			APIToken:       wsAuth.Enabled(),
+			Tenancy:        tenancy.Enabled(),
+			AdminAllowlist: len(adminAllowedNets) > 0,
+		},
+		Alerting: AlertingCapabilities{
+			ErrorRateAlerts: alertMonitor != nil && alertMonitor.config.ErrorRatePercent > 0,
+			Notifiers:       make([]string, 0, len(notifierWorkers)),
+		},
+		Exporters: ExporterCapabilities{
+			Prometheus:  GetEnvBool("METRICS_ENABLED", true),
+			Loki:        lokiForwarder != nil,
+			InfluxDB:    influxPusher != nil,
+			StatsD:      statsdEmitter != nil,
+			OTLPMetrics: otlpMetricsExporter != nil,
+			MQTT:        mqttPublisher != nil,
+		},
+		Endpoints: EndpointCapabilities{
+			Blocklist:  blocklist != nil,
+			Debug:      GetEnvBool("DEBUG_ENDPOINTS_ENABLED", false),
+			TraefikAPI: traefikAPI != nil,
+			Docker:     dockerEnricher != nil,
+		},
+	}
+
+	snapshot := logParser.logsSnapshot.Load()
+	capabilities.History.Entries = snapshot.Len()
+	if snapshot.Len() > 0 {
+		capabilities.History.OldestEntry = snapshot.At(0).Timestamp
+	}
+
+	for _, worker := range notifierWorkers {
+		capabilities.Alerting.Notifiers = append(capabilities.Alerting.Notifiers, worker.notifier.Name())
+	}
+
+	geo := GetMaxMindConfig()
+	capabilities.Geo.MaxMind = geo.Enabled && geo.DatabaseLoaded
+	capabilities.Geo.Online = !geo.Enabled || geo.FallbackToOnline
+	return capabilities
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key, ok := range set {
+		if ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func getCapabilities(c *gin.Context) {
+	c.JSON(http.StatusOK, GetCapabilities())
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Subcommands of the backend binary; without one it serves the dashboard
+var commands = []struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}{
+	{"serve", "Run the dashboard backend (the default)", runServe},
+	{"import", "Send log files or archives (.gz) to a running dashboard", runImport},
+	{"export", "Download matching logs from a running dashboard as NDJSON or CSV", runExport},
+	{"validate-config", "Check the configuration without starting anything", runValidateConfig},
+	{"test-parse", "Show how log lines are parsed, and why rejected lines are", runTestParse},
+	{"healthcheck", "Exit 0 if a running dashboard is healthy (for container health checks)", runHealthcheck},
+	{"systemd-unit", "Print a systemd unit for running this binary as a service", runSystemdUnit},
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		serve()
+		return
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		printUsage(os.Stdout)
+		return
+	}
+	for _, command := range commands {
+		if command.name == args[0] {
+			os.Exit(command.run(args[1:]))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+	printUsage(os.Stderr)
+	os.Exit(2)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [command] [flags]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, command := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", command.name, command.summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s <command> -h' for a command's flags.\n", filepath.Base(os.Args[0]))
+}
+
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] %s\n", filepath.Base(os.Args[0]), name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Load the config file and secrets like serve does, so commands see the
+// same settings; reports the error and returns false if they don't load
+func loadCLIConfig() bool {
+	if err := loadConfigFile(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return false
+	}
+	if err := loadSecretFiles(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load secrets: %v\n", err)
+		return false
+	}
+	// Invalid logging settings are reported by validate-config
+	if config, err := GetLoggingConfigFromEnv(); err == nil {
+		SetLoggingConfig(config)
+	}
+	return true
+}
+
+func runServe(args []string) int {
+	fs := newFlagSet("serve", "")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	serve()
+	return 0
+}
+
+// Connection to a running dashboard
+type apiClient struct {
+	server   string
+	token    string
+	insecure bool
+	timeout  time.Duration
+}
+
+func addClientFlags(fs *flag.FlagSet, timeout time.Duration) *apiClient {
+	client := &apiClient{}
+	fs.StringVar(&client.server, "server", "", "dashboard URL (default http(s)://localhost:$PORT)")
+	fs.StringVar(&client.token, "token", "", "API token (default $API_AUTH_TOKEN)")
+	fs.BoolVar(&client.insecure, "insecure", false, "skip TLS certificate verification")
+	fs.DurationVar(&client.timeout, "timeout", timeout, "request timeout (0 = none)")
+	return client
+}
+
+func (c *apiClient) baseURL() string {
+	if c.server != "" {
+		return strings.TrimSuffix(c.server, "/")
+	}
+	scheme := "http"
+	if GetTLSSettings("").Enabled() {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://localhost:%s", scheme, GetEnvString("PORT", "3001"))
+}
+
+func (c *apiClient) do(method, path string, query url.Values, body io.Reader) (*http.Response, error) {
+	target := c.baseURL() + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	token := c.token
+	if token == "" {
+		token = os.Getenv("API_AUTH_TOKEN")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: c.timeout}
+	if c.insecure {
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure) == nil && failure.Error != "" {
+			return nil, fmt.Errorf("%s %s: %s (%s)", method, path, failure.Error, resp.Status)
+		}
+		return nil, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	return resp, nil
+}
+
+func runImport(args []string) int {
+	fs := newFlagSet("import", "<file|-> ...")
+	client := addClientFlags(fs, 0)
+	source := fs.String("source", "", "source the entries are attributed to (default: each file's path, \""+DEFAULT_IMPORT_SOURCE+"\" for stdin)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if !loadCLIConfig() {
+		return 1
+	}
+
+	status := 0
+	for _, path := range fs.Args() {
+		name := *source
+		var body io.ReadCloser = os.Stdin
+		if path != "-" {
+			file, err := openLogFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				status = 1
+				continue
+			}
+			body = file
+			if name == "" {
+				name = path
+			}
+		}
+		query := url.Values{}
+		if name != "" {
+			query.Set("source", name)
+		}
+
+		resp, err := client.do(http.MethodPost, "/api/admin/import", query, body)
+		body.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			status = 1
+			continue
+		}
+		var result BackfillResult
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: invalid response: %v\n", path, err)
+			status = 1
+			continue
+		}
+		fmt.Printf("%s: imported %d of %d lines into %s\n", path, result.Accepted, result.Lines, result.Source)
+	}
+	return status
+}
+
+func runExport(args []string) int {
+	fs := newFlagSet("export", "[filter=value ...]")
+	client := addClientFlags(fs, 0)
+	format := fs.String("format", "ndjson", "ndjson or csv")
+	fields := fs.String("fields", "", "comma separated fields to include")
+	output := fs.String("o", "-", "file to write to (- for stdout)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s export [flags] [filter=value ...]\n", filepath.Base(os.Args[0]))
+		fmt.Fprintln(fs.Output(), "Filters are the /api/logs query parameters, e.g. service=api statusClass=5xx")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if !loadCLIConfig() {
+		return 1
+	}
+
+	query := url.Values{}
+	query.Set("format", *format)
+	if *fields != "" {
+		query.Set("fields", *fields)
+	}
+	for _, filter := range fs.Args() {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok || key == "" {
+			fmt.Fprintf(os.Stderr, "Invalid filter %q (use name=value)\n", filter)
+			return 2
+		}
+		query.Add(key, value)
+	}
+
+	resp, err := client.do(http.MethodGet, "/api/export", query, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runHealthcheck(args []string) int {
+	fs := newFlagSet("healthcheck", "")
+	client := addClientFlags(fs, 5*time.Second)
+	ready := fs.Bool("ready", false, "check readiness (log sources attached, OTLP running) instead of liveness")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if !loadCLIConfig() {
+		return 1
+	}
+
+	path := "/health/live"
+	if *ready {
+		path = "/health/ready"
+	}
+	resp, err := client.do(http.MethodGet, path, nil, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unhealthy: %v\n", err)
+		return 1
+	}
+	resp.Body.Close()
+	fmt.Println("OK")
+	return 0
+}
+
+func runSystemdUnit(args []string) int {
+	fs := newFlagSet("systemd-unit", "")
+	binary := fs.String("binary", "", "path the service runs (default: this binary)")
+	configFile := fs.String("config", "/etc/traefik-log-dashboard/dashboard.env", "config file the service reads")
+	user := fs.String("user", "traefik-log-dashboard", "user the service runs as; needs read access to the logs")
+	workingDir := fs.String("working-dir", "", "working directory, e.g. for a relative MAXMIND_DB_PATH")
+	watchdog := fs.Duration("watchdog", DEFAULT_SYSTEMD_WATCHDOG, "restart the service if it stops responding for this long (0 disables)")
+	writable := fs.String("writable", "", "comma separated paths the service may write to (BLOCKLIST_FILE, ...)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	unit := SystemdUnit{
+		Binary:          *binary,
+		ConfigFile:      *configFile,
+		User:            *user,
+		WorkingDir:      *workingDir,
+		WatchdogSeconds: int(watchdog.Seconds()),
+		StopSeconds:     int(GetDrainConfig().Timeout.Seconds()) + 10,
+	}
+	if unit.Binary == "" {
+		executable, err := os.Executable()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot find this binary's path, pass -binary: %v\n", err)
+			return 1
+		}
+		if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+			executable = resolved
+		}
+		unit.Binary = executable
+	}
+	for _, path := range strings.Split(*writable, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			unit.ReadWritePaths = append(unit.ReadWritePaths, path)
+		}
+	}
+
+	if err := unit.Write(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runValidateConfig(args []string) int {
+	fs := newFlagSet("validate-config", "")
+	file := fs.String("config", "", "config file to check (default $CONFIG_FILE or .env)")
+	skipPorts := fs.Bool("skip-ports", false, "don't check that the listen ports are free (e.g. next to a running server)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *file != "" {
+		os.Setenv("CONFIG_FILE", *file)
+	}
+	if !loadCLIConfig() {
+		return 1
+	}
+
+	validation := ValidateConfig(!*skipPorts)
+	for _, issue := range validation.Errors {
+		fmt.Printf("FAIL  %-16s %s\n", issue.Check, issue.Message)
+	}
+	for _, issue := range validation.Warnings {
+		fmt.Printf("WARN  %-16s %s\n", issue.Check, issue.Message)
+	}
+	if !validation.Valid {
+		fmt.Printf("Configuration has %d error(s)\n", len(validation.Errors))
+		return 1
+	}
+	fmt.Println("Configuration is valid")
+	return 0
+}
+
+func runTestParse(args []string) int {
+	fs := newFlagSet("test-parse", "[file|- ...]")
+	asJSON := fs.Bool("json", false, "print each parsed entry as JSON")
+	quiet := fs.Bool("q", false, "only print rejected lines and the summary")
+	source := fs.String("source", "", "source name used for tenant mapping (default: the file's path)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if !loadCLIConfig() {
+		return 1
+	}
+	var err error
+	if tenancy, err = LoadTenancy(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid tenancy configuration: %v\n", err)
+		return 1
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	lp := NewLogParser()
+	encoder := json.NewEncoder(os.Stdout)
+	parsed, rejected := 0, 0
+	reasons := make(map[string]int)
+
+	for _, path := range paths {
+		name := *source
+		var r io.ReadCloser = os.Stdin
+		if path != "-" {
+			file, err := openLogFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				return 1
+			}
+			r = file
+			if name == "" {
+				name = path
+			}
+		}
+
+		err := eachLine(r, func(number int, line string) {
+			if strings.TrimSpace(line) == "" {
+				return
+			}
+			decoded := lp.decodeLine(name, line)
+			if decoded.entry == nil {
+				rejected++
+				reasons[decoded.reason]++
+				fmt.Printf("%s:%d: rejected (%s): %s\n", path, number, decoded.reason, truncate(line, 200))
+				return
+			}
+			parsed++
+			switch {
+			case *asJSON:
+				encoder.Encode(decoded.entry)
+			case !*quiet:
+				entry := decoded.entry
+				fmt.Printf("%s:%d: %s %s %s%s %d %.1fms service=%s router=%s\n", path, number, entry.Timestamp,
+					entry.Method, entry.RequestHost, entry.Path, entry.Status, entry.ResponseTime, entry.ServiceName, entry.RouterName)
+			}
+			putLogEntry(decoded.entry)
+		})
+		r.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "%d lines parsed, %d rejected\n", parsed, rejected)
+	names := make([]string, 0, len(reasons))
+	for reason := range reasons {
+		names = append(names, reason)
+	}
+	sort.Strings(names)
+	for _, reason := range names {
+		fmt.Fprintf(os.Stderr, "  %6d  %s\n", reasons[reason], reason)
+	}
+	if rejected > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Call fn with each line of r and its 1-based number
+func eachLine(r io.Reader, fn func(number int, line string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		fn(number, scanner.Text())
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Header names CLIENT_IP_HEADERS may list, in the default order: headers set
+// by a CDN edge first, X-Forwarded-For (which clients can prepend to) last
+var clientIPHeaderNames = []string{"CF-Connecting-IP", "True-Client-IP", "X-Real-IP", "X-Forwarded-For"}
+
+// Cloudflare's published edge ranges (https://www.cloudflare.com/ips/), what
+// the "cloudflare" keyword of TRUSTED_PROXIES stands for
+var cloudflareRanges = []string{
+	"173.245.48.0/20", "103.21.244.0/22", "103.22.200.0/22", "103.31.4.0/22",
+	"141.101.64.0/18", "108.162.192.0/18", "190.93.240.0/20", "188.114.96.0/20",
+	"197.234.240.0/22", "198.41.128.0/17", "162.158.0.0/15", "104.16.0.0/13",
+	"104.24.0.0/14", "172.64.0.0/13", "131.0.72.0/22",
+	"2400:cb00::/32", "2606:4700::/32", "2803:f800::/32", "2405:b500::/32",
+	"2405:8100::/32", "2a06:98c0::/29", "2c0f:f248::/32",
+}
+
+// Loopback, RFC 1918 and IPv6 unique local ranges, the "private" keyword
+var privateRanges = []string{
+	"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "::1/128", "fc00::/7",
+}
+
+// Replaces the address Traefik saw with the visitor's when the request came
+// through a trusted proxy (a CDN edge, a load balancer) that said who it was
+// forwarding for. Only the forwarded headers Traefik was told to keep in the
+// access log are available. Entries from untrusted peers keep ClientAddr,
+// since anyone can send these headers.
+type ClientIPResolver struct {
+	proxies []netip.Prefix
+	headers []string // Canonical names, first match wins
+}
+
+// The resolver in use; nil when TRUSTED_PROXIES is unset
+var clientIPResolver atomic.Pointer[ClientIPResolver]
+
+// Create the resolver from TRUSTED_PROXIES (addresses, CIDRs and the
+// keywords "cloudflare" and "private") and CLIENT_IP_HEADERS; nil when no
+// proxy is trusted
+func NewClientIPResolver() (*ClientIPResolver, error) {
+	r := &ClientIPResolver{}
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		ranges := []string{entry}
+		switch strings.ToLower(entry) {
+		case "":
+			continue
+		case "cloudflare":
+			ranges = cloudflareRanges
+		case "private":
+			ranges = privateRanges
+		}
+		for _, value := range ranges {
+			prefix, err := parseDropPrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+			}
+			r.proxies = append(r.proxies, prefix)
+		}
+	}
+
+	for _, name := range strings.Split(GetEnvString("CLIENT_IP_HEADERS", strings.Join(clientIPHeaderNames, ",")), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		canonical := ""
+		for _, supported := range clientIPHeaderNames {
+			if strings.EqualFold(name, supported) {
+				canonical = supported
+			}
+		}
+		if canonical == "" {
+			return nil, fmt.Errorf("unsupported header %q in CLIENT_IP_HEADERS, use %s", name, strings.Join(clientIPHeaderNames, ", "))
+		}
+		r.headers = append(r.headers, canonical)
+	}
+
+	if len(r.proxies) == 0 {
+		return nil, nil
+	}
+	if len(r.headers) == 0 {
+		return nil, fmt.Errorf("CLIENT_IP_HEADERS must name at least one header when TRUSTED_PROXIES is set")
+	}
+	return r, nil
+}
+
+func SetClientIPResolver(r *ClientIPResolver) {
+	clientIPResolver.Store(r)
+	if r != nil {
+		appLog.Info("Resolving client IPs behind trusted proxies", "ranges", len(r.proxies), "headers", strings.Join(r.headers, ","))
+	}
+}
+
+// The visitor's address for an entry whose peer is peer; ok is false when
+// the peer isn't trusted or no header holds an address
+func (r *ClientIPResolver) Resolve(peer netip.Addr, raw *RawLogEntry) (string, netip.Addr, bool) {
+	if !prefixesContain(r.proxies, peer) {
+		return "", netip.Addr{}, false
+	}
+	for _, name := range r.headers {
+		if name == "X-Forwarded-For" {
+			if ip, addr, ok := r.forwardedFor(raw.XForwardedFor.String("")); ok {
+				return ip, addr, true
+			}
+			continue
+		}
+		if ip, addr := extractClientIP(strings.TrimSpace(raw.forwardedHeader(name))); addr.IsValid() {
+			return ip, addr, true
+		}
+	}
+	return "", netip.Addr{}, false
+}
+
+// Walk X-Forwarded-For from the right, where the closest hop appended its
+// peer, to the first address that isn't a trusted proxy; anything left of it
+// came from the client and may be forged. If every hop is trusted the
+// leftmost address is the best there is.
+func (r *ClientIPResolver) forwardedFor(value string) (string, netip.Addr, bool) {
+	var leftmost string
+	var leftmostAddr netip.Addr
+	for value != "" {
+		hop := value
+		if i := strings.LastIndexByte(value, ','); i >= 0 {
+			hop, value = value[i+1:], value[:i]
+		} else {
+			value = ""
+		}
+		ip, addr := extractClientIP(strings.TrimSpace(hop))
+		if !addr.IsValid() {
+			// An unparseable hop ends the chain that can be trusted
+			break
+		}
+		if !prefixesContain(r.proxies, addr) {
+			return ip, addr, true
+		}
+		leftmost, leftmostAddr = ip, addr
+	}
+	return leftmost, leftmostAddr, leftmostAddr.IsValid()
+}
+
+// A single-value forwarded header kept in the access log
+func (raw *RawLogEntry) forwardedHeader(name string) string {
+	switch name {
+	case "CF-Connecting-IP":
+		return raw.CFConnectingIP.String("")
+	case "True-Client-IP":
+		return raw.TrueClientIP.String("")
+	case "X-Real-IP":
+		return raw.XRealIP.String("")
+	}
+	return ""
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const (
+	DEFAULT_CONFIG_FILE = ".env"
+	DEFAULT_LOG_FILE    = "/logs/traefik.log"
+)
+
+var (
+	configReloadMu sync.Mutex
+	configFileKeys map[string]bool // Variables whose value came from the config file
+)
+
+// What a reload of the config file changed
+type ConfigReloadResult struct {
+	Changed         []string `json:"changed"`                   // Variables whose value changed
+	Applied         []string `json:"applied"`                   // Settings applied without a restart
+	RestartRequired []string `json:"restartRequired,omitempty"` // Changed variables only read at startup
+}
+
+// Read the config file named by CONFIG_FILE (.env by default, which may be
+// missing); variables already set in the environment win over the file
+func loadConfigFile() error {
+	values, err := readConfigFile()
+	if err != nil {
+		return err
+	}
+
+	configReloadMu.Lock()
+	defer configReloadMu.Unlock()
+	configFileKeys = make(map[string]bool, len(values))
+	for key, value := range values {
+		if _, set := os.LookupEnv(key); set {
+			continue
+		}
+		os.Setenv(key, value)
+		configFileKeys[key] = true
+	}
+	return nil
+}
+
+func readConfigFile() (map[string]string, error) {
+	path := GetEnvString("CONFIG_FILE", DEFAULT_CONFIG_FILE)
+	values, err := godotenv.Read(path)
+	if errors.Is(err, fs.ErrNotExist) && os.Getenv("CONFIG_FILE") == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// Settings re-applied when one of their variables changes; apply validates
+// first and returns the step that commits the change
+type reloadableSetting struct {
+	name  string
+	vars  []string
+	apply func() (func(), error)
+}
+
+var reloadableSettings = []reloadableSetting{
+	{"logging", []string{"LOG_LEVEL", "LOG_LEVELS", "LOG_FORMAT", "OTLP_DEBUG"}, func() (func(), error) {
+		config, err := GetLoggingConfigFromEnv()
+		return func() { SetLoggingConfig(config) }, err
+	}},
+	{"maxLogs", []string{"MAX_LOGS_IN_MEMORY"}, func() (func(), error) {
+		maxLogs, err := GetMaxLogsFromEnv()
+		return func() { logParser.SetMaxLogs(maxLogs) }, err
+	}},
+	{"ingest", []string{"INGEST_MAX_PER_SECOND", "INGEST_SAMPLE_RATE", "INGEST_DROP_RULES"}, func() (func(), error) {
+		config, err := GetIngestConfigFromEnv()
+		return func() { ingestControl.SetConfig(config) }, err
+	}},
+	{"otlpSampling", []string{"OTLP_SAMPLE_PERCENT", "OTLP_DROP_RULES"}, func() (func(), error) {
+		config, err := GetOTLPSamplingConfigFromEnv()
+		return func() { otlpSampler.SetConfig(config) }, err
+	}},
+	{"clientIP", []string{"TRUSTED_PROXIES", "CLIENT_IP_HEADERS"}, func() (func(), error) {
+		resolver, err := NewClientIPResolver()
+		return func() { SetClientIPResolver(resolver) }, err
+	}},
+	{"headers", []string{"LOG_HEADERS"}, func() (func(), error) {
+		capture, err := NewHeaderCapture()
+		return func() { SetHeaderCapture(capture) }, err
+	}},
+	{"pathTemplates", []string{"PATH_TEMPLATE_RULES", "PATH_AUTO_TEMPLATE"}, func() (func(), error) {
+		config, err := GetPathTemplateConfigFromEnv()
+		return func() { SetPathTemplateConfig(config) }, err
+	}},
+	{"aliases", []string{"SERVICE_ALIASES", "ROUTER_ALIASES"}, func() (func(), error) {
+		aliases, err := NewNameAliases()
+		return func() { SetNameAliases(aliases) }, err
+	}},
+	{"initialLoad", []string{"LOG_INITIAL_LINES", "LOG_INITIAL_LINES_PER_SOURCE"}, func() (func(), error) {
+		config, err := GetInitialLoadConfigFromEnv()
+		return func() { SetInitialLoadConfig(config) }, err
+	}},
+	{"buffers", []string{"LOG_LISTENER_BUFFER", "WS_SEND_BUFFER"}, func() (func(), error) {
+		sizes, err := GetBufferSizesFromEnv()
+		return func() { SetBufferSizes(sizes) }, err
+	}},
+	{"geo", []string{"USE_MAXMIND", "MAXMIND_FALLBACK_ONLINE"}, func() (func(), error) {
+		useMaxMind := os.Getenv("USE_MAXMIND") == "true"
+		fallback := os.Getenv("MAXMIND_FALLBACK_ONLINE") != "false"
+		if useMaxMind && GetMaxMindConfig().DatabasePath == "" {
+			return nil, fmt.Errorf("cannot enable MaxMind: no database path configured")
+		}
+		return func() {
+			if err := SetGeoProviderSettings(useMaxMind, fallback, GetMaxRequestsPerMinute()); err != nil {
+				appLog.Error("Failed to apply geo settings", "error", err)
+			}
+		}, nil
+	}},
+	{"statsCache", []string{"STATS_CACHE_INTERVAL_MS", "STATS_CACHE_MAX_ENTRIES"}, func() (func(), error) {
+		interval := time.Duration(GetEnvInt("STATS_CACHE_INTERVAL_MS", int(DEFAULT_STATS_CACHE_INTERVAL/time.Millisecond))) * time.Millisecond
+		maxEntries := GetEnvInt("STATS_CACHE_MAX_ENTRIES", DEFAULT_STATS_CACHE_MAX_ENTRIES)
+		return func() { logParser.statsCache.Configure(interval, maxEntries) }, nil
+	}},
+	{"parseErrorSamples", []string{"PARSE_ERROR_SAMPLES"}, func() (func(), error) {
+		size := GetEnvInt("PARSE_ERROR_SAMPLES", DEFAULT_PARSE_ERROR_SAMPLES)
+		return func() { parseErrorTracker.SetSampleSize(size) }, nil
+	}},
+	{"geoTrackedIPs", []string{"GEO_TRACKED_IPS"}, func() (func(), error) {
+		limit := GetEnvInt("GEO_TRACKED_IPS", DEFAULT_GEO_TRACKED_IPS)
+		return func() { logParser.SetGeoTrackedIPs(limit) }, nil
+	}},
+	// The rescan interval is read when the sources are set up
+	{"logSources", []string{"TRAEFIK_LOG_FILE", "LOG_RESCAN_INTERVAL_SECONDS", "LOG_BACKFILL_ROTATED"}, func() (func(), error) {
+		logFile := os.Getenv("TRAEFIK_LOG_FILE")
+		if !logFilesEnabled || logFile == "none" {
+			return nil, fmt.Errorf("switching between log file monitoring and OTLP-only mode requires a restart")
+		}
+		if logFile == "" {
+			logFile = DEFAULT_LOG_FILE
+		}
+		return func() {
+			if err := logParser.SetLogFiles(splitLogSources(logFile)); err != nil {
+				appLog.Error("Failed to apply log sources", "error", err)
+				return
+			}
+			broadcastSystemEvent("logSourcesChanged", map[string]interface{}{"files": logParser.WatchedFiles()})
+		}, nil
+	}},
+}
+
+// Re-read the config file and apply what changed. Log sources, buffer sizes,
+// intervals and geo settings take effect right away; the log buffer,
+// WebSocket clients and running sources that are still configured are kept.
+// Nothing is changed if a changed setting doesn't validate.
+func ReloadConfig() (ConfigReloadResult, error) {
+	configReloadMu.Lock()
+	defer configReloadMu.Unlock()
+
+	var result ConfigReloadResult
+	values, err := readConfigFile()
+	if err != nil {
+		return result, err
+	}
+
+	// Variables from the environment keep winning; ones that were set by the
+	// file and are gone from it now are unset
+	previous := make(map[string]*string)
+	keys := make(map[string]bool, len(values))
+	set := func(key string, value *string) {
+		if old, ok := os.LookupEnv(key); ok {
+			if value != nil && *value == old {
+				return
+			}
+			previous[key] = &old
+		} else if value == nil {
+			return
+		} else {
+			previous[key] = nil
+		}
+		if value != nil {
+			os.Setenv(key, *value)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+	for key, value := range values {
+		if _, fromEnv := os.LookupEnv(key); fromEnv && !configFileKeys[key] {
+			continue
+		}
+		set(key, &value)
+		keys[key] = true
+	}
+	for key := range configFileKeys {
+		if !keys[key] {
+			set(key, nil)
+		}
+	}
+	restore := func() {
+		for key, value := range previous {
+			if value != nil {
+				os.Setenv(key, *value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+	}
+
+	for key := range previous {
+		result.Changed = append(result.Changed, key)
+	}
+	sort.Strings(result.Changed)
+
+	// Validate every affected setting before applying any of them
+	reloadable := make(map[string]bool)
+	var commits []func()
+	for _, setting := range reloadableSettings {
+		affected := false
+		for _, name := range setting.vars {
+			reloadable[name] = true
+			_, changed := previous[name]
+			affected = affected || changed
+		}
+		if !affected {
+			continue
+		}
+		commit, err := setting.apply()
+		if err != nil {
+			restore()
+			return ConfigReloadResult{}, fmt.Errorf("%s: %w", setting.name, err)
+		}
+		commits = append(commits, commit)
+		result.Applied = append(result.Applied, setting.name)
+	}
+	for _, key := range result.Changed {
+		if !reloadable[key] {
+			result.RestartRequired = append(result.RestartRequired, key)
+		}
+	}
+
+	configFileKeys = keys
+	for _, commit := range commits {
+		commit()
+	}
+
+	if len(result.Changed) == 0 {
+		appLog.Info("Configuration reloaded, no changes")
+	} else {
+		appLog.Info("Configuration reloaded", "changed", result.Changed, "applied", result.Applied)
+	}
+	if len(result.RestartRequired) > 0 {
+		appLog.Warn("Some changes take effect after a restart", "variables", result.RestartRequired)
+	}
+	if len(result.Applied) > 0 {
+		broadcastSystemEvent("configUpdated", map[string]interface{}{"config": GetRuntimeConfig()})
+	}
+	return result, nil
+}
+
+// Split a comma separated list of log sources
+func splitLogSources(value string) []string {
+	sources := strings.Split(value, ",")
+	for i := range sources {
+		sources[i] = strings.TrimSpace(sources[i])
+	}
+	return sources
+}
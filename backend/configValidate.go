@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oschwald/geoip2-golang"
+)
+
+const CONFIG_PROBE_TIMEOUT = 3 * time.Second // Connecting to a configured endpoint
+
+// One problem found while validating the configuration
+type ConfigIssue struct {
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// Result of validating the configuration. Errors keep the server from
+// starting; warnings are settings it starts with that probably don't work
+// as intended yet.
+type ConfigValidation struct {
+	Valid    bool          `json:"valid"`
+	Errors   []ConfigIssue `json:"errors"`
+	Warnings []ConfigIssue `json:"warnings"`
+}
+
+func (v *ConfigValidation) fail(check string, format string, args ...interface{}) {
+	v.Errors = append(v.Errors, ConfigIssue{Check: check, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *ConfigValidation) warn(check string, format string, args ...interface{}) {
+	v.Warnings = append(v.Warnings, ConfigIssue{Check: check, Message: fmt.Sprintf(format, args...)})
+}
+
+// Check the whole configuration and report every problem at once rather
+// than stopping at the first. Listen ports are only checked when the server
+// isn't running yet.
+func ValidateConfig(checkPorts bool) ConfigValidation {
+	v := ConfigValidation{Errors: []ConfigIssue{}, Warnings: []ConfigIssue{}}
+	for _, check := range configChecks() {
+		if err := check.check(); err != nil {
+			v.fail(check.name, "%v", err)
+		}
+	}
+	validateLogSources(&v)
+	validateMaxMind(&v)
+	if checkPorts {
+		validatePorts(&v)
+	}
+	validateEndpoints(&v)
+	v.Valid = len(v.Errors) == 0
+	return v
+}
+
+// The settings serve refuses to start with, checked with the same
+// constructors; anything they start is stopped again
+func configChecks() []struct {
+	name  string
+	check func() error
+} {
+	return []struct {
+		name  string
+		check func() error
+	}{
+		{"logging", func() error { _, err := GetLoggingConfigFromEnv(); return err }},
+		{"buffers", func() error {
+			if _, err := GetMaxLogsFromEnv(); err != nil {
+				return err
+			}
+			_, err := GetBufferSizesFromEnv()
+			return err
+		}},
+		{"ingest", func() error { _, err := GetIngestConfigFromEnv(); return err }},
+		{"OTLP sampling", func() error { _, err := GetOTLPSamplingConfigFromEnv(); return err }},
+		{"trusted proxies", func() error { _, err := NewClientIPResolver(); return err }},
+		{"captured headers", func() error { _, err := NewHeaderCapture(); return err }},
+		{"path templates", func() error { _, err := GetPathTemplateConfigFromEnv(); return err }},
+		{"aliases", func() error { _, err := NewNameAliases(); return err }},
+		{"initial load", func() error { _, err := GetInitialLoadConfigFromEnv(); return err }},
+		{"memory budget", func() error { _, err := NewMemoryBudget(); return err }},
+		{"basic auth", func() error { _, err := NewBasicAuth(); return err }},
+		{"OIDC", func() error { _, err := NewOIDCAuth(GetOIDCConfig()); return err }},
+		{"tenancy", func() error { _, err := LoadTenancy(); return err }},
+		{"OTLP auth", func() error { return GetOTLPAuthSettings().Validate() }},
+		{"admin allowlist", func() error { _, err := LoadAdminAllowlist(); return err }},
+		{"tickets", func() error { _, err := NewTicketSigner(); return err }},
+		{"notifications", func() error { _, err := newNotifiers(); return err }},
+		{"StatsD", func() error {
+			emitter, err := NewStatsDEmitter()
+			if emitter != nil {
+				emitter.Stop()
+			}
+			return err
+		}},
+		{"Loki", func() error {
+			forwarder, err := NewLokiForwarder()
+			if forwarder != nil {
+				forwarder.Stop()
+			}
+			return err
+		}},
+		{"blocklist", func() error { _, err := NewBlocklist(); return err }},
+		{"InfluxDB", func() error { _, err := NewInfluxPusher(); return err }},
+		{"Traefik API", func() error { _, err := NewTraefikAPIClient(); return err }},
+		{"Docker enrichment", func() error { _, err := NewDockerEnricher(); return err }},
+		{"reports", func() error { _, err := GetReportConfigFromEnv(); return err }},
+		{"OTLP metrics", func() error {
+			exporter, err := NewOTLPMetricsExporter()
+			if exporter != nil && exporter.grpcConn != nil {
+				exporter.grpcConn.Close()
+			}
+			return err
+		}},
+		{"OTLP trace forwarding", func() error {
+			forwarder, err := NewOTLPTraceForwarder()
+			if forwarder != nil && forwarder.grpcConn != nil {
+				forwarder.grpcConn.Close()
+			}
+			return err
+		}},
+		{"MQTT", func() error { _, err := NewMQTTPublisher(); return err }},
+		{"TLS", func() error { _, err := GetTLSSettings("").ServerConfig(); return err }},
+		{"OTLP TLS", func() error {
+			if !GetOTLPConfig().Enabled {
+				return nil
+			}
+			_, err := GetTLSSettings("OTLP_").ServerConfig()
+			return err
+		}},
+	}
+}
+
+// Configured log paths must be readable. Ones that don't exist yet are only
+// warned about: the dashboard waits for them to appear.
+func validateLogSources(v *ConfigValidation) {
+	logFile := os.Getenv("TRAEFIK_LOG_FILE")
+	if logFile == "none" || (logFile == "" && GetOTLPConfig().Enabled) {
+		return
+	}
+	if logFile == "" {
+		logFile = DEFAULT_LOG_FILE
+	}
+	for _, path := range splitLogSources(logFile) {
+		switch {
+		case path == "":
+		case isRemoteSource(path):
+			if u, err := url.Parse(path); err != nil || u.Host == "" {
+				v.fail("log sources", "%s is not a valid URL", path)
+			}
+		case isGlobPattern(path):
+			matches, err := expandGlob(path)
+			if err != nil {
+				v.fail("log sources", "invalid pattern %s in TRAEFIK_LOG_FILE: %v", path, err)
+			} else if len(matches) == 0 {
+				v.warn("log sources", "%s matches no files yet", path)
+			}
+			for _, match := range matches {
+				validateReadable(v, match)
+			}
+		default:
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				if _, err := os.Stat(filepath.Dir(path)); os.IsNotExist(err) {
+					v.warn("log sources", "%s does not exist and neither does its directory; is the log volume mounted?", path)
+				} else {
+					v.warn("log sources", "%s does not exist yet", path)
+				}
+				continue
+			}
+			validateReadable(v, path)
+		}
+	}
+}
+
+func validateReadable(v *ConfigValidation, path string) {
+	file, err := openSharedFile(path)
+	if err != nil {
+		v.fail("log sources", "%s is not readable by the dashboard: %v", path, err)
+		return
+	}
+	file.Close()
+}
+
+// An enabled MaxMind database must open. Without the online fallback
+// geolocation stops working altogether, so that is an error.
+func validateMaxMind(v *ConfigValidation) {
+	if os.Getenv("USE_MAXMIND") != "true" {
+		return
+	}
+	report := v.fail
+	if os.Getenv("MAXMIND_FALLBACK_ONLINE") != "false" {
+		report = v.warn
+	}
+	path := os.Getenv("MAXMIND_DB_PATH")
+	if path == "" {
+		report("MaxMind", "USE_MAXMIND is true but MAXMIND_DB_PATH is not set")
+		return
+	}
+	db, err := geoip2.Open(path)
+	if err != nil {
+		report("MaxMind", "cannot open MAXMIND_DB_PATH %s: %v", path, err)
+		return
+	}
+	db.Close()
+}
+
+// The API port, and the OTLP receiver's ports when enabled, must be valid,
+// distinct and free
+func validatePorts(v *ConfigValidation) {
+	ports := []struct {
+		name  string
+		value string
+	}{{"PORT", GetEnvString("PORT", "3001")}}
+	if otlp := GetOTLPConfig(); otlp.Enabled {
+		ports = append(ports,
+			struct{ name, value string }{"OTLP_GRPC_PORT", strconv.Itoa(otlp.GRPCPort)},
+			struct{ name, value string }{"OTLP_HTTP_PORT", strconv.Itoa(otlp.HTTPPort)})
+	}
+
+	used := make(map[string]string)
+	for _, port := range ports {
+		if number, err := strconv.Atoi(port.value); err != nil || number < 1 || number > 65535 {
+			v.fail("ports", "%s must be a port number between 1 and 65535, got %q", port.name, port.value)
+			continue
+		}
+		if other, ok := used[port.value]; ok {
+			v.fail("ports", "%s and %s are both set to %s", other, port.name, port.value)
+			continue
+		}
+		used[port.value] = port.name
+
+		listener, err := net.Listen("tcp", ":"+port.value)
+		if err != nil {
+			v.fail("ports", "%s %s is not available: %v", port.name, port.value, err)
+			continue
+		}
+		listener.Close()
+	}
+}
+
+// Remote endpoints should accept connections. They're only warned about:
+// exporters and remote sources retry until they come up.
+func validateEndpoints(v *ConfigValidation) {
+	var endpoints []struct{ name, address string }
+	for _, name := range []string{"LOKI_URL", "INFLUX_URL", "OTLP_METRICS_ENDPOINT", "OTLP_FORWARD_ENDPOINT", "MQTT_BROKER", "TRAEFIK_API_URL"} {
+		if value := os.Getenv(name); value != "" {
+			endpoints = append(endpoints, struct{ name, address string }{name, endpointAddress(value)})
+		}
+	}
+	for _, path := range splitLogSources(os.Getenv("TRAEFIK_LOG_FILE")) {
+		if isRemoteSource(path) {
+			endpoints = append(endpoints, struct{ name, address string }{redactURL(path), endpointAddress(path)})
+		}
+	}
+
+	failures := make([]error, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func(i int, address string) {
+			defer wg.Done()
+			conn, err := net.DialTimeout("tcp", address, CONFIG_PROBE_TIMEOUT)
+			if err != nil {
+				failures[i] = err
+				return
+			}
+			conn.Close()
+		}(i, endpoint.address)
+	}
+	wg.Wait()
+
+	for i, err := range failures {
+		if err != nil {
+			v.warn("endpoints", "%s: cannot connect to %s: %v", endpoints[i].name, endpoints[i].address, err)
+		}
+	}
+}
+
+// host:port to connect to for an endpoint URL, or the endpoint itself when
+// it's already host:port (as gRPC endpoints are)
+func endpointAddress(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return endpoint
+	}
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "tcp", "mqtt":
+			port = "1883"
+		case "ssl", "tls", "mqtts":
+			port = "8883"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// Pre-flight check of the running configuration; 422 when it has errors
+func getConfigValidation(c *gin.Context) {
+	validation := ValidateConfig(false)
+	status := http.StatusOK
+	if !validation.Valid {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, validation)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Fill level of a buffered channel
+type QueueDepth struct {
+	Name     string `json:"name"`
+	Length   int    `json:"length"`
+	Capacity int    `json:"capacity"`
+}
+
+func queueDepth[T any](name string, ch chan T) QueueDepth {
+	return QueueDepth{Name: name, Length: len(ch), Capacity: cap(ch)}
+}
+
+// Register pprof and the runtime summary when DEBUG_ENDPOINTS_ENABLED is set.
+// Profiles expose internals (and cost CPU), so they are off by default and
+// limited to the admin network and API token like other management endpoints.
+func registerDebugRoutes(r *gin.Engine) {
+	if !GetEnvBool("DEBUG_ENDPOINTS_ENABLED", false) {
+		return
+	}
+	appLog.Info("Debug endpoints enabled at /debug/pprof/ and /api/debug/runtime")
+
+	guarded := r.Group("", requireAdminNetwork(), requireGlobalAccess(), requireAPIToken())
+	guarded.GET("/api/debug/runtime", getRuntimeDiagnostics)
+
+	profiles := guarded.Group("/debug/pprof")
+	profiles.GET("/", gin.WrapF(pprof.Index))
+	profiles.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	profiles.GET("/profile", gin.WrapF(pprof.Profile))
+	profiles.GET("/symbol", gin.WrapF(pprof.Symbol))
+	profiles.POST("/symbol", gin.WrapF(pprof.Symbol))
+	profiles.GET("/trace", gin.WrapF(pprof.Trace))
+	// heap, goroutine, allocs, block, mutex, threadcreate
+	profiles.GET("/:name", func(c *gin.Context) {
+		pprof.Handler(c.Param("name")).ServeHTTP(c.Writer, c.Request)
+	})
+}
+
+func getRuntimeDiagnostics(c *gin.Context) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	var lastGC, lastPause interface{}
+	if ms.NumGC > 0 {
+		lastGC = time.Unix(0, int64(ms.LastGC)).UTC().Format(time.RFC3339Nano)
+		lastPause = float64(ms.PauseNs[(ms.NumGC+255)%256]) / 1e6
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"goVersion":  runtime.Version(),
+		"uptime":     time.Since(metrics.startTime).Round(time.Second).String(),
+		"goroutines": runtime.NumGoroutine(),
+		"cpus":       runtime.NumCPU(),
+		"gomaxprocs": runtime.GOMAXPROCS(0),
+		"memory": gin.H{
+			"heapAlloc":      ms.HeapAlloc,
+			"heapInuse":      ms.HeapInuse,
+			"heapObjects":    ms.HeapObjects,
+			"stackInuse":     ms.StackInuse,
+			"sys":            ms.Sys,
+			"nextGC":         ms.NextGC,
+			"memoryLimit":    goMemoryLimit(),
+			"logBuffer":      logParser.MemoryUsage(),
+			"geoTrackedIPs":  logParser.geoTrackedIPs(),
+			"topCounterKeys": logParser.topCounterKeys(),
+		},
+		"gc": gin.H{
+			"count":         ms.NumGC,
+			"lastGC":        lastGC,
+			"lastPauseMs":   lastPause,
+			"pauseTotalMs":  float64(ms.PauseTotalNs) / 1e6,
+			"cpuFraction":   ms.GCCPUFraction,
+			"forcedGCCount": ms.NumForcedGC,
+		},
+		"queues": runtimeQueueDepths(),
+	})
+}
+
+// Collect the fill level of the buffered channels between pipeline stages
+func runtimeQueueDepths() gin.H {
+	queues := gin.H{
+		"geoProcessing": logParser.geoQueueLength(),
+		"logListeners":  logParser.listenerDepths(),
+	}
+
+	if parsePool != nil {
+		queues["parsePool"] = queueDepth("parsePool", parsePool.jobs)
+	}
+	if lokiForwarder != nil {
+		queues["loki"] = queueDepth("loki", lokiForwarder.queue)
+	}
+
+	notifiers := make([]QueueDepth, 0, len(notifierWorkers))
+	for _, worker := range notifierWorkers {
+		notifiers = append(notifiers, queueDepth(worker.notifier.Name(), worker.queue))
+	}
+	queues["notifiers"] = notifiers
+
+	wsClientsMux.RLock()
+	clients := make([]gin.H, 0, len(wsClients))
+	for client := range wsClients {
+		clients = append(clients, gin.H{
+			"id":   client.clientID,
+			"send": queueDepth("send", client.send),
+			"logs": queueDepth("logs", client.logChan),
+			"raw":  queueDepth("raw", client.rawChan),
+		})
+	}
+	wsClientsMux.RUnlock()
+	queues["websocketClients"] = clients
+
+	return queues
+}
+
+// Get the fill level of every log and raw line listener channel
+func (lp *LogParser) listenerDepths() []QueueDepth {
+	lp.mu.RLock()
+	defer lp.mu.RUnlock()
+
+	depths := make([]QueueDepth, 0, len(lp.listeners)+len(lp.rawListeners))
+	for _, ch := range lp.listeners {
+		depths = append(depths, queueDepth("entries", ch))
+	}
+	for _, ch := range lp.rawListeners {
+		depths = append(depths, queueDepth("raw", ch))
+	}
+	return depths
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gin-gonic/gin"
+	"github.com/oschwald/geoip2-golang"
+)
+
+const (
+	DIAGNOSTIC_PASS = "pass"
+	DIAGNOSTIC_WARN = "warn"
+	DIAGNOSTIC_FAIL = "fail"
+	DIAGNOSTIC_SKIP = "skip" // Not applicable to this configuration or platform
+
+	MAXMIND_MAX_AGE = 60 * 24 * time.Hour // GeoLite2 is rebuilt twice a week
+)
+
+// Hosts of the online geolocation APIs, tried in this order
+var onlineGeoHosts = []string{"ip-api.com", "ipapi.co", "ipinfo.io"}
+
+// Result of one self-test; Hint says what usually fixes a failure
+type DiagnosticCheck struct {
+	Name       string `json:"name"`
+	Target     string `json:"target,omitempty"`
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	Hint       string `json:"hint,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+type DiagnosticsReport struct {
+	Passed  bool              `json:"passed"` // No check failed
+	Summary map[string]int    `json:"summary"`
+	Checks  []DiagnosticCheck `json:"checks"`
+	Time    string            `json:"time"`
+}
+
+// Unlike /api/config/validate, which checks settings, these test what the
+// running server can actually reach, read and write from where it runs
+func RunDiagnostics() DiagnosticsReport {
+	tests := []func() []DiagnosticCheck{
+		diagnoseLogSources,
+		diagnoseInotify,
+		diagnoseMaxMind,
+		diagnoseGeoDNS,
+		diagnoseDiskSpace,
+	}
+
+	// Network checks wait on timeouts, so everything runs at once
+	results := make([][]DiagnosticCheck, len(tests))
+	var wg sync.WaitGroup
+	for i, test := range tests {
+		wg.Add(1)
+		go func(i int, test func() []DiagnosticCheck) {
+			defer wg.Done()
+			results[i] = test()
+		}(i, test)
+	}
+	wg.Wait()
+
+	report := DiagnosticsReport{
+		Passed:  true,
+		Summary: map[string]int{DIAGNOSTIC_PASS: 0, DIAGNOSTIC_WARN: 0, DIAGNOSTIC_FAIL: 0, DIAGNOSTIC_SKIP: 0},
+		Checks:  []DiagnosticCheck{},
+		Time:    time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, checks := range results {
+		for _, check := range checks {
+			report.Summary[check.Status]++
+			if check.Status == DIAGNOSTIC_FAIL {
+				report.Passed = false
+			}
+			report.Checks = append(report.Checks, check)
+		}
+	}
+	return report
+}
+
+// Time a single check
+func diagnose(name, target string, test func(check *DiagnosticCheck)) DiagnosticCheck {
+	started := time.Now()
+	check := DiagnosticCheck{Name: name, Target: target, Status: DIAGNOSTIC_PASS}
+	test(&check)
+	check.DurationMs = time.Since(started).Milliseconds()
+	return check
+}
+
+func (c *DiagnosticCheck) set(status, message, hint string) {
+	c.Status, c.Message, c.Hint = status, message, hint
+}
+
+// Every watched file must open, and every remote source accept connections
+func diagnoseLogSources() []DiagnosticCheck {
+	if !logFilesEnabled {
+		return []DiagnosticCheck{diagnose("log sources", "", func(check *DiagnosticCheck) {
+			check.set(DIAGNOSTIC_SKIP, "Log files are disabled; only OTLP is received", "")
+		})}
+	}
+	statuses := logParser.SourceStatuses()
+	watched := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		watched[status.Path] = true
+	}
+
+	checks := make([]DiagnosticCheck, 0, len(statuses))
+	// Configured paths that were missing at startup are not watched at all
+	for _, path := range splitLogSources(os.Getenv("TRAEFIK_LOG_FILE")) {
+		if path == "" || path == "none" || isRemoteSource(path) || watched[path] {
+			continue
+		}
+		if isGlobPattern(path) {
+			if matches, err := expandGlob(path); err == nil && len(matches) == 0 {
+				checks = append(checks, diagnose("log source", path, func(check *DiagnosticCheck) {
+					check.set(DIAGNOSTIC_WARN, "The pattern matches no files yet", "Files that start matching are picked up within LOG_RESCAN_INTERVAL_SECONDS")
+				}))
+			}
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			checks = append(checks, diagnose("log source", path, func(check *DiagnosticCheck) {
+				diagnoseLogFile(check, SourceStatus{Path: path})
+				check.Message += "; it is not watched"
+				check.Hint = "Fix the path and restart; paths missing when the sources are set up are skipped"
+			}))
+		}
+	}
+	if len(statuses) == 0 && len(checks) == 0 {
+		return []DiagnosticCheck{diagnose("log sources", "", func(check *DiagnosticCheck) {
+			check.set(DIAGNOSTIC_FAIL, "No log sources are watched", "Set TRAEFIK_LOG_FILE to Traefik's access log")
+		})}
+	}
+
+	for _, status := range statuses {
+		if status.Mode == "http" {
+			checks = append(checks, diagnose("log source", redactURL(status.Path), func(check *DiagnosticCheck) {
+				diagnoseRemoteSource(check, status)
+			}))
+			continue
+		}
+		checks = append(checks, diagnose("log source", status.Path, func(check *DiagnosticCheck) {
+			diagnoseLogFile(check, status)
+		}))
+	}
+	return checks
+}
+
+func diagnoseLogFile(check *DiagnosticCheck, status SourceStatus) {
+	file, err := openSharedFile(status.Path)
+	switch {
+	case os.IsNotExist(err):
+		if _, dirErr := os.Stat(filepath.Dir(status.Path)); os.IsNotExist(dirErr) {
+			check.set(DIAGNOSTIC_FAIL, "Neither the file nor its directory exists",
+				"Mount the directory Traefik writes its access log to, at the same path")
+		} else {
+			check.set(DIAGNOSTIC_FAIL, "The file does not exist",
+				"Enable Traefik's accessLog with filePath set to this path; the dashboard picks the file up once it appears")
+		}
+		return
+	case os.IsPermission(err):
+		check.set(DIAGNOSTIC_FAIL, fmt.Sprintf("Not readable by the dashboard: %v", err),
+			"Make the file readable by the user the dashboard runs as, e.g. add it to the group owning the log")
+		return
+	case err != nil:
+		check.set(DIAGNOSTIC_FAIL, fmt.Sprintf("Cannot open the file: %v", err), "")
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		check.set(DIAGNOSTIC_FAIL, fmt.Sprintf("Cannot stat the file: %v", err), "")
+		return
+	}
+	check.Message = fmt.Sprintf("Readable, %d bytes, watched with %s", info.Size(), status.Mode)
+	if status.Mode == "poll" && !pollOnlySource(status.Path) {
+		check.set(DIAGNOSTIC_WARN, check.Message+" because fsnotify was unavailable",
+			"Raise fs.inotify.max_user_instances; polling still reads every line but up to a second late")
+	}
+}
+
+func diagnoseRemoteSource(check *DiagnosticCheck, status SourceStatus) {
+	address := endpointAddress(status.Path)
+	conn, err := net.DialTimeout("tcp", address, CONFIG_PROBE_TIMEOUT)
+	if err != nil {
+		check.set(DIAGNOSTIC_FAIL, fmt.Sprintf("Cannot connect to %s: %v", address, err),
+			"Check the URL and that the file server is reachable from the dashboard's network")
+		return
+	}
+	conn.Close()
+	if status.Error != "" {
+		check.set(DIAGNOSTIC_FAIL, "Reachable, but the last fetch failed: "+status.Error,
+			"Check the credentials in the URL and that the server supports Range requests")
+		return
+	}
+	check.Message = fmt.Sprintf("Reachable, %d bytes read", status.Offset)
+}
+
+// fsnotify needs an inotify instance per watched file; when the per-user
+// limit is used up the watcher falls back to polling
+func diagnoseInotify() []DiagnosticCheck {
+	return []DiagnosticCheck{diagnose("inotify", "", func(check *DiagnosticCheck) {
+		if runtime.GOOS != "linux" {
+			check.set(DIAGNOSTIC_SKIP, "inotify limits only apply on Linux", "")
+			return
+		}
+		instances := readProcInt("/proc/sys/fs/inotify/max_user_instances")
+		watches := readProcInt("/proc/sys/fs/inotify/max_user_watches")
+		limits := fmt.Sprintf("max_user_instances=%d, max_user_watches=%d", instances, watches)
+
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			check.set(DIAGNOSTIC_FAIL, fmt.Sprintf("Cannot create an inotify instance (%s): %v", limits, err),
+				"Raise fs.inotify.max_user_instances on the host, e.g. sysctl -w fs.inotify.max_user_instances=512")
+			return
+		}
+		watcher.Close()
+		check.Message = "An inotify instance can be created; " + limits
+	})}
+}
+
+func readProcInt(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	value, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return value
+}
+
+// An enabled MaxMind database must open and be recent
+func diagnoseMaxMind() []DiagnosticCheck {
+	geo := GetMaxMindConfig()
+	return []DiagnosticCheck{diagnose("MaxMind", geo.DatabasePath, func(check *DiagnosticCheck) {
+		if !geo.Enabled {
+			check.set(DIAGNOSTIC_SKIP, "MaxMind is disabled; online APIs are used", "")
+			return
+		}
+		db, err := geoip2.Open(geo.DatabasePath)
+		if err != nil {
+			check.set(DIAGNOSTIC_FAIL, fmt.Sprintf("Cannot open the database: %v", err),
+				"Download GeoLite2-City.mmdb (make maxmind-download) and mount it at MAXMIND_DB_PATH")
+			return
+		}
+		defer db.Close()
+
+		metadata := db.Metadata()
+		built := time.Unix(int64(metadata.BuildEpoch), 0).UTC()
+		check.Message = fmt.Sprintf("%s built %s", metadata.DatabaseType, built.Format("2006-01-02"))
+		switch {
+		case !geo.DatabaseLoaded:
+			check.set(DIAGNOSTIC_FAIL, check.Message+", but the running server has not loaded it",
+				"POST /api/maxmind/reload")
+		case geo.DatabaseError != "":
+			check.set(DIAGNOSTIC_FAIL, check.Message+", but lookups fail: "+geo.DatabaseError,
+				"Use a City database; Country and ASN databases lack the fields the dashboard needs")
+		case time.Since(built) > MAXMIND_MAX_AGE:
+			check.set(DIAGNOSTIC_WARN, check.Message+", which is out of date",
+				"Update the database, e.g. with MaxMind's geoipupdate")
+		}
+	})}
+}
+
+// The online geolocation APIs must resolve when they are used
+func diagnoseGeoDNS() []DiagnosticCheck {
+	geo := GetMaxMindConfig()
+	if geo.Enabled && !geo.FallbackToOnline {
+		return []DiagnosticCheck{diagnose("geo DNS", "", func(check *DiagnosticCheck) {
+			check.set(DIAGNOSTIC_SKIP, "Online geolocation is disabled", "")
+		})}
+	}
+
+	checks := make([]DiagnosticCheck, len(onlineGeoHosts))
+	var wg sync.WaitGroup
+	for i, host := range onlineGeoHosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			checks[i] = diagnose("geo DNS", host, func(check *DiagnosticCheck) {
+				ctx, cancel := context.WithTimeout(context.Background(), CONFIG_PROBE_TIMEOUT)
+				defer cancel()
+				addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+				if err != nil {
+					// The other providers are fallbacks, so one alone is a warning
+					check.set(DIAGNOSTIC_WARN, fmt.Sprintf("Cannot resolve: %v", err), "")
+					return
+				}
+				check.Message = "Resolves to " + strings.Join(addrs, ", ")
+			})
+		}(i, host)
+	}
+	wg.Wait()
+
+	for _, check := range checks {
+		if check.Status == DIAGNOSTIC_PASS {
+			return checks
+		}
+	}
+	for i := range checks {
+		checks[i].Status = DIAGNOSTIC_FAIL
+		checks[i].Hint = "No geolocation API resolves; allow DNS and outbound HTTPS, or use a MaxMind database"
+	}
+	return checks
+}
+
+// Logs are kept in memory, so the filesystems that matter are the ones
+// holding the logs themselves and the files the dashboard writes
+func diagnoseDiskSpace() []DiagnosticCheck {
+	threshold := DEFAULT_DISK_ALERT_PERCENT
+	if alertMonitor != nil && alertMonitor.config.DiskAlertPercent > 0 {
+		threshold = alertMonitor.config.DiskAlertPercent
+	}
+
+	var dirs []string
+	seen := make(map[string]bool)
+	addDir := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, file := range logParser.WatchedFiles() {
+		if !isRemoteSource(file) {
+			addDir(filepath.Dir(file))
+		}
+	}
+	if path := os.Getenv("BLOCKLIST_FILE"); path != "" {
+		addDir(filepath.Dir(path))
+	}
+	if len(dirs) == 0 {
+		return []DiagnosticCheck{diagnose("disk space", "", func(check *DiagnosticCheck) {
+			check.set(DIAGNOSTIC_SKIP, "Nothing is read from or written to local disk", "")
+		})}
+	}
+
+	checks := make([]DiagnosticCheck, 0, len(dirs))
+	for _, dir := range dirs {
+		checks = append(checks, diagnose("disk space", dir, func(check *DiagnosticCheck) {
+			percent, err := diskUsagePercent(dir)
+			if err != nil {
+				check.set(DIAGNOSTIC_SKIP, err.Error(), "")
+				return
+			}
+			check.Message = fmt.Sprintf("%.1f%% used", percent)
+			if percent >= float64(threshold) {
+				check.set(DIAGNOSTIC_WARN, check.Message+fmt.Sprintf(", at or above %d%%", threshold),
+					"Free space or tighten log rotation; Traefik stops logging when the disk is full")
+			}
+		}))
+	}
+	return checks
+}
+
+// Self-test of the deployment, for support requests
+func getDiagnostics(c *gin.Context) {
+	c.JSON(http.StatusOK, RunDiagnostics())
+}
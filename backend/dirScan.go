@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_LOG_DIR_MIN_SIZE = 50
+	DEFAULT_LOG_DIR_MAX_AGE  = 7 * 24 * time.Hour
+	LOG_DIR_LARGE_FILE_SIZE  = 1024 * 1024 // Old files this large are still picked up
+)
+
+// One LOG_DIR_EXCLUDE entry
+type excludeRule struct {
+	glob  string
+	regex *regexp.Regexp
+	dir   bool // Trailing "/": skip the whole directory
+}
+
+// Heuristics and exclusions for auto-discovering files in directory sources
+type DirScanConfig struct {
+	Exclude []excludeRule
+	MinSize int64
+	MaxAge  time.Duration // 0 disables the age check
+}
+
+// Load the scan settings. LOG_DIR_EXCLUDE is a comma separated list of globs
+// ("*.gz", "error.log"), directories ("old/") and regexes ("re:^debug-.*")
+// matched against each path relative to the scanned directory and its name.
+func GetDirScanConfig() (DirScanConfig, error) {
+	config := DirScanConfig{
+		MinSize: int64(GetEnvInt("LOG_DIR_MIN_SIZE_BYTES", DEFAULT_LOG_DIR_MIN_SIZE)),
+		MaxAge:  time.Duration(GetEnvInt("LOG_DIR_MAX_AGE_HOURS", int(DEFAULT_LOG_DIR_MAX_AGE/time.Hour))) * time.Hour,
+	}
+
+	for _, entry := range strings.Split(os.Getenv("LOG_DIR_EXCLUDE"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if expr, ok := strings.CutPrefix(entry, "re:"); ok {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return DirScanConfig{}, fmt.Errorf("invalid regex in LOG_DIR_EXCLUDE %q: %w", expr, err)
+			}
+			config.Exclude = append(config.Exclude, excludeRule{regex: re})
+			continue
+		}
+		rule := excludeRule{glob: entry}
+		if strings.HasSuffix(entry, "/") {
+			rule.dir = true
+			rule.glob = strings.TrimSuffix(entry, "/")
+		}
+		if _, err := filepath.Match(rule.glob, ""); err != nil {
+			return DirScanConfig{}, fmt.Errorf("invalid pattern in LOG_DIR_EXCLUDE %q: %w", entry, err)
+		}
+		config.Exclude = append(config.Exclude, rule)
+	}
+	return config, nil
+}
+
+// Check whether a path (relative to the scanned directory) is excluded
+func (c DirScanConfig) Excluded(rel string, isDir bool) bool {
+	rel = filepath.ToSlash(rel)
+	name := filepath.Base(rel)
+	for _, rule := range c.Exclude {
+		if rule.regex != nil {
+			if rule.regex.MatchString(rel) {
+				return true
+			}
+			continue
+		}
+		if rule.dir != isDir {
+			continue
+		}
+		if ok, _ := filepath.Match(rule.glob, name); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(rule.glob, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply the size and age heuristics
+func (c DirScanConfig) Eligible(info os.FileInfo) bool {
+	if info.Size() < c.MinSize {
+		return false
+	}
+	if c.MaxAge > 0 && time.Since(info.ModTime()) > c.MaxAge && info.Size() < LOG_DIR_LARGE_FILE_SIZE {
+		return false
+	}
+	return true
+}
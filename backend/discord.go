@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Embed colors by severity
+var discordColors = map[string]int{
+	SEVERITY_CRITICAL: 0xE74C3C,
+	SEVERITY_WARNING:  0xF1C40F,
+	SEVERITY_INFO:     0x2ECC71,
+}
+
+// Characters used to draw the error rate sparkline, lowest to highest
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Posts notifications to a Discord channel webhook as rich embeds
+type DiscordNotifier struct {
+	url      string
+	username string
+	client   *http.Client
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+	Footer      *struct {
+		Text string `json:"text"`
+	} `json:"footer,omitempty"`
+}
+
+type discordPayload struct {
+	Username string         `json:"username,omitempty"`
+	Embeds   []discordEmbed `json:"embeds"`
+}
+
+// Create the notifier from DISCORD_WEBHOOK_URL; nil when unset
+func NewDiscordNotifier() (*DiscordNotifier, error) {
+	url := os.Getenv("DISCORD_WEBHOOK_URL")
+	if url == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("DISCORD_WEBHOOK_URL must be an https URL")
+	}
+	return &DiscordNotifier{
+		url:      url,
+		username: GetEnvString("DISCORD_USERNAME", "Traefik Log Dashboard"),
+		client:   &http.Client{},
+	}, nil
+}
+
+func (d *DiscordNotifier) Name() string {
+	return "discord"
+}
+
+func (d *DiscordNotifier) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(discordPayload{
+		Username: d.username,
+		Embeds:   []discordEmbed{buildDiscordEmbed(n)},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doNotifyRequest(d.client, req)
+}
+
+func buildDiscordEmbed(n Notification) discordEmbed {
+	embed := discordEmbed{
+		Title:       n.Title,
+		Description: n.Message,
+		Color:       discordColors[n.Severity],
+		Timestamp:   n.Timestamp,
+	}
+	embed.Footer = &struct {
+		Text string `json:"text"`
+	}{Text: fmt.Sprintf("%s · %s", n.Event, n.Severity)}
+
+	if n.Service != "" {
+		embed.Fields = append(embed.Fields, discordEmbedField{Name: "Service", Value: n.Service, Inline: true})
+	}
+	if n.Tenant != "" {
+		embed.Fields = append(embed.Fields, discordEmbedField{Name: "Tenant", Value: n.Tenant, Inline: true})
+	}
+	if rate, ok := n.Details["errorRate"]; ok {
+		embed.Fields = append(embed.Fields,
+			discordEmbedField{Name: "Error rate", Value: fmt.Sprintf("%v%% (threshold %v%%)", rate, n.Details["threshold"]), Inline: true},
+			discordEmbedField{Name: "Requests", Value: fmt.Sprintf("%v (%v errors)", n.Details["requests"], n.Details["errors"]), Inline: true},
+		)
+	}
+	if series, ok := n.Details["errorRateSeries"].([]float64); ok && len(series) > 0 {
+		embed.Fields = append(embed.Fields, discordEmbedField{
+			Name:  fmt.Sprintf("Error rate per minute (last %d min)", len(series)),
+			Value: fmt.Sprintf("`%s` %s", sparkline(series), summarizeSeries(series)),
+		})
+	}
+	if paths, ok := n.Details["topPaths"].([]PathErrorCount); ok && len(paths) > 0 {
+		lines := make([]string, len(paths))
+		for i, p := range paths {
+			lines[i] = fmt.Sprintf("`%s` — %d errors", truncate(p.Path, 80), p.Errors)
+		}
+		embed.Fields = append(embed.Fields, discordEmbedField{Name: "Top offending paths", Value: strings.Join(lines, "\n")})
+	}
+
+	// System events carry a few flat details; show them as-is
+	if n.Kind == NOTIFY_KIND_SYSTEM {
+		for _, key := range []string{"file", "path", "usedPercent", "missingSince"} {
+			if value, ok := n.Details[key]; ok {
+				embed.Fields = append(embed.Fields, discordEmbedField{Name: key, Value: fmt.Sprint(value), Inline: true})
+			}
+		}
+	}
+
+	if embed.Timestamp == "" {
+		embed.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	}
+	return embed
+}
+
+// Draw values as a unicode block sparkline scaled to 0-100%
+func sparkline(values []float64) string {
+	var sb strings.Builder
+	for _, v := range values {
+		index := int(v / 100 * float64(len(sparkBlocks)-1))
+		if index < 0 {
+			index = 0
+		}
+		if index >= len(sparkBlocks) {
+			index = len(sparkBlocks) - 1
+		}
+		sb.WriteRune(sparkBlocks[index])
+	}
+	return sb.String()
+}
+
+// Summarize a series as its latest and peak values
+func summarizeSeries(values []float64) string {
+	peak := 0.0
+	for _, v := range values {
+		if v > peak {
+			peak = v
+		}
+	}
+	return fmt.Sprintf("now %g%%, peak %g%%", values[len(values)-1], peak)
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// Get how full the filesystem holding path is, as df reports it (space
+// reserved for root counts as unavailable)
+func diskUsagePercent(path string) (float64, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return 0, err
+	}
+	used := fs.Blocks - fs.Bfree
+	total := used + fs.Bavail
+	if total == 0 {
+		return 0, nil
+	}
+	return float64(used) / float64(total) * 100, nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import "golang.org/x/sys/windows"
+
+// Get how full the volume holding path is; space beyond the caller's quota
+// counts as unavailable, like the reserved blocks on Unix
+func diskUsagePercent(path string) (float64, error) {
+	dir, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var available, totalBytes, free uint64
+	if err := windows.GetDiskFreeSpaceEx(dir, &available, &totalBytes, &free); err != nil {
+		return 0, err
+	}
+	used := totalBytes - free
+	total := used + available
+	if total == 0 {
+		return 0, nil
+	}
+	return float64(used) / float64(total) * 100, nil
+}
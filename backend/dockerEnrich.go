@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DEFAULT_DOCKER_HOST          = "unix:///var/run/docker.sock"
+	DEFAULT_DOCKER_POLL_INTERVAL = 30 * time.Second
+	DEFAULT_DOCKER_LABELS        = "com.docker.compose.*,org.opencontainers.image.*"
+
+	labelComposeProject = "com.docker.compose.project"
+	labelComposeService = "com.docker.compose.service"
+)
+
+// Traefik's docker provider turns names into service and router names by
+// replacing anything but letters and digits with "-"
+var dockerNameNormalizer = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// What the dashboard shows about a container serving a Traefik service
+type ContainerInfo struct {
+	ID             string            `json:"id"` // Short ID
+	Name           string            `json:"name"`
+	Image          string            `json:"image"`
+	State          string            `json:"state"`  // running, exited, ...
+	Status         string            `json:"status"` // e.g. "Up 2 hours (healthy)"
+	ComposeProject string            `json:"composeProject,omitempty"`
+	ComposeService string            `json:"composeService,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"` // Those matching DOCKER_LABELS
+}
+
+// The fields of Docker's GET /containers/json the enricher reads
+type dockerContainer struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Image  string            `json:"Image"`
+	State  string            `json:"State"`
+	Status string            `json:"Status"`
+	Labels map[string]string `json:"Labels"`
+}
+
+type dockerState struct {
+	services  map[string][]ContainerInfo // Traefik service name (name@docker) to its containers
+	syncedAt  time.Time
+	lastError string
+}
+
+// Maps Traefik services to the containers behind them by polling the Docker
+// API, so service stats can say which container and compose stack they
+// belong to. Services come from traefik.http.services.<name> labels, or
+// the names Traefik derives when there are none (<service>-<project> for
+// compose containers, the container name otherwise).
+type DockerEnricher struct {
+	host     string // DOCKER_HOST
+	baseURL  string
+	labels   []string // Label patterns exposed on containers
+	interval time.Duration
+	client   *http.Client
+	stop     chan struct{}
+	state    atomic.Pointer[dockerState]
+}
+
+var dockerEnricher *DockerEnricher
+
+// Create the enricher when DOCKER_ENRICHMENT_ENABLED is set. DOCKER_HOST is
+// a unix socket (the default) or a tcp:// or http(s):// address, e.g. a
+// read-only socket proxy.
+func NewDockerEnricher() (*DockerEnricher, error) {
+	if !GetEnvBool("DOCKER_ENRICHMENT_ENABLED", false) {
+		return nil, nil
+	}
+	e := &DockerEnricher{
+		host:     GetEnvString("DOCKER_HOST", DEFAULT_DOCKER_HOST),
+		interval: time.Duration(GetEnvInt("DOCKER_POLL_INTERVAL_SECONDS", int(DEFAULT_DOCKER_POLL_INTERVAL/time.Second))) * time.Second,
+		stop:     make(chan struct{}),
+	}
+	if e.interval < 5*time.Second {
+		return nil, fmt.Errorf("DOCKER_POLL_INTERVAL_SECONDS must be at least 5")
+	}
+	for _, pattern := range strings.Split(GetEnvString("DOCKER_LABELS", DEFAULT_DOCKER_LABELS), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			e.labels = append(e.labels, pattern)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	switch scheme, address, _ := strings.Cut(e.host, "://"); scheme {
+	case "unix":
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", address)
+		}
+		e.baseURL = "http://docker"
+	case "tcp":
+		e.baseURL = "http://" + address
+	case "http", "https":
+		e.baseURL = strings.TrimRight(e.host, "/")
+	default:
+		return nil, fmt.Errorf("DOCKER_HOST must be a unix://, tcp:// or http(s):// address")
+	}
+	e.client = &http.Client{Timeout: 10 * time.Second, Transport: transport}
+	e.state.Store(&dockerState{services: map[string][]ContainerInfo{}})
+	return e, nil
+}
+
+func (e *DockerEnricher) Start() {
+	host := e.host
+	if !strings.HasPrefix(host, "unix://") {
+		host = redactURL(e.baseURL)
+	}
+	appLog.Info("Matching services to Docker containers", "host", host, "interval", e.interval)
+	go func() {
+		e.sync()
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.sync()
+			case <-e.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (e *DockerEnricher) Stop() {
+	close(e.stop)
+}
+
+func (e *DockerEnricher) sync() {
+	containers, err := e.listContainers()
+	if err != nil {
+		// Keep the last mapping
+		previous := *e.state.Load()
+		if previous.lastError == "" {
+			appLog.Warn("Docker sync failed", "error", err)
+		}
+		previous.lastError = err.Error()
+		e.state.Store(&previous)
+		return
+	}
+
+	state := &dockerState{services: make(map[string][]ContainerInfo), syncedAt: time.Now()}
+	for _, container := range containers {
+		info := e.containerInfo(container)
+		for _, service := range traefikServicesOf(container) {
+			state.services[service] = append(state.services[service], info)
+		}
+	}
+	if e.state.Load().lastError != "" {
+		appLog.Info("Docker sync recovered", "services", len(state.services))
+	}
+	e.state.Store(state)
+}
+
+func (e *DockerEnricher) listContainers() ([]dockerContainer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// all=1: stopped containers still explain the traffic they served
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.baseURL+"/containers/json?all=1", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	var containers []dockerContainer
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
+
+func (e *DockerEnricher) containerInfo(container dockerContainer) ContainerInfo {
+	info := ContainerInfo{
+		ID:             container.ID,
+		Image:          container.Image,
+		State:          container.State,
+		Status:         container.Status,
+		ComposeProject: container.Labels[labelComposeProject],
+		ComposeService: container.Labels[labelComposeService],
+	}
+	if len(info.ID) > 12 {
+		info.ID = info.ID[:12]
+	}
+	if len(container.Names) > 0 {
+		info.Name = strings.TrimPrefix(container.Names[0], "/")
+	}
+	for key, value := range container.Labels {
+		for _, pattern := range e.labels {
+			if matchPattern(pattern, key) {
+				if info.Labels == nil {
+					info.Labels = make(map[string]string)
+				}
+				info.Labels[key] = value
+				break
+			}
+		}
+	}
+	return info
+}
+
+// The Traefik service names a container serves, as they appear in the
+// access log
+func traefikServicesOf(container dockerContainer) []string {
+	if container.Labels["traefik.enable"] == "false" {
+		return nil
+	}
+	var services []string
+	seen := make(map[string]bool)
+	for key := range container.Labels {
+		rest, ok := strings.CutPrefix(key, "traefik.http.services.")
+		if !ok {
+			continue
+		}
+		if name, _, ok := strings.Cut(rest, "."); ok && !seen[name] {
+			seen[name] = true
+			services = append(services, name+"@docker")
+		}
+	}
+	if len(services) > 0 {
+		return services
+	}
+
+	// Without service labels Traefik names the service after the container
+	name := ""
+	if len(container.Names) > 0 {
+		name = strings.TrimPrefix(container.Names[0], "/")
+	}
+	if service, project := container.Labels[labelComposeService], container.Labels[labelComposeProject]; service != "" && project != "" {
+		name = service + "_" + project
+	}
+	if name == "" {
+		return nil
+	}
+	return []string{strings.Trim(dockerNameNormalizer.ReplaceAllString(name, "-"), "-") + "@docker"}
+}
+
+// A Traefik service's buffered traffic and the containers behind it
+type DockerServiceView struct {
+	Service        string          `json:"service"`
+	DisplayName    string          `json:"displayName,omitempty"` // Alias from SERVICE_ALIASES
+	ComposeProject string          `json:"composeProject,omitempty"`
+	Requests       int             `json:"requests"`
+	Errors5xx      int             `json:"errors5xx"`
+	AvgResponseMs  float64         `json:"avgResponseTime"`
+	Containers     []ContainerInfo `json:"containers"`
+}
+
+// All services of one compose project (or of none)
+type DockerStackView struct {
+	Project       string              `json:"project"` // Empty for containers outside compose
+	Requests      int                 `json:"requests"`
+	Errors5xx     int                 `json:"errors5xx"`
+	AvgResponseMs float64             `json:"avgResponseTime"`
+	Services      []DockerServiceView `json:"services"`
+}
+
+// Buffered requests per service by original name, with their display name
+func bufferedServiceStats() map[string]*DockerServiceView {
+	services := make(map[string]*DockerServiceView)
+	totals := make(map[string]float64)
+	logParser.eachMatch(logParser.logsSnapshot.Load(), Filters{}, math.MaxUint64, func(entry *LogEntry) bool {
+		name := entry.ServiceName
+		if entry.ServiceRaw != "" {
+			name = entry.ServiceRaw
+		}
+		view := services[name]
+		if view == nil {
+			view = &DockerServiceView{Service: name}
+			if entry.ServiceRaw != "" {
+				view.DisplayName = entry.ServiceName
+			}
+			services[name] = view
+		}
+		view.Requests++
+		if entry.Status >= 500 {
+			view.Errors5xx++
+		}
+		totals[name] += entry.ResponseTime
+		return true
+	})
+	for name, view := range services {
+		view.AvgResponseMs = math.Round(totals[name]/float64(view.Requests)*100) / 100
+	}
+	return services
+}
+
+// Every service with containers, plus services with traffic but none found,
+// by name; matching is case-insensitive like Traefik's names
+func (state *dockerState) serviceViews() []DockerServiceView {
+	traffic := bufferedServiceStats()
+	byLower := make(map[string]string, len(traffic))
+	for name := range traffic {
+		byLower[strings.ToLower(name)] = name
+	}
+	views := make([]DockerServiceView, 0, len(state.services))
+	matched := make(map[string]bool)
+	for name, containers := range state.services {
+		view := DockerServiceView{Service: name}
+		if trafficName, ok := byLower[strings.ToLower(name)]; ok {
+			view = *traffic[trafficName]
+			matched[trafficName] = true
+		}
+		view.Containers = containers
+		view.ComposeProject = containers[0].ComposeProject
+		views = append(views, view)
+	}
+	for name, stats := range traffic {
+		if !matched[name] && strings.HasSuffix(name, "@docker") {
+			stats.Containers = []ContainerInfo{}
+			views = append(views, *stats)
+		}
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].Service < views[j].Service })
+	return views
+}
+
+// The synced state, or nil after writing an error when enrichment is off
+func dockerStateFor(c *gin.Context) *dockerState {
+	if dockerEnricher == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Docker enrichment is not enabled (DOCKER_ENRICHMENT_ENABLED)"})
+		return nil
+	}
+	return dockerEnricher.state.Load()
+}
+
+func (state *dockerState) meta() gin.H {
+	meta := gin.H{"syncedAt": nil}
+	if !state.syncedAt.IsZero() {
+		meta["syncedAt"] = state.syncedAt.Format(time.RFC3339)
+	}
+	if state.lastError != "" {
+		meta["error"] = state.lastError
+	}
+	return meta
+}
+
+// Handler for /api/docker/services
+func getDockerServices(c *gin.Context) {
+	state := dockerStateFor(c)
+	if state == nil {
+		return
+	}
+	response := state.meta()
+	response["services"] = state.serviceViews()
+	c.JSON(http.StatusOK, response)
+}
+
+// Handler for /api/docker/stacks
+func getDockerStacks(c *gin.Context) {
+	state := dockerStateFor(c)
+	if state == nil {
+		return
+	}
+	byProject := make(map[string]*DockerStackView)
+	totals := make(map[string]float64)
+	for _, service := range state.serviceViews() {
+		stack := byProject[service.ComposeProject]
+		if stack == nil {
+			stack = &DockerStackView{Project: service.ComposeProject}
+			byProject[service.ComposeProject] = stack
+		}
+		stack.Services = append(stack.Services, service)
+		stack.Requests += service.Requests
+		stack.Errors5xx += service.Errors5xx
+		totals[service.ComposeProject] += service.AvgResponseMs * float64(service.Requests)
+	}
+	stacks := make([]DockerStackView, 0, len(byProject))
+	for project, stack := range byProject {
+		if stack.Requests > 0 {
+			stack.AvgResponseMs = math.Round(totals[project]/float64(stack.Requests)*100) / 100
+		}
+		stacks = append(stacks, *stack)
+	}
+	sort.Slice(stacks, func(i, j int) bool {
+		if stacks[i].Requests != stacks[j].Requests {
+			return stacks[i].Requests > stacks[j].Requests
+		}
+		return stacks[i].Project < stacks[j].Project
+	})
+
+	response := state.meta()
+	response["stacks"] = stacks
+	c.JSON(http.StatusOK, response)
+}
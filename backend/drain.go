@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DEFAULT_DRAIN_TIMEOUT = 25 * time.Second // Inside Kubernetes' default 30s grace period
+	DRAIN_CHECK_INTERVAL  = 50 * time.Millisecond
+)
+
+// Draining stops taking new clients and input, lets what was already read
+// reach clients and forwarders, then exits. SIGTERM drains unless
+// DRAIN_ON_SIGTERM=false, so rolling updates don't lose the tail of the logs.
+var (
+	draining      atomic.Bool
+	drainOnce     sync.Once
+	drainDeadline time.Time
+	drainDone     = make(chan struct{}) // Closed when clients are gone; serve then shuts down
+)
+
+type DrainConfig struct {
+	OnSIGTERM bool
+	Timeout   time.Duration
+}
+
+func GetDrainConfig() DrainConfig {
+	return DrainConfig{
+		OnSIGTERM: GetEnvBool("DRAIN_ON_SIGTERM", true),
+		Timeout:   time.Duration(GetEnvInt("DRAIN_TIMEOUT_SECONDS", int(DEFAULT_DRAIN_TIMEOUT/time.Second))) * time.Second,
+	}
+}
+
+func isDraining() bool {
+	return draining.Load()
+}
+
+// Start draining in the background; false if already draining
+func StartDrain(reason string) bool {
+	started := false
+	drainOnce.Do(func() {
+		started = true
+		drainDeadline = time.Now().Add(GetDrainConfig().Timeout)
+		draining.Store(true)
+		go drain(reason)
+	})
+	return started
+}
+
+func drain(reason string) {
+	appLog.Info("Draining", "reason", reason, "deadline", drainDeadline.Format(time.RFC3339))
+	sdNotify("STOPPING=1\nSTATUS=Draining")
+
+	wsClientsMux.RLock()
+	clients := make([]*WebSocketClient, 0, len(wsClients))
+	for client := range wsClients {
+		clients = append(clients, client)
+	}
+	wsClientsMux.RUnlock()
+
+	// Sent to every client regardless of subscriptions, so they know to
+	// reconnect elsewhere rather than report an error
+	for _, client := range clients {
+		client.sendMessage(WebSocketMessage{
+			Type: "draining",
+			Data: gin.H{
+				"reason":   reason,
+				"deadline": drainDeadline.UTC().Format(time.RFC3339),
+			},
+		})
+	}
+
+	// Stop taking input, parsing whatever the sources already hold
+	if otlpReceiver != nil {
+		otlpReceiver.Stop()
+	}
+	if logParser != nil {
+		logParser.DrainSources()
+	}
+
+	// Give clients the tail before closing them
+	for time.Now().Before(drainDeadline) && clientsPending(clients) > 0 {
+		time.Sleep(DRAIN_CHECK_INTERVAL)
+	}
+	if pending := clientsPending(clients); pending > 0 {
+		wsLog.Warn("Drain deadline reached with messages still queued for clients", "pending", pending)
+	}
+	for _, client := range clients {
+		client.GoAway("server draining")
+	}
+	wsLog.Info("Closed clients for drain", "clients", len(clients))
+
+	close(drainDone)
+}
+
+func clientsPending(clients []*WebSocketClient) int {
+	pending := 0
+	for _, client := range clients {
+		if client.IsHealthy() {
+			pending += client.pending()
+		}
+	}
+	return pending
+}
+
+// Flush forwarders and notifications once the server is down; cleanup
+// stops the forwarders, which send what they still hold
+func finishDrain() {
+	cleanup()
+	timeout := time.Until(drainDeadline)
+	if timeout < time.Second {
+		timeout = time.Second
+	}
+	if !flushNotifications(timeout) {
+		appLog.Warn("Drain deadline reached with notifications still queued")
+	}
+	appLog.Info("Drain complete")
+}
+
+func drainServer(c *gin.Context) {
+	if !StartDrain("admin API") {
+		c.JSON(http.StatusConflict, gin.H{"error": "Already draining"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"draining": true,
+		"deadline": drainDeadline.UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Drops entries matching every condition it sets, e.g.
+// {"name":"probes","path":"^/(ping|healthz)$","userAgent":"kube-probe"}.
+// Patterns are regular expressions matched anywhere in the field.
+type DropRule struct {
+	Name      string   `json:"name,omitempty"`
+	Path      string   `json:"path,omitempty"`
+	UserAgent string   `json:"userAgent,omitempty"`
+	Router    string   `json:"router,omitempty"`
+	Status    []int    `json:"status,omitempty"`
+	ClientIPs []string `json:"clientIPs,omitempty"` // Addresses or CIDRs
+}
+
+type compiledDropRule struct {
+	name      string
+	path      *regexp.Regexp
+	userAgent *regexp.Regexp
+	router    *regexp.Regexp
+	status    map[int]bool
+	clients   []netip.Prefix
+}
+
+func compileDropRules(rules []DropRule) ([]compiledDropRule, error) {
+	compiled := make([]compiledDropRule, 0, len(rules))
+	for i, rule := range rules {
+		name := rule.Name
+		if name == "" {
+			name = "rule " + strconv.Itoa(i+1)
+		}
+		c := compiledDropRule{name: name}
+
+		var err error
+		if c.path, err = compileDropPattern(rule.Path); err != nil {
+			return nil, fmt.Errorf("%s: invalid path pattern: %w", name, err)
+		}
+		if c.userAgent, err = compileDropPattern(rule.UserAgent); err != nil {
+			return nil, fmt.Errorf("%s: invalid userAgent pattern: %w", name, err)
+		}
+		if c.router, err = compileDropPattern(rule.Router); err != nil {
+			return nil, fmt.Errorf("%s: invalid router pattern: %w", name, err)
+		}
+		if len(rule.Status) > 0 {
+			c.status = make(map[int]bool, len(rule.Status))
+			for _, status := range rule.Status {
+				if status < 100 || status > 599 {
+					return nil, fmt.Errorf("%s: invalid status %d", name, status)
+				}
+				c.status[status] = true
+			}
+		}
+		for _, value := range rule.ClientIPs {
+			prefix, err := parseDropPrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			c.clients = append(c.clients, prefix)
+		}
+
+		if c.path == nil && c.userAgent == nil && c.router == nil && c.status == nil && c.clients == nil {
+			return nil, fmt.Errorf("%s has no conditions and would drop everything", name)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+func compileDropPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+// An address or CIDR; bare addresses match only themselves
+func parseDropPrefix(value string) (netip.Prefix, error) {
+	value = strings.TrimSpace(value)
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", value)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid address %q", value)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func (r *compiledDropRule) matches(entry *LogEntry) bool {
+	if r.status != nil && !r.status[entry.Status] {
+		return false
+	}
+	if r.clients != nil && !prefixesContain(r.clients, entry.clientAddr) {
+		return false
+	}
+	if r.path != nil && !r.path.MatchString(entry.Path) {
+		return false
+	}
+	if r.router != nil && !r.router.MatchString(entry.RouterName) {
+		return false
+	}
+	if r.userAgent != nil && !r.userAgent.MatchString(entry.UserAgent) {
+		return false
+	}
+	return true
+}
+
+func prefixesContain(prefixes []netip.Prefix, addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
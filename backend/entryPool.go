@@ -0,0 +1,45 @@
+package main
+
+import "sync"
+
+// Decoded lines don't need storage of their own: LogEntry values are copied
+// into the ring and to listeners, and RawLogEntry only lives until the entry
+// is built. Both are recycled, along with the byte copy of the line, so
+// steady ingestion doesn't keep the GC busy with short-lived garbage.
+
+const MAX_POOLED_LINE_BYTES = 64 << 10 // Longer line buffers are left to the GC
+
+type decodeScratch struct {
+	raw  RawLogEntry
+	line []byte
+}
+
+var (
+	decodeScratchPool = sync.Pool{New: func() any { return new(decodeScratch) }}
+	logEntryPool      = sync.Pool{New: func() any { return new(LogEntry) }}
+)
+
+// Get scratch space holding a copy of line and an empty RawLogEntry
+func getDecodeScratch(line string) *decodeScratch {
+	scratch := decodeScratchPool.Get().(*decodeScratch)
+	scratch.line = append(scratch.line[:0], line...)
+	return scratch
+}
+
+func putDecodeScratch(scratch *decodeScratch) {
+	if cap(scratch.line) > MAX_POOLED_LINE_BYTES {
+		return
+	}
+	scratch.raw = RawLogEntry{}
+	decodeScratchPool.Put(scratch)
+}
+
+func getLogEntry() *LogEntry {
+	return logEntryPool.Get().(*LogEntry)
+}
+
+// Return an entry once nothing refers to it any more
+func putLogEntry(entry *LogEntry) {
+	*entry = LogEntry{}
+	logEntryPool.Put(entry)
+}
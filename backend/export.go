@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Columns written to CSV exports when no fields are requested
+var defaultExportFields = []string{
+	"id", "timestamp", "clientIP", "method", "path", "status", "responseTime",
+	"serviceName", "routerName", "host", "size", "country", "userAgent",
+}
+
+const EXPORT_BATCH_SIZE = 500
+
+// Call fn with consecutive batches of the log entries matching the filters,
+// newest first. The batches come from one snapshot of the logs, so a large
+// export neither blocks ingestion nor needs every match in memory.
+func (lp *LogParser) EachFilteredLogs(filters Filters, fn func(batch []LogEntry) error) error {
+	var err error
+	batch := make([]LogEntry, 0, EXPORT_BATCH_SIZE)
+	lp.eachMatch(lp.logsSnapshot.Load(), filters, math.MaxUint64, func(entry *LogEntry) bool {
+		if batch = append(batch, *entry); len(batch) == EXPORT_BATCH_SIZE {
+			err = fn(batch)
+			batch = batch[:0]
+		}
+		return err == nil
+	})
+	if err == nil && len(batch) > 0 {
+		err = fn(batch)
+	}
+	return err
+}
+
+// Stream all matching logs as NDJSON or CSV. Requests carrying a signed
+// export ticket use the ticket's pinned query and tenant instead of their own.
+func exportLogs(c *gin.Context) {
+	if ticket := requestTicket(c); ticket != "" {
+		claims, err := ticketSigner.Verify(ticket, TICKET_SCOPE_EXPORT)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request.URL.RawQuery = claims.Query
+		if claims.Tenant != "" {
+			c.Set(TENANT_KEY, claims.Tenant)
+		}
+	}
+
+	format := c.DefaultQuery("format", "ndjson")
+	if format != "ndjson" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be ndjson or csv"})
+		return
+	}
+
+	filters := parseFilters(c)
+	if err := filters.Compile(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var fields []string
+	if f := c.Query("fields"); f != "" {
+		var err error
+		if fields, err = parseLogFields(f); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	filename := fmt.Sprintf("traefik-logs-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	var err error
+	if format == "csv" {
+		if fields == nil {
+			fields = defaultExportFields
+		}
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		err = writeCSVExport(c.Writer, filters, fields)
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		err = writeNDJSONExport(c.Writer, filters, fields)
+	}
+	if err != nil {
+		appLog.Warn("Export aborted", "client", c.ClientIP(), "error", err)
+	}
+}
+
+const LOGS_RESPONSE_FLUSH_EVERY = 500
+
+// Write a page of logs as the same JSON object LogsResult marshals to, one
+// entry at a time, so large limits never hold the whole body in memory
+func writeLogsResult(c *gin.Context, result LogsResult, fields []string) error {
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	buf := bufio.NewWriter(c.Writer)
+	encoder := json.NewEncoder(buf)
+	buf.WriteString(`{"logs":[`)
+	for i := range result.Logs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		var err error
+		if fields == nil {
+			err = encoder.Encode(&result.Logs[i])
+		} else {
+			err = encoder.Encode(selectLogFields(result.Logs[i:i+1], fields)[0])
+		}
+		if err != nil {
+			return err
+		}
+		if (i+1)%LOGS_RESPONSE_FLUSH_EVERY == 0 {
+			if err := buf.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+	}
+	fmt.Fprintf(buf, `],"total":%d,"page":%d,"totalPages":%d}`, result.Total, result.Page, result.TotalPages)
+	return buf.Flush()
+}
+
+func writeNDJSONExport(w gin.ResponseWriter, filters Filters, fields []string) error {
+	buf := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buf)
+	return logParser.EachFilteredLogs(filters, func(logs []LogEntry) error {
+		for i := range logs {
+			var err error
+			if fields == nil {
+				err = encoder.Encode(&logs[i])
+			} else {
+				err = encoder.Encode(selectLogFields(logs[i:i+1], fields)[0])
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if err := buf.Flush(); err != nil {
+			return err
+		}
+		w.Flush()
+		return nil
+	})
+}
+
+func writeCSVExport(w gin.ResponseWriter, filters Filters, fields []string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(fields); err != nil {
+		return err
+	}
+
+	row := make([]string, len(fields))
+	err := logParser.EachFilteredLogs(filters, func(logs []LogEntry) error {
+		for i := range logs {
+			v := reflect.ValueOf(&logs[i]).Elem()
+			for j, field := range fields {
+				row[j] = csvValue(v.Field(logEntryFieldIndex[field]))
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+		w.Flush()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Format a field for CSV, leaving nil pointers (e.g. unresolved geo) empty
+func csvValue(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Map of LogEntry JSON field names to struct field indexes, built once at startup
+var logEntryFieldIndex = buildLogEntryFieldIndex()
+
+func buildLogEntryFieldIndex() map[string]int {
+	index := make(map[string]int)
+	t := reflect.TypeOf(LogEntry{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		index[name] = i
+	}
+	return index
+}
+
+// Parse a comma-separated fields parameter, rejecting unknown LogEntry fields
+func parseLogFields(param string) ([]string, error) {
+	var fields []string
+	seen := make(map[string]bool)
+
+	// Always include the ID so clients can key rows
+	fields = append(fields, "id")
+	seen["id"] = true
+
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" || seen[field] {
+			continue
+		}
+		if _, ok := logEntryFieldIndex[field]; !ok {
+			return nil, fmt.Errorf("unknown log field: %s", field)
+		}
+		fields = append(fields, field)
+		seen[field] = true
+	}
+	return fields, nil
+}
+
+// Project log entries down to the requested JSON fields
+func selectLogFields(logs []LogEntry, fields []string) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(logs))
+	for i := range logs {
+		v := reflect.ValueOf(&logs[i]).Elem()
+		entry := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			entry[field] = v.Field(logEntryFieldIndex[field]).Interface()
+		}
+		result[i] = entry
+	}
+	return result
+}
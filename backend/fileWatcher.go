@@ -2,10 +2,11 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,17 +15,61 @@ import (
 
 type FileWatcher struct {
 	filePath      string
+	target        string          // Real file behind filePath when it is a symlink
+	watchedDirs   map[string]bool // Directories added to the fsnotify watcher
 	file          *os.File
+	fileInfo      os.FileInfo // Identity (device/inode) of the open file
 	reader        *bufio.Reader
-	lastPos       int64
+	lastPos       int64  // Bytes consumed from the file, including partial
+	partial       string // Trailing line still waiting for its newline
+	discarding    bool   // Skipping the rest of a line over MAX_LOG_LINE_BYTES
 	lastSize      int64
 	parser        *LogParser
 	watcher       *fsnotify.Watcher
 	stopChan      chan struct{}
 	running       bool
 	mu            sync.Mutex
+	checkMu       sync.Mutex // Serializes checks from the fsnotify and poll loops
 	checkInterval time.Duration
 	isInitialLoad bool
+	fromGlob      bool // Matched by a glob source; detached when deleted
+
+	fingerprint     []byte // First bytes of the open file, to spot in-place replacements
+	fingerprintSize int
+	lastModTime     time.Time
+
+	// Ingestion counters for the sources status API
+	lastRead    time.Time
+	linesParsed uint64
+	parseErrors uint64
+}
+
+const (
+	DEFAULT_LOG_POLL_INTERVAL     = 1 * time.Second
+	DEFAULT_LOG_FINGERPRINT_BYTES = 1024
+	MAX_LOG_LINE_BYTES            = 1024 * 1024 // Longer lines are discarded, as when loading files
+)
+
+// Check whether a source should be polled without fsnotify, from
+// LOG_POLL_ONLY_SOURCES: paths, wildcard patterns or directories ending in
+// "/" ("*" for every source). inotify events never fire on NFS/SMB mounts.
+func pollOnlySource(filePath string) bool {
+	for _, pattern := range strings.Split(os.Getenv("LOG_POLL_ONLY_SOURCES"), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" && matchSourcePattern(pattern, filePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match a source against a path, wildcard pattern or directory ending in
+// "/", comparing paths the way the platform does
+func matchSourcePattern(pattern, source string) bool {
+	pattern, source = normalizePath(pattern), normalizePath(source)
+	if strings.HasSuffix(pattern, "/") && strings.HasPrefix(source, pattern) {
+		return true
+	}
+	return matchPattern(pattern, source)
 }
 
 func NewFileWatcher(filePath string, parser *LogParser) (*FileWatcher, error) {
@@ -34,12 +79,25 @@ func NewFileWatcher(filePath string, parser *LogParser) (*FileWatcher, error) {
 		stopChan:      make(chan struct{}),
 		checkInterval: 1 * time.Second,
 		isInitialLoad: true,
+		watchedDirs:   make(map[string]bool),
+	}
+	fw.fingerprintSize = GetEnvInt("LOG_FINGERPRINT_BYTES", DEFAULT_LOG_FINGERPRINT_BYTES)
+
+	if pollOnlySource(filePath) {
+		fw.checkInterval = time.Duration(GetEnvInt("LOG_POLL_INTERVAL_MS", int(DEFAULT_LOG_POLL_INTERVAL/time.Millisecond))) * time.Millisecond
+		if fw.checkInterval < 100*time.Millisecond {
+			fw.checkInterval = DEFAULT_LOG_POLL_INTERVAL
+		}
+		watcherLog.Info("Polling file, fsnotify disabled", "file", filePath, "interval", fw.checkInterval)
+		return fw, nil
 	}
 
 	// Create fsnotify watcher
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		return nil, err
+		// Out of inotify instances; polling still picks up every change
+		watcherLog.Warn("fsnotify unavailable, falling back to polling", "file", filePath, "error", err)
+		return fw, nil
 	}
 	fw.watcher = watcher
 
@@ -49,10 +107,70 @@ func NewFileWatcher(filePath string, parser *LogParser) (*FileWatcher, error) {
 		watcher.Close()
 		return nil, err
 	}
+	fw.watchedDirs[dir] = true
+	fw.followTarget()
 
 	return fw, nil
 }
 
+// Resolve a log path through symlinks, or return it unchanged if it can't be
+func resolveLogPath(path string) string {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		return target
+	}
+	return filepath.Clean(path)
+}
+
+// Re-resolve filePath through symlinks (logrotate "latest" links, Kubernetes
+// /var/log/containers) and watch the real file's directory, where its
+// rotation happens. Called on every check so a retargeted link is followed.
+func (fw *FileWatcher) followTarget() {
+	target := resolveLogPath(fw.filePath)
+
+	fw.mu.Lock()
+	previous := fw.target
+	fw.target = target
+	fw.mu.Unlock()
+	if target == previous {
+		return
+	}
+	if target != filepath.Clean(fw.filePath) {
+		watcherLog.Info("Following symlink", "file", fw.filePath, "target", target)
+	}
+	if fw.watcher == nil {
+		return
+	}
+
+	linkDir := filepath.Dir(fw.filePath)
+	dir := filepath.Dir(target)
+	if !fw.watchedDirs[dir] {
+		if err := fw.watcher.Add(dir); err != nil {
+			watcherLog.Error("Error watching directory", "dir", dir, "file", fw.filePath, "error", err)
+		} else {
+			fw.watchedDirs[dir] = true
+		}
+	}
+	if previous != "" {
+		if oldDir := filepath.Dir(previous); oldDir != dir && oldDir != linkDir && fw.watchedDirs[oldDir] {
+			fw.watcher.Remove(oldDir)
+			delete(fw.watchedDirs, oldDir)
+		}
+	}
+}
+
+// Check whether an fsnotify event concerns the link or the file behind it
+func (fw *FileWatcher) isOwnEvent(name string) bool {
+	name = normalizePath(filepath.Clean(name))
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return name == normalizePath(filepath.Clean(fw.filePath)) || name == normalizePath(fw.target)
+}
+
+// Whether changes are only found by polling
+func (fw *FileWatcher) PollOnly() bool {
+	return fw.watcher == nil
+}
+
 func (fw *FileWatcher) Start() error {
 	fw.mu.Lock()
 	if fw.running {
@@ -64,11 +182,13 @@ func (fw *FileWatcher) Start() error {
 
 	// Open file and seek to end
 	if err := fw.openFile(); err != nil {
-		log.Printf("Error opening file %s: %v", fw.filePath, err)
+		watcherLog.Error("Error opening file", "file", fw.filePath, "error", err)
 	}
 
 	// Start watching
-	go fw.watchLoop()
+	if fw.watcher != nil {
+		go fw.watchLoop()
+	}
 	go fw.pollLoop()
 
 	return nil
@@ -84,7 +204,9 @@ func (fw *FileWatcher) Stop() {
 	fw.mu.Unlock()
 
 	close(fw.stopChan)
-	fw.watcher.Close()
+	if fw.watcher != nil {
+		fw.watcher.Close()
+	}
 	
 	fw.mu.Lock()
 	if fw.file != nil {
@@ -94,6 +216,27 @@ func (fw *FileWatcher) Stop() {
 	fw.mu.Unlock()
 }
 
+// Parse everything written so far, then stop; used when draining so the
+// tail of the file isn't lost
+func (fw *FileWatcher) DrainAndStop() {
+	fw.checkMu.Lock()
+	defer fw.checkMu.Unlock()
+	fw.drain()
+	fw.Stop()
+}
+
+// Continue from offset once the history up to it was loaded, rather than
+// skipping to the end of the file; call before Start
+func (fw *FileWatcher) resumeAt(offset int64, fingerprint []byte) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.lastPos = offset
+	fw.fingerprint = fingerprint
+	fw.partial = ""
+	fw.discarding = false
+	fw.isInitialLoad = false
+}
+
 func (fw *FileWatcher) openFile() error {
 	fw.mu.Lock()
 	defer fw.mu.Unlock()
@@ -109,7 +252,7 @@ func (fw *FileWatcher) openFile() error {
 	info, err := os.Stat(fw.filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			log.Printf("File %s does not exist yet", fw.filePath)
+			watcherLog.Info("File does not exist yet", "file", fw.filePath)
 			fw.lastPos = 0
 			fw.lastSize = 0
 			return nil
@@ -118,22 +261,38 @@ func (fw *FileWatcher) openFile() error {
 	}
 
 	// Open file
-	file, err := os.Open(fw.filePath)
+	file, err := openSharedFile(fw.filePath)
 	if err != nil {
 		return err
 	}
+	info = currentFileInfo(info, file)
 
 	fw.file = file
 	fw.reader = bufio.NewReaderSize(file, 64*1024) // 64KB buffer
 	fw.lastSize = info.Size()
+	fw.lastModTime = info.ModTime()
+	if openInfo, err := file.Stat(); err == nil {
+		fw.fileInfo = openInfo
+	} else {
+		fw.fileInfo = info
+	}
+	head := fw.readFingerprint()
+	replaced := fw.lastPos > 0 && !fingerprintMatches(fw.fingerprint, head)
+	fw.fingerprint = head
 
 	// If this is a new file or the file was truncated, start from beginning
 	if fw.lastPos > info.Size() {
-		log.Printf("File %s was truncated, starting from beginning", fw.filePath)
+		watcherLog.Info("File was truncated, starting from beginning", "file", fw.filePath)
 		fw.lastPos = 0
-		fw.parser.ClearLogs()
+		fw.partial = ""
+		fw.discarding = false
+	} else if replaced {
+		watcherLog.Info("File was replaced by a different file, starting from beginning", "file", fw.filePath)
+		fw.lastPos = 0
+		fw.partial = ""
+		fw.discarding = false
 	} else if fw.isInitialLoad {
-		// Initial load is handled by loadRecentLogs in LogParser
+		// Initial load is handled by loadStartup in LogParser
 		// So we seek to end to only watch for new entries
 		fw.lastPos = info.Size()
 		file.Seek(fw.lastPos, io.SeekStart)
@@ -141,22 +300,26 @@ func (fw *FileWatcher) openFile() error {
 	} else if fw.lastPos == 0 {
 		// File was recreated, start from beginning
 		fw.lastPos = 0
+		fw.partial = ""
+		fw.discarding = false
 	} else {
 		// Resume from last position
 		file.Seek(fw.lastPos, io.SeekStart)
 	}
 
 	// Try to watch the file directly
-	fw.watcher.Add(fw.filePath)
+	if fw.watcher != nil {
+		fw.watcher.Add(fw.filePath)
+	}
 
 	return nil
 }
 
-func (fw *FileWatcher) readNewLines() {
+func (fw *FileWatcher) readNewLines() int {
 	fw.mu.Lock()
 	if fw.file == nil || fw.reader == nil {
 		fw.mu.Unlock()
-		return
+		return 0
 	}
 	
 	// Create local references to avoid holding lock during read
@@ -164,79 +327,226 @@ func (fw *FileWatcher) readNewLines() {
 	fw.mu.Unlock()
 
 	linesRead := 0
-	const maxLinesPerRead = 1000 // Limit lines per read to prevent memory issues
+	const maxLinesPerRead = PARSE_BATCH_SIZE // Limit lines per read to prevent memory issues
+	lines := make([]string, 0, 64)
 
 	for linesRead < maxLinesPerRead {
-		line, err := reader.ReadString('\n')
+		chunk, err := reader.ReadString('\n')
+
+		fw.mu.Lock()
+		fw.lastPos += int64(len(chunk))
+		if len(chunk) > 0 {
+			fw.lastRead = time.Now()
+		}
 		if err != nil {
+			// Writers may flush mid-line; keep the fragment until the rest
+			// arrives, unless the line is already too long to keep
+			if !fw.discarding {
+				fw.partial += chunk
+				if len(fw.partial) > MAX_LOG_LINE_BYTES {
+					fw.discardLongLineLocked(fw.partial, false)
+				}
+			}
+			fw.mu.Unlock()
 			if err != io.EOF {
-				log.Printf("Error reading from %s: %v", fw.filePath, err)
+				watcherLog.Error("Error reading file", "file", fw.filePath, "error", err)
 			}
 			break
 		}
+		line := fw.partial + chunk
+		fw.partial = ""
+		if fw.discarding {
+			// The newline ending a discarded line
+			fw.discarding = false
+			fw.mu.Unlock()
+			continue
+		}
+		if len(line) > MAX_LOG_LINE_BYTES {
+			fw.discardLongLineLocked(line, true)
+			fw.mu.Unlock()
+			continue
+		}
+		fw.mu.Unlock()
 
 		linesRead++
 
-		// Update position
-		fw.mu.Lock()
-		if fw.file != nil {
-			pos, _ := fw.file.Seek(0, io.SeekCurrent)
-			fw.lastPos = pos
+		// Windows writers end lines with \r\n
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			lines = append(lines, line)
 		}
-		fw.mu.Unlock()
+	}
+
+	// Parse the batch once read, so decoding can spread across the parse pool
+	fw.parse(lines...)
+
+	if linesRead >= maxLinesPerRead {
+		watcherLog.Debug("Read line limit reached, pausing to prevent memory issues", "file", fw.filePath, "lines", linesRead)
+	}
+	return linesRead
+}
+
+// Drop a line over MAX_LOG_LINE_BYTES and count it as a parse error; an
+// incomplete line's rest is skipped up to its newline. Caller holds fw.mu.
+func (fw *FileWatcher) discardLongLineLocked(line string, complete bool) {
+	fw.partial = ""
+	fw.discarding = !complete
+	fw.parseErrors++
+	watcherLog.Warn("Discarding overlong log line", "file", fw.filePath, "maxBytes", MAX_LOG_LINE_BYTES)
+	if sample, broadcast := parseErrorTracker.Record(fw.filePath, line, "line longer than 1 MiB"); broadcast {
+		broadcastParseError(sample)
+	}
+}
 
-		// Parse the line
-		if line != "" && line != "\n" {
-			fw.parser.parseLine(line, true)
+// Read the open file to its end, e.g. before switching to a rotated file.
+// Nothing more will be appended, so an unterminated last line is complete.
+func (fw *FileWatcher) drain() {
+	for fw.readNewLines() > 0 {
+	}
+
+	fw.mu.Lock()
+	line := fw.partial
+	fw.partial = ""
+	fw.mu.Unlock()
+	if strings.TrimSpace(line) != "" {
+		fw.parse(strings.TrimRight(line, "\r"))
+	}
+}
+
+func (fw *FileWatcher) parse(lines ...string) {
+	if len(lines) == 0 {
+		return
+	}
+	accepted := fw.parser.parseLines(fw.filePath, lines, true)
+	fw.mu.Lock()
+	fw.linesParsed += uint64(len(lines))
+	for _, ok := range accepted {
+		if !ok {
+			fw.parseErrors++
 		}
 	}
+	fw.mu.Unlock()
+}
 
-	if linesRead >= maxLinesPerRead {
-		log.Printf("Read %d lines, pausing to prevent memory issues", linesRead)
+// Read the first fingerprintSize bytes of the open file without moving the
+// read position; caller holds fw.mu
+func (fw *FileWatcher) readFingerprint() []byte {
+	if fw.file == nil || fw.fingerprintSize <= 0 {
+		return nil
 	}
+	head := make([]byte, fw.fingerprintSize)
+	n, _ := fw.file.ReadAt(head, 0)
+	return head[:n]
+}
+
+// Check whether two fingerprints agree on their common prefix; a file that
+// grew past a short fingerprint is still the same stream
+func fingerprintMatches(previous, current []byte) bool {
+	n := min(len(previous), len(current))
+	return bytes.Equal(previous[:n], current[:n])
+}
+
+// Release the open file so the next check opens whatever is at the path
+func (fw *FileWatcher) closeFile() {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.file != nil {
+		fw.file.Close()
+	}
+	fw.file = nil
+	fw.fileInfo = nil
+	fw.fingerprint = nil
+	fw.reader = nil
+	fw.lastPos = 0
+	fw.lastSize = 0
+	fw.partial = ""
+	fw.discarding = false
 }
 
 func (fw *FileWatcher) checkFile() {
+	fw.checkMu.Lock()
+	defer fw.checkMu.Unlock()
+
+	// A check queued behind DrainAndStop must not reopen the file
+	fw.mu.Lock()
+	running := fw.running
+	fw.mu.Unlock()
+	if !running {
+		return
+	}
+
+	fw.followTarget()
+
 	info, err := os.Stat(fw.filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			// File was deleted
+			// Renamed away by logrotate (or deleted). Traefik keeps writing to
+			// the old file until it reopens its log, so keep reading it until
+			// a replacement shows up.
 			fw.mu.Lock()
-			if fw.file != nil {
-				log.Printf("File %s was deleted", fw.filePath)
-				fw.file.Close()
-				fw.file = nil
-				fw.reader = nil
-				fw.lastPos = 0
-				fw.lastSize = 0
-			}
+			open := fw.file != nil
 			fw.mu.Unlock()
+			if open {
+				fw.drain()
+			}
+		} else if deletePending(err) {
+			// Deleted on Windows: the name is only freed, and a new log
+			// can only be created, once the handle is closed
+			fw.drain()
+			fw.closeFile()
 		}
 		return
 	}
 
 	fw.mu.Lock()
+	info = currentFileInfo(info, fw.file)
 	currentSize := info.Size()
 	
 	// File was recreated or appeared
 	if fw.file == nil {
 		fw.mu.Unlock()
-		log.Printf("File %s appeared/recreated, reloading", fw.filePath)
-		// Clear existing logs since file was recreated
-		fw.parser.ClearLogs()
+		watcherLog.Info("File appeared or was recreated, reading from the beginning", "file", fw.filePath)
+		// Entries already read from this and other sources are kept
+		fw.openFile()
+		fw.readNewLines()
+		return
+	}
+
+	// A different file now lives at the path (rename-based rotation): finish
+	// the rotated file before switching so no lines are lost in between
+	if fw.fileInfo != nil && !os.SameFile(fw.fileInfo, info) {
+		fw.mu.Unlock()
+		watcherLog.Info("File was rotated, finishing the old file before switching", "file", fw.filePath)
+		fw.drain()
+		fw.closeFile()
 		fw.openFile()
 		fw.readNewLines()
 		return
 	}
 
-	// File was truncated
-	if currentSize < fw.lastSize {
-		log.Printf("File %s was truncated, reloading from beginning", fw.filePath)
+	// Same inode, but rewritten with different content (e.g. a backup copied
+	// over it at the same size); only checked when the mtime moved
+	replaced := false
+	if !info.ModTime().Equal(fw.lastModTime) {
+		head := fw.readFingerprint()
+		if currentSize >= fw.lastSize && !fingerprintMatches(fw.fingerprint, head) {
+			replaced = true
+		}
+		fw.fingerprint = head
+		fw.lastModTime = info.ModTime()
+	}
+
+	// Same file got smaller (copytruncate) or was replaced in place
+	if currentSize < fw.lastSize || replaced {
+		if replaced {
+			watcherLog.Info("File was replaced by a different file, reloading from beginning", "file", fw.filePath)
+		} else {
+			watcherLog.Info("File was truncated, reloading from beginning", "file", fw.filePath)
+		}
 		fw.lastPos = 0
+		fw.partial = ""
+		fw.discarding = false
 		fw.file.Seek(0, io.SeekStart)
 		fw.reader = bufio.NewReaderSize(fw.file, 64*1024)
-		// Clear existing logs since file was truncated
-		fw.parser.ClearLogs()
 		fw.mu.Unlock()
 		fw.readNewLines()
 		fw.mu.Lock()
@@ -254,7 +564,7 @@ func (fw *FileWatcher) checkFile() {
 func (fw *FileWatcher) watchLoop() {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("Panic in watchLoop: %v", r)
+			watcherLog.Error("Panic in watch loop", "file", fw.filePath, "panic", r)
 		}
 	}()
 
@@ -268,44 +578,24 @@ func (fw *FileWatcher) watchLoop() {
 			}
 
 			// Check if the event is for our file
-			if filepath.Clean(event.Name) == filepath.Clean(fw.filePath) {
+			if fw.isOwnEvent(event.Name) {
 				switch {
 				case event.Op&fsnotify.Write == fsnotify.Write:
 					fw.checkFile()
 				case event.Op&fsnotify.Create == fsnotify.Create:
-					log.Printf("File %s was created", fw.filePath)
+					watcherLog.Info("File was created", "file", fw.filePath)
 					time.Sleep(100 * time.Millisecond) // Give it time to be written
-					fw.openFile()
-					fw.readNewLines()
-				case event.Op&fsnotify.Remove == fsnotify.Remove:
-					fw.mu.Lock()
-					if fw.file != nil {
-						log.Printf("File %s was removed", fw.filePath)
-						fw.file.Close()
-						fw.file = nil
-						fw.reader = nil
-						fw.lastPos = 0
-						fw.lastSize = 0
-					}
-					fw.mu.Unlock()
-				case event.Op&fsnotify.Rename == fsnotify.Rename:
-					fw.mu.Lock()
-					if fw.file != nil {
-						log.Printf("File %s was renamed", fw.filePath)
-						fw.file.Close()
-						fw.file = nil
-						fw.reader = nil
-						fw.lastPos = 0
-						fw.lastSize = 0
-					}
-					fw.mu.Unlock()
+					fw.checkFile()
+				case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+					// Handled by identity: the open file is drained first
+					fw.checkFile()
 				}
 			}
 		case err, ok := <-fw.watcher.Errors:
 			if !ok {
 				return
 			}
-			log.Printf("File watcher error: %v", err)
+			watcherLog.Error("File watcher error", "file", fw.filePath, "error", err)
 		}
 	}
 }
@@ -313,7 +603,7 @@ func (fw *FileWatcher) watchLoop() {
 func (fw *FileWatcher) pollLoop() {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("Panic in pollLoop: %v", r)
+			watcherLog.Error("Panic in poll loop", "file", fw.filePath, "panic", r)
 		}
 	}()
 
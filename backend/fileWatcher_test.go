@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func accessLine(path string) string {
+	return fmt.Sprintf(`{"ClientAddr":"203.0.113.7:51234","RequestMethod":"GET","RequestPath":%q,"DownstreamStatus":200,"Duration":1000000,"ServiceName":"api@docker","RouterName":"api@docker","time":"2026-10-15T10:00:00Z"}`, path)
+}
+
+// A polling watcher reading path from its start
+func newTestWatcher(t *testing.T, path string) *FileWatcher {
+	t.Helper()
+	t.Setenv("LOG_POLL_ONLY_SOURCES", "*")
+	fw, err := NewFileWatcher(path, NewLogParser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.isInitialLoad = false
+	fw.running = true
+	if err := fw.openFile(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fw.closeFile)
+	return fw
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Request paths of the stored entries, sorted
+func parsedPaths(fw *FileWatcher) []string {
+	var paths []string
+	fw.parser.eachMatch(fw.parser.logsSnapshot.Load(), Filters{}, math.MaxUint64, func(entry *LogEntry) bool {
+		paths = append(paths, entry.Path)
+		return true
+	})
+	sort.Strings(paths)
+	return paths
+}
+
+func expectPaths(t *testing.T, fw *FileWatcher, want ...string) {
+	t.Helper()
+	sort.Strings(want)
+	if got := parsedPaths(fw); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("parsed paths = %v, want %v", got, want)
+	}
+	if fw.parseErrors != 0 {
+		t.Errorf("parse errors = %d, want 0", fw.parseErrors)
+	}
+}
+
+func TestFileWatcherSplitLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	line := accessLine("/split") + "\n"
+	appendFile(t, path, accessLine("/first")+"\n"+line[:40])
+	fw := newTestWatcher(t, path)
+
+	fw.checkFile()
+	expectPaths(t, fw, "/first")
+	if fw.partial != line[:40] {
+		t.Fatalf("partial = %q, want the first write's fragment", fw.partial)
+	}
+
+	appendFile(t, path, line[40:])
+	fw.checkFile()
+	expectPaths(t, fw, "/first", "/split")
+	if fw.partial != "" {
+		t.Errorf("partial = %q after the line was completed", fw.partial)
+	}
+}
+
+func TestFileWatcherCRLF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	appendFile(t, path, accessLine("/a")+"\r\n"+accessLine("/b")[:30])
+	fw := newTestWatcher(t, path)
+	fw.checkFile()
+
+	// The \r\n ending split across writes
+	appendFile(t, path, accessLine("/b")[30:]+"\r")
+	fw.checkFile()
+	appendFile(t, path, "\n\r\n")
+	fw.checkFile()
+	expectPaths(t, fw, "/a", "/b")
+}
+
+func TestFileWatcherPartialLineAtTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	appendFile(t, path, accessLine("/before")+"\n"+accessLine("/lost")[:50])
+	fw := newTestWatcher(t, path)
+	fw.checkFile()
+
+	// copytruncate: the fragment belongs to the old content and is dropped
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, accessLine("/after")+"\n")
+	fw.checkFile()
+	expectPaths(t, fw, "/before", "/after")
+}
+
+func TestFileWatcherPartialLineAtRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	appendFile(t, path, accessLine("/old")+"\n"+accessLine("/last")[:50])
+	fw := newTestWatcher(t, path)
+	fw.checkFile()
+
+	// The writer finishes its last line, then the file is renamed away;
+	// nothing more is appended, so the unterminated line is complete
+	appendFile(t, path, accessLine("/last")[50:])
+	if err := os.Rename(path, filepath.Join(dir, "access.log.1")); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, accessLine("/new")+"\n")
+	fw.checkFile()
+	expectPaths(t, fw, "/old", "/last", "/new")
+}
+
+func TestFileWatcherDiscardsOverlongLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	junk := strings.Repeat("x", MAX_LOG_LINE_BYTES/2+1)
+	appendFile(t, path, junk)
+	fw := newTestWatcher(t, path)
+	fw.checkFile()
+	appendFile(t, path, junk)
+	fw.checkFile()
+	if fw.partial != "" || !fw.discarding {
+		t.Fatalf("kept %d bytes of an overlong line", len(fw.partial))
+	}
+
+	// The rest of the line is skipped up to its newline
+	appendFile(t, path, junk+"\n"+accessLine("/next")+"\n")
+	fw.checkFile()
+	if got := parsedPaths(fw); len(got) != 1 || got[0] != "/next" {
+		t.Errorf("parsed paths = %v, want [/next]", got)
+	}
+	if fw.parseErrors != 1 {
+		t.Errorf("parse errors = %d, want 1", fw.parseErrors)
+	}
+}
@@ -3,11 +3,10 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net"
+	"net/netip"
 	"net/http"
 	"os"
-	"strings"
 	"sync"
 	"time"
 
@@ -36,6 +35,9 @@ const (
 	MAX_RETRY_QUEUE_SIZE    = 1000 // Limit retry queue size
 )
 
+// Online API request budget per RATE_LIMIT_WINDOW, adjustable at runtime
+var maxRequestsPerMinute = MAX_REQUESTS_PER_MINUTE
+
 type GeoData struct {
 	Country     string  `json:"country"`
 	City        string  `json:"city"`
@@ -124,9 +126,9 @@ func initMaxMind() {
 	
 	if useMaxMind && maxmindPath != "" {
 		if err := loadMaxMindDatabase(maxmindPath); err != nil {
-			log.Printf("Failed to load MaxMind database: %v", err)
+			geoLog.Error("Failed to load MaxMind database", "error", err)
 			if !fallbackToOnline {
-				log.Printf("MaxMind database failed to load and fallback is disabled")
+				geoLog.Error("MaxMind database failed to load and fallback is disabled")
 			}
 		}
 	}
@@ -154,7 +156,7 @@ func loadMaxMindDatabase(dbPath string) error {
 	}
 	
 	maxmindDB = db
-	log.Printf("MaxMind database loaded successfully from: %s", dbPath)
+	geoLog.Info("MaxMind database loaded", "path", dbPath)
 	return nil
 }
 
@@ -165,6 +167,43 @@ func ReloadMaxMindDatabase() error {
 	return loadMaxMindDatabase(maxmindPath)
 }
 
+// Update geo provider settings at runtime. Enabling MaxMind loads the
+// configured database if it isn't loaded yet.
+func SetGeoProviderSettings(enableMaxMind, onlineFallback bool, requestsPerMinute int) error {
+	maxmindMutex.RLock()
+	needsLoad := enableMaxMind && maxmindDB == nil
+	maxmindMutex.RUnlock()
+
+	if needsLoad {
+		if maxmindPath == "" {
+			return fmt.Errorf("cannot enable MaxMind: no database path configured")
+		}
+		if err := loadMaxMindDatabase(maxmindPath); err != nil {
+			return err
+		}
+	}
+
+	maxmindMutex.Lock()
+	useMaxMind = enableMaxMind
+	fallbackToOnline = onlineFallback
+	maxmindMutex.Unlock()
+
+	rateLimitMutex.Lock()
+	maxRequestsPerMinute = requestsPerMinute
+	rateLimitMutex.Unlock()
+
+	geoLog.Info("Geo provider settings updated", "useMaxMind", enableMaxMind, "fallbackToOnline", onlineFallback,
+		"maxRequestsPerMinute", requestsPerMinute)
+	return nil
+}
+
+// Get the current online API request budget
+func GetMaxRequestsPerMinute() int {
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+	return maxRequestsPerMinute
+}
+
 func GetMaxMindConfig() MaxMindConfig {
 	maxmindMutex.RLock()
 	defer maxmindMutex.RUnlock()
@@ -189,22 +228,17 @@ func GetMaxMindConfig() MaxMindConfig {
 	return config
 }
 
-func getGeoFromMaxMind(ip string) *GeoData {
+func getGeoFromMaxMind(addr netip.Addr) *GeoData {
 	maxmindMutex.RLock()
 	defer maxmindMutex.RUnlock()
 	
-	if maxmindDB == nil {
-		return nil
-	}
-	
-	parsedIP := net.ParseIP(ip)
-	if parsedIP == nil {
+	if maxmindDB == nil || !addr.IsValid() {
 		return nil
 	}
 	
-	record, err := maxmindDB.City(parsedIP)
+	record, err := maxmindDB.City(net.IP(addr.AsSlice()))
 	if err != nil {
-		log.Printf("MaxMind lookup failed for IP %s: %v", ip, err)
+		geoLog.Debug("MaxMind lookup failed", "ip", addr, "error", err)
 		return nil
 	}
 	
@@ -264,7 +298,8 @@ func GetGeoLocationFromCache(ip string) *GeoData {
 
 func GetGeoLocation(ip string) *GeoData {
 	// Check if it's a private IP
-	if isPrivateIP(ip) {
+	addr, _ := parseClientAddr(ip)
+	if isPrivateAddr(addr) {
 		return &GeoData{
 			Country:     "Private Network",
 			City:        "Local",
@@ -275,7 +310,26 @@ func GetGeoLocation(ip string) *GeoData {
 		}
 	}
 
+	// One key per address however it was written
+	addr = addr.WithZone("")
+	ip = addr.String()
+
 	// Check cache first
+	if geoData := cachedGeoLocation(ip); geoData != nil {
+		return geoData
+	}
+
+	// Concurrent lookups of the same IP share one MaxMind or online request
+	return geoLookups.Do(ip, func() *GeoData {
+		// A lookup that finished since the check above has cached its result
+		if geoData := cachedGeoLocation(ip); geoData != nil {
+			return geoData
+		}
+		return lookupGeoLocation(addr, ip)
+	})
+}
+
+func cachedGeoLocation(ip string) *GeoData {
 	if cached, found := geoCache.Get(ip); found {
 		if geoData, ok := cached.(*GeoData); ok {
 			// Add source if not set (for backward compatibility)
@@ -285,13 +339,21 @@ func GetGeoLocation(ip string) *GeoData {
 			return geoData
 		}
 	}
+	return nil
+}
+
+// Look an uncached public IP up in MaxMind and the online services
+func lookupGeoLocation(addr netip.Addr, ip string) *GeoData {
+	maxmindMutex.RLock()
+	maxmindEnabled, onlineFallback := useMaxMind, fallbackToOnline
+	maxmindMutex.RUnlock()
 
 	// Try MaxMind first if enabled
-	if useMaxMind {
-		if geoData := getGeoFromMaxMind(ip); geoData != nil {
+	if maxmindEnabled {
+		if geoData := getGeoFromMaxMind(addr); geoData != nil {
 			geoCache.Set(ip, geoData, cache.DefaultExpiration)
 			return geoData
-		} else if !fallbackToOnline {
+		} else if !onlineFallback {
 			// MaxMind failed and no fallback allowed
 			failedData := &GeoData{
 				Country:     "Unknown",
@@ -305,7 +367,7 @@ func GetGeoLocation(ip string) *GeoData {
 			return failedData
 		}
 		// If MaxMind failed but fallback is enabled, continue to online APIs
-		log.Printf("MaxMind lookup failed for %s, falling back to online APIs", ip)
+		geoLog.Debug("MaxMind lookup failed, falling back to online APIs", "ip", ip)
 	}
 
 	// Rate limiting check for online APIs
@@ -316,9 +378,9 @@ func GetGeoLocation(ip string) *GeoData {
 		lastRequestTime = now
 	}
 
-	if requestCount >= MAX_REQUESTS_PER_MINUTE {
+	if requestCount >= maxRequestsPerMinute {
 		rateLimitMutex.Unlock()
-		log.Printf("Rate limit reached for IP geolocation. Adding %s to retry queue", ip)
+		geoLog.Debug("Geolocation rate limit reached, queued for retry", "ip", ip)
 		addToRetryQueue(ip)
 		return &GeoData{
 			Country:     "Pending",
@@ -455,7 +517,7 @@ func tryFallbackService(ip string) *GeoData {
 	}
 
 	// All services failed
-	log.Printf("All geolocation services failed for IP %s", ip)
+	geoLog.Warn("All geolocation services failed", "ip", ip)
 	failedData := &GeoData{
 		Country:     "Unknown",
 		City:        "Unknown",
@@ -468,26 +530,6 @@ func tryFallbackService(ip string) *GeoData {
 	return failedData
 }
 
-func isPrivateIP(ip string) bool {
-	if ip == "" || ip == "unknown" {
-		return true
-	}
-
-	parts := strings.Split(ip, ".")
-	if len(parts) != 4 {
-		return false
-	}
-
-	return ip == "127.0.0.1" ||
-		ip == "localhost" ||
-		strings.HasPrefix(ip, "::") ||
-		ip == "::1" ||
-		parts[0] == "10" ||
-		(parts[0] == "172" && isInRange(parts[1], 16, 31)) ||
-		(parts[0] == "192" && parts[1] == "168") ||
-		(parts[0] == "169" && parts[1] == "254")
-}
-
 func getCountryName(code string) string {
 	if name, ok := countryNameMap[code]; ok {
 		return name
@@ -515,6 +557,49 @@ func addToRetryQueue(ip string) {
 	retryQueue = append(retryQueue, ip)
 }
 
+// Lookups in flight, keyed by IP; callers asking for an IP that is already
+// being looked up wait for that result instead of starting their own
+type geoFlight struct {
+	mu        sync.Mutex
+	calls     map[string]*geoCall
+	coalesced int // Callers that shared another caller's lookup
+}
+
+type geoCall struct {
+	done chan struct{}
+	data *GeoData
+}
+
+var geoLookups = &geoFlight{calls: make(map[string]*geoCall)}
+
+func (f *geoFlight) Do(ip string, lookup func() *GeoData) *GeoData {
+	f.mu.Lock()
+	if call, ok := f.calls[ip]; ok {
+		f.coalesced++
+		f.mu.Unlock()
+		<-call.done
+		return call.data
+	}
+	call := &geoCall{done: make(chan struct{})}
+	f.calls[ip] = call
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, ip)
+		f.mu.Unlock()
+		close(call.done)
+	}()
+	call.data = lookup()
+	return call.data
+}
+
+func (f *geoFlight) Stats() (inFlight, coalesced int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls), f.coalesced
+}
+
 func ProcessRetryQueue() {
 	retryQueueMutex.Lock()
 	if len(retryQueue) == 0 {
@@ -532,7 +617,7 @@ func ProcessRetryQueue() {
 	retryQueue = retryQueue[batchSize:]
 	retryQueueMutex.Unlock()
 	
-	log.Printf("Processing %d IPs from retry queue", len(batch))
+	geoLog.Debug("Processing retry queue", "ips", len(batch))
 	
 	for _, ip := range batch {
 		GetGeoLocation(ip)
@@ -550,11 +635,14 @@ func GetGeoCacheStats() GeoCacheStats {
 	retryQueueMutex.Lock()
 	queueLen := len(retryQueue)
 	retryQueueMutex.Unlock()
+	inFlight, coalesced := geoLookups.Stats()
 	
 	return GeoCacheStats{
 		Keys: geoCache.ItemCount(),
 		Stats: map[string]int{
-			"items": geoCache.ItemCount(),
+			"items":     geoCache.ItemCount(),
+			"inFlight":  inFlight,
+			"coalesced": coalesced,
 		},
 		RetryQueueLength: queueLen,
 		MaxMindConfig:    GetMaxMindConfig(),
@@ -572,7 +660,7 @@ func CloseMaxMindDatabase() {
 	if maxmindDB != nil {
 		maxmindDB.Close()
 		maxmindDB = nil
-		log.Println("MaxMind database closed")
+		geoLog.Info("MaxMind database closed")
 	}
 }
 
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+const MAX_HEADER_VALUE_BYTES = 1024 // Longer captured header values are truncated
+
+// Prefixes of the header fields in Traefik's access log: request headers
+// as the client sent them, downstream headers as Traefik answered
+var headerFieldPrefixes = []string{"request_", "downstream_"}
+
+// Which captured header fields entries keep, from LOG_HEADERS. Traefik only
+// logs the headers its accessLog.fields.headers config keeps; of those, the
+// ones matching a pattern end up in LogEntry.Headers under their field name
+// ("request_X-Request-Id", "downstream_Content-Type").
+type HeaderCapture struct {
+	patterns []string // Lowercased field names, "*" and "?" wildcards
+}
+
+// The capture in use; nil keeps no headers
+var headerCapture atomic.Pointer[HeaderCapture]
+
+// Parse LOG_HEADERS ("request_X-Request-Id,downstream_*"); nil when unset
+func NewHeaderCapture() (*HeaderCapture, error) {
+	c := &HeaderCapture{}
+	for _, pattern := range strings.Split(os.Getenv("LOG_HEADERS"), ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if !isHeaderField(pattern) {
+			return nil, fmt.Errorf("LOG_HEADERS entries must start with request_ or downstream_: %q", pattern)
+		}
+		c.patterns = append(c.patterns, pattern)
+	}
+	if len(c.patterns) == 0 {
+		return nil, nil
+	}
+	return c, nil
+}
+
+func SetHeaderCapture(c *HeaderCapture) {
+	headerCapture.Store(c)
+}
+
+// Check whether a query or facet field names a captured header
+func isHeaderField(field string) bool {
+	field = strings.ToLower(field)
+	for _, prefix := range headerFieldPrefixes {
+		if len(field) > len(prefix) && strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *HeaderCapture) retains(field string) bool {
+	field = strings.ToLower(field)
+	for _, pattern := range c.patterns {
+		if matchPattern(pattern, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// The retained header fields of an access log line; nil when it has none.
+// The struct decode skips header fields, so lines that have any are decoded
+// a second time.
+func (c *HeaderCapture) capture(line []byte) map[string]string {
+	if !bytes.Contains(line, []byte(`"request_`)) && !bytes.Contains(line, []byte(`"downstream_`)) {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return nil
+	}
+	var headers map[string]string
+	for field, data := range fields {
+		if !isHeaderField(field) || !c.retains(field) {
+			continue
+		}
+		var value logValue
+		if err := value.UnmarshalJSON(data); err != nil || !value.isString {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[field] = truncateUTF8(value.str, MAX_HEADER_VALUE_BYTES)
+	}
+	return headers
+}
+
+// Cut s to at most max bytes without splitting a character
+func truncateUTF8(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// An entry's captured header by field name, matched case-insensitively
+func headerValue(log *LogEntry, field string) (string, bool) {
+	if value, ok := log.Headers[field]; ok {
+		return value, true
+	}
+	for name, value := range log.Headers {
+		if strings.EqualFold(name, field) {
+			return value, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const DEFAULT_IMPORT_SOURCE = "import"
+
+// Parse Traefik access log lines from r (an archive, a file from another
+// host) without emitting them live, attributing entries to source
+func (lp *LogParser) ImportLogs(r io.Reader, source string) (BackfillResult, error) {
+	counted := &countingReader{r: r}
+	result := BackfillResult{Source: source}
+	var err error
+	result.Lines, result.Accepted, err = lp.ingestLines(counted, source, nil)
+	result.Bytes = counted.n
+	parserLog.Info("Imported log entries", "source", source, "accepted", result.Accepted, "lines", result.Lines)
+	return result, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Import log lines from the request body (plain or Content-Encoding: gzip)
+func importLogs(c *gin.Context) {
+	source := strings.TrimSpace(c.Query("source"))
+	if source == "" {
+		source = DEFAULT_IMPORT_SOURCE
+	}
+
+	var body io.Reader = c.Request.Body
+	switch strings.ToLower(c.GetHeader("Content-Encoding")) {
+	case "gzip":
+		gz, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer gz.Close()
+		body = gz
+	case "", "identity":
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Encoding must be gzip or identity"})
+		return
+	}
+
+	result, err := logParser.ImportLogs(body, source)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	broadcastSystemEvent("logsImported", map[string]interface{}{"source": source, "accepted": result.Accepted})
+	c.JSON(http.StatusOK, result)
+}
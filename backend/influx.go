@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const DEFAULT_INFLUX_PUSH_INTERVAL = 60 * time.Second
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// Periodically writes aggregated metrics in InfluxDB line protocol. Works
+// with InfluxDB 1.x/2.x write endpoints and VictoriaMetrics' /write.
+type InfluxPusher struct {
+	url         string
+	token       string
+	measurement string
+	interval    time.Duration
+	client      *http.Client
+	previous    map[string]*ServiceMetrics
+	stop        chan struct{}
+}
+
+var influxPusher *InfluxPusher
+
+// Create the pusher from INFLUX_URL; nil when unset. INFLUX_URL is the full
+// write URL, e.g. http://influx:8086/api/v2/write?org=home&bucket=traefik
+// or http://victoria:8428/write.
+func NewInfluxPusher() (*InfluxPusher, error) {
+	url := os.Getenv("INFLUX_URL")
+	if url == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("invalid INFLUX_URL: %s", redactURL(url))
+	}
+	interval := time.Duration(GetEnvInt("INFLUX_PUSH_INTERVAL_SECONDS", int(DEFAULT_INFLUX_PUSH_INTERVAL/time.Second))) * time.Second
+	if interval < time.Second {
+		return nil, fmt.Errorf("INFLUX_PUSH_INTERVAL_SECONDS must be at least 1")
+	}
+	return &InfluxPusher{
+		url:         url,
+		token:       os.Getenv("INFLUX_TOKEN"),
+		measurement: GetEnvString("INFLUX_MEASUREMENT", "traefik"),
+		interval:    interval,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		stop:        make(chan struct{}),
+	}, nil
+}
+
+func (p *InfluxPusher) Start() {
+	appLog.Info("Pushing metrics to InfluxDB", "url", redactURL(p.url), "interval", p.interval)
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := p.push(now); err != nil {
+					appLog.Warn("InfluxDB push failed", "error", err)
+				}
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (p *InfluxPusher) Stop() {
+	close(p.stop)
+}
+
+func (p *InfluxPusher) push(now time.Time) error {
+	snapshot := metrics.Snapshot()
+	body := p.encode(snapshot, now)
+	p.previous = snapshot.Services
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Token "+p.token)
+	}
+	return doNotifyRequest(p.client, req)
+}
+
+// Encode one line per service for the interval since the last push, plus a
+// line of dashboard-wide gauges
+func (p *InfluxPusher) encode(s MetricsSnapshot, now time.Time) []byte {
+	var buf bytes.Buffer
+	timestamp := now.UnixNano()
+	measurement := influxMeasurementEscaper.Replace(p.measurement)
+
+	for _, name := range s.ServiceNames() {
+		current := s.Services[name]
+		delta := current.Since(p.previous[name])
+		requests, errors := delta.Totals()
+		total, _ := current.Totals()
+
+		errorRate := 0.0
+		if requests > 0 {
+			errorRate = float64(errors) / float64(requests) * 100
+		}
+		service := name
+		if service == "" {
+			service = "unknown"
+		}
+
+		fmt.Fprintf(&buf, "%s_requests,service=%s requests=%di,errors=%di,rps=%s,error_rate=%s,bytes=%di,requests_total=%di,latency_p50_ms=%s,latency_p95_ms=%s,latency_p99_ms=%s %d\n",
+			measurement, influxTagEscaper.Replace(service),
+			requests, errors,
+			influxFloat(float64(requests)/p.interval.Seconds()),
+			influxFloat(errorRate),
+			delta.Bytes, total,
+			influxFloat(delta.LatencyQuantile(0.50)*1000),
+			influxFloat(delta.LatencyQuantile(0.95)*1000),
+			influxFloat(delta.LatencyQuantile(0.99)*1000),
+			timestamp)
+	}
+
+	fmt.Fprintf(&buf, "%s_dashboard ingestion_lag_seconds=%s,geo_queue_depth=%di,websocket_clients=%di,logs_in_memory=%di %d\n",
+		measurement, influxFloat(s.IngestionLag), s.GeoQueueDepth, s.WebSocketClients, s.LogsInMemory, timestamp)
+	return buf.Bytes()
+}
+
+// Format a float field, rounded to keep payloads compact
+func influxFloat(v float64) string {
+	return fmt.Sprintf("%g", math.Round(v*1000)/1000)
+}
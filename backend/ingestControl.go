@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	INGEST_DROP_SAMPLED   = "sampled"
+	INGEST_DROP_THROTTLED = "throttled"
+	INGEST_DROP_FILTERED  = "filtered"
+)
+
+type IngestRuntimeConfig struct {
+	MaxPerSecond int        `json:"maxPerSecond"` // Non-error entries stored per second; 0 = unlimited
+	SampleRate   int        `json:"sampleRate"`   // Keep 1 in N non-error entries; 0 or 1 keeps all
+	DropRules    []DropRule `json:"dropRules"`
+}
+
+// Decides which parsed entries are stored and broadcast. Entries matching a
+// drop rule (health checks, monitors) are discarded before they reach
+// stats. Of the rest, errors (status >= 400) are always kept; everything
+// else can be sampled and rate capped so a traffic spike can't blow up
+// memory or WebSocket fanout. Sampled and throttled entries are still
+// counted in stats and metrics.
+type IngestControl struct {
+	mu          sync.Mutex
+	config      IngestRuntimeConfig
+	dropRules   atomic.Pointer[[]compiledDropRule]
+	seen        uint64
+	windowStart time.Time
+	windowCount int
+	dropped     map[string]uint64
+}
+
+var ingestControl = &IngestControl{dropped: make(map[string]uint64)}
+
+// Read the ingest controls from the environment; INGEST_DROP_RULES is a
+// JSON array of drop rules
+func GetIngestConfigFromEnv() (IngestRuntimeConfig, error) {
+	config := IngestRuntimeConfig{
+		MaxPerSecond: GetEnvInt("INGEST_MAX_PER_SECOND", 0),
+		SampleRate:   GetEnvInt("INGEST_SAMPLE_RATE", 0),
+	}
+	if config.MaxPerSecond < 0 || config.SampleRate < 0 || config.SampleRate > 10000 {
+		return config, fmt.Errorf("INGEST_MAX_PER_SECOND must be 0 or more and INGEST_SAMPLE_RATE between 0 and 10000")
+	}
+	if value := os.Getenv("INGEST_DROP_RULES"); value != "" {
+		if err := json.Unmarshal([]byte(value), &config.DropRules); err != nil {
+			return config, fmt.Errorf("INGEST_DROP_RULES must be a JSON array of rules: %w", err)
+		}
+	}
+	if _, err := compileDropRules(config.DropRules); err != nil {
+		return config, fmt.Errorf("invalid INGEST_DROP_RULES: %w", err)
+	}
+	return config, nil
+}
+
+func (ic *IngestControl) Config() IngestRuntimeConfig {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	return ic.config
+}
+
+func (ic *IngestControl) SetConfig(config IngestRuntimeConfig) error {
+	rules, err := compileDropRules(config.DropRules)
+	if err != nil {
+		return err
+	}
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	ic.config = config
+	ic.dropRules.Store(&rules)
+	return nil
+}
+
+// Check whether a drop rule matches the entry, counting it as dropped if so
+func (ic *IngestControl) Drop(entry *LogEntry) bool {
+	if _, ok := ic.MatchDropRule(entry); !ok {
+		return false
+	}
+	ic.mu.Lock()
+	ic.dropped[INGEST_DROP_FILTERED]++
+	ic.mu.Unlock()
+	return true
+}
+
+// The first drop rule matching the entry, without counting anything
+func (ic *IngestControl) MatchDropRule(entry *LogEntry) (string, bool) {
+	rules := ic.dropRules.Load()
+	if rules == nil {
+		return "", false
+	}
+	for i := range *rules {
+		if (*rules)[i].matches(entry) {
+			return (*rules)[i].name, true
+		}
+	}
+	return "", false
+}
+
+// Check whether an entry should be kept, counting it as dropped if not
+func (ic *IngestControl) Admit(entry *LogEntry) bool {
+	if entry.Status >= 400 {
+		return true
+	}
+
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	if ic.config.SampleRate > 1 {
+		ic.seen++
+		if ic.seen%uint64(ic.config.SampleRate) != 1 {
+			ic.dropped[INGEST_DROP_SAMPLED]++
+			return false
+		}
+	}
+
+	if ic.config.MaxPerSecond > 0 {
+		now := time.Now()
+		if now.Sub(ic.windowStart) >= time.Second {
+			ic.windowStart = now
+			ic.windowCount = 0
+		}
+		if ic.windowCount >= ic.config.MaxPerSecond {
+			ic.dropped[INGEST_DROP_THROTTLED]++
+			return false
+		}
+		ic.windowCount++
+	}
+	return true
+}
+
+// Entries dropped so far by reason
+func (ic *IngestControl) Dropped() map[string]uint64 {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	dropped := make(map[string]uint64, len(ic.dropped))
+	for reason, count := range ic.dropped {
+		dropped[reason] = count
+	}
+	return dropped
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	DEFAULT_INITIAL_LOAD_LINES = 500
+	DEFAULT_MAX_LOGS           = 10000
+	INITIAL_LOAD_ALL           = -1 // Read the entire file
+)
+
+// How much history is read from a source when it is attached
+type InitialLoadConfig struct {
+	Lines   int            `json:"lines"`   // -1 loads the entire file
+	Sources map[string]int `json:"sources"` // Overrides keyed by path, wildcard pattern or directory ending in "/"
+}
+
+var (
+	initialLoadMu     sync.RWMutex
+	initialLoadConfig = InitialLoadConfig{Lines: DEFAULT_INITIAL_LOAD_LINES}
+)
+
+// Parse a line count, accepting "all" for the entire file
+func parseInitialLines(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "all") {
+		return INITIAL_LOAD_ALL, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < INITIAL_LOAD_ALL {
+		return 0, fmt.Errorf("invalid line count %q (use a number or \"all\")", value)
+	}
+	return n, nil
+}
+
+// Load LOG_INITIAL_LINES and LOG_INITIAL_LINES_PER_SOURCE
+// ("/logs/edge/=2000,/logs/big.log=all")
+func GetInitialLoadConfigFromEnv() (InitialLoadConfig, error) {
+	config := InitialLoadConfig{Lines: DEFAULT_INITIAL_LOAD_LINES, Sources: make(map[string]int)}
+
+	if value := os.Getenv("LOG_INITIAL_LINES"); value != "" {
+		lines, err := parseInitialLines(value)
+		if err != nil {
+			return config, fmt.Errorf("LOG_INITIAL_LINES: %w", err)
+		}
+		config.Lines = lines
+	}
+
+	for _, entry := range strings.Split(os.Getenv("LOG_INITIAL_LINES_PER_SOURCE"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		cut := strings.LastIndex(entry, "=")
+		if cut <= 0 {
+			return config, fmt.Errorf("LOG_INITIAL_LINES_PER_SOURCE: expected source=lines, got %q", entry)
+		}
+		lines, err := parseInitialLines(entry[cut+1:])
+		if err != nil {
+			return config, fmt.Errorf("LOG_INITIAL_LINES_PER_SOURCE: %w", err)
+		}
+		config.Sources[strings.TrimSpace(entry[:cut])] = lines
+	}
+	return config, nil
+}
+
+func GetInitialLoadConfig() InitialLoadConfig {
+	initialLoadMu.RLock()
+	defer initialLoadMu.RUnlock()
+	sources := make(map[string]int, len(initialLoadConfig.Sources))
+	for pattern, lines := range initialLoadConfig.Sources {
+		sources[pattern] = lines
+	}
+	return InitialLoadConfig{Lines: initialLoadConfig.Lines, Sources: sources}
+}
+
+func SetInitialLoadConfig(config InitialLoadConfig) {
+	initialLoadMu.Lock()
+	defer initialLoadMu.Unlock()
+	initialLoadConfig = config
+}
+
+// Lines to load from a source when it is attached; an exact path wins over
+// patterns, and the longest matching pattern wins over shorter ones
+func InitialLinesFor(source string) int {
+	initialLoadMu.RLock()
+	defer initialLoadMu.RUnlock()
+
+	if lines, ok := initialLoadConfig.Sources[source]; ok {
+		return lines
+	}
+	patterns := make([]string, 0, len(initialLoadConfig.Sources))
+	for pattern := range initialLoadConfig.Sources {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	for _, pattern := range patterns {
+		if matchSourcePattern(pattern, source) {
+			return initialLoadConfig.Sources[pattern]
+		}
+	}
+	return initialLoadConfig.Lines
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// Parse an address as it appears in logs: a bare IPv4 or IPv6 address,
+// optionally bracketed, with a port ("203.0.113.9:443", "[2001:db8::1]:443")
+// or a zone. IPv4-mapped IPv6 addresses are unmapped so they compare equal to
+// their IPv4 form.
+func parseClientAddr(value string) (netip.Addr, bool) {
+	_, addr, ok := splitClientAddr(value)
+	return addr, ok
+}
+
+// parseClientAddr, also returning the part of value holding the address
+func splitClientAddr(value string) (string, netip.Addr, bool) {
+	if value == "" {
+		return "", netip.Addr{}, false
+	}
+	// Pick the one parse that fits, failed parses allocate their error
+	hasPort := false
+	if value[0] == '[' {
+		hasPort = !strings.HasSuffix(value, "]")
+		if !hasPort {
+			value = value[1 : len(value)-1]
+		}
+	} else {
+		hasPort = strings.Count(value, ":") == 1
+	}
+
+	if hasPort {
+		addrPort, err := netip.ParseAddrPort(value)
+		if err != nil {
+			return "", netip.Addr{}, false
+		}
+		host := value[:strings.LastIndexByte(value, ':')]
+		return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"), addrPort.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return "", netip.Addr{}, false
+	}
+	return value, addr.Unmap(), true
+}
+
+// Extract the client IP from an address with or without a port. The IP is
+// returned in canonical form, so one client always gets the same filter and
+// geo cache key, together with the parsed address (invalid for values that
+// are not IPs, which are kept minus any port).
+func extractClientIP(clientAddr string) (string, netip.Addr) {
+	if clientAddr == "" || clientAddr == "unknown" {
+		return "unknown", netip.Addr{}
+	}
+	if host, addr, ok := splitClientAddr(clientAddr); ok {
+		// Logged addresses are nearly always canonical already; reuse them
+		// rather than allocating the same string again
+		var buf [64]byte
+		if canonical := addr.AppendTo(buf[:0]); string(canonical) == host {
+			return host, addr
+		}
+		return addr.String(), addr
+	}
+	if host, _, err := net.SplitHostPort(clientAddr); err == nil {
+		return host, netip.Addr{}
+	}
+	return clientAddr, netip.Addr{}
+}
+
+// Check whether an address can't be geolocated: loopback, private (RFC 1918
+// and IPv6 unique local), link-local or unspecified. Invalid addresses count
+// as private too.
+func isPrivateAddr(addr netip.Addr) bool {
+	return !addr.IsValid() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsUnspecified()
+}
+
+// Same check for an IP held as text; "unknown" and other non-IPs count as private
+func isPrivateIP(ip string) bool {
+	addr, _ := parseClientAddr(ip)
+	return isPrivateAddr(addr)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/patrickmn/go-cache"
+)
+
+func TestExtractClientIP(t *testing.T) {
+	for _, tt := range []struct {
+		in    string
+		ip    string
+		valid bool
+	}{
+		{"203.0.113.9", "203.0.113.9", true},
+		{"203.0.113.9:443", "203.0.113.9", true},
+		{"2001:db8::1", "2001:db8::1", true},
+		{"[2001:db8::1]", "2001:db8::1", true},
+		{"[2001:db8::1]:443", "2001:db8::1", true},
+		{"2001:DB8:0:0:0:0:0:1", "2001:db8::1", true}, // Canonicalized
+		{"fe80::1%eth0", "fe80::1%eth0", true},
+		{"[fe80::1%eth0]:8080", "fe80::1%eth0", true},
+		{"::ffff:203.0.113.9", "203.0.113.9", true}, // IPv4-mapped, unmapped
+		{"[::ffff:203.0.113.9]:443", "203.0.113.9", true},
+		{"::1", "::1", true},
+		{"", "unknown", false},
+		{"unknown", "unknown", false},
+		{"proxy.internal:8080", "proxy.internal", false},
+		{"not an ip", "not an ip", false},
+	} {
+		ip, addr := extractClientIP(tt.in)
+		if ip != tt.ip || addr.IsValid() != tt.valid {
+			t.Errorf("extractClientIP(%q) = %q, valid %v; want %q, valid %v", tt.in, ip, addr.IsValid(), tt.ip, tt.valid)
+		}
+		if tt.valid && addr.String() != tt.ip {
+			t.Errorf("extractClientIP(%q) address = %s, want %s", tt.in, addr, tt.ip)
+		}
+	}
+}
+
+func TestIsPrivateIP(t *testing.T) {
+	for _, tt := range []struct {
+		ip      string
+		private bool
+	}{
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"127.0.0.1", true},
+		{"169.254.1.1", true},
+		{"0.0.0.0", true},
+		{"8.8.8.8", false},
+		{"172.32.0.1", false},
+		{"::1", true},
+		{"::", true},
+		{"fc00::1", true}, // Unique local
+		{"fd12:3456::1", true},
+		{"fe80::1", true}, // Link-local
+		{"fe80::1%eth0", true},
+		{"ff02::1", true}, // Link-local multicast
+		{"2001:db8::1", false},
+		{"2606:4700::1111", false},
+		{"::ffff:192.168.1.1", true}, // IPv4-mapped private
+		{"::ffff:8.8.8.8", false},
+		{"[fd00::1]:443", true},
+		{"unknown", true},
+		{"", true},
+	} {
+		if got := isPrivateIP(tt.ip); got != tt.private {
+			t.Errorf("isPrivateIP(%q) = %v, want %v", tt.ip, got, tt.private)
+		}
+	}
+}
+
+var benchClientAddrs = []string{
+	"203.0.113.9:51234",
+	"10.0.0.12:443",
+	"[2001:db8::1]:443",
+	"2606:4700::1111",
+	"192.168.1.20",
+	"[fe80::1%eth0]:8080",
+	"::ffff:198.51.100.7",
+	"fd12:3456::1",
+}
+
+// Extracting the client IP and checking whether it is private, as done for
+// every ingested entry
+func BenchmarkExtractClientIP(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, value := range benchClientAddrs {
+			_, addr := extractClientIP(value)
+			isPrivateAddr(addr)
+		}
+	}
+}
+
+// GetLogs over 5000 entries with a CIDR filter and private IPs hidden. The
+// matching addresses are cached as geolocated, so the page isn't looked up
+// online.
+func BenchmarkGetLogsCIDR(b *testing.B) {
+	lp := NewLogParser()
+	for i := 0; i < 5000; i++ {
+		addr := benchClientAddrs[i%len(benchClientAddrs)]
+		if i%3 == 0 {
+			ip := fmt.Sprintf("198.51.100.%d", i%256)
+			geoCache.Set(ip, &GeoData{Country: "Test", CountryCode: "TT", Source: "cached"}, cache.DefaultExpiration)
+			addr = ip + ":443"
+		}
+		line := strings.Replace(benchLogLine, `"ClientAddr":"203.0.113.7:51234"`, fmt.Sprintf(`"ClientAddr":%q`, addr), 1)
+		if !lp.parseLine("bench.log", line, false) {
+			b.Fatal("line rejected")
+		}
+	}
+	params := LogsParams{Page: 1, Limit: 50, Filters: Filters{CIDR: "198.51.100.0/24", HidePrivateIPs: true}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if result := lp.GetLogs(params); len(result.Logs) == 0 {
+			b.Fatal("no matches")
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Find rotated siblings of a log file (access.log.1, access.log.2.gz,
+// access.log-20240101, ...), oldest first
+func findRotatedFiles(filePath string) []string {
+	dir := filepath.Dir(filePath)
+	base := filepath.Base(filePath)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	type rotated struct {
+		path  string
+		info  os.FileInfo
+		index int // Numeric suffix (logrotate without dateext); -1 otherwise
+	}
+	var files []rotated
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == base || len(name) <= len(base)+1 || !strings.HasPrefix(name, base) {
+			continue
+		}
+		if sep := name[len(base)]; sep != '.' && sep != '-' && sep != '_' {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, rotated{
+			path:  filepath.Join(dir, name),
+			info:  info,
+			index: rotationIndex(strings.TrimSuffix(name[len(base)+1:], ".gz")),
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		// access.log.2 is older than access.log.1
+		if files[i].index >= 0 && files[j].index >= 0 {
+			return files[i].index > files[j].index
+		}
+		if !files[i].info.ModTime().Equal(files[j].info.ModTime()) {
+			return files[i].info.ModTime().Before(files[j].info.ModTime())
+		}
+		// Date suffixes sort lexically
+		return files[i].path < files[j].path
+	})
+
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	return paths
+}
+
+func rotationIndex(suffix string) int {
+	if suffix == "" {
+		return -1
+	}
+	n := 0
+	for _, r := range suffix {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		n = n*10 + int(r-'0')
+	}
+	return n
+}
+
+// Retained rotations of filePath to backfill before it is tailed, oldest
+// first, skipping siblings that are watched as sources of their own
+func rotatedBackfill(filePath string, watched map[string]bool) []string {
+	var rotations []string
+	for _, rotated := range findRotatedFiles(filePath) {
+		if !watched[rotated] {
+			rotations = append(rotations, rotated)
+		}
+	}
+	return rotations
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// Open a plain or gzip compressed log file for reading
+func openLogFile(filePath string) (io.ReadCloser, error) {
+	file, err := openSharedFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(filePath, ".gz") {
+		return file, nil
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return gzipFile{Reader: gz, file: file}, nil
+}
+
+// Parse each line from r without emitting; keep, when set, can skip lines
+func (lp *LogParser) ingestLines(r io.Reader, source string, keep func(line string) bool) (lines, accepted int, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	batch := make([]string, 0, PARSE_BATCH_SIZE)
+	flush := func() {
+		for _, ok := range lp.parseLines(source, batch, false) {
+			if ok {
+				accepted++
+			}
+		}
+		batch = batch[:0]
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if keep != nil && !keep(line) {
+			continue
+		}
+		lines++
+		if batch = append(batch, line); len(batch) == PARSE_BATCH_SIZE {
+			flush()
+		}
+	}
+	flush()
+	return lines, accepted, scanner.Err()
+}
+
+// Limits for an on-demand backfill; zero values are unbounded
+type BackfillRange struct {
+	FromByte int64     `json:"fromByte"`
+	ToByte   int64     `json:"toByte"`
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+	Replace  bool      `json:"replace"` // Drop the source's entries first to avoid duplicates
+}
+
+type BackfillResult struct {
+	Source   string `json:"source"`
+	Lines    int    `json:"lines"`
+	Accepted int    `json:"accepted"`
+	Removed  int    `json:"removed"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// Re-read a watched file (or part of it) through the parser
+func (lp *LogParser) BackfillSource(filePath string, r BackfillRange) (BackfillResult, error) {
+	result := BackfillResult{Source: filePath}
+
+	file, err := openSharedFile(filePath)
+	if err != nil {
+		return result, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return result, err
+	}
+	end := info.Size()
+	if r.ToByte > 0 && r.ToByte < end {
+		end = r.ToByte
+	}
+	if r.FromByte < 0 || r.FromByte > end {
+		return result, fmt.Errorf("fromByte is outside the file (size %d)", info.Size())
+	}
+
+	// A range starting mid-line begins at the next complete line
+	start := r.FromByte
+	skipFirst := false
+	if start > 0 {
+		prev := make([]byte, 1)
+		if _, err := file.ReadAt(prev, start-1); err == nil && prev[0] != '\n' {
+			skipFirst = true
+		}
+	}
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return result, err
+	}
+	result.Bytes = end - start
+
+	var keep func(line string) bool
+	if skipFirst || !r.Since.IsZero() || !r.Until.IsZero() {
+		keep = func(line string) bool {
+			if skipFirst {
+				skipFirst = false
+				return false
+			}
+			if r.Since.IsZero() && r.Until.IsZero() {
+				return true
+			}
+			var entry struct {
+				Time string `json:"time"`
+			}
+			if json.Unmarshal([]byte(line), &entry) != nil {
+				return true // Let the parser report it
+			}
+			timestamp, err := time.Parse(time.RFC3339, entry.Time)
+			if err != nil {
+				return true
+			}
+			return (r.Since.IsZero() || !timestamp.Before(r.Since)) && (r.Until.IsZero() || timestamp.Before(r.Until))
+		}
+	}
+
+	if r.Replace {
+		result.Removed = lp.ResetSource(filePath)
+	}
+	result.Lines, result.Accepted, err = lp.ingestLines(io.LimitReader(file, end-start), filePath, keep)
+	parserLog.Info("Backfilled log entries", "file", filePath, "accepted", result.Accepted, "lines", result.Lines)
+	return result, err
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// Open a log file for reading while its writer keeps appending and rotating it
+func openSharedFile(path string) (*os.File, error) {
+	return os.Open(path)
+}
+
+// The current size and mtime of a watched file, given its directory entry
+// and the handle already open on it
+func currentFileInfo(info os.FileInfo, file *os.File) os.FileInfo {
+	return info
+}
+
+// Whether a stat error means the file was deleted while still open
+func deletePending(err error) bool {
+	return false
+}
+
+// A path in the form paths are compared in
+func normalizePath(path string) string {
+	return path
+}
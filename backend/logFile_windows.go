@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Open a log file for reading while its writer keeps appending and rotating
+// it. os.Open leaves out FILE_SHARE_DELETE, which would stop Traefik or a
+// rotation tool from renaming or deleting the file while it is being tailed.
+func openSharedFile(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	handle, err := syscall.CreateFile(name, syscall.GENERIC_READ,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(handle), path), nil
+}
+
+// The current size and mtime of a watched file. NTFS only updates the
+// directory entry when the writer flushes or closes its handle, so while
+// Traefik holds the log open the size from a path lookup lags behind; the
+// open handle has the real one.
+func currentFileInfo(info os.FileInfo, file *os.File) os.FileInfo {
+	if file == nil {
+		return info
+	}
+	open, err := file.Stat()
+	if err != nil || !os.SameFile(open, info) {
+		return info
+	}
+	return open
+}
+
+// Whether a stat error means the file was deleted while still open: its
+// name stays taken, and unreadable, until every handle on it is closed
+func deletePending(err error) bool {
+	return errors.Is(err, syscall.ERROR_ACCESS_DENIED)
+}
+
+// A path in the form paths are compared in; Windows paths are case
+// insensitive and take either slash
+func normalizePath(path string) string {
+	return strings.ToLower(filepath.ToSlash(path))
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const DEFAULT_LOG_RESCAN_INTERVAL = 10 * time.Second
+
+// Check whether a log source is a glob pattern rather than a file or directory
+func isGlobPattern(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// Expand a glob pattern into matching regular files. Besides filepath.Match
+// syntax, a "**" segment matches any number of directories.
+func expandGlob(pattern string) ([]string, error) {
+	pattern = filepath.Clean(pattern)
+	if !strings.Contains(pattern, "**") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return regularFiles(matches), nil
+	}
+
+	// Walk from the deepest directory without wildcards
+	segments := strings.Split(pattern, string(filepath.Separator))
+	baseLen := 0
+	for baseLen < len(segments) && !isGlobPattern(segments[baseLen]) {
+		baseLen++
+	}
+	base := strings.Join(segments[:baseLen], string(filepath.Separator))
+	switch {
+	case base == "" && strings.HasPrefix(pattern, string(filepath.Separator)):
+		base = string(filepath.Separator)
+	case base == "":
+		base = "."
+	case base == filepath.VolumeName(pattern):
+		// "C:" alone is the drive's current directory, not its root
+		base += string(filepath.Separator)
+	}
+	rest := segments[baseLen:]
+
+	var matches []string
+	err := filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Unreadable entries are skipped, not fatal
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return nil
+		}
+		if matchGlobSegments(rest, strings.Split(rel, string(filepath.Separator))) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+func matchGlobSegments(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Zero or more directories
+			for i := 0; i <= len(path); i++ {
+				if matchGlobSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if ok, err := filepath.Match(pattern[0], path[0]); err != nil || !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+func regularFiles(paths []string) []string {
+	files := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// Periodically re-expand glob sources, attaching watchers to files that
+// start matching and detaching ones that were deleted
+func (lp *LogParser) rescanGlobs(patterns []string, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			lp.syncGlobSources(patterns, stop)
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (lp *LogParser) syncGlobSources(patterns []string, stop chan struct{}) {
+	lp.sourcesMu.Lock()
+	defer lp.sourcesMu.Unlock()
+
+	// SetLogFiles replaced the sources while we waited for the lock
+	select {
+	case <-stop:
+		return
+	default:
+	}
+
+	matched := make(map[string]bool)
+	for _, pattern := range patterns {
+		files, err := expandGlob(pattern)
+		if err != nil {
+			watcherLog.Error("Error expanding log pattern", "pattern", pattern, "error", err)
+			continue
+		}
+		for _, file := range files {
+			matched[file] = true
+		}
+	}
+
+	watched := make(map[string]bool, len(lp.fileWatchers))
+	kept := lp.fileWatchers[:0]
+	changed := false
+	for _, fw := range lp.fileWatchers {
+		watched[fw.filePath] = true
+		watched[resolveLogPath(fw.filePath)] = true
+		if fw.fromGlob && !matched[fw.filePath] {
+			if _, err := os.Stat(fw.filePath); os.IsNotExist(err) {
+				watcherLog.Info("Log file no longer exists, detaching watcher", "file", fw.filePath)
+				fw.Stop()
+				changed = true
+				continue
+			}
+		}
+		kept = append(kept, fw)
+	}
+	lp.fileWatchers = kept
+
+	var added []string
+	for file := range matched {
+		if !watched[file] {
+			added = append(added, file)
+		}
+	}
+	sort.Strings(added)
+	// A symlink and its target are the same source
+	unique := added[:0]
+	for _, file := range added {
+		if target := resolveLogPath(file); !watched[target] {
+			watched[target] = true
+			unique = append(unique, file)
+		}
+	}
+	added = unique
+	for _, file := range added {
+		fw, err := NewFileWatcher(file, lp)
+		if err != nil {
+			watcherLog.Error("Failed to create file watcher", "file", file, "error", err)
+			continue
+		}
+		// New files are read from the beginning rather than tailed
+		fw.fromGlob = true
+		fw.isInitialLoad = false
+		if err := fw.Start(); err != nil {
+			watcherLog.Error("Failed to start file watcher", "file", file, "error", err)
+			continue
+		}
+		watcherLog.Info("Attached watcher to new log file", "file", file)
+		lp.fileWatchers = append(lp.fileWatchers, fw)
+		changed = true
+	}
+
+	if !changed {
+		return
+	}
+	files := make([]string, 0, len(lp.fileWatchers)+len(lp.remoteTailers))
+	for _, fw := range lp.fileWatchers {
+		files = append(files, fw.filePath)
+	}
+	for _, rt := range lp.remoteTailers {
+		files = append(files, rt.name)
+	}
+	lp.mu.Lock()
+	lp.watchedFiles = files
+	lp.mu.Unlock()
+	broadcastSystemEvent("logSourcesChanged", map[string]interface{}{"files": files})
+}
@@ -0,0 +1,68 @@
+package main
+
+import "strconv"
+
+// Secondary indexes over a sealed chunk of the log ring, so filtered reads
+// only visit entries that can match instead of scanning the whole buffer.
+// Each posting list holds the offsets in the chunk of the entries with that
+// value, oldest first. A chunk's index is built once when the chunk fills up
+// and never changes afterwards, so snapshots share it without locking.
+type LogIndex struct {
+	services      map[string][]uint16
+	tenants       map[string][]uint16
+	statusClasses [6][]uint16 // By status/100; 0 holds codes outside 1xx-5xx
+}
+
+func NewLogIndex(entries []LogEntry) *LogIndex {
+	x := &LogIndex{
+		services: make(map[string][]uint16),
+		tenants:  make(map[string][]uint16),
+	}
+	for i := range entries {
+		offset := uint16(i)
+		x.services[entries[i].ServiceName] = append(x.services[entries[i].ServiceName], offset)
+		if raw := entries[i].ServiceRaw; raw != "" && raw != entries[i].ServiceName {
+			// Filters match aliased services by either name
+			x.services[raw] = append(x.services[raw], offset)
+		}
+		x.tenants[entries[i].Tenant] = append(x.tenants[entries[i].Tenant], offset)
+		slot := statusClassSlot(entries[i].Status)
+		x.statusClasses[slot] = append(x.statusClasses[slot], offset)
+	}
+	return x
+}
+
+func statusClassSlot(status int) int {
+	if class := status / 100; class >= 1 && class <= 5 {
+		return class
+	}
+	return 0
+}
+
+// Get the shortest posting list every match of the filters must appear in;
+// ok is false when none of the filters is indexed
+func (x *LogIndex) Candidates(filters Filters) (offsets []uint16, ok bool) {
+	consider := func(candidate []uint16) {
+		if !ok || len(candidate) < len(offsets) {
+			offsets, ok = candidate, true
+		}
+	}
+	if filters.Service != "" {
+		consider(x.services[filters.Service])
+	}
+	if filters.Tenant != "" {
+		consider(x.tenants[filters.Tenant])
+	}
+	if filters.StatusClass != "" {
+		if class, valid := parseStatusClass(filters.StatusClass); valid {
+			consider(x.statusClasses[class])
+		}
+	}
+	if filters.Status != "" {
+		// Non-numeric status filters are ignored by matchesFilters too
+		if status, err := strconv.Atoi(filters.Status); err == nil {
+			consider(x.statusClasses[statusClassSlot(status)])
+		}
+	}
+	return offsets, ok
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const DEFAULT_LOG_LOAD_CONCURRENCY = 4 // Files read and decoded at once at startup
+
+// History of a watched file read before it is tailed
+type startupLoad struct {
+	watcher  *FileWatcher
+	rotated  []string // Retained rotations to read first, oldest first
+	maxLines int
+
+	// Set by the loader: where tailing continues, and the file's fingerprint
+	// at that point. loaded is false if the file couldn't be read.
+	loaded      bool
+	resumeAt    int64
+	fingerprint []byte
+}
+
+type loadBatch struct {
+	lines   []string
+	decoded []decodedLine
+}
+
+// Load the history of several files at once and add it oldest first across
+// all of them, so the newest entries overall are the ones kept. Files are
+// read and decoded concurrently (LOG_LOAD_CONCURRENCY at a time) while the
+// decoded streams are merged by timestamp; each file's lines keep their order.
+func (lp *LogParser) loadStartup(loads []*startupLoad) {
+	if len(loads) == 0 {
+		return
+	}
+	started := time.Now()
+	sem := make(chan struct{}, max(GetEnvInt("LOG_LOAD_CONCURRENCY", DEFAULT_LOG_LOAD_CONCURRENCY), 1))
+
+	streams := make([]*loadStream, len(loads))
+	for i, load := range loads {
+		batches := make(chan loadBatch, 1)
+		go lp.readStartupLoad(load, sem, batches)
+		streams[i] = &loadStream{source: load.watcher.filePath, order: i, batches: batches}
+	}
+
+	pending := make(loadHeap, 0, len(streams))
+	for _, s := range streams {
+		if lp.advanceStream(s) {
+			pending = append(pending, s)
+		}
+	}
+	heap.Init(&pending)
+	for len(pending) > 0 {
+		s := pending[0]
+		if lp.processDecoded(s.source, s.batch.lines[s.next], s.batch.decoded[s.next], false) {
+			s.accepted++
+		}
+		s.lines++
+		s.next++
+		if lp.advanceStream(s) {
+			heap.Fix(&pending, 0)
+		} else {
+			heap.Pop(&pending)
+		}
+	}
+
+	for i, s := range streams {
+		if len(loads[i].rotated) > 0 {
+			parserLog.Info("Loaded log entries", "file", s.source, "rotatedFiles", len(loads[i].rotated), "accepted", s.accepted, "lines", s.lines)
+		} else {
+			parserLog.Info("Loaded log entries", "file", s.source, "accepted", s.accepted, "lines", s.lines)
+		}
+	}
+	parserLog.Info("Loaded history of log files", "files", len(loads), "tookMs", time.Since(started).Milliseconds())
+}
+
+// Read a file's history in decoded batches: its rotations first, then its
+// last maxLines complete lines. A partly written last line is left to the
+// watcher, which continues where the load ended.
+func (lp *LogParser) readStartupLoad(load *startupLoad, sem chan struct{}, out chan<- loadBatch) {
+	defer close(out)
+	source := load.watcher.filePath
+
+	for _, rotated := range load.rotated {
+		file, err := openLogFile(rotated)
+		if err != nil {
+			watcherLog.Error("Error backfilling rotated file", "file", rotated, "error", err)
+			continue
+		}
+		if err := lp.sendLoadBatches(file, source, sem, out); err != nil {
+			watcherLog.Error("Error backfilling rotated file", "file", rotated, "error", err)
+		}
+		file.Close()
+	}
+
+	file, err := openSharedFile(source)
+	if err != nil {
+		watcherLog.Error("Error opening file", "file", source, "error", err)
+		return
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		watcherLog.Error("Error opening file", "file", source, "error", err)
+		return
+	}
+
+	end := newlineBefore(file, stat.Size(), 1)
+	start := end
+	switch {
+	case load.maxLines == INITIAL_LOAD_ALL:
+		start = 0
+	case load.maxLines > 0 && end > 0:
+		start = newlineBefore(file, end, load.maxLines+1)
+	}
+	if err := lp.sendLoadBatches(io.NewSectionReader(file, start, end-start), source, sem, out); err != nil {
+		watcherLog.Error("Error reading file", "file", source, "error", err)
+	}
+
+	if size := load.watcher.fingerprintSize; size > 0 {
+		head := make([]byte, size)
+		n, _ := file.ReadAt(head, 0)
+		load.fingerprint = head[:n]
+	}
+	load.resumeAt = end
+	load.loaded = true
+}
+
+// Read non-blank lines from r and send them decoded in batches. Reading and
+// decoding a batch takes a slot in sem; sending doesn't, so a loader waiting
+// for the merge to catch up never holds back the others.
+func (lp *LogParser) sendLoadBatches(r io.Reader, source string, sem chan struct{}, out chan<- loadBatch) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for more := true; more; {
+		sem <- struct{}{}
+		lines := make([]string, 0, PARSE_BATCH_SIZE)
+		for len(lines) < PARSE_BATCH_SIZE {
+			if more = scanner.Scan(); !more {
+				break
+			}
+			if line := scanner.Text(); strings.TrimSpace(line) != "" {
+				lines = append(lines, line)
+			}
+		}
+		decoded := parsePool.Decode(lp, source, lines)
+		<-sem
+
+		if len(lines) > 0 {
+			out <- loadBatch{lines: lines, decoded: decoded}
+		}
+	}
+	return scanner.Err()
+}
+
+// Find the offset just past the n-th newline counting back from end, or 0
+// if there are fewer
+func newlineBefore(file *os.File, end int64, n int) int64 {
+	buffer := make([]byte, 8192)
+	for end > 0 {
+		size := int64(len(buffer))
+		if end < size {
+			size = end
+		}
+		end -= size
+		if _, err := file.ReadAt(buffer[:size], end); err != nil && err != io.EOF {
+			return 0
+		}
+		for i := size - 1; i >= 0; i-- {
+			if buffer[i] != '\n' {
+				continue
+			}
+			if n--; n == 0 {
+				return end + i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// One file's decoded lines during the merge, positioned on its next entry
+type loadStream struct {
+	source  string
+	order   int // Breaks timestamp ties in the order the files were given
+	batches <-chan loadBatch
+	batch   loadBatch
+	next    int
+	at      time.Time // Timestamp of the next entry
+
+	lines, accepted int
+}
+
+// Move to the stream's next decoded entry; returns false once the stream is
+// done. Rejected lines have no timestamp to merge by and are recorded on the
+// way. An entry whose timestamp doesn't parse keeps its predecessor's.
+func (lp *LogParser) advanceStream(s *loadStream) bool {
+	for {
+		for ; s.next < len(s.batch.lines); s.next++ {
+			decoded := s.batch.decoded[s.next]
+			if decoded.entry != nil {
+				if at, err := time.Parse(time.RFC3339Nano, decoded.entry.Timestamp); err == nil {
+					s.at = at
+				}
+				return true
+			}
+			lp.processDecoded(s.source, s.batch.lines[s.next], decoded, false)
+			s.lines++
+		}
+		batch, ok := <-s.batches
+		if !ok {
+			return false
+		}
+		s.batch, s.next = batch, 0
+	}
+}
+
+// Streams ordered by their next entry's timestamp
+type loadHeap []*loadStream
+
+func (h loadHeap) Len() int { return len(h) }
+func (h loadHeap) Less(i, j int) bool {
+	if !h[i].at.Equal(h[j].at) {
+		return h[i].at.Before(h[j].at)
+	}
+	return h[i].order < h[j].order
+}
+func (h loadHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *loadHeap) Push(x any)   { *h = append(*h, x.(*loadStream)) }
+func (h *loadHeap) Pop() any {
+	old := *h
+	s := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return s
+}
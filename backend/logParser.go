@@ -1,30 +1,38 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"math"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
 type LogEntry struct {
 	ID                      string  `json:"id"`
+	Seq                     uint64  `json:"seq,omitempty"` // Monotonic stream sequence number
 	Timestamp               string  `json:"timestamp"`
 	ClientIP                string  `json:"clientIP"`
+	ProxyIP                 string  `json:"proxyIP,omitempty"` // Trusted proxy ClientIP was forwarded by
 	Method                  string  `json:"method"`
 	Path                    string  `json:"path"`
+	PathTemplate            string  `json:"pathTemplate,omitempty"` // Path grouped into its endpoint, e.g. /users/:id
 	Status                  int     `json:"status"`
 	ResponseTime            float64 `json:"responseTime"`
 	ServiceName             string  `json:"serviceName"`
 	RouterName              string  `json:"routerName"`
+	ServiceRaw              string  `json:"serviceRaw,omitempty"` // Original name when ServiceName is an alias
+	RouterRaw               string  `json:"routerRaw,omitempty"`  // Original name when RouterName is an alias
 	Host                    string  `json:"host"`
 	RequestAddr             string  `json:"requestAddr"`
 	RequestHost             string  `json:"requestHost"`
@@ -63,13 +71,66 @@ type LogEntry struct {
 	TLSClientSubject        string  `json:"TLSClientSubject,omitempty"`
 	TraceId                 string  `json:"TraceId,omitempty"`
 	SpanId                  string  `json:"SpanId,omitempty"`
+	ParentSpanId            string  `json:"ParentSpanId,omitempty"` // Only known for OTLP spans
+	Span                    *SpanInfo `json:"span,omitempty"`         // Name, status and events of OTLP spans
+	Headers                 map[string]string `json:"headers,omitempty"` // Header fields kept by LOG_HEADERS
 	
 	// OTLP-specific metadata
 	DataSource              string  `json:"dataSource,omitempty"` // "logfile", "otlp"
 	OTLPReceiveTime         string  `json:"otlpReceiveTime,omitempty"`
+	Tenant                  string  `json:"tenant,omitempty"` // Owning tenant when multi-tenancy is enabled
+	Source                  string  `json:"-"`                // Log file the entry was read from
+
+	clientAddr              netip.Addr // ClientIP parsed once at ingestion; invalid when not an IP
 }
 
-type RawLogEntry map[string]interface{}
+// The Traefik access log fields the parser reads. Decoding into a struct
+// skips unknown keys instead of building a map of every field, and logValue
+// keeps the lenient typing of the map lookups (numbers sent as strings, wrong
+// types falling back to defaults).
+type RawLogEntry struct {
+	Time                  logValue `json:"time"`
+	Level                 logValue `json:"level"`
+	ClientAddr            logValue `json:"ClientAddr"`
+	ClientHost            logValue `json:"ClientHost"`
+	ClientPort            logValue `json:"ClientPort"`
+	ClientUsername        logValue `json:"ClientUsername"`
+	RequestMethod         logValue `json:"RequestMethod"`
+	RequestPath           logValue `json:"RequestPath"`
+	RequestHost           logValue `json:"RequestHost"`
+	RequestAddr           logValue `json:"RequestAddr"`
+	RequestPort           logValue `json:"RequestPort"`
+	RequestProtocol       logValue `json:"RequestProtocol"`
+	RequestScheme         logValue `json:"RequestScheme"`
+	RequestLine           logValue `json:"RequestLine"`
+	RequestContentSize    logValue `json:"RequestContentSize"`
+	RequestCount          logValue `json:"RequestCount"`
+	UserAgent             logValue `json:"request_User-Agent"`
+	DownstreamStatus      logValue `json:"DownstreamStatus"`
+	DownstreamContentSize logValue `json:"DownstreamContentSize"`
+	Duration              logValue `json:"Duration"`
+	OriginDuration        logValue `json:"OriginDuration"`
+	OriginContentSize     logValue `json:"OriginContentSize"`
+	OriginStatus          logValue `json:"OriginStatus"`
+	Overhead              logValue `json:"Overhead"`
+	RetryAttempts         logValue `json:"RetryAttempts"`
+	GzipRatio             logValue `json:"GzipRatio"`
+	ServiceName           logValue `json:"ServiceName"`
+	ServiceURL            logValue `json:"ServiceURL"`
+	ServiceAddr           logValue `json:"ServiceAddr"`
+	RouterName            logValue `json:"RouterName"`
+	StartUTC              logValue `json:"StartUTC"`
+	StartLocal            logValue `json:"StartLocal"`
+	TLSVersion            logValue `json:"TLSVersion"`
+	TLSCipher             logValue `json:"TLSCipher"`
+	TLSClientSubject      logValue `json:"TLSClientSubject"`
+	TraceId               logValue `json:"TraceId"`
+	SpanId                logValue `json:"SpanId"`
+	XForwardedFor         logValue `json:"request_X-Forwarded-For"`
+	XRealIP               logValue `json:"request_X-Real-Ip"`
+	CFConnectingIP        logValue `json:"request_Cf-Connecting-Ip"`
+	TrueClientIP          logValue `json:"request_True-Client-Ip"`
+}
 
 type Stats struct {
 	TotalRequests          int                    `json:"totalRequests"`
@@ -78,6 +139,9 @@ type Stats struct {
 	Routers                map[string]int         `json:"routers"`
 	Methods                map[string]int         `json:"methods"`
 	AvgResponseTime        float64                `json:"avgResponseTime"`
+	P50ResponseTime        float64                `json:"p50ResponseTime"`
+	P95ResponseTime        float64                `json:"p95ResponseTime"`
+	P99ResponseTime        float64                `json:"p99ResponseTime"`
 	Requests5xx            int                    `json:"requests5xx"`
 	Requests4xx            int                    `json:"requests4xx"`
 	Requests2xx            int                    `json:"requests2xx"`
@@ -88,6 +152,7 @@ type Stats struct {
 	TopRouters             []RouterCount          `json:"topRouters"`
 	TopRequestAddrs        []AddrCount            `json:"topRequestAddrs"`
 	TopRequestHosts        []HostCount            `json:"topRequestHosts"`
+	TopPaths               []PathCount            `json:"topPaths"` // By path template
 	GeoProcessingRemaining int                    `json:"geoProcessingRemaining"`
 	TotalDataTransmitted   int64                  `json:"totalDataTransmitted"`
 	OldestLogTime          string                 `json:"oldestLogTime"`
@@ -126,6 +191,11 @@ type HostCount struct {
 	Count int    `json:"count"`
 }
 
+type PathCount struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
 type LogsParams struct {
 	Page    int     `json:"page"`
 	Limit   int     `json:"limit"`
@@ -139,6 +209,60 @@ type Filters struct {
 	HideUnknown    bool   `json:"hideUnknown"`
 	HidePrivateIPs bool   `json:"hidePrivateIPs"`
 	DataSource     string `json:"dataSource"` // "logfile", "otlp", "all"
+	ClientIP       string `json:"clientIP"`
+	CIDR           string `json:"cidr"`        // e.g. "203.0.113.0/24"
+	StatusClass    string `json:"statusClass"` // e.g. "5xx"
+	Query          string `json:"query"`       // filter DSL, e.g. "service:api -status:2xx"
+	Tenant         string `json:"tenant"`
+
+	cidr       netip.Prefix
+	clientAddr netip.Addr
+	queryTerms []queryTerm
+	compiled   bool
+}
+
+// Parse the CIDR and query filters so they can be matched against entries
+func (f *Filters) Compile() error {
+	if f.compiled {
+		return nil
+	}
+	if f.CIDR != "" {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(f.CIDR))
+		if err != nil {
+			return fmt.Errorf("invalid cidr %q: %v", f.CIDR, err)
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			// Entries hold IPv4-mapped addresses in IPv4 form
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		f.cidr = prefix.Masked()
+	}
+	if f.ClientIP != "" {
+		f.clientAddr, _ = parseClientAddr(strings.TrimSpace(f.ClientIP))
+	}
+	if f.StatusClass != "" {
+		if _, ok := parseStatusClass(f.StatusClass); !ok {
+			return fmt.Errorf("invalid statusClass %q", f.StatusClass)
+		}
+	}
+	if f.Query != "" {
+		terms, err := parseQuery(f.Query)
+		if err != nil {
+			return err
+		}
+		f.queryTerms = terms
+	}
+	f.compiled = true
+	return nil
+}
+
+// Check whether any filter is set
+func (f Filters) IsEmpty() bool {
+	return f.Service == "" && f.Status == "" && f.Router == "" &&
+		!f.HideUnknown && !f.HidePrivateIPs &&
+		(f.DataSource == "" || f.DataSource == "all") &&
+		f.ClientIP == "" && f.CIDR == "" && f.StatusClass == "" && f.Query == "" &&
+		f.Tenant == ""
 }
 
 type LogsResult struct {
@@ -148,6 +272,11 @@ type LogsResult struct {
 	TotalPages int        `json:"totalPages"`
 }
 
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
 type GeoStats struct {
 	Countries              []CountryCount `json:"countries"`
 	TotalCountries         int            `json:"totalCountries"`
@@ -155,21 +284,31 @@ type GeoStats struct {
 }
 
 type LogParser struct {
-	logs                  []LogEntry
+	logs                  *LogRing // Newest first
 	maxLogs               int
+	budgetMaxLogs         int // Cap from the memory budget, 0 when there is none
 	fileWatchers          []*FileWatcher  // Changed: support multiple watchers
 	stats                 Stats
 	lastTimestamp         time.Time
 	requestsInLastSecond  int
 	geoProcessingQueue    []string
-	processedIPs          map[string]bool
+	processedIPs          *RecentSet // IPs queued for geo lookup recently
 	isProcessingGeo       bool
-	mu                    sync.RWMutex
+	// Lock order when more than one is needed: mu, statsMu, geoMu
+	mu                    sync.RWMutex // Logs buffer, sequence numbers, listeners and sources
+	statsMu               sync.RWMutex // Aggregated stats, counters and top lists
+	geoMu                 sync.Mutex   // Geo processing queue
 	listeners             []chan LogEntry
-	topIPs                map[string]int
-	topRouters            map[string]int
-	topRequestAddrs       map[string]int
-	topRequestHosts       map[string]int
+	listenerDrops         rateCounter // Entries a listener's full channel missed
+	rawListeners          []chan RawLine
+	topIPs                *TopCounter
+	topRouters            *TopCounter
+	topRequestAddrs       *TopCounter
+	topRequestHosts       *TopCounter
+	topPaths              *TopCounter
+	countries             *TopCounter // Ordered view of stats.Countries
+	recentResponseTimes   *RecentMean // Last 100 entries, for AvgResponseTime
+	responseTimes         *TDigest
 	totalDataTransmitted  int64
 	oldestLogTime         time.Time
 	newestLogTime         time.Time
@@ -180,12 +319,35 @@ type LogParser struct {
 	otlpRequestCount      int
 	logFileRequestCount   int
 	dataSourceCounts      map[string]int
+
+	// Log sources attached by the last successful SetLogFiles
+	watchedFiles          []string
+	sourcesReady          bool
+	// Serializes changes to fileWatchers; globStop ends the glob rescan
+	sourcesMu             sync.Mutex
+	globStop              chan struct{}
+	remoteTailers         []*RemoteTailer
+
+	// Snapshots of the computed stats shared between readers
+	statsCache            *StatsCache
+	// Latest view of the logs, published under mu after every change so
+	// readers never take mu
+	logsSnapshot          atomic.Pointer[LogSnapshot]
+
+	// Change tracking for conditional requests
+	version               atomic.Uint64
+	lastModified          atomic.Int64 // Unix nanoseconds
+
+	// Last sequence number assigned to a processed entry
+	seq                   uint64
+	// Disambiguates entry IDs parsed in the same nanosecond
+	entryCounter          atomic.Uint64
 }
 
 func NewLogParser() *LogParser {
-	return &LogParser{
-		logs:            make([]LogEntry, 0),
-		maxLogs:         10000,
+	lp := &LogParser{
+		logs:            NewLogRing(DEFAULT_MAX_LOGS),
+		maxLogs:         DEFAULT_MAX_LOGS,
 		fileWatchers:    make([]*FileWatcher, 0), // Initialize as slice
 		stats:           Stats{
 			StatusCodes:     make(map[int]int),
@@ -197,12 +359,16 @@ func NewLogParser() *LogParser {
 		},
 		lastTimestamp:        time.Now(),
 		geoProcessingQueue:   make([]string, 0),
-		processedIPs:         make(map[string]bool),
+		processedIPs:         NewRecentSet(DEFAULT_GEO_TRACKED_IPS, GEO_TRACKED_IPS_MAX_AGE),
 		listeners:            make([]chan LogEntry, 0),
-		topIPs:               make(map[string]int),
-		topRouters:           make(map[string]int),
-		topRequestAddrs:      make(map[string]int),
-		topRequestHosts:      make(map[string]int),
+		topIPs:               NewTopCounter(),
+		topRouters:           NewTopCounter(),
+		topRequestAddrs:      NewTopCounter(),
+		topRequestHosts:      NewTopCounter(),
+		topPaths:             NewTopCounter(),
+		countries:            NewTopCounter(),
+		recentResponseTimes:  NewRecentMean(RECENT_RESPONSE_TIME_WINDOW),
+		responseTimes:        NewTDigest(DEFAULT_TDIGEST_COMPRESSION),
 		totalDataTransmitted: 0,
 		oldestLogTime:        time.Time{},
 		newestLogTime:        time.Time{},
@@ -210,6 +376,10 @@ func NewLogParser() *LogParser {
 		geoStopChan:          make(chan struct{}),
 		dataSourceCounts:     make(map[string]int),
 	}
+	lp.statsCache = NewStatsCache(lp.version.Load, DEFAULT_STATS_CACHE_INTERVAL, DEFAULT_STATS_CACHE_MAX_ENTRIES)
+	lp.publishLogsLocked()
+	lp.lastModified.Store(time.Now().UnixNano())
+	return lp
 }
 
 func (lp *LogParser) Stop() {
@@ -217,12 +387,22 @@ func (lp *LogParser) Stop() {
 	close(lp.geoStopChan)
 	
 	// Stop all file watchers
+	lp.sourcesMu.Lock()
+	if lp.globStop != nil {
+		close(lp.globStop)
+		lp.globStop = nil
+	}
 	for _, fw := range lp.fileWatchers {
 		if fw != nil {
 			fw.Stop()
 		}
 	}
 	lp.fileWatchers = nil
+	for _, rt := range lp.remoteTailers {
+		rt.Stop()
+	}
+	lp.remoteTailers = nil
+	lp.sourcesMu.Unlock()
 	
 	// Clean up listeners
 	lp.mu.Lock()
@@ -233,19 +413,40 @@ func (lp *LogParser) Stop() {
 	lp.mu.Unlock()
 }
 
-// Enhanced function to handle multiple paths and directories
-func (lp *LogParser) SetLogFiles(logPaths []string) error {
-	// Stop existing file watchers
+// Stop following sources once what they've written so far is parsed.
+// Remote tailers have no end to read up to and are just stopped.
+func (lp *LogParser) DrainSources() {
+	lp.sourcesMu.Lock()
+	defer lp.sourcesMu.Unlock()
+	if lp.globStop != nil {
+		close(lp.globStop)
+		lp.globStop = nil
+	}
 	for _, fw := range lp.fileWatchers {
 		if fw != nil {
-			fw.Stop()
+			fw.DrainAndStop()
 		}
 	}
 	lp.fileWatchers = nil
+	for _, rt := range lp.remoteTailers {
+		rt.Stop()
+	}
+	lp.remoteTailers = nil
+}
 
-	log.Printf("Setting up monitoring for %d log path(s)", len(logPaths))
+// Enhanced function to handle multiple paths and directories. Sources that
+// were already attached keep running, so setting them again neither drops
+// their position nor loads their history twice.
+func (lp *LogParser) SetLogFiles(logPaths []string) error {
+	lp.sourcesMu.Lock()
+	defer lp.sourcesMu.Unlock()
+
+	watcherLog.Info("Setting up log monitoring", "paths", len(logPaths))
 
 	var filesToMonitor []string
+	var remoteURLs []string
+	var globPatterns []string
+	fromGlob := make(map[string]bool)
 
 	// Process each path
 	for _, path := range logPaths {
@@ -254,15 +455,37 @@ func (lp *LogParser) SetLogFiles(logPaths []string) error {
 			continue
 		}
 
+		// Log served over HTTP(S) - tailed with Range requests
+		if isRemoteSource(path) {
+			remoteURLs = append(remoteURLs, path)
+			continue
+		}
+
 		// Remove trailing slash for consistency
 		if strings.HasSuffix(path, "/") && len(path) > 1 {
 			path = path[:len(path)-1]
 		}
 
+		// Glob pattern - match now and rescan for new files later
+		if isGlobPattern(path) {
+			matches, err := expandGlob(path)
+			if err != nil {
+				watcherLog.Error("Invalid log file pattern", "pattern", path, "error", err)
+				continue
+			}
+			watcherLog.Info("Expanded log file pattern", "pattern", path, "files", len(matches))
+			globPatterns = append(globPatterns, path)
+			for _, match := range matches {
+				fromGlob[match] = true
+			}
+			filesToMonitor = append(filesToMonitor, matches...)
+			continue
+		}
+
 		// Check if path exists
 		info, err := os.Stat(path)
 		if err != nil {
-			log.Printf("Warning: Path %s does not exist: %v", path, err)
+			watcherLog.Warn("Log path does not exist", "path", path, "error", err)
 			continue
 		}
 
@@ -270,7 +493,7 @@ func (lp *LogParser) SetLogFiles(logPaths []string) error {
 			// It's a directory - find log files
 			foundFiles, err := lp.findLogFilesInDirectory(path)
 			if err != nil {
-				log.Printf("Error scanning directory %s: %v", path, err)
+				watcherLog.Error("Error scanning directory", "dir", path, "error", err)
 				continue
 			}
 			filesToMonitor = append(filesToMonitor, foundFiles...)
@@ -280,39 +503,136 @@ func (lp *LogParser) SetLogFiles(logPaths []string) error {
 		}
 	}
 
-	if len(filesToMonitor) == 0 {
+	if len(filesToMonitor) == 0 && len(globPatterns) == 0 && len(remoteURLs) == 0 {
 		return fmt.Errorf("no valid log files found in provided paths: %v", logPaths)
 	}
 
-	log.Printf("Found %d log files to monitor: %v", len(filesToMonitor), filesToMonitor)
+	watcherLog.Info("Found log files to monitor", "files", filesToMonitor)
+
+	lp.mu.Lock()
+	lp.sourcesReady = false
+	lp.watchedFiles = nil
+	lp.mu.Unlock()
+
+	// Stop the previous glob rescan; attached sources are sorted out below
+	if lp.globStop != nil {
+		close(lp.globStop)
+		lp.globStop = nil
+	}
+	attached := make(map[string]*FileWatcher, len(lp.fileWatchers))
+	for _, fw := range lp.fileWatchers {
+		attached[fw.filePath] = fw
+	}
+	lp.fileWatchers = nil
+	attachedRemote := make(map[string]*RemoteTailer, len(lp.remoteTailers))
+	for _, rt := range lp.remoteTailers {
+		attachedRemote[rt.url] = rt
+	}
+	lp.remoteTailers = nil
+
+	monitored := make(map[string]bool, len(filesToMonitor))
+	for _, filePath := range filesToMonitor {
+		monitored[filePath] = true
+	}
+	backfill := GetEnvBool("LOG_BACKFILL_ROTATED", false)
 
 	// Create file watchers for each file
+	seen := make(map[string]bool)
+	var loads []*startupLoad
 	for _, filePath := range filesToMonitor {
+		// A symlink and its target are the same source
+		if seen[filePath] || seen[resolveLogPath(filePath)] {
+			continue
+		}
+		seen[filePath] = true
+		seen[resolveLogPath(filePath)] = true
+
+		if fw := attached[filePath]; fw != nil {
+			delete(attached, filePath)
+			fw.fromGlob = fromGlob[filePath]
+			lp.fileWatchers = append(lp.fileWatchers, fw)
+			continue
+		}
+
 		fw, err := NewFileWatcher(filePath, lp)
 		if err != nil {
-			log.Printf("Failed to create file watcher for %s: %v", filePath, err)
+			watcherLog.Error("Failed to create file watcher", "file", filePath, "error", err)
 			continue
 		}
+		fw.fromGlob = fromGlob[filePath]
 
 		lp.fileWatchers = append(lp.fileWatchers, fw)
 
-		// Load recent logs from this file (reduced per file to avoid memory issues)
-		lp.loadRecentLogs(filePath, 500)
+		// Retained rotations are read first so history stays in chronological order
+		load := &startupLoad{watcher: fw, maxLines: InitialLinesFor(filePath)}
+		if backfill {
+			load.rotated = rotatedBackfill(filePath, monitored)
+		}
+		loads = append(loads, load)
+	}
 
-		// Start file watching
+	for _, fw := range attached {
+		watcherLog.Info("Log file is no longer configured, detaching watcher", "file", fw.filePath)
+		fw.Stop()
+	}
+
+	// Load the new files' history together, then tail each from where it ended
+	lp.loadStartup(loads)
+	for _, load := range loads {
+		fw := load.watcher
+		if load.loaded {
+			fw.resumeAt(load.resumeAt, load.fingerprint)
+		}
 		if err := fw.Start(); err != nil {
-			log.Printf("Failed to start file watcher for %s: %v", filePath, err)
+			watcherLog.Error("Failed to start file watcher", "file", fw.filePath, "error", err)
 			continue
 		}
 
-		log.Printf("Setting up tail for file: %s", filePath)
+		watcherLog.Info("Tailing file", "file", fw.filePath)
+	}
+
+	for _, url := range remoteURLs {
+		if rt := attachedRemote[url]; rt != nil {
+			delete(attachedRemote, url)
+			lp.remoteTailers = append(lp.remoteTailers, rt)
+			continue
+		}
+		rt := NewRemoteTailer(url, lp)
+		rt.Start(InitialLinesFor(rt.name))
+		lp.remoteTailers = append(lp.remoteTailers, rt)
+		watcherLog.Info("Tailing remote log", "source", rt.name)
+	}
+	for _, rt := range attachedRemote {
+		watcherLog.Info("Remote log is no longer configured, stopping", "source", rt.name)
+		rt.Stop()
 	}
 
-	if len(lp.fileWatchers) == 0 {
+	if len(lp.fileWatchers) == 0 && len(globPatterns) == 0 && len(lp.remoteTailers) == 0 {
 		return fmt.Errorf("failed to start any file watchers for paths: %v", logPaths)
 	}
 
-	log.Printf("Successfully started %d file watchers", len(lp.fileWatchers))
+	watcherLog.Info("Started file watchers", "watchers", len(lp.fileWatchers))
+
+	watched := make([]string, 0, len(lp.fileWatchers)+len(lp.remoteTailers))
+	for _, fw := range lp.fileWatchers {
+		watched = append(watched, fw.filePath)
+	}
+	for _, rt := range lp.remoteTailers {
+		watched = append(watched, rt.name)
+	}
+	lp.mu.Lock()
+	lp.watchedFiles = watched
+	lp.sourcesReady = true
+	lp.mu.Unlock()
+
+	if len(globPatterns) > 0 {
+		interval := time.Duration(GetEnvInt("LOG_RESCAN_INTERVAL_SECONDS", int(DEFAULT_LOG_RESCAN_INTERVAL/time.Second))) * time.Second
+		if interval < time.Second {
+			interval = DEFAULT_LOG_RESCAN_INTERVAL
+		}
+		lp.globStop = make(chan struct{})
+		go lp.rescanGlobs(globPatterns, interval, lp.globStop)
+	}
 
 	// Start geo processing
 	go lp.startGeoProcessing()
@@ -324,27 +644,48 @@ func (lp *LogParser) SetLogFiles(logPaths []string) error {
 func (lp *LogParser) findLogFilesInDirectory(dirPath string) ([]string, error) {
 	var logFiles []string
 
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+	scan, err := GetDirScanConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	err = filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			log.Printf("Warning: Error accessing %s: %v", path, err)
+			watcherLog.Warn("Error accessing path", "path", path, "error", err)
 			return nil // Continue walking
 		}
 
+		if rel, relErr := filepath.Rel(dirPath, path); relErr == nil && rel != "." && scan.Excluded(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		// Skip directories
 		if info.IsDir() {
 			return nil
 		}
 
-		// Skip very small files (likely empty)
-		if info.Size() < 50 {
+		// Judge symlinked files (e.g. /var/log/containers) by their target
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(path)
+			if err != nil || !target.Mode().IsRegular() {
+				return nil
+			}
+			info = target
+		}
+
+		// Skip very small (likely empty) and stale files
+		if !scan.Eligible(info) {
 			return nil
 		}
 
 		// Check if it's likely a log file
 		if lp.isLogFile(path, info) {
 			logFiles = append(logFiles, path)
-			log.Printf("Found log file: %s (size: %d bytes, modified: %s)", 
-				path, info.Size(), info.ModTime().Format(time.RFC3339))
+			watcherLog.Debug("Found log file", "file", path, "size", info.Size(),
+				"modified", info.ModTime().Format(time.RFC3339))
 		}
 
 		return nil
@@ -364,7 +705,7 @@ func (lp *LogParser) findLogFilesInDirectory(dirPath string) ([]string, error) {
 		return infoI.ModTime().After(infoJ.ModTime())
 	})
 
-	log.Printf("Found %d log files in directory %s", len(logFiles), dirPath)
+	watcherLog.Info("Scanned log directory", "dir", dirPath, "files", len(logFiles))
 	return logFiles, nil
 }
 
@@ -382,11 +723,6 @@ func (lp *LogParser) isLogFile(path string, info os.FileInfo) bool {
 		"apache",
 	}
 
-	// Skip very old files (older than 7 days) unless they're large
-	if time.Since(info.ModTime()) > 7*24*time.Hour && info.Size() < 1024*1024 {
-		return false
-	}
-
 	// Check for log patterns in filename
 	for _, pattern := range logPatterns {
 		if strings.Contains(name, pattern) {
@@ -404,7 +740,7 @@ func (lp *LogParser) isLogFile(path string, info os.FileInfo) bool {
 
 // Check if file contains JSON log entries
 func (lp *LogParser) hasJSONContent(filePath string) bool {
-	file, err := os.Open(filePath)
+	file, err := openSharedFile(filePath)
 	if err != nil {
 		return false
 	}
@@ -467,159 +803,158 @@ func min(a, b int) int {
 	return b
 }
 
-func (lp *LogParser) loadRecentLogs(filePath string, maxLines int) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		log.Printf("Error opening file %s: %v", filePath, err)
-		return
-	}
-	defer file.Close()
+// Parse a line read from source. Live lines (emit) are also copied to raw
+// line listeners along with the parser's verdict.
+func (lp *LogParser) parseLine(source, line string, emit bool) bool {
+	return lp.processDecoded(source, line, lp.decodeLine(source, line), emit)
+}
 
-	// Get file size
-	stat, err := file.Stat()
-	if err != nil {
-		return
+// Parse a batch of lines from source: decoded in parallel on the parse pool,
+// then processed in their original order. Returns which lines were accepted.
+func (lp *LogParser) parseLines(source string, lines []string, emit bool) []bool {
+	decoded := parsePool.Decode(lp, source, lines)
+	accepted := make([]bool, len(lines))
+	for i, line := range lines {
+		accepted[i] = lp.processDecoded(source, line, decoded[i], emit)
 	}
+	return accepted
+}
 
-	// Start from end and read backwards to get last N lines
-	var lines []string
-	var offset int64 = stat.Size()
-	bufferSize := int64(8192)
-	
-	for len(lines) < maxLines && offset > 0 {
-		if offset < bufferSize {
-			bufferSize = offset
-		}
-		offset -= bufferSize
-		
-		buffer := make([]byte, bufferSize)
-		_, err := file.ReadAt(buffer, offset)
-		if err != nil && err != io.EOF {
-			break
-		}
-		
-		// Process buffer in reverse
-		content := string(buffer)
-		newLines := strings.Split(content, "\n")
-		
-		// Prepend to lines slice
-		if len(lines) > 0 && len(newLines) > 0 {
-			// Handle partial line at boundary
-			lines[0] = newLines[len(newLines)-1] + lines[0]
-			if len(newLines) > 1 {
-				lines = append(newLines[:len(newLines)-1], lines...)
-			}
-		} else {
-			lines = append(newLines, lines...)
-		}
-		
-		if len(lines) > maxLines {
-			lines = lines[len(lines)-maxLines:]
-			break
+// Store a decoded line's entry and record why it was rejected if it was
+func (lp *LogParser) processDecoded(source, line string, decoded decodedLine, emit bool) bool {
+	accepted, reason := false, decoded.reason
+	if decoded.entry != nil {
+		if accepted = lp.processLogEntry(decoded.entry, emit); !accepted {
+			reason = "rejected by processor"
 		}
+		// Stored and broadcast by value, so the entry can be reused
+		putLogEntry(decoded.entry)
 	}
-
-	// Parse the lines
-	validLines := 0
-	for _, line := range lines {
-		if strings.TrimSpace(line) != "" {
-			if lp.parseLine(line, false) {
-				validLines++
-			}
+	if !accepted && reason != "empty line" {
+		if sample, broadcast := parseErrorTracker.Record(source, line, reason); broadcast {
+			broadcastParseError(sample)
 		}
 	}
-	
-	log.Printf("Loading %d valid log entries from %s (out of %d lines)", validLines, filePath, len(lines))
+	if emit {
+		lp.notifyRawListeners(source, line, accepted, reason)
+	}
+	return accepted
 }
 
-func (lp *LogParser) parseLine(line string, emit bool) bool {
+// Decode a line into an entry without touching parser state, so it is safe
+// to run concurrently; reason says why the line was rejected if it was
+func (lp *LogParser) decodeLine(source, line string) decodedLine {
 	if strings.TrimSpace(line) == "" {
-		return false
+		return decodedLine{reason: "empty line"}
 	}
 
-	var raw RawLogEntry
-	if err := json.Unmarshal([]byte(line), &raw); err != nil {
-		return false // Ignore non-JSON lines
+	scratch := getDecodeScratch(line)
+	defer putDecodeScratch(scratch)
+	raw := &scratch.raw
+	if err := json.Unmarshal(scratch.line, raw); err != nil {
+		return decodedLine{reason: "invalid JSON: " + err.Error()} // Ignore non-JSON lines
 	}
 
 	// Check if this looks like a valid Traefik log entry
 	if !lp.isValidTraefikLog(raw) {
-		return false
+		return decodedLine{reason: "not a Traefik access log entry"}
 	}
 
-	logEntry := LogEntry{
-		ID:           fmt.Sprintf("%d-%d", time.Now().UnixNano(), len(lp.logs)),
-		Timestamp:    getStringValue(raw, "time", time.Now().Format(time.RFC3339)),
-		ClientIP:     lp.extractIP(getStringValue(raw, "ClientAddr", "")),
-		Method:       getStringValue(raw, "RequestMethod", "GET"),
-		Path:         getStringValue(raw, "RequestPath", ""),
-		Status:       getIntValue(raw, "DownstreamStatus", 0),
-		ResponseTime: getFloatValue(raw, "Duration", 0) / 1e6, // Convert nanoseconds to ms
-		ServiceName:  getStringValue(raw, "ServiceName", "unknown"),
-		RouterName:   getStringValue(raw, "RouterName", "unknown"),
-		Host:         getStringValue(raw, "RequestHost", ""),
-		RequestAddr:  getStringValue(raw, "RequestAddr", ""),
-		RequestHost:  getStringValue(raw, "RequestHost", ""),
-		UserAgent:    getStringValue(raw, "request_User-Agent", ""),
-		Size:         getIntValue(raw, "DownstreamContentSize", 0),
+	clientIP, clientAddr := extractClientIP(raw.ClientAddr.String(""))
+	proxyIP := ""
+	if resolver := clientIPResolver.Load(); resolver != nil {
+		if ip, addr, ok := resolver.Resolve(clientAddr, raw); ok {
+			proxyIP, clientIP, clientAddr = clientIP, ip, addr
+		}
+	}
+	timestamp := raw.Time.String("")
+	if !raw.Time.isString {
+		timestamp = time.Now().Format(time.RFC3339)
+	}
+	logEntry := getLogEntry()
+	*logEntry = LogEntry{
+		ID:           lp.nextEntryID(),
+		Timestamp:    timestamp,
+		ClientIP:     clientIP,
+		ProxyIP:      proxyIP,
+		Method:       raw.RequestMethod.String("GET"),
+		Path:         raw.RequestPath.String(""),
+		Status:       raw.DownstreamStatus.Int(0),
+		ResponseTime: raw.Duration.Float(0) / 1e6, // Convert nanoseconds to ms
+		ServiceName:  raw.ServiceName.String("unknown"),
+		RouterName:   raw.RouterName.String("unknown"),
+		Host:         raw.RequestHost.String(""),
+		RequestAddr:  raw.RequestAddr.String(""),
+		RequestHost:  raw.RequestHost.String(""),
+		UserAgent:    raw.UserAgent.String(""),
+		Size:         raw.DownstreamContentSize.Int(0),
 		
 		// Additional fields
-		StartUTC:           getStringValue(raw, "StartUTC", ""),
-		StartLocal:         getStringValue(raw, "StartLocal", ""),
-		Duration:           getInt64Value(raw, "Duration", 0),
-		ServiceURL:         getStringValue(raw, "ServiceURL", ""),
-		ServiceAddr:        getStringValue(raw, "ServiceAddr", ""),
-		ClientHost:         getStringValue(raw, "ClientHost", ""),
-		ClientPort:         getStringValue(raw, "ClientPort", ""),
-		ClientUsername:     getStringValue(raw, "ClientUsername", ""),
-		RequestPort:        getStringValue(raw, "RequestPort", ""),
-		RequestProtocol:    getStringValue(raw, "RequestProtocol", ""),
-		RequestScheme:      getStringValue(raw, "RequestScheme", ""),
-		RequestLine:        getStringValue(raw, "RequestLine", ""),
-		RequestContentSize: getIntValue(raw, "RequestContentSize", 0),
-		OriginDuration:     getInt64Value(raw, "OriginDuration", 0),
-		OriginContentSize:  getIntValue(raw, "OriginContentSize", 0),
-		OriginStatus:       getIntValue(raw, "OriginStatus", 0),
-		DownstreamStatus:   getIntValue(raw, "DownstreamStatus", 0),
-		RequestCount:       getIntValue(raw, "RequestCount", 0),
-		GzipRatio:          getFloatValue(raw, "GzipRatio", 0),
-		Overhead:           getInt64Value(raw, "Overhead", 0),
-		RetryAttempts:      getIntValue(raw, "RetryAttempts", 0),
-		TLSVersion:         getStringValue(raw, "TLSVersion", ""),
-		TLSCipher:          getStringValue(raw, "TLSCipher", ""),
-		TLSClientSubject:   getStringValue(raw, "TLSClientSubject", ""),
-		TraceId:            getStringValue(raw, "TraceId", ""),
-		SpanId:             getStringValue(raw, "SpanId", ""),
+		StartUTC:           raw.StartUTC.String(""),
+		StartLocal:         raw.StartLocal.String(""),
+		Duration:           raw.Duration.Int64(0),
+		ServiceURL:         raw.ServiceURL.String(""),
+		ServiceAddr:        raw.ServiceAddr.String(""),
+		ClientHost:         raw.ClientHost.String(""),
+		ClientPort:         raw.ClientPort.String(""),
+		ClientUsername:     raw.ClientUsername.String(""),
+		RequestPort:        raw.RequestPort.String(""),
+		RequestProtocol:    raw.RequestProtocol.String(""),
+		RequestScheme:      raw.RequestScheme.String(""),
+		RequestLine:        raw.RequestLine.String(""),
+		RequestContentSize: raw.RequestContentSize.Int(0),
+		OriginDuration:     raw.OriginDuration.Int64(0),
+		OriginContentSize:  raw.OriginContentSize.Int(0),
+		OriginStatus:       raw.OriginStatus.Int(0),
+		DownstreamStatus:   raw.DownstreamStatus.Int(0),
+		RequestCount:       raw.RequestCount.Int(0),
+		GzipRatio:          raw.GzipRatio.Float(0),
+		Overhead:           raw.Overhead.Int64(0),
+		RetryAttempts:      raw.RetryAttempts.Int(0),
+		TLSVersion:         raw.TLSVersion.String(""),
+		TLSCipher:          raw.TLSCipher.String(""),
+		TLSClientSubject:   raw.TLSClientSubject.String(""),
+		TraceId:            raw.TraceId.String(""),
+		SpanId:             raw.SpanId.String(""),
 		
 		// Mark as log file source
 		DataSource:         "logfile",
+		Tenant:             tenancy.TenantForSource(source),
+		Source:             source,
+
+		clientAddr:         clientAddr,
 	}
+	if capture := headerCapture.Load(); capture != nil {
+		logEntry.Headers = capture.capture(scratch.line)
+	}
+	return decodedLine{entry: logEntry}
+}
 
-	return lp.processLogEntry(&logEntry, emit)
+// Generate a unique entry ID, "<unix nanos>-<counter>"
+func (lp *LogParser) nextEntryID() string {
+	var buf [48]byte
+	id := strconv.AppendInt(buf[:0], time.Now().UnixNano(), 10)
+	id = append(id, '-')
+	id = strconv.AppendUint(id, lp.entryCounter.Add(1), 10)
+	return string(id)
 }
 
 // Check if a raw log entry looks like a valid Traefik log
-func (lp *LogParser) isValidTraefikLog(raw RawLogEntry) bool {
+func (lp *LogParser) isValidTraefikLog(raw *RawLogEntry) bool {
 	// Must have a timestamp
-	if _, hasTime := raw["time"]; !hasTime {
+	if !raw.Time.present {
 		return false
 	}
 
 	// For access logs, must have downstream status or request method
-	if _, hasStatus := raw["DownstreamStatus"]; hasStatus {
-		return true
-	}
-	
-	if _, hasMethod := raw["RequestMethod"]; hasMethod {
+	if raw.DownstreamStatus.present || raw.RequestMethod.present {
 		return true
 	}
 
 	// For other logs, check for level (but we might not want these)
-	if level, hasLevel := raw["level"]; hasLevel {
+	if raw.Level.isString {
 		// Only accept error/warn logs, ignore debug/info
-		if levelStr, ok := level.(string); ok {
-			return levelStr == "error" || levelStr == "warn"
-		}
+		return raw.Level.str == "error" || raw.Level.str == "warn"
 	}
 
 	return false
@@ -630,17 +965,25 @@ func (lp *LogParser) ProcessOTLPLogEntry(logEntry LogEntry) {
 	// Set OTLP-specific metadata
 	logEntry.DataSource = "otlp"
 	logEntry.OTLPReceiveTime = time.Now().Format(time.RFC3339)
+	logEntry.Tenant = tenancy.TenantForService(logEntry.ServiceName)
 	
 	// Process the same way as file-based log entries
 	lp.processLogEntry(&logEntry, true) // Always emit OTLP entries for real-time updates
 	
-	log.Printf("[LogParser] Processed OTLP log entry - Trace: %s, Span: %s", logEntry.TraceId, logEntry.SpanId)
+	otlpLog.Debug("Processed OTLP log entry", "traceId", logEntry.TraceId, "spanId", logEntry.SpanId)
 }
 
 // Common log entry processing logic used by both file and OTLP entries
 func (lp *LogParser) processLogEntry(logEntry *LogEntry, emit bool) bool {
+	if !logEntry.clientAddr.IsValid() {
+		logEntry.clientAddr, _ = parseClientAddr(logEntry.ClientIP)
+	}
+	if logEntry.PathTemplate == "" && logEntry.Path != "" {
+		logEntry.PathTemplate = templatePath(logEntry.Path)
+	}
+
 	// Try to get geolocation from cache immediately
-	if logEntry.ClientIP != "unknown" && !lp.isPrivateIP(logEntry.ClientIP) {
+	if !isPrivateAddr(logEntry.clientAddr) {
 		if geoData := GetGeoLocationFromCache(logEntry.ClientIP); geoData != nil {
 			logEntry.Country = &geoData.Country
 			logEntry.City = &geoData.City
@@ -650,35 +993,49 @@ func (lp *LogParser) processLogEntry(logEntry *LogEntry, emit bool) bool {
 		}
 	}
 
+	// Health checks and monitors matching a drop rule don't count anywhere
+	if ingestControl.Drop(logEntry) {
+		return true
+	}
+	if aliases := nameAliases.Load(); aliases != nil {
+		aliases.apply(logEntry)
+	}
+
 	lp.updateStats(logEntry)
+	metrics.Observe(logEntry)
+	if statsdEmitter != nil {
+		statsdEmitter.Observe(logEntry)
+	}
 
-	lp.mu.Lock()
-	// Add log to the main logs slice
-	lp.logs = append([]LogEntry{*logEntry}, lp.logs...)
-	if len(lp.logs) > lp.maxLogs {
-		lp.logs = lp.logs[:lp.maxLogs]
+	// Counted above, but sampled out or over the rate cap for storage and streaming
+	if !ingestControl.Admit(logEntry) {
+		return true
 	}
 
+	lp.mu.Lock()
+	lp.seq++
+	logEntry.Seq = lp.seq
+
+	// Add log to the ring buffer, evicting the oldest once full
+	lp.logs.Push(*logEntry)
+	lp.publishLogsLocked()
+	lp.mu.Unlock()
+
 	// Add to geo processing queue if needed and not in cache
-	if logEntry.ClientIP != "unknown" && !lp.isPrivateIP(logEntry.ClientIP) && logEntry.Country == nil {
-		if !lp.processedIPs[logEntry.ClientIP] {
+	if !isPrivateAddr(logEntry.clientAddr) && logEntry.Country == nil {
+		lp.geoMu.Lock()
+		if lp.processedIPs.Add(logEntry.ClientIP) {
 			lp.geoProcessingQueue = append(lp.geoProcessingQueue, logEntry.ClientIP)
-			lp.processedIPs[logEntry.ClientIP] = true
 		}
+		lp.geoMu.Unlock()
 	}
-	
-	// Update data source counters
-	lp.dataSourceCounts[logEntry.DataSource]++
-	if logEntry.DataSource == "otlp" {
-		lp.otlpRequestCount++
-	} else if logEntry.DataSource == "logfile" {
-		lp.logFileRequestCount++
-	}
-	
-	lp.mu.Unlock()
+	lp.touch()
 
 	if emit {
 		lp.notifyListeners(*logEntry)
+		if lokiForwarder != nil {
+			lokiForwarder.Forward(logEntry)
+		}
 	}
 
 	return true
@@ -688,12 +1045,34 @@ func (lp *LogParser) ClearLogs() {
 	lp.mu.Lock()
 	defer lp.mu.Unlock()
 
-	log.Println("Clearing all logs and stats")
+	parserLog.Info("Clearing all logs and stats")
 	
 	// Clear logs
-	lp.logs = make([]LogEntry, 0)
+	lp.logs.Reset()
+	lp.publishLogsLocked()
+	lp.statsMu.Lock()
+	lp.resetStatsLocked()
+	lp.statsMu.Unlock()
 	
-	// Reset stats
+	// Clear geo processing data
+	lp.geoMu.Lock()
+	lp.geoProcessingQueue = make([]string, 0)
+	lp.processedIPs.Reset()
+	lp.geoMu.Unlock()
+	lp.touch()
+	
+	// Notify listeners of the clear
+	for _, listener := range lp.listeners {
+		select {
+		case listener <- LogEntry{ID: "CLEAR"}:
+		default:
+		}
+	}
+}
+
+// Zero all aggregated stats; caller must hold lp.statsMu
+func (lp *LogParser) resetStatsLocked() {
+	lp.statsCache.Invalidate()
 	lp.stats = Stats{
 		StatusCodes:     make(map[int]int),
 		Services:        make(map[string]int),
@@ -704,10 +1083,14 @@ func (lp *LogParser) ClearLogs() {
 	}
 	
 	// Reset counters
-	lp.topIPs = make(map[string]int)
-	lp.topRouters = make(map[string]int)
-	lp.topRequestAddrs = make(map[string]int)
-	lp.topRequestHosts = make(map[string]int)
+	lp.topIPs = NewTopCounter()
+	lp.topRouters = NewTopCounter()
+	lp.topRequestAddrs = NewTopCounter()
+	lp.topRequestHosts = NewTopCounter()
+	lp.topPaths = NewTopCounter()
+	lp.countries = NewTopCounter()
+	lp.recentResponseTimes = NewRecentMean(RECENT_RESPONSE_TIME_WINDOW)
+	lp.responseTimes = NewTDigest(DEFAULT_TDIGEST_COMPRESSION)
 	lp.requestsInLastSecond = 0
 	
 	// Reset data tracking
@@ -719,79 +1102,41 @@ func (lp *LogParser) ClearLogs() {
 	lp.otlpRequestCount = 0
 	lp.logFileRequestCount = 0
 	lp.dataSourceCounts = make(map[string]int)
-	
-	// Clear geo processing data
-	lp.geoProcessingQueue = make([]string, 0)
-	lp.processedIPs = make(map[string]bool)
-	
-	// Notify listeners of the clear
-	for _, listener := range lp.listeners {
-		select {
-		case listener <- LogEntry{ID: "CLEAR"}:
-		default:
-		}
-	}
 }
 
-func (lp *LogParser) extractIP(clientAddr string) string {
-	if clientAddr == "" {
-		return "unknown"
-	}
-
-	// Handle IPv6 addresses in brackets
-	if strings.HasPrefix(clientAddr, "[") {
-		if match := strings.Index(clientAddr, "]"); match != -1 {
-			return clientAddr[1:match]
-		}
-	}
-
-	// Handle IPv4 with port
-	if strings.Contains(clientAddr, ".") && strings.Contains(clientAddr, ":") {
-		if lastColon := strings.LastIndex(clientAddr, ":"); lastColon != -1 {
-			return clientAddr[:lastColon]
-		}
-	}
-
-	// Handle IPv6 without brackets
-	if strings.Contains(clientAddr, ":") && !strings.Contains(clientAddr, ".") {
-		return clientAddr
-	}
-
-	return clientAddr
-}
+// Drop the entries read from one log file and rebuild the stats from what
+// remains in memory. Returns the number of entries removed.
+func (lp *LogParser) ResetSource(source string) int {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
 
-func (lp *LogParser) isPrivateIP(ip string) bool {
-	if ip == "" || ip == "unknown" {
-		return true
-	}
+	removed := lp.logs.Filter(func(entry *LogEntry) bool {
+		return entry.Source != source
+	})
+	lp.publishLogsLocked()
 
-	parts := strings.Split(ip, ".")
-	if len(parts) != 4 {
-		return false
+	// Replay oldest first, as the entries were originally counted
+	lp.statsMu.Lock()
+	lp.resetStatsLocked()
+	for i := lp.logs.Len() - 1; i >= 0; i-- {
+		lp.updateStatsLocked(lp.logs.At(i))
 	}
+	lp.statsMu.Unlock()
+	lp.touch()
 
-	return ip == "127.0.0.1" ||
-		ip == "localhost" ||
-		strings.HasPrefix(ip, "::") ||
-		ip == "::1" ||
-		parts[0] == "10" ||
-		(parts[0] == "172" && isInRange(parts[1], 16, 31)) ||
-		(parts[0] == "192" && parts[1] == "168") ||
-		(parts[0] == "169" && parts[1] == "254")
-}
-
-func isInRange(s string, min, max int) bool {
-	n, err := strconv.Atoi(s)
-	if err != nil {
-		return false
-	}
-	return n >= min && n <= max
+	parserLog.Info("Reset log source", "source", source, "removed", removed)
+	return removed
 }
 
 func (lp *LogParser) updateStats(log *LogEntry) {
-	lp.mu.Lock()
-	defer lp.mu.Unlock()
+	lp.statsMu.Lock()
+	defer lp.statsMu.Unlock()
+	lp.updateStatsLocked(log)
+}
 
+// Count an entry into the aggregated stats; caller must hold lp.statsMu
+func (lp *LogParser) updateStatsLocked(log *LogEntry) {
+	lp.statsCache.Counted()
 	lp.stats.TotalRequests++
 
 	statusGroup := log.Status / 100
@@ -815,31 +1160,42 @@ func (lp *LogParser) updateStats(log *LogEntry) {
 	lp.stats.Methods[log.Method]++
 
 	if log.ClientIP != "" && log.ClientIP != "unknown" {
-		lp.topIPs[log.ClientIP]++
+		lp.topIPs.Inc(log.ClientIP)
 	}
 
 	if log.RouterName != "" && log.RouterName != "unknown" {
-		lp.topRouters[log.RouterName]++
+		lp.topRouters.Inc(log.RouterName)
 	}
 
 	if log.RequestAddr != "" {
-		lp.topRequestAddrs[log.RequestAddr]++
+		lp.topRequestAddrs.Inc(log.RequestAddr)
 	}
 
 	if log.RequestHost != "" {
-		lp.topRequestHosts[log.RequestHost]++
+		lp.topRequestHosts.Inc(log.RequestHost)
+	}
+
+	if log.PathTemplate != "" {
+		lp.topPaths.Inc(log.PathTemplate)
 	}
 
 	// Update country stats if already geolocated
 	if log.Country != nil && log.CountryCode != nil {
 		key := fmt.Sprintf("%s|%s", *log.CountryCode, *log.Country)
 		lp.stats.Countries[key]++
+		lp.countries.Inc(key)
 	}
 
 	// Update data source statistics
 	if log.DataSource != "" {
 		lp.stats.DataSources[log.DataSource]++
 	}
+	lp.dataSourceCounts[log.DataSource]++
+	if log.DataSource == "otlp" {
+		lp.otlpRequestCount++
+	} else if log.DataSource == "logfile" {
+		lp.logFileRequestCount++
+	}
 
 	// Update total data transmitted
 	lp.totalDataTransmitted += int64(log.Size)
@@ -854,18 +1210,10 @@ func (lp *LogParser) updateStats(log *LogEntry) {
 		}
 	}
 
-	// Update average response time
-	totalResponseTime := 0.0
-	count := 0
-	for i := range lp.logs {
-		if i < 100 { // Only calculate for last 100 logs for performance
-			totalResponseTime += lp.logs[i].ResponseTime
-			count++
-		}
-	}
-	if count > 0 {
-		lp.stats.AvgResponseTime = totalResponseTime / float64(count)
-	}
+	// Update average (last 100 entries) and percentile response times
+	lp.recentResponseTimes.Add(log.ResponseTime)
+	lp.stats.AvgResponseTime = lp.recentResponseTimes.Mean()
+	lp.responseTimes.Add(log.ResponseTime)
 
 	// Update requests per second
 	now := time.Now()
@@ -877,12 +1225,26 @@ func (lp *LogParser) updateStats(log *LogEntry) {
 	lp.requestsInLastSecond++
 }
 
+// Get the global stats; the snapshot is shared between callers, so treat
+// its maps as read-only
 func (lp *LogParser) GetStats() Stats {
-	lp.mu.RLock()
-	defer lp.mu.RUnlock()
+	return lp.statsCache.Stats("", func() Stats {
+		lp.statsMu.RLock()
+		defer lp.statsMu.RUnlock()
+		return lp.statsLocked()
+	})
+}
 
+// Build the stats snapshot; caller must hold lp.statsMu. Maps are copied so
+// the snapshot can be used after the lock is released.
+func (lp *LogParser) statsLocked() Stats {
 	stats := lp.stats
-	stats.GeoProcessingRemaining = len(lp.geoProcessingQueue)
+	stats.StatusCodes = copyCounts(lp.stats.StatusCodes)
+	stats.Services = copyCounts(lp.stats.Services)
+	stats.Routers = copyCounts(lp.stats.Routers)
+	stats.Methods = copyCounts(lp.stats.Methods)
+	stats.Countries = copyCounts(lp.stats.Countries)
+	stats.GeoProcessingRemaining = lp.geoQueueLength()
 
 	// Add new fields
 	stats.TotalDataTransmitted = lp.totalDataTransmitted
@@ -890,10 +1252,7 @@ func (lp *LogParser) GetStats() Stats {
 	// Add OTLP-specific stats
 	stats.OTLPRequests = lp.otlpRequestCount
 	stats.LogFileRequests = lp.logFileRequestCount
-	stats.DataSources = make(map[string]int)
-	for source, count := range lp.dataSourceCounts {
-		stats.DataSources[source] = count
-	}
+	stats.DataSources = copyCounts(lp.dataSourceCounts)
 	
 	// Format timestamps
 	if !lp.oldestLogTime.IsZero() {
@@ -905,108 +1264,241 @@ func (lp *LogParser) GetStats() Stats {
 	
 	// Calculate analysis period
 	if !lp.oldestLogTime.IsZero() && !lp.newestLogTime.IsZero() {
-		duration := lp.newestLogTime.Sub(lp.oldestLogTime)
-		if duration < time.Minute {
-			stats.AnalysisPeriod = fmt.Sprintf("%.0f seconds", duration.Seconds())
-		} else if duration < time.Hour {
-			stats.AnalysisPeriod = fmt.Sprintf("%.1f minutes", duration.Minutes())
-		} else if duration < 24*time.Hour {
-			stats.AnalysisPeriod = fmt.Sprintf("%.1f hours", duration.Hours())
-		} else {
-			stats.AnalysisPeriod = fmt.Sprintf("%.1f days", duration.Hours()/24)
-		}
+		stats.AnalysisPeriod = formatAnalysisPeriod(lp.newestLogTime.Sub(lp.oldestLogTime))
 	}
 
-	// Get top IPs
-	stats.TopIPs = getTopItems(lp.topIPs, 10, func(k string, v int) IPCount {
+	// Top lists are kept in order as entries arrive, so nothing is sorted here
+	stats.TopIPs = topCounts(lp.topIPs, TOP_LIST_SIZE, func(k string, v int) IPCount {
 		return IPCount{IP: k, Count: v}
 	})
 
 	// Get ALL countries for the map
-	countries := make([]CountryCount, 0)
-	for key, count := range lp.stats.Countries {
-		parts := strings.Split(key, "|")
-		if len(parts) == 2 {
-			countries = append(countries, CountryCount{
-				CountryCode: parts[0],
-				Country:     parts[1],
-				Count:       count,
-			})
-		}
-	}
-	sort.Slice(countries, func(i, j int) bool {
-		return countries[i].Count > countries[j].Count
-	})
-	stats.TopCountries = countries
+	stats.TopCountries = lp.countryList()
 
-	// Get top routers
-	stats.TopRouters = getTopItems(lp.topRouters, 10, func(k string, v int) RouterCount {
+	stats.TopRouters = topCounts(lp.topRouters, TOP_LIST_SIZE, func(k string, v int) RouterCount {
 		return RouterCount{Router: k, Count: v}
 	})
-
-	// Get top request addresses
-	stats.TopRequestAddrs = getTopItems(lp.topRequestAddrs, 10, func(k string, v int) AddrCount {
+	stats.TopRequestAddrs = topCounts(lp.topRequestAddrs, TOP_LIST_SIZE, func(k string, v int) AddrCount {
 		return AddrCount{Addr: k, Count: v}
 	})
-
-	// Get top request hosts
-	stats.TopRequestHosts = getTopItems(lp.topRequestHosts, 10, func(k string, v int) HostCount {
+	stats.TopRequestHosts = topCounts(lp.topRequestHosts, TOP_LIST_SIZE, func(k string, v int) HostCount {
 		return HostCount{Host: k, Count: v}
 	})
+	stats.TopPaths = topCounts(lp.topPaths, TOP_LIST_SIZE, func(k string, v int) PathCount {
+		return PathCount{Path: k, Count: v}
+	})
 
 	stats.AvgResponseTime = math.Round(stats.AvgResponseTime*100) / 100
+	stats.P50ResponseTime = math.Round(lp.responseTimes.Quantile(0.50)*100) / 100
+	stats.P95ResponseTime = math.Round(lp.responseTimes.Quantile(0.95)*100) / 100
+	stats.P99ResponseTime = math.Round(lp.responseTimes.Quantile(0.99)*100) / 100
 
 	return stats
 }
 
-func (lp *LogParser) GetLogs(params LogsParams) LogsResult {
-	lp.mu.RLock()
-	filteredLogs := make([]LogEntry, 0, len(lp.logs))
-	
-	for _, log := range lp.logs {
-		// Apply filters
-		if params.Filters.Service != "" && log.ServiceName != params.Filters.Service {
-			continue
+// Compute stats over the in-memory logs matching the given filters
+func (lp *LogParser) GetFilteredStats(filters Filters) (Stats, error) {
+	if err := filters.Compile(); err != nil {
+		return Stats{}, err
+	}
+
+	return lp.filteredStats(lp.logsSnapshot.Load(), filters), nil
+}
+
+// Aggregate stats over matching logs in a snapshot; filters must be compiled
+func (lp *LogParser) filteredStats(snapshot *LogSnapshot, filters Filters) Stats {
+	// The overall counts bound how many keys the low-cardinality maps get
+	lp.statsMu.RLock()
+	stats := Stats{
+		StatusCodes: make(map[int]int, len(lp.stats.StatusCodes)),
+		Services:    make(map[string]int, len(lp.stats.Services)),
+		Routers:     make(map[string]int, len(lp.stats.Routers)),
+		Methods:     make(map[string]int, len(lp.stats.Methods)),
+		Countries:   make(map[string]int),
+		DataSources: make(map[string]int, len(lp.dataSourceCounts)),
+	}
+	lp.statsMu.RUnlock()
+	topIPs := make(map[string]int)
+	topRouters := make(map[string]int)
+	topRequestAddrs := make(map[string]int)
+	topRequestHosts := make(map[string]int)
+	topPaths := make(map[string]int)
+	var oldest, newest time.Time
+	totalResponseTime := 0.0
+	responseTimes := NewTDigest(DEFAULT_TDIGEST_COMPRESSION)
+
+	lp.eachMatch(snapshot, filters, math.MaxUint64, func(log *LogEntry) bool {
+		stats.TotalRequests++
+		stats.StatusCodes[log.Status]++
+		switch log.Status / 100 {
+		case 2:
+			stats.Requests2xx++
+		case 4:
+			stats.Requests4xx++
+		case 5:
+			stats.Requests5xx++
 		}
-		if params.Filters.Status != "" {
-			if status, err := strconv.Atoi(params.Filters.Status); err == nil && log.Status != status {
-				continue
-			}
+		if log.ServiceName != "" && log.ServiceName != "unknown" {
+			stats.Services[log.ServiceName]++
 		}
-		if params.Filters.Router != "" && log.RouterName != params.Filters.Router {
-			continue
+		if log.RouterName != "" && log.RouterName != "unknown" {
+			stats.Routers[log.RouterName]++
+			topRouters[log.RouterName]++
 		}
-		if params.Filters.HideUnknown && (log.ServiceName == "unknown" || log.RouterName == "unknown") {
-			continue
+		stats.Methods[log.Method]++
+		if log.ClientIP != "" && log.ClientIP != "unknown" {
+			topIPs[log.ClientIP]++
 		}
-		if params.Filters.HidePrivateIPs && lp.isPrivateIP(log.ClientIP) {
-			continue
+		if log.RequestAddr != "" {
+			topRequestAddrs[log.RequestAddr]++
 		}
-		// New: Data source filter
-		if params.Filters.DataSource != "" && params.Filters.DataSource != "all" && log.DataSource != params.Filters.DataSource {
-			continue
+		if log.RequestHost != "" {
+			topRequestHosts[log.RequestHost]++
 		}
-		
-		filteredLogs = append(filteredLogs, log)
+		if log.PathTemplate != "" {
+			topPaths[log.PathTemplate]++
+		}
+		if log.Country != nil && log.CountryCode != nil {
+			stats.Countries[fmt.Sprintf("%s|%s", *log.CountryCode, *log.Country)]++
+		}
+		if log.DataSource != "" {
+			stats.DataSources[log.DataSource]++
+		}
+		if log.DataSource == "otlp" {
+			stats.OTLPRequests++
+		} else if log.DataSource == "logfile" {
+			stats.LogFileRequests++
+		}
+		stats.TotalDataTransmitted += int64(log.Size)
+		totalResponseTime += log.ResponseTime
+		responseTimes.Add(log.ResponseTime)
+
+		if timestamp, err := time.Parse(time.RFC3339, log.Timestamp); err == nil {
+			if oldest.IsZero() || timestamp.Before(oldest) {
+				oldest = timestamp
+			}
+			if newest.IsZero() || timestamp.After(newest) {
+				newest = timestamp
+			}
+		}
+		return true
+	})
+	stats.GeoProcessingRemaining = lp.geoQueueLength()
+	lp.statsMu.RLock()
+	stats.RequestsPerSecond = lp.stats.RequestsPerSecond
+	lp.statsMu.RUnlock()
+
+	if stats.TotalRequests > 0 {
+		stats.AvgResponseTime = math.Round(totalResponseTime/float64(stats.TotalRequests)*100) / 100
+		stats.P50ResponseTime = math.Round(responseTimes.Quantile(0.50)*100) / 100
+		stats.P95ResponseTime = math.Round(responseTimes.Quantile(0.95)*100) / 100
+		stats.P99ResponseTime = math.Round(responseTimes.Quantile(0.99)*100) / 100
+	}
+	if !oldest.IsZero() {
+		stats.OldestLogTime = oldest.Format(time.RFC3339)
+		stats.NewestLogTime = newest.Format(time.RFC3339)
+		stats.AnalysisPeriod = formatAnalysisPeriod(newest.Sub(oldest))
 	}
-	lp.mu.RUnlock()
 
-	// Pagination
-	start := (params.Page - 1) * params.Limit
-	end := start + params.Limit
-	if end > len(filteredLogs) {
-		end = len(filteredLogs)
+	stats.TopIPs = getTopItems(topIPs, 10, func(k string, v int) IPCount {
+		return IPCount{IP: k, Count: v}
+	})
+	stats.TopCountries = countryCounts(stats.Countries)
+	stats.TopRouters = getTopItems(topRouters, 10, func(k string, v int) RouterCount {
+		return RouterCount{Router: k, Count: v}
+	})
+	stats.TopRequestAddrs = getTopItems(topRequestAddrs, 10, func(k string, v int) AddrCount {
+		return AddrCount{Addr: k, Count: v}
+	})
+	stats.TopRequestHosts = getTopItems(topRequestHosts, 10, func(k string, v int) HostCount {
+		return HostCount{Host: k, Count: v}
+	})
+	stats.TopPaths = getTopItems(topPaths, 10, func(k string, v int) PathCount {
+		return PathCount{Path: k, Count: v}
+	})
+
+	return stats
+}
+
+// Format the span between the oldest and newest log for display
+func formatAnalysisPeriod(duration time.Duration) string {
+	if duration < time.Minute {
+		return fmt.Sprintf("%.0f seconds", duration.Seconds())
+	} else if duration < time.Hour {
+		return fmt.Sprintf("%.1f minutes", duration.Minutes())
+	} else if duration < 24*time.Hour {
+		return fmt.Sprintf("%.1f hours", duration.Hours())
 	}
-	if start > len(filteredLogs) {
-		start = len(filteredLogs)
+	return fmt.Sprintf("%.1f days", duration.Hours()/24)
+}
+
+// Countries in count order from the incrementally sorted counter; caller
+// must hold lp.statsMu
+func (lp *LogParser) countryList() []CountryCount {
+	return countryListFrom(lp.countries.Top(0))
+}
+
+func countryListFrom(counts []topCount) []CountryCount {
+	countries := make([]CountryCount, 0, len(counts))
+	for _, item := range counts {
+		if code, name, ok := strings.Cut(item.Key, "|"); ok {
+			countries = append(countries, CountryCount{CountryCode: code, Country: name, Count: item.Count})
+		}
 	}
+	return countries
+}
 
-	paginatedLogs := filteredLogs[start:end]
+// Convert "code|name" country keys into counts sorted by count
+func countryCounts(countryMap map[string]int) []CountryCount {
+	countries := make([]CountryCount, 0)
+	for key, count := range countryMap {
+		parts := strings.Split(key, "|")
+		if len(parts) == 2 {
+			countries = append(countries, CountryCount{
+				CountryCode: parts[0],
+				Country:     parts[1],
+				Count:       count,
+			})
+		}
+	}
+	sort.Slice(countries, func(i, j int) bool {
+		return countries[i].Count > countries[j].Count
+	})
+	return countries
+}
 
-	// Try to geolocate logs without location data (on-demand for display)
+func (lp *LogParser) GetLogs(params LogsParams) LogsResult {
+	if err := params.Filters.Compile(); err != nil {
+		parserLog.Warn("Ignoring logs query", "error", err)
+		return LogsResult{Logs: []LogEntry{}, Page: params.Page}
+	}
+
+	// Count every match but only copy the requested page, so deep pages and
+	// large limits don't materialize the whole filtered set
+	start := (params.Page - 1) * params.Limit
+	end := start + params.Limit
+	total := 0
+
+	snapshot := lp.logsSnapshot.Load()
+	paginatedLogs := make([]LogEntry, 0, min(max(params.Limit, 0), snapshot.Len()))
+	lp.eachMatch(snapshot, params.Filters, math.MaxUint64, func(log *LogEntry) bool {
+		if total >= start && total < end {
+			paginatedLogs = append(paginatedLogs, *log)
+		}
+		total++
+		return true
+	})
+
+	// Try to geolocate logs without location data (on-demand for display),
+	// once per IP on the page
+	located := make(map[string]*GeoData)
 	for i := range paginatedLogs {
-		if paginatedLogs[i].Country == nil && paginatedLogs[i].ClientIP != "" && !lp.isPrivateIP(paginatedLogs[i].ClientIP) {
-			geoData := GetGeoLocation(paginatedLogs[i].ClientIP)
+		if paginatedLogs[i].Country == nil && !isPrivateAddr(paginatedLogs[i].clientAddr) {
+			geoData, ok := located[paginatedLogs[i].ClientIP]
+			if !ok {
+				geoData = GetGeoLocation(paginatedLogs[i].ClientIP)
+				located[paginatedLogs[i].ClientIP] = geoData
+			}
 			if geoData != nil {
 				paginatedLogs[i].Country = &geoData.Country
 				paginatedLogs[i].City = &geoData.City
@@ -1019,15 +1511,146 @@ func (lp *LogParser) GetLogs(params LogsParams) LogsResult {
 
 	return LogsResult{
 		Logs:       paginatedLogs,
-		Total:      len(filteredLogs),
+		Total:      total,
 		Page:       params.Page,
-		TotalPages: int(math.Ceil(float64(len(filteredLogs)) / float64(params.Limit))),
+		TotalPages: int(math.Ceil(float64(total) / float64(params.Limit))),
+	}
+}
+
+// Call fn for the entries of a snapshot matching the filters with a sequence
+// number below the given bound, newest first, until fn returns false; returns
+// whether every match was visited. Uses the secondary indexes when a filter
+// is indexed.
+func (lp *LogParser) eachMatch(snapshot *LogSnapshot, filters Filters, below uint64, fn func(entry *LogEntry) bool) bool {
+	return snapshot.EachCandidate(filters, below, func(entry *LogEntry) bool {
+		return !lp.matchesFilters(entry, filters) || fn(entry)
+	})
+}
+
+// Store a snapshot of the logs for readers; caller must hold lp.mu
+func (lp *LogParser) publishLogsLocked() {
+	lp.logsSnapshot.Store(lp.logs.Snapshot(lp.seq))
+}
+
+// Check whether a log entry passes the given filters
+func (lp *LogParser) matchesFilters(log *LogEntry, filters Filters) bool {
+	if filters.Tenant != "" && log.Tenant != filters.Tenant {
+		return false
+	}
+	if filters.Service != "" && !matchesName(filters.Service, log.ServiceName, log.ServiceRaw) {
+		return false
+	}
+	if filters.Status != "" {
+		if status, err := strconv.Atoi(filters.Status); err == nil && log.Status != status {
+			return false
+		}
+	}
+	if filters.Router != "" && !matchesName(filters.Router, log.RouterName, log.RouterRaw) {
+		return false
+	}
+	if filters.HideUnknown && (log.ServiceName == "unknown" || log.RouterName == "unknown") {
+		return false
+	}
+	if filters.HidePrivateIPs && isPrivateAddr(log.clientAddr) {
+		return false
+	}
+	// Data source filter
+	if filters.DataSource != "" && filters.DataSource != "all" && log.DataSource != filters.DataSource {
+		return false
+	}
+	if filters.ClientIP != "" {
+		if filters.clientAddr.IsValid() {
+			if log.clientAddr != filters.clientAddr {
+				return false
+			}
+		} else if log.ClientIP != filters.ClientIP {
+			return false
+		}
+	}
+	if filters.CIDR != "" && !filters.cidr.Contains(log.clientAddr.WithZone("")) {
+		return false
+	}
+	if filters.StatusClass != "" && !matchStatus(filters.StatusClass, log.Status) {
+		return false
+	}
+	if filters.Query != "" && !matchesQuery(log, filters.queryTerms) {
+		return false
+	}
+	return true
+}
+
+// Get distinct values with counts for the requested fields under the given filters
+func (lp *LogParser) GetFacets(fields []string, filters Filters) map[string][]FacetCount {
+	if err := filters.Compile(); err != nil {
+		parserLog.Warn("Ignoring facets query", "error", err)
+		return map[string][]FacetCount{}
 	}
+
+	counts := make(map[string]map[string]int, len(fields))
+	for _, field := range fields {
+		counts[field] = make(map[string]int)
+	}
+
+	lp.eachMatch(lp.logsSnapshot.Load(), filters, math.MaxUint64, func(log *LogEntry) bool {
+		for field, values := range counts {
+			if value, ok := facetValue(log, field); ok {
+				values[value]++
+			}
+		}
+		return true
+	})
+
+	result := make(map[string][]FacetCount, len(counts))
+	for field, values := range counts {
+		facets := make([]FacetCount, 0, len(values))
+		for value, count := range values {
+			facets = append(facets, FacetCount{Value: value, Count: count})
+		}
+		sort.Slice(facets, func(i, j int) bool {
+			if facets[i].Count == facets[j].Count {
+				return facets[i].Value < facets[j].Value
+			}
+			return facets[i].Count > facets[j].Count
+		})
+		result[field] = facets
+	}
+	return result
+}
+
+// Extract the facet value of a log entry for a supported field
+func facetValue(log *LogEntry, field string) (string, bool) {
+	switch field {
+	case "service":
+		return log.ServiceName, log.ServiceName != ""
+	case "router":
+		return log.RouterName, log.RouterName != ""
+	case "status":
+		return strconv.Itoa(log.Status), log.Status != 0
+	case "method":
+		return log.Method, log.Method != ""
+	case "host":
+		return log.RequestHost, log.RequestHost != ""
+	case "country":
+		if log.Country == nil {
+			return "", false
+		}
+		return *log.Country, true
+	case "dataSource":
+		return log.DataSource, log.DataSource != ""
+	case "tenant":
+		return log.Tenant, log.Tenant != ""
+	case "pathTemplate":
+		return log.PathTemplate, log.PathTemplate != ""
+	}
+	if isHeaderField(field) {
+		return headerValue(log, field)
+	}
+	return "", false
 }
 
 func (lp *LogParser) GetServices() []string {
-	lp.mu.RLock()
-	defer lp.mu.RUnlock()
+	lp.statsMu.RLock()
+	defer lp.statsMu.RUnlock()
 
 	services := make([]string, 0, len(lp.stats.Services))
 	for service := range lp.stats.Services {
@@ -1038,8 +1661,8 @@ func (lp *LogParser) GetServices() []string {
 }
 
 func (lp *LogParser) GetRouters() []string {
-	lp.mu.RLock()
-	defer lp.mu.RUnlock()
+	lp.statsMu.RLock()
+	defer lp.statsMu.RUnlock()
 
 	routers := make([]string, 0, len(lp.stats.Routers))
 	for router := range lp.stats.Routers {
@@ -1050,47 +1673,119 @@ func (lp *LogParser) GetRouters() []string {
 }
 
 func (lp *LogParser) GetGeoStats() GeoStats {
-	lp.mu.RLock()
-	defer lp.mu.RUnlock()
+	return lp.statsCache.GeoStats(func() GeoStats {
+		lp.statsMu.RLock()
+		defer lp.statsMu.RUnlock()
 
-	countries := make([]CountryCount, 0)
-	for key, count := range lp.stats.Countries {
-		parts := strings.Split(key, "|")
-		if len(parts) == 2 {
-			countries = append(countries, CountryCount{
-				CountryCode: parts[0],
-				Country:     parts[1],
-				Count:       count,
-			})
+		countries := lp.countryList()
+
+		return GeoStats{
+			Countries:              countries,
+			TotalCountries:         len(countries),
+			GeoProcessingRemaining: lp.geoQueueLength(),
 		}
-	}
-	sort.Slice(countries, func(i, j int) bool {
-		return countries[i].Count > countries[j].Count
 	})
+}
+
+// Get the number of IPs waiting for geolocation
+func (lp *LogParser) geoQueueLength() int {
+	lp.geoMu.Lock()
+	defer lp.geoMu.Unlock()
+	return len(lp.geoProcessingQueue)
+}
+
+// Limit how many recently queued IPs are remembered to avoid queueing them
+// again; forgotten IPs are answered from the geo cache if queued again
+func (lp *LogParser) SetGeoTrackedIPs(limit int) {
+	lp.geoMu.Lock()
+	defer lp.geoMu.Unlock()
+	lp.processedIPs = NewRecentSet(limit, GEO_TRACKED_IPS_MAX_AGE)
+}
+
+func (lp *LogParser) geoTrackedIPs() int {
+	lp.geoMu.Lock()
+	defer lp.geoMu.Unlock()
+	return lp.processedIPs.Len()
+}
+
+// Get the maximum number of logs kept in memory
+func (lp *LogParser) GetMaxLogs() int {
+	lp.mu.RLock()
+	defer lp.mu.RUnlock()
+	return lp.maxLogs
+}
 
-	return GeoStats{
-		Countries:              countries,
-		TotalCountries:         len(countries),
-		GeoProcessingRemaining: len(lp.geoProcessingQueue),
+// Change the maximum number of logs kept in memory, dropping the oldest if needed
+func (lp *LogParser) SetMaxLogs(maxLogs int) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.maxLogs = maxLogs
+	lp.resizeLogsLocked()
+}
+
+// Cap the buffer below maxLogs to stay within the memory budget (0 removes the cap)
+func (lp *LogParser) SetBudgetMaxLogs(maxLogs int) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.budgetMaxLogs = maxLogs
+	lp.resizeLogsLocked()
+}
+
+// Get how many logs are actually kept: maxLogs, or less under the memory budget
+func (lp *LogParser) EffectiveMaxLogs() int {
+	lp.mu.RLock()
+	defer lp.mu.RUnlock()
+	return lp.logs.Cap()
+}
+
+func (lp *LogParser) resizeLogsLocked() {
+	capacity := lp.maxLogs
+	if lp.budgetMaxLogs > 0 && lp.budgetMaxLogs < capacity {
+		capacity = lp.budgetMaxLogs
+	}
+	if capacity == lp.logs.Cap() {
+		return
+	}
+	before := lp.logs.Len()
+	lp.logs.Resize(capacity)
+	lp.publishLogsLocked()
+	if lp.logs.Len() < before {
+		lp.touch()
 	}
 }
 
-func (lp *LogParser) IsProcessingGeo() bool {
+// Check whether log files are attached and their initial load has finished
+func (lp *LogParser) SourcesReady() bool {
+	lp.mu.RLock()
+	defer lp.mu.RUnlock()
+	return lp.sourcesReady
+}
+
+// Get the log files currently being watched
+func (lp *LogParser) WatchedFiles() []string {
 	lp.mu.RLock()
 	defer lp.mu.RUnlock()
+	files := make([]string, len(lp.watchedFiles))
+	copy(files, lp.watchedFiles)
+	return files
+}
+
+func (lp *LogParser) IsProcessingGeo() bool {
+	lp.geoMu.Lock()
+	defer lp.geoMu.Unlock()
 	return lp.isProcessingGeo
 }
 
 // Get OTLP-specific statistics
 func (lp *LogParser) GetOTLPStats() map[string]interface{} {
-	lp.mu.RLock()
-	defer lp.mu.RUnlock()
+	lp.statsMu.RLock()
+	defer lp.statsMu.RUnlock()
 	
 	return map[string]interface{}{
 		"otlpRequests":       lp.otlpRequestCount,
 		"logFileRequests":    lp.logFileRequestCount,
 		"totalRequests":      lp.stats.TotalRequests,
-		"dataSources":        lp.dataSourceCounts,
+		"dataSources":        copyCounts(lp.dataSourceCounts),
 		"otlpPercentage":     func() float64 {
 			if lp.stats.TotalRequests == 0 {
 				return 0.0
@@ -1101,26 +1796,26 @@ func (lp *LogParser) GetOTLPStats() map[string]interface{} {
 }
 
 func (lp *LogParser) startGeoProcessing() {
-	lp.mu.Lock()
+	lp.geoMu.Lock()
 	if lp.isProcessingGeo {
-		lp.mu.Unlock()
+		lp.geoMu.Unlock()
 		return
 	}
 	lp.isProcessingGeo = true
-	lp.mu.Unlock()
+	lp.geoMu.Unlock()
 
-	log.Println("Starting background geo processing...")
+	geoLog.Info("Starting background geo processing")
 
 	for {
 		select {
 		case <-lp.geoStopChan:
-			log.Println("Geo processing stopped")
+			geoLog.Info("Geo processing stopped")
 			return
 		default:
-			lp.mu.Lock()
+			lp.geoMu.Lock()
 			if len(lp.geoProcessingQueue) == 0 {
 				lp.isProcessingGeo = false
-				lp.mu.Unlock()
+				lp.geoMu.Unlock()
 				time.Sleep(5 * time.Second) // Wait before checking again
 				continue
 			}
@@ -1132,48 +1827,117 @@ func (lp *LogParser) startGeoProcessing() {
 			}
 			ipBatch := lp.geoProcessingQueue[:batchSize]
 			lp.geoProcessingQueue = lp.geoProcessingQueue[batchSize:]
-			lp.mu.Unlock()
+			lp.geoMu.Unlock()
+			lp.touch()
 
-			// Process each IP in the batch
+			// Look the batch up, then fill in every entry from those IPs in
+			// one pass over the buffer rather than one pass per IP
+			resolved := make(map[string]*GeoData, len(ipBatch))
 			for _, ip := range ipBatch {
-				geoData := GetGeoLocation(ip)
-				if geoData != nil {
-					lp.mu.Lock()
-					
-					// Update country stats
-					key := fmt.Sprintf("%s|%s", geoData.CountryCode, geoData.Country)
-					
-					// Update all logs with this IP
-					updatedCount := 0
-					for i := range lp.logs {
-						if lp.logs[i].ClientIP == ip && lp.logs[i].Country == nil {
-							lp.logs[i].Country = &geoData.Country
-							lp.logs[i].City = &geoData.City
-							lp.logs[i].CountryCode = &geoData.CountryCode
-							lp.logs[i].Lat = &geoData.Lat
-							lp.logs[i].Lon = &geoData.Lon
-							updatedCount++
-						}
-					}
-					
-					if updatedCount > 0 {
-						lp.stats.Countries[key] += updatedCount
-					}
-					
-					lp.mu.Unlock()
+				if geoData := GetGeoLocation(ip); geoData != nil {
+					resolved[ip] = geoData
 				}
 			}
+			lp.applyGeoData(resolved)
 
-			log.Printf("Processed geo data for %d IPs. %d IPs remaining in queue.", len(ipBatch), len(lp.geoProcessingQueue))
+			remaining := lp.geoQueueLength()
+			geoLog.Debug("Processed geo batch", "ips", len(ipBatch), "remaining", remaining)
 
 			// Rate limit - only if there are more IPs to process
-			if len(lp.geoProcessingQueue) > 0 {
+			if remaining > 0 {
 				time.Sleep(60 * time.Second)
 			}
 		}
 	}
 }
 
+// Set the location of buffered entries from the resolved IPs and count them
+// into the country stats
+func (lp *LogParser) applyGeoData(resolved map[string]*GeoData) {
+	if len(resolved) == 0 {
+		return
+	}
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	// Snapshots may be reading the stored entries, so they are updated as copies
+	updated := make(map[string]int)
+	lp.logs.Update(func(entry *LogEntry) bool {
+		_, ok := resolved[entry.ClientIP]
+		return ok && entry.Country == nil
+	}, func(entry *LogEntry) {
+		geoData := resolved[entry.ClientIP]
+		entry.Country = &geoData.Country
+		entry.City = &geoData.City
+		entry.CountryCode = &geoData.CountryCode
+		entry.Lat = &geoData.Lat
+		entry.Lon = &geoData.Lon
+		updated[fmt.Sprintf("%s|%s", geoData.CountryCode, geoData.Country)]++
+	})
+
+	if len(updated) > 0 {
+		lp.publishLogsLocked()
+		lp.statsMu.Lock()
+		for key, count := range updated {
+			lp.stats.Countries[key] += count
+			lp.countries.Add(key, count)
+		}
+		lp.statsMu.Unlock()
+		lp.touch()
+	}
+}
+
+// Get the sequence number of the most recently processed entry
+func (lp *LogParser) CurrentSeq() uint64 {
+	return lp.logsSnapshot.Load().seq
+}
+
+// Get buffered entries after the given sequence number, oldest first. Returns
+// false if entries after seq have already been evicted from the buffer.
+func (lp *LogParser) GetLogsSince(seq uint64) ([]LogEntry, bool) {
+	snapshot := lp.logsSnapshot.Load()
+	if seq > snapshot.seq {
+		return nil, false
+	}
+	if seq == snapshot.seq {
+		return []LogEntry{}, true
+	}
+
+	// Logs are stored newest first
+	n := 0
+	for n < snapshot.Len() && snapshot.At(n).Seq > seq {
+		n++
+	}
+	if n == 0 || snapshot.At(n-1).Seq != seq+1 {
+		return nil, false
+	}
+
+	entries := make([]LogEntry, n)
+	for i := 0; i < n; i++ {
+		entries[i] = *snapshot.At(n - 1 - i)
+	}
+	return entries, true
+}
+
+// Record that the parser's data changed
+func (lp *LogParser) touch() {
+	lp.version.Add(1)
+	lp.lastModified.Store(time.Now().UnixNano())
+}
+
+// Get the current data version and when it last changed
+func (lp *LogParser) GetVersion() (uint64, time.Time) {
+	return lp.version.Load(), time.Unix(0, lp.lastModified.Load())
+}
+
+func copyCounts[K comparable](counts map[K]int) map[K]int {
+	copied := make(map[K]int, len(counts))
+	for key, count := range counts {
+		copied[key] = count
+	}
+	return copied
+}
+
 func (lp *LogParser) AddListener(ch chan LogEntry) {
 	lp.mu.Lock()
 	defer lp.mu.Unlock()
@@ -1202,63 +1966,84 @@ func (lp *LogParser) notifyListeners(log LogEntry) {
 		case listener <- log:
 		default:
 			// Don't block if listener is not ready
+			lp.listenerDrops.Add(1)
 		}
 	}
 }
 
 // Helper functions
-func getStringValue(m map[string]interface{}, key, defaultValue string) string {
-	if v, ok := m[key]; ok {
-		if s, ok := v.(string); ok {
-			return s
+// A field of a raw log line. Records whether the key was present at all and
+// keeps string and number values; other JSON types only count as present.
+type logValue struct {
+	present  bool
+	isString bool
+	isNumber bool
+	str      string
+	num      float64
+}
+
+func (v *logValue) UnmarshalJSON(data []byte) error {
+	v.present = true
+	if len(data) == 0 {
+		return nil
+	}
+	switch c := data[0]; {
+	case c == '"':
+		// Most values have nothing to unescape or replace
+		if bytes.IndexByte(data, '\\') < 0 && utf8.Valid(data) {
+			v.str = string(data[1 : len(data)-1])
+		} else if err := json.Unmarshal(data, &v.str); err != nil {
+			return err
+		}
+		v.isString = true
+	case c == '-' || (c >= '0' && c <= '9'):
+		num, err := strconv.ParseFloat(string(data), 64)
+		if err != nil {
+			return err
 		}
+		v.num, v.isNumber = num, true
+	}
+	return nil
+}
+
+func (v logValue) String(defaultValue string) string {
+	if v.isString {
+		return v.str
 	}
 	return defaultValue
 }
 
-func getIntValue(m map[string]interface{}, key string, defaultValue int) int {
-	if v, ok := m[key]; ok {
-		switch v := v.(type) {
-		case float64:
-			return int(v)
-		case int:
-			return v
-		case string:
-			if i, err := strconv.Atoi(v); err == nil {
-				return i
-			}
+func (v logValue) Int(defaultValue int) int {
+	switch {
+	case v.isNumber:
+		return int(v.num)
+	case v.isString:
+		if i, err := strconv.Atoi(v.str); err == nil {
+			return i
 		}
 	}
 	return defaultValue
 }
 
-func getInt64Value(m map[string]interface{}, key string, defaultValue int64) int64 {
-	if v, ok := m[key]; ok {
-		switch v := v.(type) {
-		case float64:
-			return int64(v)
-		case int64:
-			return v
-		case string:
-			if i, err := strconv.ParseInt(v, 10, 64); err == nil {
-				return i
-			}
+func (v logValue) Int64(defaultValue int64) int64 {
+	switch {
+	case v.isNumber:
+		return int64(v.num)
+	case v.isString:
+		if i, err := strconv.ParseInt(v.str, 10, 64); err == nil {
+			return i
 		}
 	}
 	return defaultValue
 }
 
-func getFloatValue(m map[string]interface{}, key string, defaultValue float64) float64 {
-	if v, ok := m[key]; ok {
-		switch v := v.(type) {
-		case float64:
-			return v
-		case int:
-			return float64(v)
-		case string:
-			if f, err := strconv.ParseFloat(v, 64); err == nil {
-				return f
-			}
+func (v logValue) Float(defaultValue float64) float64 {
+	switch {
+	case v.isNumber:
+		return v.num
+	case v.isString:
+		if f, err := strconv.ParseFloat(v.str, 64); err == nil {
+			return f
 		}
 	}
 	return defaultValue
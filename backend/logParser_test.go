@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+// A typical Traefik JSON access log line
+const benchLogLine = `{"ClientAddr":"203.0.113.7:51234","ClientHost":"203.0.113.7","ClientPort":"51234","DownstreamContentSize":1532,"DownstreamStatus":200,"Duration":2345678,"OriginContentSize":1532,"OriginDuration":2100000,"OriginStatus":200,"Overhead":245678,"RequestAddr":"api.example.com","RequestContentSize":0,"RequestCount":42,"RequestHost":"api.example.com","RequestMethod":"GET","RequestPath":"/users/42/orders?page=2","RequestPort":"-","RequestProtocol":"HTTP/2.0","RequestScheme":"https","RetryAttempts":0,"RouterName":"api@docker","ServiceAddr":"172.18.0.5:8080","ServiceName":"api@docker","ServiceURL":"http://172.18.0.5:8080","StartLocal":"2026-10-15T10:00:00.123456789Z","StartUTC":"2026-10-15T10:00:00.123456789Z","entryPointName":"websecure","level":"info","msg":"","request_User-Agent":"Mozilla/5.0 (X11; Linux x86_64)","time":"2026-10-15T10:00:00Z"}`
+
+// Decode and store one line, as the file watcher does for every line it
+// reads. Run with -benchmem to see the allocations per line.
+func BenchmarkParseLine(b *testing.B) {
+	lp := NewLogParser()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if !lp.parseLine("bench.log", benchLogLine, false) {
+			b.Fatal("line rejected")
+		}
+	}
+}
+
+// Decoding alone, without storing the entry
+func BenchmarkDecodeLine(b *testing.B) {
+	lp := NewLogParser()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		decoded := lp.decodeLine("bench.log", benchLogLine)
+		if decoded.entry == nil {
+			b.Fatal(decoded.reason)
+		}
+		putLogEntry(decoded.entry)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+const LOG_CHUNK_SIZE = 256 // Entries per chunk; offsets must fit LogIndex's uint16
+
+// Fixed-capacity buffer of log entries, stored oldest first in chunks of
+// LOG_CHUNK_SIZE. Adding an entry is O(1) and drops the oldest one once
+// full. Entries are indexed newest first, so At(0) is the latest entry.
+//
+// Stored entries are never written again: the newest chunk is only appended
+// to, full chunks are sealed with an index, and changes to existing entries
+// copy the chunks they touch. A LogSnapshot taken under the parser lock can
+// therefore be read without it while the writer carries on.
+type LogRing struct {
+	LogSnapshot // Current contents
+	capacity    int
+}
+
+type logChunk struct {
+	entries []LogEntry // Always LOG_CHUNK_SIZE long; filled from the start
+	index   *LogIndex  // Set when the chunk is sealed, before a newer chunk exists
+}
+
+// Immutable view of the ring's contents at one point in time
+type LogSnapshot struct {
+	chunks  []*logChunk // Oldest first; only the last one may be partly filled
+	skip    int         // Entries at the start of the first chunk already dropped
+	headLen int         // Entries filled in the last chunk
+	size    int
+	seq     uint64 // The parser's sequence number when the snapshot was taken
+}
+
+func NewLogRing(capacity int) *LogRing {
+	return &LogRing{capacity: max(capacity, 1)}
+}
+
+func (r *LogRing) Cap() int {
+	return r.capacity
+}
+
+// Take a snapshot of the current contents; caller must hold the parser lock
+func (r *LogRing) Snapshot(seq uint64) *LogSnapshot {
+	snapshot := r.LogSnapshot
+	snapshot.seq = seq
+	return &snapshot
+}
+
+// Add an entry as the newest, dropping the oldest when full
+func (r *LogRing) Push(entry LogEntry) {
+	if len(r.chunks) == 0 || r.headLen == LOG_CHUNK_SIZE {
+		if n := len(r.chunks); n > 0 {
+			head := r.chunks[n-1]
+			head.index = NewLogIndex(head.entries)
+		}
+		// Appending never touches the part of the slice snapshots cover
+		r.chunks = append(r.chunks, &logChunk{entries: make([]LogEntry, LOG_CHUNK_SIZE)})
+		r.headLen = 0
+	}
+	r.chunks[len(r.chunks)-1].entries[r.headLen] = entry
+	r.headLen++
+	r.size++
+
+	if r.size > r.capacity {
+		r.size--
+		if r.skip++; r.skip == LOG_CHUNK_SIZE {
+			r.chunks = r.chunks[1:]
+			r.skip = 0
+		}
+	}
+}
+
+// Change entries in place of copies: update is called with a copy of every
+// entry for which needsUpdate returns true, and each chunk holding one is
+// replaced. Returns how many entries were updated.
+func (r *LogRing) Update(needsUpdate func(entry *LogEntry) bool, update func(entry *LogEntry)) int {
+	updated := 0
+	var chunks []*logChunk
+	for c, chunk := range r.chunks {
+		start, end := r.chunkBounds(c)
+		var copied *logChunk
+		for i := start; i < end; i++ {
+			if !needsUpdate(&chunk.entries[i]) {
+				continue
+			}
+			if copied == nil {
+				copied = &logChunk{entries: make([]LogEntry, LOG_CHUNK_SIZE), index: chunk.index}
+				copy(copied.entries, chunk.entries)
+				if chunks == nil {
+					chunks = make([]*logChunk, len(r.chunks))
+					copy(chunks, r.chunks)
+				}
+				chunks[c] = copied
+			}
+			update(&copied.entries[i])
+			updated++
+		}
+	}
+	if chunks != nil {
+		r.chunks = chunks
+	}
+	return updated
+}
+
+// Change the capacity, keeping the newest entries
+func (r *LogRing) Resize(capacity int) {
+	r.rebuild(max(capacity, 1), nil)
+}
+
+// Remove every entry
+func (r *LogRing) Reset() {
+	r.LogSnapshot = LogSnapshot{}
+}
+
+// Remove entries for which keep returns false; returns how many were removed
+func (r *LogRing) Filter(keep func(entry *LogEntry) bool) int {
+	before := r.size
+	r.rebuild(r.capacity, keep)
+	return before - r.size
+}
+
+// Re-add the kept entries oldest to newest into new chunks
+func (r *LogRing) rebuild(capacity int, keep func(entry *LogEntry) bool) {
+	old := r.LogSnapshot
+	r.LogSnapshot = LogSnapshot{}
+	r.capacity = capacity
+	for i := old.Len() - 1; i >= 0; i-- {
+		if entry := old.At(i); keep == nil || keep(entry) {
+			r.Push(*entry)
+		}
+	}
+}
+
+func (s *LogSnapshot) Len() int {
+	return s.size
+}
+
+// Get the i-th newest entry (0 = newest). Entries must not be modified.
+func (s *LogSnapshot) At(i int) *LogEntry {
+	position := s.skip + s.size - 1 - i
+	return &s.chunks[position/LOG_CHUNK_SIZE].entries[position%LOG_CHUNK_SIZE]
+}
+
+// Get the range of live entries in the c-th chunk
+func (s *LogSnapshot) chunkBounds(c int) (start, end int) {
+	end = LOG_CHUNK_SIZE
+	if c == len(s.chunks)-1 {
+		end = s.headLen
+	}
+	if c == 0 {
+		start = s.skip
+	}
+	return start, end
+}
+
+// Call fn for the entries that may match the filters with a sequence number
+// below the given bound, newest first, until fn returns false; returns
+// whether every candidate was visited. Sealed chunks are narrowed down with
+// their indexes when a filter is indexed; the caller still has to check the
+// filters on each candidate.
+func (s *LogSnapshot) EachCandidate(filters Filters, below uint64, fn func(entry *LogEntry) bool) bool {
+	for c := len(s.chunks) - 1; c >= 0; c-- {
+		chunk := s.chunks[c]
+		start, end := s.chunkBounds(c)
+		if start >= end || chunk.entries[start].Seq >= below {
+			continue // Sequence numbers rise through a chunk
+		}
+
+		// The newest chunk may still be filling up and has no index yet
+		if c < len(s.chunks)-1 {
+			if offsets, ok := chunk.index.Candidates(filters); ok {
+				for i := len(offsets) - 1; i >= 0 && int(offsets[i]) >= start; i-- {
+					if entry := &chunk.entries[offsets[i]]; entry.Seq < below && !fn(entry) {
+						return false
+					}
+				}
+				continue
+			}
+		}
+		for i := end - 1; i >= start; i-- {
+			if entry := &chunk.entries[i]; entry.Seq < below && !fn(entry) {
+				return false
+			}
+		}
+	}
+	return true
+}
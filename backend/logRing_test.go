@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func pushSeq(r *LogRing, from, to int) {
+	for seq := from; seq <= to; seq++ {
+		r.Push(LogEntry{Seq: uint64(seq)})
+	}
+}
+
+// Check the snapshot holds sequence numbers newest..newest-length+1, newest first
+func expectSeqs(t *testing.T, s *LogSnapshot, newest, length int) {
+	t.Helper()
+	if s.Len() != length {
+		t.Fatalf("length = %d, want %d", s.Len(), length)
+	}
+	for i := 0; i < length; i++ {
+		if got := s.At(i).Seq; got != uint64(newest-i) {
+			t.Fatalf("At(%d) = %d, want %d", i, got, newest-i)
+		}
+	}
+}
+
+func TestLogRingWraparound(t *testing.T) {
+	for _, capacity := range []int{1, LOG_CHUNK_SIZE - 1, LOG_CHUNK_SIZE, LOG_CHUNK_SIZE + 1, 2*LOG_CHUNK_SIZE + 88} {
+		t.Run(fmt.Sprint(capacity), func(t *testing.T) {
+			r := NewLogRing(capacity)
+			for n := 1; n <= 5*LOG_CHUNK_SIZE+3; n++ {
+				r.Push(LogEntry{Seq: uint64(n)})
+				// Around every chunk boundary and past the capacity
+				if n%LOG_CHUNK_SIZE <= 1 || n%LOG_CHUNK_SIZE == LOG_CHUNK_SIZE-1 || n == capacity+1 {
+					expectSeqs(t, r.Snapshot(0), n, min(n, capacity))
+				}
+			}
+			// Dropped chunks are released rather than kept growing
+			if maxChunks := capacity/LOG_CHUNK_SIZE + 2; len(r.chunks) > maxChunks {
+				t.Errorf("%d chunks for %d entries", len(r.chunks), capacity)
+			}
+		})
+	}
+}
+
+func TestLogRingSnapshotIsImmutable(t *testing.T) {
+	r := NewLogRing(2 * LOG_CHUNK_SIZE)
+	pushSeq(r, 1, LOG_CHUNK_SIZE+10)
+	snapshot := r.Snapshot(0)
+
+	// Pushing past the capacity and updating entries leave it unchanged
+	pushSeq(r, LOG_CHUNK_SIZE+11, 3*LOG_CHUNK_SIZE)
+	updated := r.Update(func(entry *LogEntry) bool { return entry.Seq%2 == 0 }, func(entry *LogEntry) {
+		entry.Path = "/updated"
+	})
+	if updated != LOG_CHUNK_SIZE {
+		t.Errorf("updated %d entries, want %d", updated, LOG_CHUNK_SIZE)
+	}
+	expectSeqs(t, snapshot, LOG_CHUNK_SIZE+10, LOG_CHUNK_SIZE+10)
+	for i := 0; i < snapshot.Len(); i++ {
+		if snapshot.At(i).Path != "" {
+			t.Fatalf("snapshot entry %d was modified", snapshot.At(i).Seq)
+		}
+	}
+
+	current := r.Snapshot(0)
+	expectSeqs(t, current, 3*LOG_CHUNK_SIZE, 2*LOG_CHUNK_SIZE)
+	for i := 0; i < current.Len(); i++ {
+		if entry := current.At(i); (entry.Path == "/updated") != (entry.Seq%2 == 0) {
+			t.Fatalf("entry %d has path %q", entry.Seq, entry.Path)
+		}
+	}
+}
+
+func TestLogRingResize(t *testing.T) {
+	r := NewLogRing(3 * LOG_CHUNK_SIZE)
+	pushSeq(r, 1, 4*LOG_CHUNK_SIZE+5)
+
+	// Shrinking keeps the newest entries
+	r.Resize(LOG_CHUNK_SIZE + 7)
+	expectSeqs(t, r.Snapshot(0), 4*LOG_CHUNK_SIZE+5, LOG_CHUNK_SIZE+7)
+
+	// Growing keeps them all and makes room for more
+	r.Resize(4 * LOG_CHUNK_SIZE)
+	expectSeqs(t, r.Snapshot(0), 4*LOG_CHUNK_SIZE+5, LOG_CHUNK_SIZE+7)
+	pushSeq(r, 4*LOG_CHUNK_SIZE+6, 8*LOG_CHUNK_SIZE)
+	expectSeqs(t, r.Snapshot(0), 8*LOG_CHUNK_SIZE, 4*LOG_CHUNK_SIZE)
+
+	if r.Resize(0); r.Cap() != 1 {
+		t.Errorf("capacity = %d, want at least 1", r.Cap())
+	}
+	expectSeqs(t, r.Snapshot(0), 8*LOG_CHUNK_SIZE, 1)
+}
+
+func TestLogRingFilter(t *testing.T) {
+	r := NewLogRing(2 * LOG_CHUNK_SIZE)
+	pushSeq(r, 1, 3*LOG_CHUNK_SIZE)
+	if removed := r.Filter(func(entry *LogEntry) bool { return entry.Seq%2 == 1 }); removed != LOG_CHUNK_SIZE {
+		t.Errorf("removed %d entries, want %d", removed, LOG_CHUNK_SIZE)
+	}
+	snapshot := r.Snapshot(0)
+	if snapshot.Len() != LOG_CHUNK_SIZE {
+		t.Fatalf("length = %d, want %d", snapshot.Len(), LOG_CHUNK_SIZE)
+	}
+	for i := 0; i < snapshot.Len(); i++ {
+		if got, want := snapshot.At(i).Seq, uint64(3*LOG_CHUNK_SIZE-1-2*i); got != want {
+			t.Fatalf("At(%d) = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestLogRingEachCandidate(t *testing.T) {
+	r := NewLogRing(2*LOG_CHUNK_SIZE + 10)
+	pushSeq(r, 1, 4*LOG_CHUNK_SIZE)
+	snapshot := r.Snapshot(0)
+
+	// Newest first, below the bound, across the sealed and filling chunks
+	below := uint64(4*LOG_CHUNK_SIZE - 20)
+	next := below - 1
+	complete := snapshot.EachCandidate(Filters{}, below, func(entry *LogEntry) bool {
+		if entry.Seq != next {
+			t.Fatalf("visited %d, want %d", entry.Seq, next)
+		}
+		next--
+		return true
+	})
+	if oldest := uint64(4*LOG_CHUNK_SIZE - snapshot.Len()); !complete || next != oldest {
+		t.Errorf("stopped before %d (complete %v), want the oldest entry %d", next+1, complete, oldest+1)
+	}
+}
+
+func TestLogParserResizeBuffer(t *testing.T) {
+	lp := NewLogParser()
+	lp.SetMaxLogs(3 * LOG_CHUNK_SIZE)
+	for i := 1; i <= 2*LOG_CHUNK_SIZE; i++ {
+		if !lp.parseLine("access.log", accessLine(fmt.Sprintf("/%d", i)), false) {
+			t.Fatal("line rejected")
+		}
+	}
+	expectPaths := func(newest, length int) {
+		t.Helper()
+		snapshot := lp.logsSnapshot.Load()
+		if snapshot.Len() != length || lp.EffectiveMaxLogs() < length {
+			t.Fatalf("%d entries kept, capacity %d; want %d", snapshot.Len(), lp.EffectiveMaxLogs(), length)
+		}
+		for i := 0; i < length; i++ {
+			if got, want := snapshot.At(i).Path, fmt.Sprintf("/%d", newest-i); got != want {
+				t.Fatalf("At(%d) = %s, want %s", i, got, want)
+			}
+		}
+	}
+
+	// The memory budget caps the buffer below maxLogs, and lifting it lets
+	// the buffer grow back
+	lp.SetBudgetMaxLogs(LOG_CHUNK_SIZE / 2)
+	expectPaths(2*LOG_CHUNK_SIZE, LOG_CHUNK_SIZE/2)
+	if lp.EffectiveMaxLogs() != LOG_CHUNK_SIZE/2 {
+		t.Errorf("capacity = %d under the budget", lp.EffectiveMaxLogs())
+	}
+	lp.SetBudgetMaxLogs(0)
+	if lp.EffectiveMaxLogs() != 3*LOG_CHUNK_SIZE {
+		t.Errorf("capacity = %d without the budget", lp.EffectiveMaxLogs())
+	}
+	expectPaths(2*LOG_CHUNK_SIZE, LOG_CHUNK_SIZE/2)
+
+	lp.SetMaxLogs(LOG_CHUNK_SIZE / 4)
+	expectPaths(2*LOG_CHUNK_SIZE, LOG_CHUNK_SIZE/4)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Subsystems with their own logger and level (LOG_LEVELS=ws=warn,geo=error)
+const (
+	SUBSYSTEM_APP     = "app"
+	SUBSYSTEM_PARSER  = "parser"
+	SUBSYSTEM_WATCHER = "watcher"
+	SUBSYSTEM_GEO     = "geo"
+	SUBSYSTEM_WS      = "ws"
+	SUBSYSTEM_OTLP    = "otlp"
+)
+
+var (
+	logOutput        atomic.Pointer[slog.Handler] // LOG_FORMAT handler every logger writes through
+	defaultLogOutput slog.Handler                 = slog.NewTextHandler(os.Stderr, nil)
+	logLevels                                     = map[string]*slog.LevelVar{
+		SUBSYSTEM_APP:     new(slog.LevelVar),
+		SUBSYSTEM_PARSER:  new(slog.LevelVar),
+		SUBSYSTEM_WATCHER: new(slog.LevelVar),
+		SUBSYSTEM_GEO:     new(slog.LevelVar),
+		SUBSYSTEM_WS:      new(slog.LevelVar),
+		SUBSYSTEM_OTLP:    new(slog.LevelVar),
+	}
+
+	appLog     = newSubsystemLogger(SUBSYSTEM_APP)
+	parserLog  = newSubsystemLogger(SUBSYSTEM_PARSER)
+	watcherLog = newSubsystemLogger(SUBSYSTEM_WATCHER)
+	geoLog     = newSubsystemLogger(SUBSYSTEM_GEO)
+	wsLog      = newSubsystemLogger(SUBSYSTEM_WS)
+	otlpLog    = newSubsystemLogger(SUBSYSTEM_OTLP)
+)
+
+// Logging settings from LOG_LEVEL, LOG_LEVELS and LOG_FORMAT
+type LoggingConfig struct {
+	Level  slog.Level            // Default for every subsystem
+	Levels map[string]slog.Level // Per subsystem overrides
+	JSON   bool
+}
+
+func init() {
+	// Anything still using the standard logger (libraries included) goes
+	// through the app logger
+	slog.SetDefault(appLog)
+}
+
+// Read the logging settings from the environment
+func GetLoggingConfigFromEnv() (LoggingConfig, error) {
+	config := LoggingConfig{Levels: make(map[string]slog.Level)}
+	if err := config.Level.UnmarshalText([]byte(GetEnvString("LOG_LEVEL", "info"))); err != nil {
+		return config, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error")
+	}
+
+	for _, pair := range strings.Split(os.Getenv("LOG_LEVELS"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		subsystem, value, ok := strings.Cut(pair, "=")
+		subsystem = strings.TrimSpace(subsystem)
+		if _, known := logLevels[subsystem]; !ok || !known {
+			return config, fmt.Errorf("LOG_LEVELS entries must be subsystem=level with a subsystem from %s, got %q", strings.Join(logSubsystems(), ", "), pair)
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+			return config, fmt.Errorf("invalid level for %s in LOG_LEVELS: %q", subsystem, value)
+		}
+		config.Levels[subsystem] = level
+	}
+	// OTLP_DEBUG predates LOG_LEVELS
+	if _, set := config.Levels[SUBSYSTEM_OTLP]; !set && GetEnvBool("OTLP_DEBUG", false) {
+		config.Levels[SUBSYSTEM_OTLP] = slog.LevelDebug
+	}
+
+	switch format := GetEnvString("LOG_FORMAT", "console"); format {
+	case "console":
+	case "json":
+		config.JSON = true
+	default:
+		return config, fmt.Errorf("LOG_FORMAT must be console or json, got %q", format)
+	}
+	return config, nil
+}
+
+// Apply logging settings; loggers already handed out pick them up
+func SetLoggingConfig(config LoggingConfig) {
+	var output slog.Handler
+	if config.JSON {
+		output = slog.NewJSONHandler(os.Stderr, nil)
+	} else {
+		output = slog.NewTextHandler(os.Stderr, nil)
+	}
+	logOutput.Store(&output)
+
+	for subsystem, level := range logLevels {
+		if override, ok := config.Levels[subsystem]; ok {
+			level.Set(override)
+		} else {
+			level.Set(config.Level)
+		}
+	}
+}
+
+func logSubsystems() []string {
+	names := make([]string, 0, len(logLevels))
+	for name := range logLevels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Log at error level and exit
+func fatal(msg string, args ...any) {
+	appLog.Error(msg, args...)
+	os.Exit(1)
+}
+
+func newSubsystemLogger(subsystem string) *slog.Logger {
+	return slog.New(&subsystemHandler{
+		level: logLevels[subsystem],
+		derive: func(output slog.Handler) slog.Handler {
+			return output.WithAttrs([]slog.Attr{slog.String("subsystem", subsystem)})
+		},
+	})
+}
+
+// Filters by its subsystem's level and writes through the current output
+// handler, so the format can change after loggers are created
+type subsystemHandler struct {
+	level  *slog.LevelVar
+	derive func(slog.Handler) slog.Handler // Adds the subsystem and any With attributes
+}
+
+func (h *subsystemHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *subsystemHandler) Handle(ctx context.Context, r slog.Record) error {
+	output := defaultLogOutput
+	if configured := logOutput.Load(); configured != nil {
+		output = *configured
+	}
+	return h.derive(output).Handle(ctx, r)
+}
+
+func (h *subsystemHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derive := h.derive
+	return &subsystemHandler{level: h.level, derive: func(output slog.Handler) slog.Handler {
+		return derive(output).WithAttrs(attrs)
+	}}
+}
+
+func (h *subsystemHandler) WithGroup(name string) slog.Handler {
+	derive := h.derive
+	return &subsystemHandler{level: h.level, derive: func(output slog.Handler) slog.Handler {
+		return derive(output).WithGroup(name)
+	}}
+}
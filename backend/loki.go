@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	LOKI_QUEUE_SIZE         = 10000
+	DEFAULT_LOKI_BATCH_SIZE = 1000
+	DEFAULT_LOKI_BATCH_WAIT = time.Second
+	LOKI_MAX_RETRIES        = 3
+	LOKI_INITIAL_BACKOFF    = 500 * time.Millisecond
+)
+
+// Forwards live LogEntry records to Loki's push API, one stream per
+// service/router/status (plus tenant) label set. Entries are batched and
+// dropped rather than blocking ingestion if Loki falls behind.
+type LokiForwarder struct {
+	url       string
+	tenantID  string
+	username  string
+	password  string
+	labels    map[string]string // Static labels added to every stream
+	batchSize int
+	batchWait time.Duration
+	client    *http.Client
+	queue     chan LogEntry
+	stop      chan struct{}
+	done      chan struct{}
+	dropped   uint64
+}
+
+var lokiForwarder *LokiForwarder
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// Create the forwarder from LOKI_URL (e.g. http://loki:3100); nil when unset
+func NewLokiForwarder() (*LokiForwarder, error) {
+	url := os.Getenv("LOKI_URL")
+	if url == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("invalid LOKI_URL: %s", redactURL(url))
+	}
+
+	labels := map[string]string{"job": "traefik"}
+	for _, pair := range strings.Split(os.Getenv("LOKI_LABELS"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid LOKI_LABELS entry: %s", pair)
+		}
+		labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	batchSize := GetEnvInt("LOKI_BATCH_SIZE", DEFAULT_LOKI_BATCH_SIZE)
+	if batchSize < 1 {
+		return nil, fmt.Errorf("LOKI_BATCH_SIZE must be at least 1")
+	}
+
+	f := &LokiForwarder{
+		url:       strings.TrimRight(url, "/") + "/loki/api/v1/push",
+		tenantID:  os.Getenv("LOKI_TENANT_ID"),
+		username:  os.Getenv("LOKI_USERNAME"),
+		password:  os.Getenv("LOKI_PASSWORD"),
+		labels:    labels,
+		batchSize: batchSize,
+		batchWait: time.Duration(GetEnvInt("LOKI_BATCH_WAIT_MS", int(DEFAULT_LOKI_BATCH_WAIT/time.Millisecond))) * time.Millisecond,
+		client:    &http.Client{Timeout: 15 * time.Second},
+		queue:     make(chan LogEntry, LOKI_QUEUE_SIZE),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if f.batchWait <= 0 {
+		f.batchWait = DEFAULT_LOKI_BATCH_WAIT
+	}
+	go f.run()
+	appLog.Info("Forwarding parsed logs to Loki", "url", redactURL(f.url))
+	return f, nil
+}
+
+// Queue an entry for forwarding without blocking
+func (f *LokiForwarder) Forward(entry *LogEntry) {
+	select {
+	case f.queue <- *entry:
+	default:
+		if dropped := atomic.AddUint64(&f.dropped, 1); dropped%1000 == 1 {
+			appLog.Warn("Loki queue full", "droppedTotal", dropped)
+		}
+	}
+}
+
+func (f *LokiForwarder) run() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.batchWait)
+	defer ticker.Stop()
+
+	batch := make([]LogEntry, 0, f.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := f.push(batch); err != nil {
+			appLog.Warn("Dropping Loki batch", "entries", len(batch), "error", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry := <-f.queue:
+			batch = append(batch, entry)
+			if len(batch) >= f.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-f.stop:
+			// Drain what's already queued before exiting
+			for {
+				select {
+				case entry := <-f.queue:
+					batch = append(batch, entry)
+					if len(batch) >= f.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Flush queued entries and stop
+func (f *LokiForwarder) Stop() {
+	close(f.stop)
+	<-f.done
+}
+
+// Group entries into streams by label set
+func (f *LokiForwarder) streams(batch []LogEntry) []lokiStream {
+	byLabels := make(map[string]*lokiStream)
+	var order []string
+
+	for i := range batch {
+		entry := &batch[i]
+		labels := make(map[string]string, len(f.labels)+4)
+		for key, value := range f.labels {
+			labels[key] = value
+		}
+		labels["service"] = entry.ServiceName
+		labels["router"] = entry.RouterName
+		labels["status"] = strconv.Itoa(entry.Status)
+		if entry.Tenant != "" {
+			labels["tenant"] = entry.Tenant
+		}
+		key := labels["service"] + "\x00" + labels["router"] + "\x00" + labels["status"] + "\x00" + entry.Tenant
+
+		stream, ok := byLabels[key]
+		if !ok {
+			stream = &lokiStream{Stream: labels}
+			byLabels[key] = stream
+			order = append(order, key)
+		}
+
+		line, err := json.Marshal(entry)
+		if err != nil {
+			continue
+		}
+		timestamp := time.Now()
+		if parsed, err := time.Parse(time.RFC3339, entry.Timestamp); err == nil {
+			timestamp = parsed
+		}
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(timestamp.UnixNano(), 10), string(line)})
+	}
+
+	result := make([]lokiStream, 0, len(order))
+	for _, key := range order {
+		result = append(result, *byLabels[key])
+	}
+	return result
+}
+
+// Push a batch, retrying transient failures (network errors, 429, 5xx)
+func (f *LokiForwarder) push(batch []LogEntry) error {
+	body, err := json.Marshal(map[string]interface{}{"streams": f.streams(batch)})
+	if err != nil {
+		return err
+	}
+
+	backoff := LOKI_INITIAL_BACKOFF
+	for attempt := 0; ; attempt++ {
+		retryable, err := f.send(body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= LOKI_MAX_RETRIES {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (f *LokiForwarder) send(body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if f.tenantID != "" {
+		req.Header.Set("X-Scope-OrgID", f.tenantID)
+	}
+	if f.username != "" {
+		req.SetBasicAuth(f.username, f.password)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("Loki returned HTTP %d", resp.StatusCode)
+}
@@ -3,10 +3,14 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"hash/fnv"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -15,7 +19,6 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	"github.com/joho/godotenv"
 )
 
 var (
@@ -23,36 +26,165 @@ var (
 	otlpReceiver *OTLPReceiver
 	upgrader     = websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
-			return true // Allow connections from any origin
+			return wsAuth.CheckOrigin(r)
 		},
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
+		Subprotocols:    wsSubprotocols,
 	}
 	wsClients    = make(map[*WebSocketClient]bool)
 	wsClientsMux = sync.RWMutex{}
 	healthTicker *time.Ticker
 	healthStop   chan struct{}
+
+	// Whether log files are expected to be monitored (false in OTLP-only mode)
+	logFilesEnabled bool
 )
 
-func main() {
+// Run the dashboard backend until a shutdown signal
+func serve() {
 	// Load environment variables
-	godotenv.Load()
+	if err := loadConfigFile(); err != nil {
+		fatal("Failed to load config", "error", err)
+	}
+	if err := loadSecretFiles(); err != nil {
+		fatal("Failed to load secrets", "error", err)
+	}
+	loggingConfig, err := GetLoggingConfigFromEnv()
+	if err != nil {
+		fatal("Invalid logging configuration", "error", err)
+	}
+	SetLoggingConfig(loggingConfig)
+
+	// Check the whole configuration up front so every problem is reported at once
+	validation := ValidateConfig(true)
+	for _, issue := range validation.Warnings {
+		appLog.Warn("Configuration warning", "check", issue.Check, "message", issue.Message)
+	}
+	for _, issue := range validation.Errors {
+		appLog.Error("Configuration error", "check", issue.Check, "message", issue.Message)
+	}
+	if !validation.Valid {
+		fatal("Refusing to start with an invalid configuration", "errors", len(validation.Errors))
+	}
 
 	// Initialize log parser
 	logParser = NewLogParser()
+	parsePool = NewParsePool()
+	logParser.statsCache.Configure(
+		time.Duration(GetEnvInt("STATS_CACHE_INTERVAL_MS", int(DEFAULT_STATS_CACHE_INTERVAL/time.Millisecond)))*time.Millisecond,
+		GetEnvInt("STATS_CACHE_MAX_ENTRIES", DEFAULT_STATS_CACHE_MAX_ENTRIES))
+	ingestConfig, err := GetIngestConfigFromEnv()
+	if err == nil {
+		err = ingestControl.SetConfig(ingestConfig)
+	}
+	if err != nil {
+		fatal("Invalid ingest configuration", "error", err)
+	}
+	otlpSampling, err := GetOTLPSamplingConfigFromEnv()
+	if err == nil {
+		err = otlpSampler.SetConfig(otlpSampling)
+	}
+	if err != nil {
+		fatal("Invalid OTLP sampling configuration", "error", err)
+	}
+	resolver, err := NewClientIPResolver()
+	if err != nil {
+		fatal("Invalid trusted proxy configuration", "error", err)
+	}
+	SetClientIPResolver(resolver)
+	capture, err := NewHeaderCapture()
+	if err != nil {
+		fatal("Invalid header capture configuration", "error", err)
+	}
+	SetHeaderCapture(capture)
+	pathTemplates, err := GetPathTemplateConfigFromEnv()
+	if err == nil {
+		err = SetPathTemplateConfig(pathTemplates)
+	}
+	if err != nil {
+		fatal("Invalid path template configuration", "error", err)
+	}
+	aliases, err := NewNameAliases()
+	if err != nil {
+		fatal("Invalid alias configuration", "error", err)
+	}
+	SetNameAliases(aliases)
+	parseErrorTracker.SetSampleSize(GetEnvInt("PARSE_ERROR_SAMPLES", DEFAULT_PARSE_ERROR_SAMPLES))
+	maxLogs, err := GetMaxLogsFromEnv()
+	if err != nil {
+		fatal("Invalid buffer configuration", "error", err)
+	}
+	if maxLogs != DEFAULT_MAX_LOGS {
+		logParser.SetMaxLogs(maxLogs)
+	}
+	buffers, err := GetBufferSizesFromEnv()
+	if err != nil {
+		fatal("Invalid buffer configuration", "error", err)
+	}
+	SetBufferSizes(buffers)
+	logParser.SetGeoTrackedIPs(GetEnvInt("GEO_TRACKED_IPS", DEFAULT_GEO_TRACKED_IPS))
+	logParser.StartTopCompaction(time.Duration(GetEnvInt("TOP_COMPACTION_INTERVAL_SECONDS", int(DEFAULT_TOP_COMPACTION_INTERVAL/time.Second))) * time.Second)
+	initialLoad, err := GetInitialLoadConfigFromEnv()
+	if err != nil {
+		fatal("Invalid initial load configuration", "error", err)
+	}
+	SetInitialLoadConfig(initialLoad)
+	if memoryBudget, err = NewMemoryBudget(); err != nil {
+		fatal("Invalid memory budget configuration", "error", err)
+	}
+	if memoryBudget != nil {
+		memoryBudget.Start(logParser)
+	}
+
+	// Initialize WebSocket authentication
+	wsAuth = NewWSAuth()
+
+	// Initialize basic auth and OIDC; refuse to start with a broken config rather than run unprotected
+	if basicAuth, err = NewBasicAuth(); err != nil {
+		fatal("Invalid basic auth configuration", "error", err)
+	}
+	if oidcAuth, err = NewOIDCAuth(GetOIDCConfig()); err != nil {
+		fatal("Invalid OIDC configuration", "error", err)
+	}
+	if tenancy, err = LoadTenancy(); err != nil {
+		fatal("Invalid tenancy configuration", "error", err)
+	}
+	if adminAllowedNets, err = LoadAdminAllowlist(); err != nil {
+		fatal("Invalid admin allowlist", "error", err)
+	}
+	if ticketSigner, err = NewTicketSigner(); err != nil {
+		fatal("Invalid ticket configuration", "error", err)
+	}
+	apiUsage.SetDefaults(GetDefaultTokenLimits())
+
+	// Initialize alert/system event notifications
+	if err := initNotifications(); err != nil {
+		fatal("Invalid notification configuration", "error", err)
+	}
+	if statsdEmitter, err = NewStatsDEmitter(); err != nil {
+		fatal("Invalid StatsD configuration", "error", err)
+	}
+	if lokiForwarder, err = NewLokiForwarder(); err != nil {
+		fatal("Invalid Loki configuration", "error", err)
+	}
+	if config := GetMaxMindConfig(); config.Enabled && config.DatabasePath != "" && !config.DatabaseLoaded {
+		notifySystemEvent("maxmindLoadFailed", SEVERITY_WARNING, "MaxMind database failed to load",
+			"The configured MaxMind database could not be opened at startup", map[string]interface{}{"path": config.DatabasePath})
+	}
 
 	// Initialize OTLP receiver if enabled
 	otlpConfig := GetOTLPConfig()
 	if otlpConfig.Enabled {
 		otlpReceiver = NewOTLPReceiver(logParser, otlpConfig)
-		log.Printf("OTLP receiver initialized - GRPC:%d, HTTP:%d", otlpConfig.GRPCPort, otlpConfig.HTTPPort)
+		otlpLog.Info("OTLP receiver initialized", "grpcPort", otlpConfig.GRPCPort, "httpPort", otlpConfig.HTTPPort)
 		
 		// Start OTLP receiver
 		if err := otlpReceiver.Start(); err != nil {
-			log.Printf("Failed to start OTLP receiver: %v", err)
+			otlpLog.Error("Failed to start OTLP receiver", "error", err)
 		}
 	} else {
-		log.Printf("OTLP receiver is disabled")
+		otlpLog.Info("OTLP receiver is disabled")
 	}
 
 	// Setup graceful shutdown
@@ -63,58 +195,105 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	drainOnSIGTERM := GetDrainConfig().OnSIGTERM
 	go func() {
-		<-sigChan
-		log.Println("Shutdown signal received, cleaning up...")
-		cancel()
-		cleanup()
-		os.Exit(0)
+		for sig := range sigChan {
+			if isDraining() {
+				// Asked again while draining: give up on the tail
+				appLog.Warn("Signal received while draining, exiting now", "signal", sig.String())
+				os.Exit(1)
+			}
+			if sig == syscall.SIGTERM && drainOnSIGTERM {
+				StartDrain("SIGTERM")
+				continue
+			}
+			appLog.Info("Shutdown signal received, cleaning up")
+			sdNotify("STOPPING=1")
+			cancel()
+			cleanup()
+			os.Exit(0)
+		}
+	}()
+
+	// SIGHUP re-reads the config file
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			appLog.Info("SIGHUP received, reloading configuration")
+			sdNotify("RELOADING=1")
+			if _, err := ReloadConfig(); err != nil {
+				appLog.Error("Configuration reload failed", "error", err)
+			}
+			sdNotify("READY=1")
+		}
 	}()
 
 	// Start WebSocket health monitoring
 	startWebSocketHealthMonitor()
 
-	// Setup Gin router
-	r := gin.Default()
+	// Start the stats/geo broadcaster shared by all WebSocket clients
+	startBroadcastHub()
 
-	// Configure CORS
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"*"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-	}))
+	// Start alert rules and system health checks
+	alertMonitor = startAlertMonitor(GetAlertConfig())
 
-	// API Routes
-	r.GET("/api/stats", getStats)
-	r.GET("/api/logs", getLogs)
-	r.GET("/api/services", getServices)
-	r.GET("/api/routers", getRouters)
-	r.GET("/api/geo-stats", getGeoStats)
-	r.GET("/api/geo-processing-status", getGeoProcessingStatus)
-	r.POST("/api/set-log-file", setLogFile)
-	r.POST("/api/set-log-files", setLogFiles)
-	
-	// OTLP API Routes
-	r.GET("/api/otlp/status", getOTLPStatus)
-	r.POST("/api/otlp/start", startOTLPReceiver)
-	r.POST("/api/otlp/stop", stopOTLPReceiver)
-	r.GET("/api/otlp/stats", getOTLPStats)
-	
-	// MaxMind API Routes
-	r.GET("/api/maxmind/config", getMaxMindConfig)
-	r.POST("/api/maxmind/reload", reloadMaxMindDatabase)
-	r.POST("/api/maxmind/test", testMaxMindDatabase)
-	
-	// WebSocket status endpoint for debugging
-	r.GET("/api/websocket/status", getWebSocketStatus)
-	
-	// Health check with WebSocket status
-	r.GET("/health", healthCheck)
+	// Ban abusive clients via a Traefik dynamic config file
+	if blocklist, err = NewBlocklist(); err != nil {
+		fatal("Invalid blocklist configuration", "error", err)
+	}
+	if blocklist != nil {
+		blocklist.Start()
+	}
 
-	// WebSocket endpoint
-	r.GET("/ws", handleWebSocket)
+	// Router/service definitions from Traefik's API and containers from Docker's
+	if traefikAPI, err = NewTraefikAPIClient(); err != nil {
+		fatal("Invalid Traefik API configuration", "error", err)
+	}
+	if traefikAPI != nil {
+		traefikAPI.Start()
+	}
+	if dockerEnricher, err = NewDockerEnricher(); err != nil {
+		fatal("Invalid Docker enrichment configuration", "error", err)
+	}
+	if dockerEnricher != nil {
+		dockerEnricher.Start()
+	}
+
+	// Daily/weekly summaries for download and the notifiers
+	if reporter, err = NewReporter(); err != nil {
+		fatal("Invalid report configuration", "error", err)
+	}
+	reporter.Start()
+
+	// Start optional metrics exporters
+	if influxPusher, err = NewInfluxPusher(); err != nil {
+		fatal("Invalid InfluxDB configuration", "error", err)
+	}
+	if influxPusher != nil {
+		influxPusher.Start()
+	}
+	if otlpMetricsExporter, err = NewOTLPMetricsExporter(); err != nil {
+		fatal("Invalid OTLP metrics export configuration", "error", err)
+	}
+	if otlpMetricsExporter != nil {
+		otlpMetricsExporter.Start()
+	}
+	if otlpTraceForwarder, err = NewOTLPTraceForwarder(); err != nil {
+		fatal("Invalid OTLP trace forwarding configuration", "error", err)
+	}
+	if otlpTraceForwarder != nil {
+		otlpTraceForwarder.Start()
+	}
+	if mqttPublisher, err = NewMQTTPublisher(); err != nil {
+		fatal("Invalid MQTT configuration", "error", err)
+	}
+	if mqttPublisher != nil {
+		mqttPublisher.Start()
+	}
+
+	// Setup Gin router
+	r := newRouter()
 
 	// Handle log files ONLY if OTLP is disabled OR if TRAEFIK_LOG_FILE is explicitly set
 	logFile := os.Getenv("TRAEFIK_LOG_FILE")
@@ -122,24 +301,16 @@ func main() {
 	// FIXED: Only watch log files if explicitly configured or OTLP is disabled
 	if !otlpConfig.Enabled || (logFile != "" && logFile != "none") {
 		if logFile == "" {
-			logFile = "/logs/traefik.log" // Default only when OTLP is disabled
+			logFile = DEFAULT_LOG_FILE // Default only when OTLP is disabled
 		}
 		
-		log.Printf("Setting up log file monitoring for: %s", logFile)
+		watcherLog.Info("Setting up log file monitoring", "sources", logFile)
+		logFilesEnabled = true
 
-		// Check if multiple log files are specified
-		if strings.Contains(logFile, ",") {
-			logFiles := strings.Split(logFile, ",")
-			for i := range logFiles {
-				logFiles[i] = strings.TrimSpace(logFiles[i])
-			}
-			go logParser.SetLogFiles(logFiles)
-		} else {
-			go logParser.SetLogFiles([]string{logFile})
-		}
+		// Multiple log files may be specified, separated by commas
+		go logParser.SetLogFiles(splitLogSources(logFile))
 	} else {
-		log.Printf("Running in OTLP-only mode - log file monitoring disabled")
-		log.Printf("OTLP_ENABLED=%t, TRAEFIK_LOG_FILE='%s'", otlpConfig.Enabled, logFile)
+		appLog.Info("Running in OTLP-only mode, log file monitoring disabled", "otlpEnabled", otlpConfig.Enabled, "logFile", logFile)
 	}
 
 	// Start the server
@@ -148,48 +319,237 @@ func main() {
 		port = "3001"
 	}
 
-	log.Printf("Server running on port %s", port)
-	log.Printf("MaxMind configuration: %+v", GetMaxMindConfig())
-	log.Printf("OTLP configuration: %+v", otlpConfig)
-	log.Printf("WebSocket clients tracking enabled")
+	appLog.Info("Server running", "port", port)
+	geoLog.Info("MaxMind configuration", "config", GetMaxMindConfig())
+	otlpLog.Info("OTLP configuration", "config", otlpConfig)
 	
+	// TLS / mutual TLS for the API and WebSocket
+	apiTLS := GetTLSSettings("")
+	tlsConfig, err := apiTLS.ServerConfig()
+	if err != nil {
+		fatal("Invalid TLS configuration", "error", err)
+	}
+	if tlsConfig != nil {
+		appLog.Info("TLS enabled", "clientCertificatesRequired", apiTLS.MutualTLS())
+	}
+
 	// Start server with graceful shutdown
 	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: r,
+		Addr:      ":" + port,
+		Handler:   r,
+		TLSConfig: tlsConfig,
 	}
 
+	// Bind before reporting ready, so services ordered after this one can
+	// connect as soon as they start
+	listener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		fatal("Failed to start server", "error", err)
+	}
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal("Failed to start server:", err)
+		var err error
+		if tlsConfig != nil {
+			// Certificates come from TLSConfig
+			err = srv.ServeTLS(listener, "", "")
+		} else {
+			err = srv.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal("Failed to start server", "error", err)
 		}
 	}()
-
-	// Wait for shutdown signal
-	<-ctx.Done()
+	sdNotify("READY=1\nSTATUS=Listening on port " + port)
+	startSystemdWatchdog()
+
+	// Wait for shutdown signal or the end of a drain
+	drained := false
+	select {
+	case <-ctx.Done():
+	case <-drainDone:
+		drained = true
+	}
 	
 	// Shutdown server with timeout
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
 	
 	if err := srv.Shutdown(shutdownCtx); err != nil {
-		log.Printf("Server shutdown error: %v", err)
+		appLog.Error("Server shutdown error", "error", err)
+	}
+	if drained {
+		finishDrain()
 	}
 }
 
+// Build the router with the middleware and every API route
+func newRouter() *gin.Engine {
+	r := gin.Default()
+
+	// Configure CORS
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"*"},
+		ExposeHeaders:    []string{"Content-Length", "ETag", "Last-Modified"},
+		AllowCredentials: true,
+	}))
+
+	// User authentication (no-op unless basic auth or OIDC is configured)
+	r.Use(authMiddleware())
+	registerAuthRoutes(r)
+
+	// Per-API-key rate limits and daily quotas
+	r.Use(rateLimitMiddleware())
+
+	// Mutating admin routes: admin network, global scope and the API token
+	admin := r.Group("", requireAdminNetwork(), requireGlobalAccess(), requireAPIToken())
+
+	// API Routes
+	r.GET("/api/stats", getStats)
+	r.GET("/api/logs", getLogs)
+	r.GET("/api/services", getServices)
+	r.GET("/api/routers", getRouters)
+	r.GET("/api/facets", getFacets)
+	r.GET("/api/sources", getSources)
+	r.GET("/api/sources/:id/errors", getSourceErrors)
+	r.POST("/api/batch", runBatchQueries)
+	r.GET("/api/export", exportLogs)
+	r.GET("/api/traces/:traceId", getTrace)
+	r.GET("/api/traces/:traceId/waterfall", getTraceWaterfall)
+	r.POST("/api/tickets", requireAPIToken(), issueTicket)
+	r.GET("/api/geo-stats", getGeoStats)
+	r.GET("/api/geo-processing-status", requireGlobalAccess(), getGeoProcessingStatus)
+	admin.POST("/api/set-log-file", setLogFile)
+	admin.POST("/api/set-log-files", setLogFiles)
+	admin.POST("/api/reset-log-source", resetLogSource)
+	admin.POST("/api/sources/:id/backfill", backfillSource)
+	
+	// OTLP API Routes
+	r.GET("/api/otlp/status", requireGlobalAccess(), getOTLPStatus)
+	admin.POST("/api/otlp/start", startOTLPReceiver)
+	admin.POST("/api/otlp/stop", stopOTLPReceiver)
+	r.GET("/api/otlp/stats", requireGlobalAccess(), getOTLPStats)
+	r.GET("/api/otlp/metrics", requireGlobalAccess(), getTraefikMetrics)
+	r.GET("/api/otlp/metrics/compare", requireGlobalAccess(), compareTraefikMetrics)
+	
+	// Traefik API enrichment
+	r.GET("/api/traefik/routers", requireGlobalAccess(), getTraefikRouters)
+	r.GET("/api/traefik/services", requireGlobalAccess(), getTraefikServices)
+	r.GET("/api/traefik/middlewares", requireGlobalAccess(), getTraefikMiddlewares)
+	r.GET("/api/docker/services", requireGlobalAccess(), getDockerServices)
+	r.GET("/api/docker/stacks", requireGlobalAccess(), getDockerStacks)
+	r.GET("/api/reports", requireGlobalAccess(), listReports)
+	admin.POST("/api/reports", createReport)
+	r.GET("/api/reports/:id", requireGlobalAccess(), getReport)
+	
+	// MaxMind API Routes
+	r.GET("/api/maxmind/config", requireGlobalAccess(), getMaxMindConfig)
+	admin.POST("/api/maxmind/reload", reloadMaxMindDatabase)
+	admin.POST("/api/maxmind/test", testMaxMindDatabase)
+	
+	// Runtime configuration
+	r.GET("/api/config/validate", requireAdminNetwork(), requireGlobalAccess(), getConfigValidation)
+	r.GET("/api/diagnostics", requireAdminNetwork(), requireGlobalAccess(), getDiagnostics)
+	r.GET("/api/admin/config", requireAdminNetwork(), requireGlobalAccess(), getAdminConfig)
+	admin.PATCH("/api/admin/config", patchAdminConfig)
+	admin.POST("/api/admin/reload", reloadAdminConfig)
+	admin.POST("/api/admin/import", importLogs)
+	admin.POST("/api/admin/drain", drainServer)
+	r.GET("/api/admin/usage", requireAdminNetwork(), requireGlobalAccess(), getAPIUsage)
+	r.GET("/api/admin/blocklist", requireAdminNetwork(), requireGlobalAccess(), getBlocklist)
+	admin.DELETE("/api/admin/blocklist/:ip", unbanIP)
+	
+	// WebSocket status endpoint for debugging
+	r.GET("/api/websocket/status", requireGlobalAccess(), getWebSocketStatus)
+	admin.DELETE("/api/admin/websocket/clients/:id", disconnectWSClient)
+	
+	// Profiling and runtime diagnostics (off unless DEBUG_ENDPOINTS_ENABLED)
+	registerDebugRoutes(r)
+
+	// Prometheus metrics
+	if GetEnvBool("METRICS_ENABLED", true) {
+		r.GET("/metrics", requireGlobalAccess(), serveMetrics)
+	}
+	r.GET("/api/self-stats", requireGlobalAccess(), getSelfStats)
+
+	// Optional features, for the frontend to gate UI on
+	r.GET("/api/capabilities", getCapabilities)
+
+	// Dry-run parsing for debugging log formats
+	r.POST("/api/parse-test", parseTest)
+
+	// Health check with WebSocket status
+	r.GET("/health", healthCheck)
+	r.GET("/health/live", livenessCheck)
+	r.GET("/health/ready", readinessCheck)
+
+	// WebSocket endpoint
+	r.POST("/api/ws/token", requireAPIToken(), issueWSToken)
+	r.GET("/ws", handleWebSocket)
+
+	return r
+}
+
 func cleanup() {
-	log.Println("Starting cleanup...")
+	appLog.Info("Starting cleanup")
 	
 	// Stop health monitor
 	if healthStop != nil {
 		close(healthStop)
 	}
+
+	// Stop broadcast hub
+	if hubStop != nil {
+		close(hubStop)
+	}
+
+	if memoryBudget != nil {
+		memoryBudget.Stop()
+	}
+
+	// Stop alert monitor
+	if alertMonitor != nil {
+		alertMonitor.Stop()
+	}
+	if blocklist != nil {
+		blocklist.Stop()
+	}
+	if traefikAPI != nil {
+		traefikAPI.Stop()
+	}
+	if dockerEnricher != nil {
+		dockerEnricher.Stop()
+	}
+	if reporter != nil {
+		reporter.Stop()
+	}
+
+	// Stop metrics exporters
+	if influxPusher != nil {
+		influxPusher.Stop()
+	}
+	if statsdEmitter != nil {
+		statsdEmitter.Stop()
+	}
+	if otlpMetricsExporter != nil {
+		otlpMetricsExporter.Stop()
+	}
+	if lokiForwarder != nil {
+		lokiForwarder.Stop()
+	}
+	if mqttPublisher != nil {
+		mqttPublisher.Stop()
+	}
 	
 	// Stop OTLP receiver
 	if otlpReceiver != nil {
-		log.Println("Stopping OTLP receiver...")
+		otlpLog.Info("Stopping OTLP receiver")
 		otlpReceiver.Stop()
 	}
+	// After the receiver, so everything it took is sent on
+	if otlpTraceForwarder != nil {
+		otlpTraceForwarder.Stop()
+	}
 	
 	// Stop log parser
 	if logParser != nil {
@@ -210,7 +570,7 @@ func cleanup() {
 	// Close MaxMind database
 	CloseMaxMindDatabase()
 	
-	log.Println("Cleanup completed")
+	appLog.Info("Cleanup completed")
 }
 
 // WebSocket Client Management Functions
@@ -218,14 +578,14 @@ func addWSClient(client *WebSocketClient) {
 	wsClientsMux.Lock()
 	defer wsClientsMux.Unlock()
 	wsClients[client] = true
-	log.Printf("[WebSocket] Total clients connected: %d", len(wsClients))
+	wsLog.Info("Client registered", "clients", len(wsClients))
 }
 
 func removeWSClient(client *WebSocketClient) {
 	wsClientsMux.Lock()
 	defer wsClientsMux.Unlock()
 	delete(wsClients, client)
-	log.Printf("[WebSocket] Client removed. Total clients: %d", len(wsClients))
+	wsLog.Info("Client removed", "clients", len(wsClients))
 }
 
 func getWSClientCount() int {
@@ -262,7 +622,46 @@ func broadcastGeoUpdate() {
 		client.ForceGeoRefresh()
 	}
 	
-	log.Printf("[WebSocket] Broadcasted geo updates to %d connected clients", len(clientList))
+	wsLog.Debug("Broadcast geo updates", "clients", len(clientList))
+}
+
+// Publish an event to every client subscribed to the channel
+func broadcastEvent(channel, eventType string, data interface{}) {
+	wsClientsMux.RLock()
+	clientList := make([]*WebSocketClient, 0, len(wsClients))
+	for client := range wsClients {
+		if client.IsHealthy() {
+			clientList = append(clientList, client)
+		}
+	}
+	wsClientsMux.RUnlock()
+
+	for _, client := range clientList {
+		client.SendEvent(channel, eventType, data)
+	}
+}
+
+// Publish a system event (configuration changes, source changes, ...).
+// These describe global state, so tenant-scoped clients don't receive them.
+func broadcastSystemEvent(event string, details map[string]interface{}) {
+	data := map[string]interface{}{
+		"event":     event,
+		"details":   details,
+		"timestamp": time.Now().Format(time.RFC3339),
+	}
+
+	wsClientsMux.RLock()
+	clientList := make([]*WebSocketClient, 0, len(wsClients))
+	for client := range wsClients {
+		if client.IsHealthy() && client.tenant == "" {
+			clientList = append(clientList, client)
+		}
+	}
+	wsClientsMux.RUnlock()
+
+	for _, client := range clientList {
+		client.SendEvent(WS_CHANNEL_SYSTEM_EVENTS, "systemEvent", data)
+	}
 }
 
 // Start periodic WebSocket health monitoring
@@ -294,12 +693,12 @@ func startWebSocketHealthMonitor() {
 					}
 					wsClientsMux.Unlock()
 					
-					log.Printf("[WebSocket] Health check: removed %d unhealthy clients, %d remaining", 
-						len(unhealthyClients), totalClients-len(unhealthyClients))
+					wsLog.Info("Health check removed unhealthy clients", "removed", len(unhealthyClients),
+						"remaining", totalClients-len(unhealthyClients))
 				}
 				
 				if totalClients > 0 && len(unhealthyClients) == 0 {
-					log.Printf("[WebSocket] Health check: %d clients healthy", totalClients)
+					wsLog.Debug("Health check passed", "clients", totalClients)
 				}
 			case <-healthStop:
 				healthTicker.Stop()
@@ -311,7 +710,7 @@ func startWebSocketHealthMonitor() {
 
 // Enhanced trigger immediate geo processing with better client notification
 func triggerImmediateGeoProcessing() {
-	log.Println("[GeoLocation] Triggering immediate geo processing for existing IPs...")
+	geoLog.Info("Triggering immediate geo processing for existing IPs")
 	
 	// Get current stats to find top IPs that might need re-processing
 	stats := logParser.GetStats()
@@ -319,7 +718,7 @@ func triggerImmediateGeoProcessing() {
 	// Re-process top IPs immediately with the new MaxMind database
 	var ipsToProcess []string
 	for _, ipData := range stats.TopIPs {
-		if ipData.IP != "unknown" && !isPrivateIPCheck(ipData.IP) {
+		if !isPrivateIP(ipData.IP) {
 			ipsToProcess = append(ipsToProcess, ipData.IP)
 		}
 		// Limit to top 20 IPs to avoid overwhelming the system
@@ -339,50 +738,81 @@ func triggerImmediateGeoProcessing() {
 			geoData := GetGeoLocation(ip)
 			if geoData != nil {
 				processedCount++
-				log.Printf("[GeoLocation] Re-processed IP %s: %s, %s", ip, geoData.Country, geoData.City)
+				geoLog.Debug("Re-processed IP", "ip", ip, "country", geoData.Country, "city", geoData.City)
 			}
 		}
 		
 		if processedCount > 0 {
-			log.Printf("[GeoLocation] Completed immediate geo processing for %d IPs", processedCount)
+			geoLog.Info("Completed immediate geo processing", "ips", processedCount)
 			// Broadcast updates to all connected clients
 			broadcastGeoUpdate()
 		}
 	}()
 }
 
-// Helper function to check private IPs (duplicate of the one in logParser but needed here)
-func isPrivateIPCheck(ip string) bool {
-	if ip == "" || ip == "unknown" {
-		return true
-	}
-
-	parts := strings.Split(ip, ".")
-	if len(parts) != 4 {
+// API Route Handlers
+// Set ETag/Last-Modified from the parser's data version and answer 304 when the
+// client's cached copy is still current
+func notModified(c *gin.Context) bool {
+	version, lastModified := logParser.GetVersion()
+
+	hash := fnv.New32a()
+	hash.Write([]byte(c.Request.URL.Path + "?" + c.Request.URL.RawQuery))
+	// Tenants see different data for the same URL
+	hash.Write([]byte("#" + requestTenant(c)))
+	etag := fmt.Sprintf(`W/"%d-%x"`, version, hash.Sum32())
+
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	c.Header("Cache-Control", "no-cache")
+
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == etag || candidate == "*" {
+				c.Status(http.StatusNotModified)
+				return true
+			}
+		}
 		return false
 	}
 
-	return ip == "127.0.0.1" ||
-		ip == "localhost" ||
-		strings.HasPrefix(ip, "::") ||
-		ip == "::1" ||
-		parts[0] == "10" ||
-		(parts[0] == "172" && isInRangeCheck(parts[1], 16, 31)) ||
-		(parts[0] == "192" && parts[1] == "168") ||
-		(parts[0] == "169" && parts[1] == "254")
+	if ims := c.GetHeader("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.Truncate(time.Second).After(t) {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
 }
 
-func isInRangeCheck(s string, min, max int) bool {
-	var n int
-	if _, err := fmt.Sscanf(s, "%d", &n); err != nil {
-		return false
-	}
-	return n >= min && n <= max
+// Remove the validators notModified set, for responses served from a cached
+// snapshot older than the version they name
+func dropValidators(c *gin.Context) {
+	c.Writer.Header().Del("ETag")
+	c.Writer.Header().Del("Last-Modified")
 }
 
-// API Route Handlers
 func getStats(c *gin.Context) {
-	stats := logParser.GetStats()
+	if notModified(c) {
+		return
+	}
+
+	filters := parseFilters(c)
+	if filters.IsEmpty() {
+		stats := logParser.GetStats()
+		if logParser.statsCache.Behind("") {
+			dropValidators(c)
+		}
+		c.JSON(http.StatusOK, stats)
+		return
+	}
+
+	stats, err := logParser.GetFilteredStats(filters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, stats)
 }
 
@@ -406,30 +836,178 @@ func getLogs(c *gin.Context) {
 		}
 	}
 
-	params.Filters.Service = c.Query("service")
-	params.Filters.Status = c.Query("status")
-	params.Filters.Router = c.Query("router")
-	params.Filters.HideUnknown = c.Query("hideUnknown") == "true"
-	params.Filters.HidePrivateIPs = c.Query("hidePrivateIPs") == "true"
-	params.Filters.DataSource = c.Query("dataSource")
+	params.Filters = parseFilters(c)
+	if err := params.Filters.Compile(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Sparse fieldsets: only serialize the requested LogEntry fields
+	var fields []string
+	if f := c.Query("fields"); f != "" {
+		var err error
+		if fields, err = parseLogFields(f); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	result := logParser.GetLogs(params)
-	c.JSON(http.StatusOK, result)
+	if err := writeLogsResult(c, result, fields); err != nil {
+		appLog.Warn("Logs response aborted", "client", c.ClientIP(), "error", err)
+	}
+}
+
+// Parse log filters from query parameters, falling back to the runtime defaults
+func parseFilters(c *gin.Context) Filters {
+	defaults := GetDefaultFilters()
+	filters := Filters{
+		Service:        c.Query("service"),
+		Status:         c.Query("status"),
+		Router:         c.Query("router"),
+		HideUnknown:    queryBool(c, "hideUnknown", defaults.HideUnknown),
+		HidePrivateIPs: queryBool(c, "hidePrivateIPs", defaults.HidePrivateIPs),
+		DataSource:     c.Query("dataSource"),
+		ClientIP:       c.Query("clientIP"),
+		CIDR:           c.Query("cidr"),
+		StatusClass:    c.Query("statusClass"),
+		Query:          c.Query("q"),
+		Tenant:         c.Query("tenant"),
+	}
+	scopeFilters(c, &filters)
+	return filters
+}
+
+// Read a boolean query parameter, using the default when it's absent
+func queryBool(c *gin.Context, key string, defaultValue bool) bool {
+	if value, ok := c.GetQuery(key); ok {
+		return value == "true"
+	}
+	return defaultValue
+}
+
+var facetFields = map[string]bool{
+	"service":      true,
+	"router":       true,
+	"status":       true,
+	"method":       true,
+	"host":         true,
+	"country":      true,
+	"dataSource":   true,
+	"tenant":       true,
+	"pathTemplate": true,
+}
+
+// Facets are the fields above and captured headers
+func validFacetField(field string) bool {
+	return facetFields[field] || isHeaderField(field)
+}
+
+func getFacets(c *gin.Context) {
+	fieldsParam := c.DefaultQuery("fields", "service,router,status,country")
+
+	var fields []string
+	for _, field := range strings.Split(fieldsParam, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !validFacetField(field) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported facet field: %s", field)})
+			return
+		}
+		fields = append(fields, field)
+	}
+
+	filters := parseFilters(c)
+	if err := filters.Compile(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	facets := logParser.GetFacets(fields, filters)
+	c.JSON(http.StatusOK, facets)
+}
+
+func runBatchQueries(c *gin.Context) {
+	var queries []BatchQuery
+	if err := c.ShouldBindJSON(&queries); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(queries) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one query is required"})
+		return
+	}
+	if len(queries) > MAX_BATCH_QUERIES {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("too many queries: %d (max %d)", len(queries), MAX_BATCH_QUERIES),
+		})
+		return
+	}
+	for i := range queries {
+		scopeFilters(c, &queries[i].Filters)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": logParser.RunBatch(queries),
+	})
 }
 
 func getServices(c *gin.Context) {
+	if notModified(c) {
+		return
+	}
+
+	if tenant := requestTenant(c); tenant != "" {
+		c.JSON(http.StatusOK, tenantFacetValues(tenant, "service"))
+		return
+	}
 	services := logParser.GetServices()
 	c.JSON(http.StatusOK, services)
 }
 
 func getRouters(c *gin.Context) {
+	if tenant := requestTenant(c); tenant != "" {
+		c.JSON(http.StatusOK, tenantFacetValues(tenant, "router"))
+		return
+	}
 	routers := logParser.GetRouters()
 	c.JSON(http.StatusOK, routers)
 }
 
+// Get the distinct values of a field among a tenant's entries
+func tenantFacetValues(tenant, field string) []string {
+	values := []string{}
+	for _, facet := range logParser.GetFacets([]string{field}, Filters{Tenant: tenant})[field] {
+		values = append(values, facet.Value)
+	}
+	sort.Strings(values)
+	return values
+}
+
 func getGeoStats(c *gin.Context) {
-	stats := logParser.GetGeoStats()
-	c.JSON(http.StatusOK, stats)
+	if notModified(c) {
+		return
+	}
+
+	filters := parseFilters(c)
+	if filters.IsEmpty() {
+		stats := logParser.GetGeoStats()
+		if logParser.statsCache.GeoBehind() {
+			dropValidators(c)
+		}
+		c.JSON(http.StatusOK, stats)
+		return
+	}
+
+	stats, err := logParser.GetFilteredStats(filters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, geoStatsFromStats(&stats))
 }
 
 func getGeoProcessingStatus(c *gin.Context) {
@@ -454,6 +1032,8 @@ func getMaxMindConfig(c *gin.Context) {
 
 func reloadMaxMindDatabase(c *gin.Context) {
 	if err := ReloadMaxMindDatabase(); err != nil {
+		notifySystemEvent("maxmindLoadFailed", SEVERITY_WARNING, "MaxMind database failed to load",
+			err.Error(), map[string]interface{}{"path": GetMaxMindConfig().DatabasePath})
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   err.Error(),
@@ -463,6 +1043,7 @@ func reloadMaxMindDatabase(c *gin.Context) {
 
 	// Clear geo cache to ensure fresh lookups
 	ClearGeoCache()
+	broadcastSystemEvent("maxmindReloaded", nil)
 
 	// Trigger immediate geo processing for existing IPs
 	triggerImmediateGeoProcessing()
@@ -516,6 +1097,7 @@ func setLogFile(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	broadcastSystemEvent("logSourcesChanged", map[string]interface{}{"files": logParser.WatchedFiles()})
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -537,6 +1119,7 @@ func setLogFiles(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	broadcastSystemEvent("logSourcesChanged", map[string]interface{}{"files": logParser.WatchedFiles()})
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -544,6 +1127,83 @@ func setLogFiles(c *gin.Context) {
 	})
 }
 
+// Forget the entries read from one log file, keeping every other source
+func resetLogSource(c *gin.Context) {
+	var req struct {
+		FilePath string `json:"filePath" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	watched := false
+	for _, file := range logParser.WatchedFiles() {
+		if file == req.FilePath {
+			watched = true
+			break
+		}
+	}
+	if !watched {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Log file is not being watched"})
+		return
+	}
+
+	removed := logParser.ResetSource(req.FilePath)
+	parseErrorTracker.Reset(req.FilePath)
+	broadcastSystemEvent("logSourceReset", map[string]interface{}{"file": req.FilePath, "removed": removed})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"removed": removed,
+	})
+}
+
+func getAdminConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, GetRuntimeConfig())
+}
+
+func patchAdminConfig(c *gin.Context) {
+	var patch RuntimeConfigPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	config, err := ApplyRuntimeConfigPatch(patch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	broadcastSystemEvent("configUpdated", map[string]interface{}{"config": config})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"config":  config,
+	})
+}
+
+func reloadAdminConfig(c *gin.Context) {
+	result, err := ReloadConfig()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"reload":  result,
+		"config":  GetRuntimeConfig(),
+	})
+}
+
 func getWebSocketStatus(c *gin.Context) {
 	status := gin.H{
 		"connectedClients": getWSClientCount(),
@@ -558,6 +1218,35 @@ func getWebSocketStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, status)
 }
 
+// Force-disconnect a WebSocket client by ID
+func disconnectWSClient(c *gin.Context) {
+	clientID := c.Param("id")
+
+	wsClientsMux.RLock()
+	var target *WebSocketClient
+	for client := range wsClients {
+		if client.clientID == clientID {
+			target = client
+			break
+		}
+	}
+	wsClientsMux.RUnlock()
+
+	if target == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "WebSocket client not found"})
+		return
+	}
+
+	removeWSClient(target)
+	target.Disconnect("disconnected by administrator")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Client disconnected",
+		"clientID": clientID,
+		"label":    target.label,
+	})
+}
+
 // OTLP API Route Handlers
 func getOTLPStatus(c *gin.Context) {
 	if otlpReceiver == nil {
@@ -654,6 +1343,7 @@ func healthCheck(c *gin.Context) {
 	health := gin.H{
 		"status": "ok",
 		"timestamp": time.Now().Format(time.RFC3339),
+		"draining": isDraining(),
 		"websocket": gin.H{
 			"connectedClients": getWSClientCount(),
 			"upgraderConfig": gin.H{
@@ -666,8 +1356,9 @@ func healthCheck(c *gin.Context) {
 			"databaseLoaded": config.DatabaseLoaded,
 		},
 		"logParser": gin.H{
-			"totalLogs":       len(logParser.logs),
+			"totalLogs":       logParser.logsSnapshot.Load().Len(),
 			"isProcessingGeo": logParser.IsProcessingGeo(),
+			"memory":          logParser.MemoryUsage(),
 		},
 	}
 	
@@ -694,21 +1385,184 @@ func healthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, health)
 }
 
+// Liveness probe: the process is up and serving HTTP
+func livenessCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "alive",
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// Readiness probe: log sources are attached, their storage is reachable and
+// geolocation is usable
+func readinessCheck(c *gin.Context) {
+	ready := true
+	checks := gin.H{}
+
+	if logFilesEnabled {
+		files := logParser.WatchedFiles()
+		sourcesReady := logParser.SourcesReady()
+		checks["logSources"] = gin.H{
+			"ready": sourcesReady,
+			"files": files,
+		}
+		if !sourcesReady {
+			ready = false
+		}
+
+		unreachable := make([]string, 0)
+		for _, file := range files {
+			if isRemoteSource(file) {
+				continue
+			}
+			if _, err := os.Stat(filepath.Dir(file)); err != nil {
+				unreachable = append(unreachable, file)
+			}
+		}
+		checks["storage"] = gin.H{
+			"ready":       len(unreachable) == 0,
+			"unreachable": unreachable,
+		}
+		if len(unreachable) > 0 {
+			ready = false
+		}
+	}
+
+	if otlpReceiver != nil && otlpReceiver.GetConfig().Enabled {
+		running := otlpReceiver.IsRunning()
+		checks["otlp"] = gin.H{"ready": running}
+		if !running {
+			ready = false
+		}
+	}
+
+	// MaxMind is only required when there is no online fallback
+	config := GetMaxMindConfig()
+	maxmindReady := !config.Enabled || config.DatabaseLoaded || config.FallbackToOnline
+	checks["maxmind"] = gin.H{
+		"ready":          maxmindReady,
+		"enabled":        config.Enabled,
+		"databaseLoaded": config.DatabaseLoaded,
+	}
+	if !maxmindReady {
+		ready = false
+	}
+
+	// Out of rotation while draining, whatever else holds
+	checks["drain"] = gin.H{"ready": !isDraining()}
+
+	status := http.StatusOK
+	statusText := "ready"
+	if isDraining() {
+		status = http.StatusServiceUnavailable
+		statusText = "draining"
+	} else if !ready {
+		status = http.StatusServiceUnavailable
+		statusText = "not ready"
+	}
+
+	c.JSON(status, gin.H{
+		"status":    statusText,
+		"checks":    checks,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// Parse a push interval in seconds from the WebSocket handshake; zero when absent
+func parseWSInterval(c *gin.Context, key string) (time.Duration, error) {
+	value := c.Query(key)
+	if value == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.Atoi(value)
+	interval := time.Duration(seconds) * time.Second
+	if err != nil || interval < MIN_WS_PUSH_INTERVAL || interval > MAX_WS_PUSH_INTERVAL {
+		return 0, fmt.Errorf("%s must be between %d and %d seconds", key,
+			int(MIN_WS_PUSH_INTERVAL/time.Second), int(MAX_WS_PUSH_INTERVAL/time.Second))
+	}
+	return interval, nil
+}
+
 // Enhanced WebSocket handler with better error handling and logging
 func handleWebSocket(c *gin.Context) {
-	log.Printf("[WebSocket] New connection attempt from %s", c.ClientIP())
+	wsLog.Debug("New connection attempt", "client", c.ClientIP())
+
+	if isDraining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is draining"})
+		return
+	}
+
+	tenant := requestTenant(c)
+	if ticket := c.Query("ticket"); ticket != "" {
+		claims, err := ticketSigner.Verify(ticket, TICKET_SCOPE_WS)
+		if err != nil {
+			wsLog.Warn("Rejected ticket", "client", c.ClientIP(), "error", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		tenant = claims.Tenant
+	} else if wsAuth.Enabled() {
+		tokenTenant, ok := wsAuth.ConsumeToken(c.Query("token"))
+		if !ok {
+			wsLog.Warn("Rejected unauthenticated connection", "client", c.ClientIP())
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing or invalid WebSocket token"})
+			return
+		}
+		tenant = tokenTenant
+	}
+
+	// Validate per-client options before upgrading so errors get a proper status
+	statsPeriod, err := parseWSInterval(c, "statsInterval")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	geoStatsPeriod, err := parseWSInterval(c, "geoStatsInterval")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	initialLogs := DEFAULT_WS_INITIAL_LOGS
+	if value := c.Query("initialLogs"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > MAX_WS_INITIAL_LOGS {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("initialLogs must be between 0 and %d", MAX_WS_INITIAL_LOGS)})
+			return
+		}
+		initialLogs = n
+	}
 	
+	label := strings.TrimSpace(c.Query("label"))
+	if len(label) > MAX_WS_LABEL_LENGTH {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("label must be at most %d characters", MAX_WS_LABEL_LENGTH)})
+		return
+	}
+
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Printf("[WebSocket] Upgrade error from %s: %v", c.ClientIP(), err)
+		wsLog.Warn("Upgrade failed", "client", c.ClientIP(), "error", err)
 		return
 	}
 
 	client := NewWebSocketClient(conn, logParser)
+	client.SetPushIntervals(statsPeriod, geoStatsPeriod)
+	client.SetInitialLogCount(initialLogs)
+	client.SetLabel(label)
+	client.SetTenant(tenant)
+
+	// Clients reconnecting after a short drop can ask to replay missed entries
+	if resumeFrom := c.Query("resumeFrom"); resumeFrom != "" {
+		if seq, err := strconv.ParseUint(resumeFrom, 10, 64); err == nil {
+			client.SetResumeFrom(seq)
+		} else {
+			wsLog.Warn("Ignoring invalid resumeFrom", "client", c.ClientIP(), "resumeFrom", resumeFrom)
+		}
+	}
+
 	addWSClient(client)
 	
 	// Start client goroutines
 	client.Start()
 	
-	log.Printf("[WebSocket] Client setup complete for %s", c.ClientIP())
+	wsLog.Debug("Client setup complete", "client", c.ClientIP())
 }
\ No newline at end of file
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+const (
+	DEFAULT_MEMORY_BUDGET_PERCENT  = 50 // Share of GOMEMLIMIT given to the log buffer in auto mode
+	DEFAULT_MEMORY_CHECK_INTERVAL  = 10 * time.Second
+	MEMORY_BUDGET_SAMPLE_SIZE      = 256 // Entries measured to estimate the average entry size
+	MEMORY_BUDGET_MIN_LOGS         = 100
+	MEMORY_PRESSURE_HIGH           = 0.90 // Heap share of GOMEMLIMIT at which the buffer is shrunk
+	MEMORY_PRESSURE_LOW            = 0.75 // Below this the buffer may grow back
+	MEMORY_PRESSURE_EVICT_FRACTION = 0.25 // Share of the buffer dropped per check under pressure
+)
+
+// Sizes the in-memory log buffer by bytes instead of a fixed entry count, and
+// shrinks it ahead of the Go memory limit so small hosts don't get OOM killed
+type MemoryBudget struct {
+	budget   int64 // Bytes the log buffer may use
+	limit    int64 // GOMEMLIMIT, 0 when unset
+	interval time.Duration
+	stop     chan struct{}
+
+	mu            sync.Mutex
+	underPressure bool
+}
+
+// Buffer memory as reported on /health
+type MemoryUsage struct {
+	BufferBytes      int64  `json:"bufferBytes"` // Estimated
+	EntryBytes       int64  `json:"entryBytes"`  // Estimated average per entry
+	Entries          int    `json:"entries"`
+	MaxLogs          int    `json:"maxLogs"`          // Configured limit
+	EffectiveMaxLogs int    `json:"effectiveMaxLogs"` // After the memory budget
+	BudgetBytes      int64  `json:"budgetBytes,omitempty"`
+	MemoryLimit      int64  `json:"memoryLimit,omitempty"`
+	HeapBytes        uint64 `json:"heapBytes"`
+	UnderPressure    bool   `json:"underPressure"`
+}
+
+var memoryBudget *MemoryBudget
+
+var (
+	logEntrySize       = int64(unsafe.Sizeof(LogEntry{}))
+	logEntryStringSize = int64(unsafe.Sizeof(""))
+)
+
+// Get GOMEMLIMIT (or debug.SetMemoryLimit's value), 0 when there is none
+func goMemoryLimit() int64 {
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		return limit
+	}
+	return 0
+}
+
+// Create the budget from LOG_MEMORY_BUDGET_MB; nil when unset. "auto" gives
+// the buffer LOG_MEMORY_BUDGET_PERCENT of GOMEMLIMIT.
+func NewMemoryBudget() (*MemoryBudget, error) {
+	value := strings.TrimSpace(os.Getenv("LOG_MEMORY_BUDGET_MB"))
+	if value == "" {
+		return nil, nil
+	}
+
+	limit := goMemoryLimit()
+	var budget int64
+	if strings.EqualFold(value, "auto") {
+		if limit == 0 {
+			return nil, fmt.Errorf("LOG_MEMORY_BUDGET_MB=auto requires GOMEMLIMIT to be set")
+		}
+		percent := GetEnvInt("LOG_MEMORY_BUDGET_PERCENT", DEFAULT_MEMORY_BUDGET_PERCENT)
+		if percent < 1 || percent > 90 {
+			return nil, fmt.Errorf("LOG_MEMORY_BUDGET_PERCENT must be between 1 and 90")
+		}
+		budget = limit / 100 * int64(percent)
+	} else {
+		mb, err := strconv.Atoi(value)
+		if err != nil || mb < 1 {
+			return nil, fmt.Errorf("invalid LOG_MEMORY_BUDGET_MB %q (use megabytes or \"auto\")", value)
+		}
+		budget = int64(mb) << 20
+	}
+
+	interval := time.Duration(GetEnvInt("LOG_MEMORY_CHECK_INTERVAL_SECONDS", int(DEFAULT_MEMORY_CHECK_INTERVAL/time.Second))) * time.Second
+	if interval < time.Second {
+		return nil, fmt.Errorf("LOG_MEMORY_CHECK_INTERVAL_SECONDS must be at least 1")
+	}
+	return &MemoryBudget{budget: budget, limit: limit, interval: interval, stop: make(chan struct{})}, nil
+}
+
+func (b *MemoryBudget) Start(lp *LogParser) {
+	if b.limit > 0 {
+		appLog.Info("Log buffer memory budget", "budgetMB", b.budget>>20, "limitMB", b.limit>>20)
+	} else {
+		appLog.Info("Log buffer memory budget", "budgetMB", b.budget>>20)
+	}
+	b.check(lp)
+	go func() {
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				b.check(lp)
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (b *MemoryBudget) Stop() {
+	close(b.stop)
+}
+
+func (b *MemoryBudget) UnderPressure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.underPressure
+}
+
+// Recompute how many entries fit the budget and apply it
+func (b *MemoryBudget) check(lp *LogParser) {
+	entryBytes, entries := lp.estimateEntryBytes()
+	capacity := math.MaxInt
+	if entryBytes > 0 {
+		capacity = int(b.budget / entryBytes)
+	}
+
+	pressure := 0.0
+	if b.limit > 0 {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		pressure = float64(ms.HeapAlloc) / float64(b.limit)
+	}
+	current := lp.EffectiveMaxLogs()
+	switch {
+	case pressure >= MEMORY_PRESSURE_HIGH:
+		// Evict proactively rather than wait for the limit; the GC can only
+		// give back what the buffer lets go of
+		capacity = min(capacity, int(float64(entries)*(1-MEMORY_PRESSURE_EVICT_FRACTION)))
+	case pressure >= MEMORY_PRESSURE_LOW:
+		// Don't grow back until the heap has settled
+		capacity = min(capacity, current)
+	}
+	capacity = max(capacity, MEMORY_BUDGET_MIN_LOGS)
+
+	b.mu.Lock()
+	wasUnderPressure := b.underPressure
+	b.underPressure = pressure >= MEMORY_PRESSURE_HIGH
+	b.mu.Unlock()
+
+	if b.underPressure && !wasUnderPressure {
+		appLog.Warn("Heap near the memory limit, shrinking log buffer", "heapPercent", math.Round(pressure*100), "maxLogs", capacity)
+		notifySystemEvent("memoryPressure", SEVERITY_WARNING, "Memory pressure",
+			"Heap usage is close to the memory limit; the oldest logs are being evicted",
+			map[string]interface{}{"heapPercent": math.Round(pressure * 100), "maxLogs": capacity})
+	}
+	lp.SetBudgetMaxLogs(capacity)
+}
+
+// Estimate the average in-memory size of a stored entry from a sample of the
+// newest ones; returns 0 when the buffer is empty
+func (lp *LogParser) estimateEntryBytes() (int64, int) {
+	snapshot := lp.logsSnapshot.Load()
+	entries := snapshot.Len()
+	sample := min(entries, MEMORY_BUDGET_SAMPLE_SIZE)
+	if sample == 0 {
+		return 0, entries
+	}
+	var total int64
+	for i := 0; i < sample; i++ {
+		total += logEntryBytes(snapshot.At(i))
+	}
+	return total / int64(sample), entries
+}
+
+// Approximate the memory held by an entry: the struct plus string contents
+// and the values behind pointer fields
+func logEntryBytes(entry *LogEntry) int64 {
+	size := logEntrySize
+	v := reflect.ValueOf(entry).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			size += int64(field.Len())
+		case reflect.Ptr:
+			if field.IsNil() {
+				continue
+			}
+			if elem := field.Elem(); elem.Kind() == reflect.String {
+				size += logEntryStringSize + int64(elem.Len())
+			} else {
+				size += int64(elem.Type().Size())
+			}
+		case reflect.Map:
+			for iter := field.MapRange(); iter.Next(); {
+				size += 2*logEntryStringSize + int64(iter.Key().Len()+iter.Value().Len())
+			}
+		}
+	}
+	return size
+}
+
+// Report the buffer's estimated memory use and the limits applied to it
+func (lp *LogParser) MemoryUsage() MemoryUsage {
+	entryBytes, entries := lp.estimateEntryBytes()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	usage := MemoryUsage{
+		BufferBytes:      entryBytes * int64(entries),
+		EntryBytes:       entryBytes,
+		Entries:          entries,
+		MaxLogs:          lp.GetMaxLogs(),
+		EffectiveMaxLogs: lp.EffectiveMaxLogs(),
+		MemoryLimit:      goMemoryLimit(),
+		HeapBytes:        ms.HeapAlloc,
+	}
+	if memoryBudget != nil {
+		usage.BudgetBytes = memoryBudget.budget
+		usage.UnderPressure = memoryBudget.UnderPressure()
+	}
+	return usage
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Upper bounds (seconds) of the request latency histogram buckets
+var LATENCY_BUCKETS = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Counters for one Traefik service. LatencyBuckets holds per-bucket (not
+// cumulative) counts with a final overflow bucket for +Inf.
+type ServiceMetrics struct {
+	Requests       map[int]uint64 `json:"requests"` // By status code
+	Bytes          uint64         `json:"bytes"`
+	LatencyBuckets []uint64       `json:"latencyBuckets"`
+	LatencyCount   uint64         `json:"latencyCount"`
+	LatencySum     float64        `json:"latencySum"` // Seconds
+}
+
+// Total requests and 5xx responses across all status codes
+func (s *ServiceMetrics) Totals() (requests, errors uint64) {
+	for status, count := range s.Requests {
+		requests += count
+		if status >= 500 {
+			errors += count
+		}
+	}
+	return requests, errors
+}
+
+// Estimate a latency quantile (0-1) in seconds from the histogram by linear
+// interpolation inside the bucket that contains it
+func (s *ServiceMetrics) LatencyQuantile(q float64) float64 {
+	if s.LatencyCount == 0 {
+		return 0
+	}
+	rank := q * float64(s.LatencyCount)
+	var cumulative uint64
+	lower := 0.0
+	for i, count := range s.LatencyBuckets {
+		upper := LATENCY_BUCKETS[len(LATENCY_BUCKETS)-1]
+		if i < len(LATENCY_BUCKETS) {
+			upper = LATENCY_BUCKETS[i]
+		}
+		if float64(cumulative+count) >= rank && count > 0 {
+			return lower + (upper-lower)*(rank-float64(cumulative))/float64(count)
+		}
+		cumulative += count
+		lower = upper
+	}
+	return lower
+}
+
+// Point-in-time copy of every exported metric
+type MetricsSnapshot struct {
+	Services           map[string]*ServiceMetrics
+	Ingested           map[string]uint64 // By data source
+	IngestDropped      map[string]uint64 // By reason (sampled, throttled, filtered)
+	IngestionLag       float64           // Seconds between an entry's timestamp and its ingestion, last entry
+	GeoQueueDepth      int
+	GeoRetryQueueDepth int
+	WebSocketClients   int
+	LogsInMemory       int
+	Goroutines         int
+	Memory             runtime.MemStats
+	StartTime          time.Time
+}
+
+// Ingestion-time counters. Unlike the dashboard stats they are never reset,
+// so scrapers always see monotonic counters.
+type Metrics struct {
+	mu           sync.Mutex
+	services     map[string]*ServiceMetrics
+	ingested     map[string]uint64
+	ingestionLag float64
+	ingestRate   rateCounter
+	startTime    time.Time
+}
+
+var metrics = &Metrics{
+	services:  make(map[string]*ServiceMetrics),
+	ingested:  make(map[string]uint64),
+	startTime: time.Now(),
+}
+
+// Record a newly ingested log entry
+func (m *Metrics) Observe(entry *LogEntry) {
+	latency := entry.ResponseTime / 1000 // ms -> s
+	bucket := sort.SearchFloat64s(LATENCY_BUCKETS, latency)
+
+	var lag float64
+	if timestamp, err := time.Parse(time.RFC3339, entry.Timestamp); err == nil {
+		lag = time.Since(timestamp).Seconds()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	service, ok := m.services[entry.ServiceName]
+	if !ok {
+		service = &ServiceMetrics{
+			Requests:       make(map[int]uint64),
+			LatencyBuckets: make([]uint64, len(LATENCY_BUCKETS)+1),
+		}
+		m.services[entry.ServiceName] = service
+	}
+	service.Requests[entry.Status]++
+	if entry.Size > 0 {
+		service.Bytes += uint64(entry.Size)
+	}
+	service.LatencyBuckets[bucket]++
+	service.LatencyCount++
+	service.LatencySum += latency
+
+	m.ingested[entry.DataSource]++
+	m.ingestRate.Add(1)
+	if lag > 0 {
+		m.ingestionLag = lag
+	}
+}
+
+// Copy the counters together with current gauges
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	snapshot := MetricsSnapshot{
+		Services:         make(map[string]*ServiceMetrics),
+		Ingested:         make(map[string]uint64),
+		IngestDropped:    ingestControl.Dropped(),
+		WebSocketClients: getWSClientCount(),
+		Goroutines:       runtime.NumGoroutine(),
+		StartTime:        m.startTime,
+	}
+
+	m.mu.Lock()
+	for name, service := range m.services {
+		requests := make(map[int]uint64, len(service.Requests))
+		for status, count := range service.Requests {
+			requests[status] = count
+		}
+		copied := *service
+		copied.Requests = requests
+		copied.LatencyBuckets = append([]uint64(nil), service.LatencyBuckets...)
+		snapshot.Services[name] = &copied
+	}
+	for source, count := range m.ingested {
+		snapshot.Ingested[source] = count
+	}
+	snapshot.IngestionLag = m.ingestionLag
+	m.mu.Unlock()
+
+	if logParser != nil {
+		snapshot.GeoQueueDepth = logParser.geoQueueLength()
+		snapshot.LogsInMemory = logParser.logsSnapshot.Load().Len()
+	}
+	snapshot.GeoRetryQueueDepth = GetGeoCacheStats().RetryQueueLength
+	runtime.ReadMemStats(&snapshot.Memory)
+	return snapshot
+}
+
+// Request, 5xx and latency totals per service, cheaper than a Snapshot
+func (m *Metrics) ServiceTotals() map[string]MetricTotals {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	totals := make(map[string]MetricTotals, len(m.services))
+	for name, service := range m.services {
+		requests, errors := service.Totals()
+		totals[name] = MetricTotals{
+			Requests:      requests,
+			Errors:        errors,
+			DurationCount: service.LatencyCount,
+			DurationSum:   service.LatencySum,
+		}
+	}
+	return totals
+}
+
+// Sorted service names of a snapshot, for stable output
+func (s *MetricsSnapshot) ServiceNames() []string {
+	names := make([]string, 0, len(s.Services))
+	for name := range s.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Escape a Prometheus label value
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// Write the snapshot in the Prometheus text exposition format
+func writePrometheusMetrics(w io.Writer, s MetricsSnapshot) {
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+
+	header := func(name, kind, help string) {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	names := s.ServiceNames()
+
+	header("traefik_dashboard_requests_total", "counter", "Requests seen in Traefik access logs by service and status code.")
+	for _, name := range names {
+		statuses := make([]int, 0, len(s.Services[name].Requests))
+		for status := range s.Services[name].Requests {
+			statuses = append(statuses, status)
+		}
+		sort.Ints(statuses)
+		for _, status := range statuses {
+			fmt.Fprintf(out, "traefik_dashboard_requests_total{service=\"%s\",status=\"%d\"} %d\n",
+				escapeLabel(name), status, s.Services[name].Requests[status])
+		}
+	}
+
+	header("traefik_dashboard_request_duration_seconds", "histogram", "Request latency by service.")
+	for _, name := range names {
+		service := s.Services[name]
+		label := escapeLabel(name)
+		var cumulative uint64
+		for i, bound := range LATENCY_BUCKETS {
+			cumulative += service.LatencyBuckets[i]
+			fmt.Fprintf(out, "traefik_dashboard_request_duration_seconds_bucket{service=\"%s\",le=\"%g\"} %d\n", label, bound, cumulative)
+		}
+		fmt.Fprintf(out, "traefik_dashboard_request_duration_seconds_bucket{service=\"%s\",le=\"+Inf\"} %d\n", label, service.LatencyCount)
+		fmt.Fprintf(out, "traefik_dashboard_request_duration_seconds_sum{service=\"%s\"} %g\n", label, service.LatencySum)
+		fmt.Fprintf(out, "traefik_dashboard_request_duration_seconds_count{service=\"%s\"} %d\n", label, service.LatencyCount)
+	}
+
+	header("traefik_dashboard_response_bytes_total", "counter", "Response bytes sent by service.")
+	for _, name := range names {
+		fmt.Fprintf(out, "traefik_dashboard_response_bytes_total{service=\"%s\"} %d\n", escapeLabel(name), s.Services[name].Bytes)
+	}
+
+	header("traefik_dashboard_entries_ingested_total", "counter", "Log entries ingested by data source.")
+	sources := make([]string, 0, len(s.Ingested))
+	for source := range s.Ingested {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		fmt.Fprintf(out, "traefik_dashboard_entries_ingested_total{source=\"%s\"} %d\n", escapeLabel(source), s.Ingested[source])
+	}
+
+	header("traefik_dashboard_entries_dropped_total", "counter", "Log entries not stored, by reason (sampled, throttled: still counted in stats; filtered: by a drop rule, not counted).")
+	reasons := make([]string, 0, len(s.IngestDropped))
+	for reason := range s.IngestDropped {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(out, "traefik_dashboard_entries_dropped_total{reason=\"%s\"} %d\n", reason, s.IngestDropped[reason])
+	}
+
+	gauges := []struct {
+		name, help string
+		value      float64
+	}{
+		{"traefik_dashboard_ingestion_lag_seconds", "Delay between the latest entry's timestamp and its ingestion.", s.IngestionLag},
+		{"traefik_dashboard_geo_queue_depth", "IPs waiting for geolocation.", float64(s.GeoQueueDepth)},
+		{"traefik_dashboard_geo_retry_queue_depth", "IPs waiting for a geolocation retry.", float64(s.GeoRetryQueueDepth)},
+		{"traefik_dashboard_websocket_clients", "Connected WebSocket clients.", float64(s.WebSocketClients)},
+		{"traefik_dashboard_logs_in_memory", "Log entries held in memory.", float64(s.LogsInMemory)},
+		{"go_goroutines", "Number of goroutines that currently exist.", float64(s.Goroutines)},
+		{"go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(s.Memory.Alloc)},
+		{"go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(s.Memory.HeapInuse)},
+		{"go_memstats_sys_bytes", "Number of bytes obtained from system.", float64(s.Memory.Sys)},
+		{"process_start_time_seconds", "Start time of the process since unix epoch in seconds.", float64(s.StartTime.Unix())},
+	}
+	for _, g := range gauges {
+		header(g.name, "gauge", g.help)
+		fmt.Fprintf(out, "%s %g\n", g.name, g.value)
+	}
+
+	header("go_gc_cycles_total", "counter", "Number of completed GC cycles.")
+	fmt.Fprintf(out, "go_gc_cycles_total %d\n", s.Memory.NumGC)
+}
+
+func serveMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	writePrometheusMetrics(c.Writer, metrics.Snapshot())
+}
+
+// Counters accumulated between two snapshots of the same service; prev may be nil
+func (s *ServiceMetrics) Since(prev *ServiceMetrics) *ServiceMetrics {
+	if prev == nil {
+		return s
+	}
+	delta := &ServiceMetrics{
+		Requests:       make(map[int]uint64, len(s.Requests)),
+		Bytes:          s.Bytes - prev.Bytes,
+		LatencyBuckets: make([]uint64, len(s.LatencyBuckets)),
+		LatencyCount:   s.LatencyCount - prev.LatencyCount,
+		LatencySum:     s.LatencySum - prev.LatencySum,
+	}
+	for status, count := range s.Requests {
+		if diff := count - prev.Requests[status]; diff > 0 {
+			delta.Requests[status] = diff
+		}
+	}
+	for i := range s.LatencyBuckets {
+		delta.LatencyBuckets[i] = s.LatencyBuckets[i] - prev.LatencyBuckets[i]
+	}
+	return delta
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_MQTT_PUBLISH_INTERVAL = 30 * time.Second
+	MQTT_DIAL_TIMEOUT             = 10 * time.Second
+)
+
+// Minimal MQTT 3.1.1 client: connect with a last will, publish at QoS 0 and
+// disconnect. That's all state publishing needs, so no library is pulled in.
+type mqttConn struct {
+	conn net.Conn
+	w    *bufio.Writer
+}
+
+type mqttConnectOptions struct {
+	clientID    string
+	username    string
+	password    string
+	keepAlive   time.Duration
+	willTopic   string
+	willMessage string
+}
+
+func mqttString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+// Encode the fixed header: packet type/flags and the variable-length remaining length
+func mqttPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+func dialMQTT(broker *url.URL, opts mqttConnectOptions) (*mqttConn, error) {
+	dialer := &net.Dialer{Timeout: MQTT_DIAL_TIMEOUT}
+	var conn net.Conn
+	var err error
+	switch broker.Scheme {
+	case "tcp", "mqtt":
+		conn, err = dialer.Dial("tcp", broker.Host)
+	case "ssl", "tls", "mqtts":
+		conn, err = tls.DialWithDialer(dialer, "tcp", broker.Host, &tls.Config{MinVersion: tls.VersionTLS12})
+	default:
+		return nil, fmt.Errorf("unsupported MQTT scheme %q", broker.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var flags byte = 0x02 // Clean session
+	body := mqttString(nil, "MQTT")
+	body = append(body, 4) // Protocol level 3.1.1
+	if opts.willTopic != "" {
+		flags |= 0x04 | 0x20 // Will flag, will retain
+	}
+	if opts.username != "" {
+		flags |= 0x80
+		if opts.password != "" {
+			flags |= 0x40
+		}
+	}
+	body = append(body, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(opts.keepAlive/time.Second))
+	body = mqttString(body, opts.clientID)
+	if opts.willTopic != "" {
+		body = mqttString(body, opts.willTopic)
+		body = mqttString(body, opts.willMessage)
+	}
+	if opts.username != "" {
+		body = mqttString(body, opts.username)
+		if opts.password != "" {
+			body = mqttString(body, opts.password)
+		}
+	}
+
+	conn.SetDeadline(time.Now().Add(MQTT_DIAL_TIMEOUT))
+	if _, err := conn.Write(mqttPacket(0x10, body)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("no CONNACK: %w", err)
+	}
+	if ack[0] != 0x20 || ack[3] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("broker refused connection (code %d)", ack[3])
+	}
+	conn.SetDeadline(time.Time{})
+
+	return &mqttConn{conn: conn, w: bufio.NewWriter(conn)}, nil
+}
+
+func (c *mqttConn) Publish(topic string, payload []byte, retain bool) error {
+	var header byte = 0x30
+	if retain {
+		header |= 0x01
+	}
+	body := mqttString(nil, topic)
+	body = append(body, payload...)
+	c.conn.SetWriteDeadline(time.Now().Add(MQTT_DIAL_TIMEOUT))
+	if _, err := c.w.Write(mqttPacket(header, body)); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+func (c *mqttConn) Close() {
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.conn.Write(mqttPacket(0xE0, nil))
+	c.conn.Close()
+}
+
+// A Home Assistant sensor read from the shared state payload
+type haSensor struct {
+	key   string
+	name  string
+	unit  string
+	icon  string
+	class string // state_class
+}
+
+var haSensors = []haSensor{
+	{key: "rps", name: "Requests per second", unit: "req/s", icon: "mdi:speedometer", class: "measurement"},
+	{key: "error_rate", name: "Error rate", unit: "%", icon: "mdi:alert-circle-outline", class: "measurement"},
+	{key: "avg_response_ms", name: "Average response time", unit: "ms", icon: "mdi:timer-outline", class: "measurement"},
+	{key: "top_service", name: "Top service", icon: "mdi:server-network"},
+	{key: "requests_total", name: "Total requests", icon: "mdi:counter", class: "total_increasing"},
+	{key: "websocket_clients", name: "Dashboard clients", icon: "mdi:monitor-dashboard", class: "measurement"},
+}
+
+// Publishes summarized traffic state to MQTT, with Home Assistant discovery
+// so the sensors appear automatically
+type MQTTPublisher struct {
+	broker          *url.URL
+	opts            mqttConnectOptions
+	topicPrefix     string
+	discoveryPrefix string
+	discovery       bool
+	interval        time.Duration
+	conn            *mqttConn
+	previous        map[string]*ServiceMetrics
+	stop            chan struct{}
+}
+
+var mqttPublisher *MQTTPublisher
+
+// Create the publisher from MQTT_BROKER (tcp://host:1883 or ssl://host:8883); nil when unset
+func NewMQTTPublisher() (*MQTTPublisher, error) {
+	broker := os.Getenv("MQTT_BROKER")
+	if broker == "" {
+		return nil, nil
+	}
+	brokerURL, err := url.Parse(broker)
+	if err != nil || brokerURL.Host == "" {
+		return nil, fmt.Errorf("invalid MQTT_BROKER: %s", broker)
+	}
+
+	interval := time.Duration(GetEnvInt("MQTT_PUBLISH_INTERVAL_SECONDS", int(DEFAULT_MQTT_PUBLISH_INTERVAL/time.Second))) * time.Second
+	if interval < time.Second {
+		return nil, fmt.Errorf("MQTT_PUBLISH_INTERVAL_SECONDS must be at least 1")
+	}
+
+	topicPrefix := strings.TrimRight(GetEnvString("MQTT_TOPIC_PREFIX", "traefik-log-dashboard"), "/")
+	return &MQTTPublisher{
+		broker: brokerURL,
+		opts: mqttConnectOptions{
+			clientID: GetEnvString("MQTT_CLIENT_ID", "traefik-log-dashboard"),
+			username: os.Getenv("MQTT_USERNAME"),
+			password: os.Getenv("MQTT_PASSWORD"),
+			// Publishing every interval keeps the session alive
+			keepAlive:   interval*2 + 10*time.Second,
+			willTopic:   topicPrefix + "/availability",
+			willMessage: "offline",
+		},
+		topicPrefix:     topicPrefix,
+		discoveryPrefix: strings.TrimRight(GetEnvString("MQTT_DISCOVERY_PREFIX", "homeassistant"), "/"),
+		discovery:       GetEnvBool("MQTT_HA_DISCOVERY", true),
+		interval:        interval,
+		stop:            make(chan struct{}),
+	}, nil
+}
+
+func (p *MQTTPublisher) Start() {
+	appLog.Info("Publishing state to MQTT", "broker", p.broker.Host, "interval", p.interval)
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := p.publish(); err != nil {
+					appLog.Warn("MQTT publish failed", "error", err)
+					if p.conn != nil {
+						p.conn.Close()
+						p.conn = nil
+					}
+				}
+			case <-p.stop:
+				if p.conn != nil {
+					p.conn.Publish(p.opts.willTopic, []byte("offline"), true)
+					p.conn.Close()
+				}
+				return
+			}
+		}
+	}()
+}
+
+func (p *MQTTPublisher) Stop() {
+	close(p.stop)
+}
+
+// Connect if needed (announcing availability and discovery) and publish state
+func (p *MQTTPublisher) publish() error {
+	if p.conn == nil {
+		conn, err := dialMQTT(p.broker, p.opts)
+		if err != nil {
+			return err
+		}
+		p.conn = conn
+		if err := conn.Publish(p.opts.willTopic, []byte("online"), true); err != nil {
+			return err
+		}
+		if p.discovery {
+			if err := p.publishDiscovery(); err != nil {
+				return err
+			}
+		}
+	}
+
+	state, err := json.Marshal(p.state())
+	if err != nil {
+		return err
+	}
+	return p.conn.Publish(p.topicPrefix+"/state", state, true)
+}
+
+// Summarize traffic since the previous publish
+func (p *MQTTPublisher) state() map[string]interface{} {
+	snapshot := metrics.Snapshot()
+	defer func() { p.previous = snapshot.Services }()
+
+	var requests, errors, total uint64
+	var latencySum float64
+	var latencyCount uint64
+	topService, topRequests := "", uint64(0)
+	for _, name := range snapshot.ServiceNames() {
+		current := snapshot.Services[name]
+		delta := current.Since(p.previous[name])
+		serviceRequests, serviceErrors := delta.Totals()
+		serviceTotal, _ := current.Totals()
+
+		requests += serviceRequests
+		errors += serviceErrors
+		total += serviceTotal
+		latencySum += delta.LatencySum
+		latencyCount += delta.LatencyCount
+		if serviceRequests > topRequests {
+			topService, topRequests = name, serviceRequests
+		}
+	}
+
+	errorRate, avgResponse := 0.0, 0.0
+	if requests > 0 {
+		errorRate = float64(errors) / float64(requests) * 100
+	}
+	if latencyCount > 0 {
+		avgResponse = latencySum / float64(latencyCount) * 1000
+	}
+	if topService == "" {
+		topService = "none"
+	}
+
+	return map[string]interface{}{
+		"rps":               roundTo(float64(requests)/p.interval.Seconds(), 2),
+		"error_rate":        roundTo(errorRate, 2),
+		"avg_response_ms":   roundTo(avgResponse, 1),
+		"top_service":       topService,
+		"requests_total":    total,
+		"websocket_clients": snapshot.WebSocketClients,
+	}
+}
+
+func (p *MQTTPublisher) publishDiscovery() error {
+	nodeID := sanitizeMQTTID(p.opts.clientID)
+	device := map[string]interface{}{
+		"identifiers":  []string{nodeID},
+		"name":         "Traefik Log Dashboard",
+		"manufacturer": "hhftechnology",
+		"model":        "traefik-log-dashboard",
+	}
+
+	sensors := append([]haSensor(nil), haSensors...)
+	sort.Slice(sensors, func(i, j int) bool { return sensors[i].key < sensors[j].key })
+	for _, sensor := range sensors {
+		config := map[string]interface{}{
+			"name":               sensor.name,
+			"unique_id":          nodeID + "_" + sensor.key,
+			"state_topic":        p.topicPrefix + "/state",
+			"value_template":     fmt.Sprintf("{{ value_json.%s }}", sensor.key),
+			"availability_topic": p.opts.willTopic,
+			"icon":               sensor.icon,
+			"device":             device,
+		}
+		if sensor.unit != "" {
+			config["unit_of_measurement"] = sensor.unit
+		}
+		if sensor.class != "" {
+			config["state_class"] = sensor.class
+		}
+		payload, err := json.Marshal(config)
+		if err != nil {
+			return err
+		}
+		topic := fmt.Sprintf("%s/sensor/%s/%s/config", p.discoveryPrefix, nodeID, sensor.key)
+		if err := p.conn.Publish(topic, payload, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Home Assistant node IDs may only contain [a-zA-Z0-9_-]
+func sanitizeMQTTID(id string) string {
+	var sb strings.Builder
+	for _, r := range id {
+		if r == '_' || r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	if sb.Len() == 0 {
+		return "traefik_log_dashboard"
+	}
+	return sb.String()
+}
+
+func roundTo(v float64, decimals int) float64 {
+	scale := 1.0
+	for i := 0; i < decimals; i++ {
+		scale *= 10
+	}
+	return float64(int64(v*scale+0.5)) / scale
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+type aliasRule struct {
+	pattern string
+	alias   string
+}
+
+// Display names for one kind of identifier: exact names first, then
+// wildcard patterns in the order given
+type aliasTable struct {
+	exact    map[string]string
+	patterns []aliasRule
+}
+
+// Friendly names for generated service and router identifiers
+// (whoami-docker-abc123@docker becomes whoami), from SERVICE_ALIASES and
+// ROUTER_ALIASES. They are applied once an entry passes the drop rules, so
+// stats, filters, metrics and exports all see the display name; the
+// original is kept in ServiceRaw/RouterRaw and filters match either.
+type NameAliases struct {
+	services aliasTable
+	routers  aliasTable
+}
+
+// The aliases in use; nil when none are configured
+var nameAliases atomic.Pointer[NameAliases]
+
+func NewNameAliases() (*NameAliases, error) {
+	services, err := parseAliasTable("SERVICE_ALIASES")
+	if err != nil {
+		return nil, err
+	}
+	routers, err := parseAliasTable("ROUTER_ALIASES")
+	if err != nil {
+		return nil, err
+	}
+	if services.empty() && routers.empty() {
+		return nil, nil
+	}
+	return &NameAliases{services: services, routers: routers}, nil
+}
+
+func SetNameAliases(a *NameAliases) {
+	nameAliases.Store(a)
+}
+
+// Parse "name=alias" pairs separated by commas; names may use "*" and "?"
+// wildcards
+func parseAliasTable(name string) (aliasTable, error) {
+	table := aliasTable{exact: make(map[string]string)}
+	for _, pair := range strings.Split(os.Getenv(name), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, alias, ok := strings.Cut(pair, "=")
+		key, alias = strings.TrimSpace(key), strings.TrimSpace(alias)
+		if !ok || key == "" || alias == "" {
+			return table, fmt.Errorf("invalid %s entry %q, expected name=alias", name, pair)
+		}
+		if strings.ContainsAny(key, "*?") {
+			table.patterns = append(table.patterns, aliasRule{pattern: key, alias: alias})
+		} else {
+			table.exact[key] = alias
+		}
+	}
+	return table, nil
+}
+
+func (t aliasTable) empty() bool {
+	return len(t.exact) == 0 && len(t.patterns) == 0
+}
+
+func (t aliasTable) lookup(name string) (string, bool) {
+	if alias, ok := t.exact[name]; ok {
+		return alias, true
+	}
+	for _, rule := range t.patterns {
+		if matchPattern(rule.pattern, name) {
+			return rule.alias, true
+		}
+	}
+	return "", false
+}
+
+// Replace an entry's service and router names with their display names
+func (a *NameAliases) apply(entry *LogEntry) {
+	if entry.ServiceRaw == "" {
+		if alias, ok := a.services.lookup(entry.ServiceName); ok {
+			entry.ServiceRaw, entry.ServiceName = entry.ServiceName, alias
+		}
+	}
+	if entry.RouterRaw == "" {
+		if alias, ok := a.routers.lookup(entry.RouterName); ok {
+			entry.RouterRaw, entry.RouterName = entry.RouterName, alias
+		}
+	}
+}
+
+// Check a service or router filter against the display name and the
+// original one
+func matchesName(want, name, raw string) bool {
+	return want == name || (raw != "" && want == raw)
+}
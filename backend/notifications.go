@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	NOTIFY_QUEUE_SIZE      = 100
+	NOTIFY_SEND_TIMEOUT    = 10 * time.Second
+	DEFAULT_NOTIFY_RETRIES = 3
+	DEFAULT_NOTIFY_BACKOFF = 2 * time.Second
+
+	NOTIFY_KIND_ALERT  = "alert"
+	NOTIFY_KIND_SYSTEM = "system"
+	NOTIFY_KIND_REPORT = "report"
+
+	SEVERITY_INFO     = "info"
+	SEVERITY_WARNING  = "warning"
+	SEVERITY_CRITICAL = "critical"
+)
+
+// An alert or system event delivered to the configured notifiers
+type Notification struct {
+	Kind      string                 `json:"kind"`  // "alert", "system" or "report"
+	Event     string                 `json:"event"` // e.g. "errorRateHigh", "logSourceLost"
+	Severity  string                 `json:"severity"`
+	Title     string                 `json:"title"`
+	Message   string                 `json:"message"`
+	Service   string                 `json:"service,omitempty"`
+	Tenant    string                 `json:"tenant,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Timestamp string                 `json:"timestamp"`
+}
+
+// A delivery channel for notifications
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, n Notification) error
+}
+
+// A notifier with its own queue, so a slow or failing endpoint never delays
+// the others
+type notifierWorker struct {
+	notifier Notifier
+	events   map[string]bool // nil means every event
+	queue    chan Notification
+	pending  sync.WaitGroup // Queued or being delivered
+}
+
+var (
+	notifierWorkers []*notifierWorker
+	notifyRetries   = DEFAULT_NOTIFY_RETRIES
+	notifyBackoff   = DEFAULT_NOTIFY_BACKOFF
+)
+
+// Parse a comma-separated event filter such as WEBHOOK_EVENTS; nil when unset
+func parseEventFilter(envKey string) map[string]bool {
+	value := os.Getenv(envKey)
+	if value == "" {
+		return nil
+	}
+	events := make(map[string]bool)
+	for _, event := range strings.Split(value, ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			events[event] = true
+		}
+	}
+	return events
+}
+
+// Register a notifier and start its delivery goroutine. Events may be
+// filtered by name or by kind ("alert", "system", "report").
+func registerNotifier(notifier Notifier, events map[string]bool) {
+	worker := &notifierWorker{
+		notifier: notifier,
+		events:   events,
+		queue:    make(chan Notification, NOTIFY_QUEUE_SIZE),
+	}
+	notifierWorkers = append(notifierWorkers, worker)
+	go worker.run()
+	appLog.Info("Notifications enabled", "notifier", notifier.Name())
+}
+
+// A notifier set up in the environment and the events it receives
+type configuredNotifier struct {
+	notifier Notifier
+	events   map[string]bool
+}
+
+// Configure retry behaviour and every notifier set up in the environment
+func initNotifications() error {
+	notifyRetries = GetEnvInt("NOTIFY_MAX_RETRIES", DEFAULT_NOTIFY_RETRIES)
+	notifyBackoff = time.Duration(GetEnvInt("NOTIFY_RETRY_BACKOFF_SECONDS", int(DEFAULT_NOTIFY_BACKOFF/time.Second))) * time.Second
+
+	notifiers, err := newNotifiers()
+	if err != nil {
+		return err
+	}
+	for _, configured := range notifiers {
+		registerNotifier(configured.notifier, configured.events)
+	}
+	return nil
+}
+
+// Create the notifiers set up in the environment without starting them
+func newNotifiers() ([]configuredNotifier, error) {
+	var notifiers []configuredNotifier
+
+	webhooks, err := NewWebhookNotifiers()
+	if err != nil {
+		return nil, err
+	}
+	for _, webhook := range webhooks {
+		notifiers = append(notifiers, configuredNotifier{webhook, parseEventFilter("WEBHOOK_EVENTS")})
+	}
+
+	discord, err := NewDiscordNotifier()
+	if err != nil {
+		return nil, err
+	}
+	if discord != nil {
+		notifiers = append(notifiers, configuredNotifier{discord, parseEventFilter("DISCORD_EVENTS")})
+	}
+
+	telegram, err := NewTelegramNotifier()
+	if err != nil {
+		return nil, err
+	}
+	if telegram != nil {
+		notifiers = append(notifiers, configuredNotifier{telegram, parseEventFilter("TELEGRAM_EVENTS")})
+	}
+
+	ntfy, err := NewNtfyNotifier()
+	if err != nil {
+		return nil, err
+	}
+	if ntfy != nil {
+		notifiers = append(notifiers, configuredNotifier{ntfy, parseEventFilter("NTFY_EVENTS")})
+	}
+
+	gotify, err := NewGotifyNotifier()
+	if err != nil {
+		return nil, err
+	}
+	if gotify != nil {
+		notifiers = append(notifiers, configuredNotifier{gotify, parseEventFilter("GOTIFY_EVENTS")})
+	}
+	return notifiers, nil
+}
+
+func (w *notifierWorker) accepts(n Notification) bool {
+	return w.events == nil || w.events[n.Event] || w.events[n.Kind]
+}
+
+func (w *notifierWorker) run() {
+	for n := range w.queue {
+		w.deliver(n)
+		w.pending.Done()
+	}
+}
+
+// Send with exponential backoff between attempts
+func (w *notifierWorker) deliver(n Notification) {
+	backoff := notifyBackoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), NOTIFY_SEND_TIMEOUT)
+		err := w.notifier.Send(ctx, n)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt >= notifyRetries {
+			appLog.Error("Giving up on notification", "notifier", w.notifier.Name(), "event", n.Event, "attempts", attempt+1, "error", err)
+			return
+		}
+		appLog.Warn("Notification failed, retrying", "notifier", w.notifier.Name(), "event", n.Event, "attempt", attempt+1, "retryIn", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Queue a notification for every interested notifier without blocking;
+// notifications are dropped when a notifier's queue is full
+func notify(n Notification) {
+	if n.Timestamp == "" {
+		n.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	}
+	for _, worker := range notifierWorkers {
+		if !worker.accepts(n) {
+			continue
+		}
+		worker.pending.Add(1)
+		select {
+		case worker.queue <- n:
+		default:
+			worker.pending.Done()
+			appLog.Warn("Notification queue full, dropping event", "notifier", worker.notifier.Name(), "event", n.Event)
+		}
+	}
+}
+
+// Wait for queued notifications to be delivered, retries included; false
+// if some were still pending after timeout
+func flushNotifications(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		for _, worker := range notifierWorkers {
+			worker.pending.Wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Notify about a system event (log source lost, MaxMind load failure, ...)
+func notifySystemEvent(event, severity, title, message string, details map[string]interface{}) {
+	notify(Notification{
+		Kind:     NOTIFY_KIND_SYSTEM,
+		Event:    event,
+		Severity: severity,
+		Title:    title,
+		Message:  message,
+		Details:  details,
+	})
+}
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	OIDC_SESSION_COOKIE   = "tld_session"
+	OIDC_STATE_TTL        = 10 * time.Minute
+	OIDC_CLOCK_SKEW       = time.Minute
+	OIDC_JWKS_MIN_REFRESH = time.Minute
+	OIDC_HTTP_TIMEOUT     = 10 * time.Second
+	DEFAULT_OIDC_SCOPES   = "openid profile email"
+	DEFAULT_OIDC_GROUPS   = "groups"
+)
+
+type OIDCConfig struct {
+	Issuer        string   `json:"issuer"`
+	ClientID      string   `json:"clientId"`
+	ClientSecret  string   `json:"-"`
+	RedirectURL   string   `json:"redirectUrl"`
+	Scopes        []string `json:"scopes"`
+	GroupsClaim   string   `json:"groupsClaim"`
+	AllowedGroups []string `json:"allowedGroups"`
+}
+
+// Subset of the provider's discovery document we rely on
+type oidcProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+}
+
+// Validated identity from an ID or access token
+type OIDCIdentity struct {
+	Subject   string
+	Name      string
+	Groups    []string
+	ExpiresAt time.Time
+}
+
+// OIDC authentication: validates bearer/session JWTs against the issuer's
+// JWKS and runs the authorization code flow for browser logins
+type OIDCAuth struct {
+	config   OIDCConfig
+	provider oidcProviderMetadata
+	client   *http.Client
+
+	keysMu      sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+
+	// Pending login states for CSRF protection
+	states *cache.Cache
+}
+
+var oidcAuth *OIDCAuth
+
+func GetOIDCConfig() OIDCConfig {
+	config := OIDCConfig{
+		Issuer:       strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		GroupsClaim:  os.Getenv("OIDC_GROUPS_CLAIM"),
+	}
+
+	scopes := os.Getenv("OIDC_SCOPES")
+	if scopes == "" {
+		scopes = DEFAULT_OIDC_SCOPES
+	}
+	config.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = DEFAULT_OIDC_GROUPS
+	}
+	for _, group := range strings.Split(os.Getenv("OIDC_ALLOWED_GROUPS"), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			config.AllowedGroups = append(config.AllowedGroups, group)
+		}
+	}
+	return config
+}
+
+// Create the OIDC authenticator and run provider discovery. Returns nil
+// when OIDC_ISSUER is not set.
+func NewOIDCAuth(config OIDCConfig) (*OIDCAuth, error) {
+	if config.Issuer == "" {
+		return nil, nil
+	}
+	if config.ClientID == "" {
+		return nil, fmt.Errorf("OIDC_CLIENT_ID is required when OIDC_ISSUER is set")
+	}
+
+	auth := &OIDCAuth{
+		config: config,
+		client: &http.Client{Timeout: OIDC_HTTP_TIMEOUT},
+		keys:   make(map[string]crypto.PublicKey),
+		states: cache.New(OIDC_STATE_TTL, time.Minute),
+	}
+
+	if err := auth.getJSON(config.Issuer+"/.well-known/openid-configuration", &auth.provider); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	if auth.provider.Issuer != config.Issuer {
+		return nil, fmt.Errorf("OIDC issuer mismatch: configured %s, provider reports %s", config.Issuer, auth.provider.Issuer)
+	}
+	if err := auth.refreshKeys(); err != nil {
+		return nil, err
+	}
+
+	appLog.Info("OIDC authentication enabled", "issuer", config.Issuer, "client", config.ClientID,
+		"allowedGroups", config.AllowedGroups)
+	return auth, nil
+}
+
+func (a *OIDCAuth) Enabled() bool {
+	return a != nil
+}
+
+func (a *OIDCAuth) getJSON(endpoint string, target interface{}) error {
+	resp, err := a.client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// Fetch the provider's signing keys
+func (a *OIDCAuth) refreshKeys() error {
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := a.getJSON(a.provider.JWKSURI, &jwks); err != nil {
+		return fmt.Errorf("failed to fetch OIDC signing keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, key := range jwks.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		switch key.Kty {
+		case "RSA":
+			n, errN := decodeBase64URLInt(key.N)
+			e, errE := decodeBase64URLInt(key.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[key.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch key.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := decodeBase64URLInt(key.X)
+			y, errY := decodeBase64URLInt(key.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[key.Kid] = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("OIDC provider published no usable signing keys")
+	}
+
+	a.keysMu.Lock()
+	a.keys = keys
+	a.keysFetched = time.Now()
+	a.keysMu.Unlock()
+	return nil
+}
+
+// Look up a signing key, refetching the JWKS (at most once a minute) when
+// the key ID is unknown so provider key rotation is picked up
+func (a *OIDCAuth) signingKey(kid string) (crypto.PublicKey, error) {
+	a.keysMu.RLock()
+	key, ok := a.keys[kid]
+	fetched := a.keysFetched
+	a.keysMu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if time.Since(fetched) < OIDC_JWKS_MIN_REFRESH {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := a.refreshKeys(); err != nil {
+		return nil, err
+	}
+
+	a.keysMu.RLock()
+	defer a.keysMu.RUnlock()
+	if key, ok := a.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// Validate a JWT's signature and claims and return the identity it carries
+func (a *OIDCAuth) ValidateToken(token string) (*OIDCIdentity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+
+	key, err := a.signingKey(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	if err := verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	return a.validateClaims(claims)
+}
+
+func (a *OIDCAuth) validateClaims(claims map[string]interface{}) (*OIDCIdentity, error) {
+	if iss, _ := claims["iss"].(string); iss != a.config.Issuer {
+		return nil, errors.New("token issuer mismatch")
+	}
+	if !audienceContains(claims["aud"], a.config.ClientID) {
+		return nil, errors.New("token audience mismatch")
+	}
+
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("token has no expiry")
+	}
+	expiresAt := time.Unix(int64(exp), 0)
+	if now.After(expiresAt.Add(OIDC_CLOCK_SKEW)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(OIDC_CLOCK_SKEW).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token not yet valid")
+	}
+
+	identity := &OIDCIdentity{ExpiresAt: expiresAt}
+	identity.Subject, _ = claims["sub"].(string)
+	for _, claim := range []string{"preferred_username", "email", "name", "sub"} {
+		if name, ok := claims[claim].(string); ok && name != "" {
+			identity.Name = name
+			break
+		}
+	}
+	switch groups := claims[a.config.GroupsClaim].(type) {
+	case []interface{}:
+		for _, group := range groups {
+			if g, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, g)
+			}
+		}
+	case string:
+		identity.Groups = strings.Fields(strings.ReplaceAll(groups, ",", " "))
+	}
+
+	if len(a.config.AllowedGroups) > 0 && !groupsIntersect(identity.Groups, a.config.AllowedGroups) {
+		return nil, fmt.Errorf("user %s is not in an allowed group", identity.Name)
+	}
+	return identity, nil
+}
+
+// Extract a token from the Authorization header, session cookie or, for
+// WebSocket upgrades where browsers can't set headers, ?access_token=
+func (a *OIDCAuth) requestToken(r *http.Request) string {
+	if token := bearerToken(r); token != "" {
+		return token
+	}
+	if cookie, err := r.Cookie(OIDC_SESSION_COOKIE); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	return ""
+}
+
+// Authenticate a request, returning the identity when it carries a valid token
+func (a *OIDCAuth) authorizeRequest(r *http.Request) (*OIDCIdentity, bool) {
+	token := a.requestToken(r)
+	if token == "" {
+		return nil, false
+	}
+	identity, err := a.ValidateToken(token)
+	if err != nil {
+		appLog.Warn("Rejected OIDC token", "client", r.RemoteAddr, "error", err)
+		return nil, false
+	}
+	return identity, true
+}
+
+// Start the authorization code flow
+func (a *OIDCAuth) handleLogin(c *gin.Context) {
+	if a.config.RedirectURL == "" || a.config.ClientSecret == "" {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "OIDC browser login requires OIDC_REDIRECT_URL and OIDC_CLIENT_SECRET"})
+		return
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	state := hex.EncodeToString(buf)
+
+	// Only allow relative return paths to avoid open redirects
+	returnTo := c.Query("returnTo")
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") {
+		returnTo = "/"
+	}
+	a.states.Set(state, returnTo, cache.DefaultExpiration)
+
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {a.config.ClientID},
+		"redirect_uri":  {a.config.RedirectURL},
+		"scope":         {strings.Join(a.config.Scopes, " ")},
+		"state":         {state},
+	}
+	c.Redirect(http.StatusFound, a.provider.AuthorizationEndpoint+"?"+query.Encode())
+}
+
+// Finish the authorization code flow and set the session cookie
+func (a *OIDCAuth) handleCallback(c *gin.Context) {
+	if errParam := c.Query("error"); errParam != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errParam, "description": c.Query("error_description")})
+		return
+	}
+
+	state := c.Query("state")
+	returnTo, found := a.states.Get(state)
+	if state == "" || !found {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		return
+	}
+	a.states.Delete(state)
+
+	idToken, err := a.exchangeCode(c.Request.Context(), c.Query("code"))
+	if err != nil {
+		appLog.Warn("OIDC code exchange failed", "error", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+
+	identity, err := a.ValidateToken(idToken)
+	if err != nil {
+		appLog.Warn("Rejected OIDC ID token", "error", err)
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	maxAge := int(time.Until(identity.ExpiresAt).Seconds())
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(OIDC_SESSION_COOKIE, idToken, maxAge, "/", "", requestIsSecure(c.Request), true)
+	appLog.Info("User logged in", "user", identity.Name)
+
+	c.Redirect(http.StatusFound, returnTo.(string))
+}
+
+func (a *OIDCAuth) exchangeCode(ctx context.Context, code string) (string, error) {
+	if code == "" {
+		return "", errors.New("missing authorization code")
+	}
+
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {a.config.RedirectURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(a.config.ClientID), url.QueryEscape(a.config.ClientSecret))
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return "", err
+	}
+	if tokens.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+	return tokens.IDToken, nil
+}
+
+func (a *OIDCAuth) handleLogout(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(OIDC_SESSION_COOKIE, "", -1, "/", "", requestIsSecure(c.Request), true)
+
+	response := gin.H{"message": "Logged out"}
+	if a.provider.EndSessionEndpoint != "" {
+		response["endSessionUrl"] = a.provider.EndSessionEndpoint
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Check whether the request reached us (or the proxy in front) over TLS
+func requestIsSecure(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+func decodeJWTSegment(segment string, target interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+func decodeBase64URLInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// Verify a JWS signature for the RSA and ECDSA algorithms OIDC providers use
+func verifyJWTSignature(alg string, key crypto.PublicKey, signingInput string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported token algorithm %q", alg)
+	}
+
+	hasher := hash.New()
+	hasher.Write([]byte(signingInput))
+	digest := hasher.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return errors.New("token algorithm does not match key type")
+		}
+		if err := rsa.VerifyPKCS1v15(k, hash, digest, signature); err != nil {
+			return errors.New("invalid token signature")
+		}
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return errors.New("token algorithm does not match key type")
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid token signature")
+		}
+	default:
+		return errors.New("unsupported signing key type")
+	}
+	return nil
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func groupsIntersect(groups, allowed []string) bool {
+	for _, group := range groups {
+		for _, a := range allowed {
+			if group == a {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// An OIDC provider serving discovery and a JWKS with locally generated keys
+type testOIDCProvider struct {
+	server     *httptest.Server
+	rsaKey     *rsa.PrivateKey
+	ecKey      *ecdsa.PrivateKey
+	mu         sync.Mutex
+	jwks       []map[string]string
+	jwksServed atomic.Int32
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{"kid": kid, "kty": "RSA", "use": "sig",
+		"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{"kid": kid, "kty": "EC", "crv": "P-256",
+		"x": b64(key.X.FillBytes(make([]byte, 32))), "y": b64(key.Y.FillBytes(make([]byte, 32)))}
+}
+
+func newTestOIDCProvider(t *testing.T) *testOIDCProvider {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testOIDCProvider{rsaKey: rsaKey, ecKey: ecKey}
+	p.jwks = []map[string]string{rsaJWK("rsa", &rsaKey.PublicKey), ecJWK("ec", &ecKey.PublicKey)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcProviderMetadata{Issuer: p.server.URL, JWKSURI: p.server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.jwksServed.Add(1)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": p.jwks})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *testOIDCProvider) auth(t *testing.T) *OIDCAuth {
+	t.Helper()
+	auth, err := NewOIDCAuth(OIDCConfig{Issuer: p.server.URL, ClientID: "dashboard", GroupsClaim: DEFAULT_OIDC_GROUPS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth
+}
+
+// Sign claims as a JWT with the given algorithm, using the RSA key for RS*
+// and the EC key for ES*
+func (p *testOIDCProvider) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := b64(header) + "." + b64(payload)
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(signingInput))
+
+	var signature []byte
+	var err error
+	if strings.HasPrefix(alg, "ES") {
+		var r, s *big.Int
+		if r, s, err = ecdsa.Sign(rand.Reader, p.ecKey, digest.Sum(nil)); err == nil {
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	} else {
+		signature, err = rsa.SignPKCS1v15(rand.Reader, p.rsaKey, crypto.SHA256, digest.Sum(nil))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + b64(signature)
+}
+
+func TestOIDCValidateToken(t *testing.T) {
+	p := newTestOIDCProvider(t)
+	auth := p.auth(t)
+	now := time.Now()
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":                p.server.URL,
+			"aud":                "dashboard",
+			"sub":                "user-1",
+			"preferred_username": "alice",
+			"exp":                now.Add(time.Hour).Unix(),
+			"groups":             []string{"users"},
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+	skew := int64(OIDC_CLOCK_SKEW / time.Second)
+
+	for _, tt := range []struct {
+		name          string
+		alg, kid      string
+		claims        map[string]interface{}
+		allowedGroups []string
+		tamper        bool
+		err           string
+	}{
+		{name: "RS256", alg: "RS256", kid: "rsa", claims: claims(nil)},
+		{name: "ES256", alg: "ES256", kid: "ec", claims: claims(nil)},
+		{name: "RS alg with EC key", alg: "RS256", kid: "ec", claims: claims(nil), err: "does not match key type"},
+		{name: "ES alg with RSA key", alg: "ES256", kid: "rsa", claims: claims(nil), err: "does not match key type"},
+		{name: "alg none", alg: "none", kid: "rsa", claims: claims(nil), err: "unsupported token algorithm"},
+		{name: "bad signature", alg: "RS256", kid: "rsa", claims: claims(nil), tamper: true, err: "invalid token signature"},
+		{name: "bad EC signature", alg: "ES256", kid: "ec", claims: claims(nil), tamper: true, err: "invalid token signature"},
+		{name: "expired", alg: "RS256", kid: "rsa", claims: claims(map[string]interface{}{"exp": now.Unix() - 2*skew}), err: "token expired"},
+		{name: "expired within skew", alg: "RS256", kid: "rsa", claims: claims(map[string]interface{}{"exp": now.Unix() - skew/2})},
+		{name: "no expiry", alg: "RS256", kid: "rsa", claims: claims(map[string]interface{}{"exp": nil}), err: "no expiry"},
+		{name: "not yet valid", alg: "RS256", kid: "rsa", claims: claims(map[string]interface{}{"nbf": now.Unix() + 2*skew}), err: "not yet valid"},
+		{name: "nbf within skew", alg: "RS256", kid: "rsa", claims: claims(map[string]interface{}{"nbf": now.Unix() + skew/2})},
+		{name: "aud array", alg: "RS256", kid: "rsa", claims: claims(map[string]interface{}{"aud": []string{"other", "dashboard"}})},
+		{name: "aud array without client", alg: "RS256", kid: "rsa", claims: claims(map[string]interface{}{"aud": []string{"other"}}), err: "audience mismatch"},
+		{name: "aud string mismatch", alg: "RS256", kid: "rsa", claims: claims(map[string]interface{}{"aud": "other"}), err: "audience mismatch"},
+		{name: "issuer mismatch", alg: "RS256", kid: "rsa", claims: claims(map[string]interface{}{"iss": "https://evil.example.com"}), err: "issuer mismatch"},
+		{name: "allowed group", alg: "RS256", kid: "rsa", claims: claims(map[string]interface{}{"groups": []string{"users", "admins"}}), allowedGroups: []string{"admins"}},
+		{name: "allowed group in string claim", alg: "RS256", kid: "rsa", claims: claims(map[string]interface{}{"groups": "users, admins"}), allowedGroups: []string{"admins"}},
+		{name: "not in allowed group", alg: "RS256", kid: "rsa", claims: claims(nil), allowedGroups: []string{"admins"}, err: "not in an allowed group"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			auth.config.AllowedGroups = tt.allowedGroups
+			token := p.sign(t, tt.alg, tt.kid, tt.claims)
+			if tt.tamper {
+				// Flip a bit in the middle of the signature
+				parts := strings.Split(token, ".")
+				signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+				signature[len(signature)/2] ^= 1
+				token = parts[0] + "." + parts[1] + "." + b64(signature)
+			}
+
+			identity, err := auth.ValidateToken(token)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("rejected: %v", err)
+				}
+				if identity.Subject != "user-1" || identity.Name != "alice" {
+					t.Errorf("identity = %+v", identity)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestOIDCUnknownKeyRefreshesJWKS(t *testing.T) {
+	p := newTestOIDCProvider(t)
+	auth := p.auth(t)
+	rotated, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	p.jwks = append(p.jwks, rsaJWK("rotated", &rotated.PublicKey))
+	p.mu.Unlock()
+	p.rsaKey = rotated
+	token := p.sign(t, "RS256", "rotated", map[string]interface{}{
+		"iss": p.server.URL, "aud": "dashboard", "sub": "user-1", "exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	// Keys were just fetched, so an unknown key ID doesn't refetch them yet
+	if _, err := auth.ValidateToken(token); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("error = %v, want unknown signing key", err)
+	}
+	if served := p.jwksServed.Load(); served != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", served)
+	}
+
+	auth.keysMu.Lock()
+	auth.keysFetched = time.Now().Add(-OIDC_JWKS_MIN_REFRESH)
+	auth.keysMu.Unlock()
+	if _, err := auth.ValidateToken(token); err != nil {
+		t.Fatalf("rejected after the key rotation: %v", err)
+	}
+	if served := p.jwksServed.Load(); served != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", served)
+	}
+
+	// Unknown after the refresh too
+	auth.keysMu.Lock()
+	auth.keysFetched = time.Now().Add(-OIDC_JWKS_MIN_REFRESH)
+	auth.keysMu.Unlock()
+	if _, err := auth.ValidateToken(p.sign(t, "RS256", "missing", nil)); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Errorf("error = %v, want unknown signing key", err)
+	}
+}
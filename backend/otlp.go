@@ -2,20 +2,25 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
-	"compress/gzip"
 
 	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
 	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // Registers the gzip compressor exporters default to
 	"google.golang.org/grpc/reflection"
 )
 
@@ -28,11 +33,20 @@ type OTLPReceiver struct {
 	enabled        bool
 	stopChan       chan struct{}
 	isRunning      bool
+	tls            TLSSettings
+	tlsConfig      *tls.Config
+	auth           OTLPAuthSettings
 	
 	// Statistics
 	tracesReceived    int64
 	spansProcessed    int64
 	errorCount       int64
+
+	logsReceived        atomic.Int64 // Export requests on /v1/logs or LogsService
+	logRecordsProcessed atomic.Int64
+	logRecordsRejected  atomic.Int64 // Not HTTP access logs
+	metricsReceived     atomic.Int64 // Export requests on /v1/metrics or MetricsService
+	authFailures        atomic.Int64 // Requests without a valid token
 }
 
 // processOTLPJSON processes OTLP trace data in JSON format.
@@ -41,15 +55,15 @@ func (r *OTLPReceiver) processOTLPJSON(remoteAddr string, body []byte) error {
 	unmarshaler := ptrace.JSONUnmarshaler{}
 	traces, err := unmarshaler.UnmarshalTraces(body)
 	if err != nil {
-		log.Printf("[OTLP] Failed to unmarshal JSON traces: %v", err)
+		otlpLog.Warn("Failed to unmarshal JSON traces", "error", err)
 		return err
 	}
 
 	resourceSpansCount := traces.ResourceSpans().Len()
-	log.Printf("[OTLP] Successfully parsed %d resource spans (JSON)", resourceSpansCount)
+	otlpLog.Debug("Parsed resource spans", "encoding", "json", "resourceSpans", resourceSpansCount)
 
 	if resourceSpansCount == 0 {
-		log.Printf("[OTLP] No resource spans found in JSON trace data")
+		otlpLog.Debug("No resource spans found in trace data", "encoding", "json")
 		return nil
 	}
 
@@ -63,6 +77,8 @@ type OTLPConfig struct {
 	HTTPPort   int    `json:"httpPort"`
 	GRPCAddr   string `json:"grpcAddr"`
 	HTTPAddr   string `json:"httpAddr"`
+	TLS        TLSSettings `json:"tls"`
+	Auth       OTLPAuthSettings `json:"auth"`
 }
 
 func NewOTLPReceiver(logParser *LogParser, config OTLPConfig) *OTLPReceiver {
@@ -71,6 +87,8 @@ func NewOTLPReceiver(logParser *LogParser, config OTLPConfig) *OTLPReceiver {
 		grpcPort:          config.GRPCPort,
 		httpPort:          config.HTTPPort,
 		enabled:           config.Enabled,
+		tls:               config.TLS,
+		auth:              config.Auth,
 		stopChan:          make(chan struct{}),
 		isRunning:         false,
 		tracesReceived:    0,
@@ -81,16 +99,31 @@ func NewOTLPReceiver(logParser *LogParser, config OTLPConfig) *OTLPReceiver {
 
 func (r *OTLPReceiver) Start() error {
 	if !r.enabled {
-		log.Println("[OTLP] OTLP receiver is disabled")
+		otlpLog.Info("OTLP receiver is disabled")
 		return nil
 	}
 
 	if r.isRunning {
-		log.Println("[OTLP] OTLP receiver is already running")
+		otlpLog.Info("OTLP receiver is already running")
 		return nil
 	}
 
-	log.Printf("[OTLP] Starting OTLP receiver - GRPC:%d, HTTP:%d", r.grpcPort, r.httpPort)
+	otlpLog.Info("Starting OTLP receiver", "grpcPort", r.grpcPort, "httpPort", r.httpPort)
+
+	tlsConfig, err := r.tls.ServerConfig()
+	if err != nil {
+		return fmt.Errorf("invalid OTLP TLS configuration: %v", err)
+	}
+	r.tlsConfig = tlsConfig
+	if tlsConfig != nil {
+		otlpLog.Info("TLS enabled", "clientCertificatesRequired", r.tls.MutualTLS())
+	}
+	if err := r.auth.Validate(); err != nil {
+		return fmt.Errorf("invalid OTLP auth configuration: %v", err)
+	}
+	if r.auth.Enabled() {
+		otlpLog.Info("Token authentication enabled", "header", r.auth.Header, "tokens", len(r.auth.Tokens))
+	}
 
 	// Start GRPC server
 	if err := r.startGRPCServer(); err != nil {
@@ -103,7 +136,7 @@ func (r *OTLPReceiver) Start() error {
 	}
 
 	r.isRunning = true
-	log.Println("[OTLP] OTLP receiver started successfully")
+	otlpLog.Info("OTLP receiver started")
 	return nil
 }
 
@@ -112,7 +145,7 @@ func (r *OTLPReceiver) Stop() error {
 		return nil
 	}
 
-	log.Println("[OTLP] Stopping OTLP receiver...")
+	otlpLog.Info("Stopping OTLP receiver")
 	close(r.stopChan)
 	r.isRunning = false
 
@@ -127,12 +160,12 @@ func (r *OTLPReceiver) Stop() error {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		if err := r.httpServer.Shutdown(ctx); err != nil {
-			log.Printf("[OTLP] HTTP server shutdown error: %v", err)
+			otlpLog.Error("HTTP server shutdown error", "error", err)
 		}
 		r.httpServer = nil
 	}
 
-	log.Println("[OTLP] OTLP receiver stopped")
+	otlpLog.Info("OTLP receiver stopped")
 	return nil
 }
 
@@ -142,21 +175,28 @@ func (r *OTLPReceiver) startGRPCServer() error {
 		return err
 	}
 
-	r.grpcServer = grpc.NewServer()
+	// gzip-compressed requests are decoded by the compressor registered above
+	opts := []grpc.ServerOption{grpc.MaxRecvMsgSize(OTLP_MAX_BODY_BYTES)}
+	if r.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(r.tlsConfig)))
+	}
+	opts = append(opts, r.grpcAuthOptions()...)
+	r.grpcServer = grpc.NewServer(opts...)
 	
-	// Register OTLP trace service (placeholder for now)
 	r.registerTraceService()
+	plogotlp.RegisterGRPCServer(r.grpcServer, &otlpLogsServer{receiver: r})
+	pmetricotlp.RegisterGRPCServer(r.grpcServer, &otlpMetricsServer{receiver: r})
 	
 	// Enable reflection for debugging
 	reflection.Register(r.grpcServer)
 
 	go func() {
 		if err := r.grpcServer.Serve(lis); err != nil {
-			log.Printf("[OTLP] GRPC server error: %v", err)
+			otlpLog.Error("GRPC server error", "error", err)
 		}
 	}()
 
-	log.Printf("[OTLP] GRPC server listening on :%d", r.grpcPort)
+	otlpLog.Info("GRPC server listening", "port", r.grpcPort)
 	return nil
 }
 
@@ -165,21 +205,31 @@ func (r *OTLPReceiver) startHTTPServer() error {
 	
 	// Register OTLP HTTP endpoints
 	mux.HandleFunc("/v1/traces", r.handleHTTPTraces)
+	mux.HandleFunc("/v1/logs", r.handleHTTPLogs)
+	mux.HandleFunc("/v1/metrics", r.handleHTTPMetrics)
 	mux.HandleFunc("/health", r.handleHealth)
 	mux.HandleFunc("/", r.handleRoot) // For debugging
 	
 	r.httpServer = &http.Server{
 		Addr:    fmt.Sprintf(":%d", r.httpPort),
-		Handler: r.corsMiddleware(mux),
+		Handler: r.corsMiddleware(r.authMiddleware(mux)),
+		TLSConfig: r.tlsConfig,
 	}
 
 	go func() {
-		if err := r.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("[OTLP] HTTP server error: %v", err)
+		var err error
+		if r.tlsConfig != nil {
+			// Certificates come from TLSConfig
+			err = r.httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = r.httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			otlpLog.Error("HTTP server error", "error", err)
 		}
 	}()
 
-	log.Printf("[OTLP] HTTP server listening on :%d", r.httpPort)
+	otlpLog.Info("HTTP server listening", "port", r.httpPort)
 	return nil
 }
 
@@ -198,10 +248,22 @@ func (r *OTLPReceiver) corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// TraceService for exporters sending traces over gRPC
+type otlpTracesServer struct {
+	ptraceotlp.UnimplementedGRPCServer
+	receiver *OTLPReceiver
+}
+
+func (s *otlpTracesServer) Export(ctx context.Context, request ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	s.receiver.tracesReceived++
+	if err := s.receiver.processOTLPSpans(request.Traces()); err != nil {
+		return ptraceotlp.NewExportResponse(), err
+	}
+	return ptraceotlp.NewExportResponse(), nil
+}
+
 func (r *OTLPReceiver) registerTraceService() {
-	// In a full implementation, you would register the OTLP trace service here
-	// This would implement the OpenTelemetry protobuf service definitions
-	log.Println("[OTLP] GRPC trace service registered (placeholder implementation)")
+	ptraceotlp.RegisterGRPCServer(r.grpcServer, &otlpTracesServer{receiver: r})
 }
 
 func (r *OTLPReceiver) handleHTTPTraces(w http.ResponseWriter, req *http.Request) {
@@ -214,41 +276,41 @@ func (r *OTLPReceiver) handleHTTPTraces(w http.ResponseWriter, req *http.Request
 	contentEncoding := req.Header.Get("Content-Encoding")
 	contentLength := req.Header.Get("Content-Length")
 	
-	log.Printf("[OTLP] Received HTTP trace request from %s, Content-Type: %s, Content-Encoding: %s, Content-Length: %s", 
-		req.RemoteAddr, contentType, contentEncoding, contentLength)
+	otlpLog.Debug("Received HTTP trace request", "client", req.RemoteAddr, "contentType", contentType,
+		"contentEncoding", contentEncoding, "contentLength", contentLength)
 
 	// Read request body
-	body, err := io.ReadAll(req.Body)
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, OTLP_MAX_BODY_BYTES))
 	if err != nil {
-		log.Printf("[OTLP] Error reading request body: %v", err)
-		http.Error(w, "Bad request", http.StatusBadRequest)
+		otlpLog.Warn("Error reading request body", "client", req.RemoteAddr, "error", err)
+		http.Error(w, "Bad request", decompressStatus(err))
 		r.errorCount++
 		return
 	}
 	defer req.Body.Close()
 
 	if len(body) == 0 {
-		log.Printf("[OTLP] Received empty body")
+		otlpLog.Warn("Received empty body", "client", req.RemoteAddr)
 		http.Error(w, "Empty body", http.StatusBadRequest)
 		r.errorCount++
 		return
 	}
 
-	log.Printf("[OTLP] Received %d bytes of trace data", len(body))
+	otlpLog.Debug("Received trace data", "bytes", len(body))
 	r.tracesReceived++
 
 	// Handle content encoding (decompression)
-	if contentEncoding != "" {
-		decompressed, err := r.decompressBody(body, contentEncoding)
-		if err != nil {
-			log.Printf("[OTLP] Error decompressing body with encoding %s: %v", contentEncoding, err)
-			http.Error(w, "Failed to decompress body", http.StatusBadRequest)
-			r.errorCount++
-			return
-		}
-		log.Printf("[OTLP] Decompressed %d bytes to %d bytes", len(body), len(decompressed))
-		body = decompressed
+	decompressed, err := r.decompressBody(body, contentEncoding)
+	if err != nil {
+		otlpLog.Warn("Error decompressing body", "contentEncoding", contentEncoding, "error", err)
+		http.Error(w, err.Error(), decompressStatus(err))
+		r.errorCount++
+		return
+	}
+	if len(decompressed) != len(body) {
+		otlpLog.Debug("Decompressed trace data", "bytes", len(body), "decompressedBytes", len(decompressed))
 	}
+	body = decompressed
 
 	// Process based on content type
 	var processingErr error
@@ -261,18 +323,18 @@ func (r *OTLPReceiver) handleHTTPTraces(w http.ResponseWriter, req *http.Request
 		// Try protobuf first, then JSON as fallback
 		processingErr = r.processOTLPProtobuf(req.RemoteAddr, body)
 		if processingErr != nil {
-			log.Printf("[OTLP] Protobuf parsing failed, trying JSON: %v", processingErr)
+			otlpLog.Debug("Protobuf parsing failed, trying JSON", "error", processingErr)
 			processingErr = r.processOTLPJSON(req.RemoteAddr, body)
 		}
 	}
 
 	if processingErr != nil {
-		log.Printf("[OTLP] Error processing OTLP data: %v", processingErr)
+		otlpLog.Warn("Error processing OTLP data", "client", req.RemoteAddr, "error", processingErr)
 		
 		// As a last resort, create sample data based on the request
 		// This ensures the dashboard shows activity even when parsing fails
 		if GetEnvBool("OTLP_FALLBACK_ENABLED", true) {
-			log.Printf("[OTLP] Generating fallback sample data for failed parse")
+			otlpLog.Info("Generating fallback sample data for failed parse")
 			r.createFallbackLogEntry(req.RemoteAddr)
 		} else {
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -293,15 +355,15 @@ func (r *OTLPReceiver) processOTLPProtobuf(remoteAddr string, body []byte) error
 	unmarshaler := ptrace.ProtoUnmarshaler{}
 	traces, err := unmarshaler.UnmarshalTraces(body)
 	if err != nil {
-		log.Printf("[OTLP] Failed to unmarshal traces: %v", err)
+		otlpLog.Debug("Failed to unmarshal traces", "error", err)
 		return err
 	}
 
 	resourceSpansCount := traces.ResourceSpans().Len()
-	log.Printf("[OTLP] Successfully parsed %d resource spans", resourceSpansCount)
+	otlpLog.Debug("Parsed resource spans", "encoding", "protobuf", "resourceSpans", resourceSpansCount)
 	
 	if resourceSpansCount == 0 {
-		log.Printf("[OTLP] No resource spans found in trace data")
+		otlpLog.Debug("No resource spans found in trace data", "encoding", "protobuf")
 		return nil
 	}
 	
@@ -319,7 +381,7 @@ func (r *OTLPReceiver) processOTLPSpans(traces ptrace.Traces) error {
 		
 		// Log resource attributes for debugging
 		if GetEnvBool("OTLP_DEBUG", false) {
-			log.Printf("[OTLP] Resource attributes: %v", r.attributesToMap(resource.Attributes()))
+			otlpLog.Debug("Resource attributes", "attributes", r.attributesToMap(resource.Attributes()))
 		}
 		
 		for j := 0; j < resourceSpan.ScopeSpans().Len(); j++ {
@@ -330,11 +392,14 @@ func (r *OTLPReceiver) processOTLPSpans(traces ptrace.Traces) error {
 				
 				// Log span attributes for debugging
 				if GetEnvBool("OTLP_DEBUG", false) {
-					log.Printf("[OTLP] Span '%s' attributes: %v", span.Name(), r.attributesToMap(span.Attributes()))
+					otlpLog.Debug("Span attributes", "span", span.Name(), "attributes", r.attributesToMap(span.Attributes()))
 				}
 				
 				// Convert span to log entry
 				logEntry := r.spanToLogEntry(span, resource)
+				if !otlpSampler.Keep(&logEntry) {
+					continue
+				}
 				
 				// Process through existing pipeline
 				r.logParser.ProcessOTLPLogEntry(logEntry)
@@ -344,7 +409,10 @@ func (r *OTLPReceiver) processOTLPSpans(traces ptrace.Traces) error {
 		}
 	}
 	
-	log.Printf("[OTLP] Processed %d spans successfully", processedCount)
+	otlpLog.Debug("Processed spans", "spans", processedCount)
+	if otlpTraceForwarder != nil {
+		otlpTraceForwarder.Forward(traces)
+	}
 	return nil
 }
 
@@ -422,11 +490,13 @@ func (r *OTLPReceiver) spanToLogEntry(span ptrace.Span, resource pcommon.Resourc
 	spanStatus := span.Status()
 	spanName := span.Name()
 	
+	clientIP, clientAddr := extractClientIP(httpClientIP)
+
 	// Build log entry with proper Traefik mapping
 	logEntry := LogEntry{
 		ID:           fmt.Sprintf("otlp-%s", span.SpanID().String()),
 		Timestamp:    span.StartTimestamp().AsTime().Format(time.RFC3339),
-		ClientIP:     r.extractClientIP(httpClientIP),
+		ClientIP:     clientIP,
 		Method:       httpMethod,
 		Path:         path,
 		Status:       httpStatusCode,
@@ -442,8 +512,9 @@ func (r *OTLPReceiver) spanToLogEntry(span ptrace.Span, resource pcommon.Resourc
 		// OpenTelemetry specific fields
 		TraceId:      span.TraceID().String(),
 		SpanId:       span.SpanID().String(),
+		ParentSpanId: span.ParentSpanID().String(),
 		Duration:     durationNs,
-		StartUTC:     span.StartTimestamp().AsTime().UTC().Format(time.RFC3339),
+		StartUTC:     span.StartTimestamp().AsTime().UTC().Format(time.RFC3339Nano),
 		StartLocal:   span.StartTimestamp().AsTime().Format(time.RFC3339),
 		
 		// Additional metadata
@@ -472,34 +543,40 @@ func (r *OTLPReceiver) spanToLogEntry(span ptrace.Span, resource pcommon.Resourc
 		
 		// Performance metrics
 		Overhead: r.calculateOverhead(span, attrs),
+
+		Span: r.spanInfo(span),
+
+		clientAddr: clientAddr,
 	}
 	
-	log.Printf("[OTLP] Converted span '%s' to log entry: %s %s %d (%.2fms)", 
-		spanName, httpMethod, path, httpStatusCode, responseTimeMs)
+	otlpLog.Debug("Converted span to log entry", "span", spanName, "method", httpMethod, "path", path,
+		"status", httpStatusCode, "durationMs", responseTimeMs)
 	
 	return logEntry
 }
 
-// Helper function to extract client IP from various sources
-func (r *OTLPReceiver) extractClientIP(httpClientIP string) string {
-	if httpClientIP != "" && httpClientIP != "unknown" {
-		// Handle IPv6 addresses in brackets
-		if strings.HasPrefix(httpClientIP, "[") {
-			if match := strings.Index(httpClientIP, "]"); match != -1 {
-				return httpClientIP[1:match]
-			}
-		}
-		
-		// Handle IPv4 with port
-		if strings.Contains(httpClientIP, ".") && strings.Contains(httpClientIP, ":") {
-			if lastColon := strings.LastIndex(httpClientIP, ":"); lastColon != -1 {
-				return httpClientIP[:lastColon]
-			}
-		}
-		
-		return httpClientIP
+// Details of a span for the trace waterfall; events beyond MAX_SPAN_EVENTS
+// are dropped so chatty spans can't take over the buffer
+func (r *OTLPReceiver) spanInfo(span ptrace.Span) *SpanInfo {
+	info := &SpanInfo{
+		Name:          span.Name(),
+		Kind:          span.Kind().String(),
+		Status:        span.Status().Code().String(),
+		StatusMessage: span.Status().Message(),
+	}
+	events := span.Events()
+	for i := 0; i < events.Len() && i < MAX_SPAN_EVENTS; i++ {
+		event := events.At(i)
+		info.Events = append(info.Events, SpanEvent{
+			Name:       event.Name(),
+			Time:       event.Timestamp().AsTime().UTC().Format(time.RFC3339Nano),
+			Attributes: r.attributesToMap(event.Attributes()),
+		})
+	}
+	if events.Len() > MAX_SPAN_EVENTS {
+		info.DroppedEvents = events.Len() - MAX_SPAN_EVENTS
 	}
-	return "unknown"
+	return info
 }
 
 // Helper function to calculate span overhead
@@ -600,8 +677,10 @@ func (r *OTLPReceiver) handleHealth(w http.ResponseWriter, req *http.Request) {
 		"running": %t,
 		"tracesReceived": %d,
 		"spansProcessed": %d,
+		"logsReceived": %d,
+		"logRecordsProcessed": %d,
 		"errors": %d
-	}`, r.isRunning, r.tracesReceived, r.spansProcessed, r.errorCount)))
+	}`, r.isRunning, r.tracesReceived, r.spansProcessed, r.logsReceived.Load(), r.logRecordsProcessed.Load(), r.errorCount)))
 }
 
 func (r *OTLPReceiver) handleRoot(w http.ResponseWriter, req *http.Request) {
@@ -612,21 +691,26 @@ func (r *OTLPReceiver) handleRoot(w http.ResponseWriter, req *http.Request) {
 		"version": "1.0.0",
 		"endpoints": {
 			"traces": "/v1/traces",
+			"logs": "/v1/logs",
+			"metrics": "/v1/metrics",
 			"health": "/health"
 		},
 		"config": {
 			"grpcPort": %d,
 			"httpPort": %d,
 			"enabled": %t,
-			"running": %t
+			"running": %t,
+			"tls": %t
 		},
 		"stats": {
 			"tracesReceived": %d,
 			"spansProcessed": %d,
+			"logsReceived": %d,
+			"logRecordsProcessed": %d,
 			"errors": %d
 		}
-	}`, r.grpcPort, r.httpPort, r.enabled, r.isRunning, 
-		r.tracesReceived, r.spansProcessed, r.errorCount)))
+	}`, r.grpcPort, r.httpPort, r.enabled, r.isRunning, r.tlsConfig != nil,
+		r.tracesReceived, r.spansProcessed, r.logsReceived.Load(), r.logRecordsProcessed.Load(), r.errorCount)))
 }
 
 // Configuration validation and status methods
@@ -641,6 +725,8 @@ func (r *OTLPReceiver) GetConfig() OTLPConfig {
 		HTTPPort: r.httpPort,
 		GRPCAddr: fmt.Sprintf("0.0.0.0:%d", r.grpcPort),
 		HTTPAddr: fmt.Sprintf("0.0.0.0:%d", r.httpPort),
+		TLS:      r.tls,
+		Auth:     r.auth,
 	}
 }
 
@@ -652,6 +738,13 @@ func (r *OTLPReceiver) GetStats() map[string]interface{} {
 		"running":         r.IsRunning(),
 		"tracesReceived":  r.tracesReceived,
 		"spansProcessed":  r.spansProcessed,
+		"logsReceived":        r.logsReceived.Load(),
+		"logRecordsProcessed": r.logRecordsProcessed.Load(),
+		"logRecordsRejected":  r.logRecordsRejected.Load(),
+		"metricsReceived":     r.metricsReceived.Load(),
+		"authFailures":        r.authFailures.Load(),
+		"dropped":             otlpSampler.Dropped(),
+		"forwarding":          forwardingStats(),
 		"errorCount":      r.errorCount,
 		"timestamp":       time.Now().Format(time.RFC3339),
 	}
@@ -710,6 +803,8 @@ func GetOTLPConfig() OTLPConfig {
 		HTTPPort: httpPort,
 		GRPCAddr: fmt.Sprintf("0.0.0.0:%d", grpcPort),
 		HTTPAddr: fmt.Sprintf("0.0.0.0:%d", httpPort),
+		TLS:      GetTLSSettings("OTLP_"),
+		Auth:     GetOTLPAuthSettings(),
 	}
 }
 
@@ -738,20 +833,3 @@ func GetEnvString(key, defaultValue string) string {
 	}
 	return defaultValue
 }
-
-// decompressBody decompresses the body according to the given encoding (supports "gzip").
-func (r *OTLPReceiver) decompressBody(body []byte, encoding string) ([]byte, error) {
-	switch strings.ToLower(encoding) {
-	case "gzip":
-		reader, err := gzip.NewReader(strings.NewReader(string(body)))
-		if err != nil {
-			return nil, err
-		}
-		defer reader.Close()
-		return io.ReadAll(reader)
-	case "identity", "":
-		return body, nil
-	default:
-		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
-	}
-}
\ No newline at end of file
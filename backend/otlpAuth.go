@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const DEFAULT_OTLP_AUTH_HEADER = "Authorization"
+
+// Token authentication for the OTLP listeners, read from OTLP_AUTH_TOKENS
+// (comma-separated, so a token can be rotated without dropping exporters)
+// and OTLP_AUTH_HEADER. With the default Authorization header exporters send
+// "Bearer <token>"; any other header carries the bare token. Works with or
+// without mutual TLS, which is configured through OTLP_TLS_CLIENT_CA_FILE.
+type OTLPAuthSettings struct {
+	Header string   `json:"header,omitempty"`
+	Tokens []string `json:"-"`
+}
+
+func GetOTLPAuthSettings() OTLPAuthSettings {
+	settings := OTLPAuthSettings{Header: os.Getenv("OTLP_AUTH_HEADER")}
+	for _, token := range strings.Split(os.Getenv("OTLP_AUTH_TOKENS"), ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			settings.Tokens = append(settings.Tokens, token)
+		}
+	}
+	if settings.Enabled() && settings.Header == "" {
+		settings.Header = DEFAULT_OTLP_AUTH_HEADER
+	}
+	return settings
+}
+
+func (s OTLPAuthSettings) Enabled() bool {
+	return len(s.Tokens) > 0
+}
+
+func (s OTLPAuthSettings) Validate() error {
+	if !s.Enabled() {
+		if s.Header != "" {
+			return fmt.Errorf("OTLP_AUTH_HEADER requires OTLP_AUTH_TOKENS")
+		}
+		return nil
+	}
+	// gRPC metadata keys are lowercase header names; grpc- ones are reserved
+	if s.Header == "" || strings.ContainsAny(s.Header, " \t:") || strings.HasPrefix(strings.ToLower(s.Header), "grpc-") {
+		return fmt.Errorf("invalid OTLP_AUTH_HEADER %q", s.Header)
+	}
+	return nil
+}
+
+// Check a value of the auth header against the configured tokens
+func (s OTLPAuthSettings) authorize(value string) bool {
+	if strings.EqualFold(s.Header, DEFAULT_OTLP_AUTH_HEADER) {
+		if len(value) < len("Bearer ") || !strings.EqualFold(value[:len("Bearer ")], "Bearer ") {
+			return false
+		}
+		value = value[len("Bearer "):]
+	}
+	authorized := false
+	for _, token := range s.Tokens {
+		// Every token is compared so timing doesn't tell which one matched
+		if subtle.ConstantTimeCompare([]byte(value), []byte(token)) == 1 {
+			authorized = true
+		}
+	}
+	return authorized
+}
+
+// Reject OTLP/HTTP requests without a valid token. /health stays open for
+// load balancer probes, as on the API.
+func (r *OTLPReceiver) authMiddleware(next http.Handler) http.Handler {
+	if !r.auth.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodOptions || req.URL.Path == "/health" || r.auth.authorize(req.Header.Get(r.auth.Header)) {
+			next.ServeHTTP(w, req)
+			return
+		}
+		r.rejectUnauthenticated(req.RemoteAddr, req.URL.Path)
+		if strings.EqualFold(r.auth.Header, DEFAULT_OTLP_AUTH_HEADER) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="otlp"`)
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// gRPC counterpart of authMiddleware, covering every service on the listener
+func (r *OTLPReceiver) grpcAuthOptions() []grpc.ServerOption {
+	if !r.auth.Enabled() {
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := r.authorizeGRPC(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := r.authorizeGRPC(stream.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	}
+}
+
+func (r *OTLPReceiver) authorizeGRPC(ctx context.Context, method string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get(strings.ToLower(r.auth.Header)) {
+		if r.auth.authorize(value) {
+			return nil
+		}
+	}
+	client := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		client = p.Addr.String()
+	}
+	r.rejectUnauthenticated(client, method)
+	return status.Error(codes.Unauthenticated, "missing or invalid OTLP token")
+}
+
+func (r *OTLPReceiver) rejectUnauthenticated(client, target string) {
+	r.authFailures.Add(1)
+	otlpLog.Debug("Rejected unauthenticated request", "client", client, "target", target)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const OTLP_MAX_BODY_BYTES = 64 << 20 // An export request, compressed or not
+
+var (
+	errUnsupportedEncoding = errors.New("unsupported content encoding")
+	errBodyTooLarge        = fmt.Errorf("decompressed body exceeds %d bytes", OTLP_MAX_BODY_BYTES)
+)
+
+// Undo the Content-Encoding of an export request: gzip or deflate, or a
+// list of them applied in order. A gzip body sent without the header is
+// recognised by its magic number.
+func (r *OTLPReceiver) decompressBody(body []byte, encoding string) ([]byte, error) {
+	if strings.TrimSpace(encoding) == "" {
+		if len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+			return decodeContent(body, "gzip")
+		}
+		return body, nil
+	}
+	codings := strings.Split(encoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		var err error
+		if body, err = decodeContent(body, strings.ToLower(strings.TrimSpace(codings[i]))); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+func decodeContent(body []byte, coding string) ([]byte, error) {
+	var reader io.ReadCloser
+	switch coding {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		reader = gz
+	case "deflate":
+		// zlib-wrapped as HTTP specifies, though some clients send raw deflate
+		if zr, err := zlib.NewReader(bytes.NewReader(body)); err == nil {
+			reader = zr
+		} else {
+			reader = flate.NewReader(bytes.NewReader(body))
+		}
+	case "zstd", "br", "snappy":
+		return nil, fmt.Errorf("%w %s; set the exporter's compression to gzip", errUnsupportedEncoding, coding)
+	default:
+		return nil, fmt.Errorf("%w %s", errUnsupportedEncoding, coding)
+	}
+	defer reader.Close()
+
+	// Bounded, so a small compressed body can't expand without limit
+	data, err := io.ReadAll(io.LimitReader(reader, OTLP_MAX_BODY_BYTES+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s body: %w", coding, err)
+	}
+	if len(data) > OTLP_MAX_BODY_BYTES {
+		return nil, errBodyTooLarge
+	}
+	return data, nil
+}
+
+// Status for a body that couldn't be read or decompressed
+func decompressStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, errUnsupportedEncoding):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, errBodyTooLarge), errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusBadRequest
+	}
+}
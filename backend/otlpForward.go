@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	OTLP_FORWARD_QUEUE_SIZE      = 1000 // Export requests waiting to be forwarded
+	OTLP_FORWARD_MAX_RETRIES     = 3
+	OTLP_FORWARD_INITIAL_BACKOFF = 500 * time.Millisecond
+)
+
+// Re-exports received traces, unsampled, to an upstream collector or Tempo
+// so the dashboard can sit in an existing tracing pipeline. Requests are
+// queued and dropped rather than slowing down the receiver if the upstream
+// falls behind.
+type OTLPTraceForwarder struct {
+	endpoint   string
+	protocol   string // "http" or "grpc"
+	headers    map[string]string
+	httpClient *http.Client
+	grpcConn   *grpc.ClientConn
+	grpcClient ptraceotlp.GRPCClient
+	queue      chan ptrace.Traces
+	stop       chan struct{}
+	done       chan struct{}
+
+	forwarded atomic.Int64 // Export requests accepted upstream
+	failed    atomic.Int64 // Given up on after retries
+	dropped   atomic.Int64 // Queue full
+}
+
+var otlpTraceForwarder *OTLPTraceForwarder
+
+// Create the forwarder from OTLP_FORWARD_ENDPOINT; nil when unset. Like
+// OTLP_METRICS_ENDPOINT, a base URL (http://tempo:4318) for HTTP and a
+// host:port (tempo:4317) for gRPC.
+func NewOTLPTraceForwarder() (*OTLPTraceForwarder, error) {
+	endpoint := os.Getenv("OTLP_FORWARD_ENDPOINT")
+	if endpoint == "" {
+		return nil, nil
+	}
+
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("OTLP_FORWARD_HEADERS"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid OTLP_FORWARD_HEADERS entry: %s", pair)
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	f := &OTLPTraceForwarder{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		protocol: GetEnvString("OTLP_FORWARD_PROTOCOL", "http"),
+		headers:  headers,
+		queue:    make(chan ptrace.Traces, OTLP_FORWARD_QUEUE_SIZE),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	switch f.protocol {
+	case "http":
+		if !strings.HasPrefix(f.endpoint, "http://") && !strings.HasPrefix(f.endpoint, "https://") {
+			return nil, fmt.Errorf("OTLP_FORWARD_ENDPOINT must be an http(s) URL for the http protocol")
+		}
+		f.httpClient = &http.Client{Timeout: 10 * time.Second}
+	case "grpc":
+		creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+		if GetEnvBool("OTLP_FORWARD_INSECURE", false) {
+			creds = insecure.NewCredentials()
+		}
+		conn, err := grpc.Dial(f.endpoint, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP gRPC client: %w", err)
+		}
+		f.grpcConn = conn
+		f.grpcClient = ptraceotlp.NewGRPCClient(conn)
+	default:
+		return nil, fmt.Errorf("OTLP_FORWARD_PROTOCOL must be http or grpc")
+	}
+
+	return f, nil
+}
+
+func (f *OTLPTraceForwarder) Start() {
+	otlpLog.Info("Forwarding received traces", "protocol", f.protocol, "endpoint", f.displayEndpoint())
+	go f.run()
+}
+
+// Queue traces for forwarding without blocking. The receiver is done with
+// them; nothing may modify them afterwards.
+func (f *OTLPTraceForwarder) Forward(traces ptrace.Traces) {
+	select {
+	case f.queue <- traces:
+	default:
+		if dropped := f.dropped.Add(1); dropped%100 == 1 {
+			otlpLog.Warn("Trace forwarding queue full", "droppedTotal", dropped)
+		}
+	}
+}
+
+func (f *OTLPTraceForwarder) run() {
+	defer close(f.done)
+	defer func() {
+		if f.grpcConn != nil {
+			f.grpcConn.Close()
+		}
+	}()
+	for {
+		select {
+		case traces := <-f.queue:
+			f.push(traces)
+		case <-f.stop:
+			// Send what's already queued before exiting
+			for {
+				select {
+				case traces := <-f.queue:
+					f.push(traces)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// The endpoint for logs and stats; URLs lose their path and credentials,
+// gRPC host:port endpoints have neither
+func (f *OTLPTraceForwarder) displayEndpoint() string {
+	if f.protocol == "grpc" {
+		return f.endpoint
+	}
+	return redactURL(f.endpoint)
+}
+
+// Send what's queued and stop
+func (f *OTLPTraceForwarder) Stop() {
+	close(f.stop)
+	<-f.done
+}
+
+// Export one request, retrying transient failures (network errors, 429, 5xx,
+// unavailable)
+func (f *OTLPTraceForwarder) push(traces ptrace.Traces) {
+	request := ptraceotlp.NewExportRequestFromTraces(traces)
+	backoff := OTLP_FORWARD_INITIAL_BACKOFF
+	for attempt := 0; ; attempt++ {
+		retryable, err := f.send(request)
+		if err == nil {
+			f.forwarded.Add(1)
+			return
+		}
+		if !retryable || attempt >= OTLP_FORWARD_MAX_RETRIES {
+			f.failed.Add(1)
+			otlpLog.Warn("Dropping forwarded traces", "spans", traces.SpanCount(), "error", err)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (f *OTLPTraceForwarder) send(request ptraceotlp.ExportRequest) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if f.grpcClient != nil {
+		if len(f.headers) > 0 {
+			ctx = metadata.NewOutgoingContext(ctx, metadata.New(f.headers))
+		}
+		_, err := f.grpcClient.Export(ctx, request, grpc.UseCompressor(grpcgzip.Name))
+		switch status.Code(err) {
+		case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Aborted:
+			return true, err
+		}
+		return false, err
+	}
+
+	body, err := request.MarshalProto()
+	if err != nil {
+		return false, err
+	}
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(body)
+	writer.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.endpoint+"/v1/traces", &compressed)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "gzip")
+	for key, value := range f.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("upstream returned HTTP %d", resp.StatusCode)
+}
+
+// Forwarding counters for /api/otlp/stats; nil when not forwarding
+func forwardingStats() map[string]interface{} {
+	if otlpTraceForwarder == nil {
+		return nil
+	}
+	return otlpTraceForwarder.Stats()
+}
+
+func (f *OTLPTraceForwarder) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"endpoint":  f.displayEndpoint(),
+		"protocol":  f.protocol,
+		"forwarded": f.forwarded.Load(),
+		"failed":    f.failed.Load(),
+		"dropped":   f.dropped.Load(),
+		"queued":    len(f.queue),
+	}
+}
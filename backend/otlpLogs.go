@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+)
+
+const OTLP_LOGS_SOURCE = "otlp" // Source parse errors of log records are reported under
+
+// LogsService for collectors exporting over gRPC
+type otlpLogsServer struct {
+	plogotlp.UnimplementedGRPCServer
+	receiver *OTLPReceiver
+}
+
+func (s *otlpLogsServer) Export(ctx context.Context, request plogotlp.ExportRequest) (plogotlp.ExportResponse, error) {
+	s.receiver.logsReceived.Add(1)
+	return s.receiver.processOTLPLogs(request.Logs()), nil
+}
+
+// Access logs shipped as OTLP log records, e.g. by a Collector's filelog
+// receiver or Traefik's own OTLP access log exporter
+func (r *OTLPReceiver) handleHTTPLogs(w http.ResponseWriter, req *http.Request) {
+	body, isJSON, ok := r.readExportBody(w, req)
+	if !ok {
+		return
+	}
+	r.logsReceived.Add(1)
+
+	request := plogotlp.NewExportRequest()
+	var err error
+	if isJSON {
+		err = request.UnmarshalJSON(body)
+	} else {
+		err = request.UnmarshalProto(body)
+	}
+	if err != nil {
+		otlpLog.Warn("Failed to unmarshal logs", "client", req.RemoteAddr, "json", isJSON, "error", err)
+		http.Error(w, "Invalid OTLP logs request", http.StatusBadRequest)
+		r.errorCount++
+		return
+	}
+
+	response := r.processOTLPLogs(request.Logs())
+	if isJSON {
+		writeExportResponse(w, isJSON, response.MarshalJSON)
+	} else {
+		writeExportResponse(w, isJSON, response.MarshalProto)
+	}
+}
+
+// Read and decompress the body of an OTLP/HTTP export request; ok is false
+// when an error response was already written
+func (r *OTLPReceiver) readExportBody(w http.ResponseWriter, req *http.Request) (body []byte, isJSON, ok bool) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, false, false
+	}
+	defer req.Body.Close()
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, OTLP_MAX_BODY_BYTES))
+	if err != nil {
+		otlpLog.Warn("Error reading request body", "client", req.RemoteAddr, "error", err)
+		http.Error(w, "Bad request", decompressStatus(err))
+		r.errorCount++
+		return nil, false, false
+	}
+	encoding := req.Header.Get("Content-Encoding")
+	if body, err = r.decompressBody(body, encoding); err != nil {
+		otlpLog.Warn("Error decompressing body", "contentEncoding", encoding, "error", err)
+		http.Error(w, err.Error(), decompressStatus(err))
+		r.errorCount++
+		return nil, false, false
+	}
+	return body, strings.Contains(req.Header.Get("Content-Type"), "application/json"), true
+}
+
+// Answer in the encoding the exporter used
+func writeExportResponse(w http.ResponseWriter, isJSON bool, marshal func() ([]byte, error)) {
+	data, err := marshal()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if isJSON {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// Ingest every record that is an HTTP access log; others are reported back
+// as rejected and recorded as parse errors of the "otlp" source
+func (r *OTLPReceiver) processOTLPLogs(logs plog.Logs) plogotlp.ExportResponse {
+	var processed, rejected int64
+	var lastReason string
+	for i := 0; i < logs.ResourceLogs().Len(); i++ {
+		resourceLogs := logs.ResourceLogs().At(i)
+		resource := resourceLogs.Resource().Attributes()
+		for j := 0; j < resourceLogs.ScopeLogs().Len(); j++ {
+			records := resourceLogs.ScopeLogs().At(j).LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				record := records.At(k)
+				entry, reason := r.logRecordToLogEntry(record, resource)
+				if entry == nil {
+					rejected++
+					lastReason = reason
+					if sample, broadcast := parseErrorTracker.Record(OTLP_LOGS_SOURCE, record.Body().AsString(), reason); broadcast {
+						broadcastParseError(sample)
+					}
+					continue
+				}
+				if !otlpSampler.Keep(entry) {
+					putLogEntry(entry)
+					continue
+				}
+				r.logParser.ProcessOTLPLogEntry(*entry)
+				putLogEntry(entry)
+				processed++
+			}
+		}
+	}
+	r.logRecordsProcessed.Add(processed)
+	r.logRecordsRejected.Add(rejected)
+	otlpLog.Debug("Processed log records", "records", processed, "rejected", rejected)
+
+	response := plogotlp.NewExportResponse()
+	if rejected > 0 {
+		response.PartialSuccess().SetRejectedLogRecords(rejected)
+		response.PartialSuccess().SetErrorMessage(fmt.Sprintf("%d records are not HTTP access logs, e.g. %s", rejected, lastReason))
+	}
+	return response
+}
+
+// Convert a log record to an entry. Records carrying Traefik's access log
+// fields (a JSON line as the body, or the fields as a map body or
+// attributes) decode exactly like lines of a log file; otherwise the HTTP
+// semantic convention attributes are used. reason says why a record was
+// rejected.
+func (r *OTLPReceiver) logRecordToLogEntry(record plog.LogRecord, resource pcommon.Map) (*LogEntry, string) {
+	fields := record.Attributes().AsRaw()
+	switch body := record.Body(); body.Type() {
+	case pcommon.ValueTypeMap:
+		for key, value := range body.Map().AsRaw() {
+			fields[key] = value
+		}
+	case pcommon.ValueTypeStr:
+		if line := strings.TrimSpace(body.Str()); strings.HasPrefix(line, "{") {
+			var parsed map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &parsed); err != nil {
+				return nil, "body is not valid JSON: " + err.Error()
+			}
+			for key, value := range parsed {
+				fields[key] = value
+			}
+		}
+	}
+
+	timestamp := record.Timestamp()
+	if timestamp == 0 {
+		timestamp = record.ObservedTimestamp()
+	}
+	recorded := time.Now()
+	if timestamp != 0 {
+		recorded = timestamp.AsTime()
+	}
+
+	_, hasStatus := fields["DownstreamStatus"]
+	_, hasMethod := fields["RequestMethod"]
+	var entry *LogEntry
+	if hasStatus || hasMethod {
+		decoded := r.traefikFieldsToLogEntry(fields, recorded)
+		if decoded.entry == nil {
+			return nil, decoded.reason
+		}
+		entry = decoded.entry
+	} else if entry = r.semanticFieldsToLogEntry(fields, resource, recorded); entry == nil {
+		return nil, "no Traefik access log fields or HTTP method/status attributes"
+	}
+
+	if entry.TraceId == "" && !record.TraceID().IsEmpty() {
+		entry.TraceId = record.TraceID().String()
+	}
+	if entry.SpanId == "" && !record.SpanID().IsEmpty() {
+		entry.SpanId = record.SpanID().String()
+	}
+	return entry, ""
+}
+
+// Decode Traefik's field names through the file parser; the record's time
+// stands in for a missing "time"
+func (r *OTLPReceiver) traefikFieldsToLogEntry(fields map[string]interface{}, recorded time.Time) decodedLine {
+	if _, ok := fields["time"]; !ok {
+		fields["time"] = recorded.Format(time.RFC3339)
+	}
+	line, err := json.Marshal(fields)
+	if err != nil {
+		return decodedLine{reason: "attributes can't be encoded: " + err.Error()}
+	}
+	return r.logParser.decodeLine(OTLP_LOGS_SOURCE, string(line))
+}
+
+// Map the HTTP semantic conventions (and their pre-1.20 names) to an
+// entry; nil when neither a method nor a status is present
+func (r *OTLPReceiver) semanticFieldsToLogEntry(fields map[string]interface{}, resource pcommon.Map, recorded time.Time) *LogEntry {
+	method := fieldString(fields, "http.request.method", "http.method")
+	status := int(fieldNumber(fields, "http.response.status_code", "http.status_code"))
+	if method == "" && status == 0 {
+		return nil
+	}
+	if method == "" {
+		method = "GET"
+	}
+
+	path := fieldString(fields, "url.path", "http.target")
+	host := fieldString(fields, "server.address", "http.host")
+	scheme := fieldString(fields, "url.scheme", "http.scheme")
+	if full := fieldString(fields, "url.full", "http.url"); full != "" {
+		if u, err := url.Parse(full); err == nil {
+			if path == "" {
+				path = u.RequestURI()
+			}
+			if host == "" {
+				host = u.Host
+			}
+			if scheme == "" {
+				scheme = u.Scheme
+			}
+		}
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	service := fieldString(fields, "traefik.service")
+	if service == "" {
+		if name, ok := resource.Get("service.name"); ok {
+			service = name.AsString()
+		}
+	}
+	if service == "" {
+		service = "unknown"
+	}
+	router := fieldString(fields, "traefik.router", "http.route")
+	if router == "" {
+		router = "unknown"
+	}
+
+	protocol := "HTTP"
+	if version := fieldString(fields, "network.protocol.version", "http.flavor"); version != "" {
+		protocol = "HTTP/" + version
+	}
+	port := fieldString(fields, "server.port")
+	requestAddr := host
+	if port != "" && host != "" && !strings.Contains(host, ":") {
+		requestAddr = host + ":" + port
+	}
+	clientIP, clientAddr := extractClientIP(fieldString(fields, "client.address", "http.client_ip", "network.peer.address"))
+	durationNs := int64(fieldNumber(fields, "http.server.request.duration") * 1e9) // Seconds
+
+	entry := getLogEntry()
+	*entry = LogEntry{
+		ID:                 r.logParser.nextEntryID(),
+		Timestamp:          recorded.Format(time.RFC3339),
+		ClientIP:           clientIP,
+		Method:             method,
+		Path:               path,
+		Status:             status,
+		ResponseTime:       float64(durationNs) / 1e6,
+		ServiceName:        service,
+		RouterName:         router,
+		Host:               host,
+		RequestAddr:        requestAddr,
+		RequestHost:        host,
+		UserAgent:          fieldString(fields, "user_agent.original", "http.user_agent"),
+		Size:               int(fieldNumber(fields, "http.response.body.size", "http.response_content_length")),
+		StartUTC:           recorded.UTC().Format(time.RFC3339),
+		StartLocal:         recorded.Format(time.RFC3339),
+		Duration:           durationNs,
+		ClientPort:         fieldString(fields, "client.port"),
+		RequestPort:        port,
+		RequestProtocol:    protocol,
+		RequestScheme:      scheme,
+		RequestLine:        fmt.Sprintf("%s %s %s", method, path, protocol),
+		RequestContentSize: int(fieldNumber(fields, "http.request.body.size", "http.request_content_length")),
+		DownstreamStatus:   status,
+		RequestCount:       1,
+		TLSVersion:         fieldString(fields, "tls.protocol.version"),
+		TLSCipher:          fieldString(fields, "tls.cipher"),
+		Source:             OTLP_LOGS_SOURCE,
+
+		clientAddr: clientAddr,
+	}
+	return entry
+}
+
+// The first of keys present, as a string
+func fieldString(fields map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch value := fields[key].(type) {
+		case string:
+			return value
+		case int64:
+			return strconv.FormatInt(value, 10)
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// The first of keys present, as a number; strings holding numbers count
+func fieldNumber(fields map[string]interface{}, keys ...string) float64 {
+	for _, key := range keys {
+		switch value := fields[key].(type) {
+		case int64:
+			return float64(value)
+		case float64:
+			return value
+		case string:
+			if number, err := strconv.ParseFloat(value, 64); err == nil {
+				return number
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+)
+
+const (
+	TRAEFIK_METRICS_RETENTION      = time.Hour // Samples kept for comparisons
+	DEFAULT_METRICS_COMPARE_WINDOW = 15 * time.Minute
+)
+
+// Traefik's request counters and duration histograms per entrypoint, router
+// and service, with "." or "_" separators
+var traefikMetricName = regexp.MustCompile(`^traefik[._](entrypoint|router|service)[._](requests_total|request_duration_seconds)$`)
+
+// MetricsService for Traefik exporting over gRPC
+type otlpMetricsServer struct {
+	pmetricotlp.UnimplementedGRPCServer
+	receiver *OTLPReceiver
+}
+
+func (s *otlpMetricsServer) Export(ctx context.Context, request pmetricotlp.ExportRequest) (pmetricotlp.ExportResponse, error) {
+	s.receiver.metricsReceived.Add(1)
+	traefikMetrics.Record(request.Metrics())
+	return pmetricotlp.NewExportResponse(), nil
+}
+
+func (r *OTLPReceiver) handleHTTPMetrics(w http.ResponseWriter, req *http.Request) {
+	body, isJSON, ok := r.readExportBody(w, req)
+	if !ok {
+		return
+	}
+	r.metricsReceived.Add(1)
+
+	request := pmetricotlp.NewExportRequest()
+	var err error
+	if isJSON {
+		err = request.UnmarshalJSON(body)
+	} else {
+		err = request.UnmarshalProto(body)
+	}
+	if err != nil {
+		otlpLog.Warn("Failed to unmarshal metrics", "client", req.RemoteAddr, "json", isJSON, "error", err)
+		http.Error(w, "Invalid OTLP metrics request", http.StatusBadRequest)
+		r.errorCount++
+		return
+	}
+
+	traefikMetrics.Record(request.Metrics())
+	response := pmetricotlp.NewExportResponse()
+	if isJSON {
+		writeExportResponse(w, isJSON, response.MarshalJSON)
+	} else {
+		writeExportResponse(w, isJSON, response.MarshalProto)
+	}
+}
+
+// Requests, 5xx responses and request durations, accumulated
+type MetricTotals struct {
+	Requests      uint64  `json:"requests"`
+	Errors        uint64  `json:"errors"`
+	DurationCount uint64  `json:"-"`
+	DurationSum   float64 `json:"-"` // Seconds
+}
+
+func (t MetricTotals) Since(prev MetricTotals) MetricTotals {
+	return MetricTotals{
+		Requests:      t.Requests - min64(prev.Requests, t.Requests),
+		Errors:        t.Errors - min64(prev.Errors, t.Errors),
+		DurationCount: t.DurationCount - min64(prev.DurationCount, t.DurationCount),
+		DurationSum:   math.Max(t.DurationSum-prev.DurationSum, 0),
+	}
+}
+
+func (t MetricTotals) AvgDurationMs() float64 {
+	if t.DurationCount == 0 {
+		return 0
+	}
+	return math.Round(t.DurationSum/float64(t.DurationCount)*1e5) / 100
+}
+
+func min64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// One data point stream: a metric with a particular set of attributes.
+// Cumulative values are turned into increments so Traefik restarts don't
+// make totals go backwards.
+type metricStream struct {
+	level, name string
+	start       pcommon.Timestamp
+	count       float64 // Last cumulative request count or histogram count
+	sum         float64 // Last cumulative histogram sum
+}
+
+// Totals from Traefik's metrics alongside the dashboard's own for the same
+// moment
+type traefikMetricsSample struct {
+	time      time.Time
+	traefik   map[string]map[string]MetricTotals // Level -> entrypoint/router/service name
+	dashboard map[string]MetricTotals            // By service
+}
+
+// Key series of Traefik's own metrics, received over OTLP, to cross-check
+// the numbers derived from its logs
+type TraefikMetrics struct {
+	mu         sync.Mutex
+	streams    map[string]*metricStream
+	series     map[string]map[string]MetricTotals
+	samples    []traefikMetricsSample
+	exports    uint64
+	lastExport time.Time
+}
+
+var traefikMetrics = &TraefikMetrics{
+	streams: make(map[string]*metricStream),
+	series:  make(map[string]map[string]MetricTotals),
+}
+
+// Add the data points of Traefik's request metrics and take a sample for
+// comparisons; other metrics are ignored
+func (tm *TraefikMetrics) Record(data pmetric.Metrics) {
+	dashboard := metrics.ServiceTotals()
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	for i := 0; i < data.ResourceMetrics().Len(); i++ {
+		scopes := data.ResourceMetrics().At(i).ScopeMetrics()
+		for j := 0; j < scopes.Len(); j++ {
+			list := scopes.At(j).Metrics()
+			for k := 0; k < list.Len(); k++ {
+				tm.recordMetric(list.At(k))
+			}
+		}
+	}
+
+	now := time.Now()
+	tm.exports++
+	tm.lastExport = now
+	sample := traefikMetricsSample{time: now, traefik: make(map[string]map[string]MetricTotals, len(tm.series)), dashboard: dashboard}
+	for level, names := range tm.series {
+		copied := make(map[string]MetricTotals, len(names))
+		for name, totals := range names {
+			copied[name] = totals
+		}
+		sample.traefik[level] = copied
+	}
+	tm.samples = append(tm.samples, sample)
+	expired := 0
+	for expired < len(tm.samples)-1 && now.Sub(tm.samples[expired].time) > TRAEFIK_METRICS_RETENTION {
+		expired++
+	}
+	tm.samples = tm.samples[expired:]
+}
+
+func (tm *TraefikMetrics) recordMetric(metric pmetric.Metric) {
+	match := traefikMetricName.FindStringSubmatch(metric.Name())
+	if match == nil {
+		return
+	}
+	level, histogram := match[1], strings.HasSuffix(match[2], "seconds")
+
+	switch {
+	case !histogram && metric.Type() == pmetric.MetricTypeSum:
+		delta := metric.Sum().AggregationTemporality() == pmetric.AggregationTemporalityDelta
+		points := metric.Sum().DataPoints()
+		for i := 0; i < points.Len(); i++ {
+			point := points.At(i)
+			value := point.DoubleValue()
+			if point.ValueType() == pmetric.NumberDataPointValueTypeInt {
+				value = float64(point.IntValue())
+			}
+			stream := tm.stream(metric.Name(), level, point.Attributes())
+			increment, _ := stream.advance(point.StartTimestamp(), value, 0, delta)
+			totals := tm.series[level][stream.name]
+			totals.Requests += uint64(math.Round(increment))
+			if code, _ := strconv.Atoi(attributeString(point.Attributes(), "code")); code >= 500 {
+				totals.Errors += uint64(math.Round(increment))
+			}
+			tm.series[level][stream.name] = totals
+		}
+	case histogram && metric.Type() == pmetric.MetricTypeHistogram:
+		delta := metric.Histogram().AggregationTemporality() == pmetric.AggregationTemporalityDelta
+		points := metric.Histogram().DataPoints()
+		for i := 0; i < points.Len(); i++ {
+			point := points.At(i)
+			stream := tm.stream(metric.Name(), level, point.Attributes())
+			count, sum := stream.advance(point.StartTimestamp(), float64(point.Count()), point.Sum(), delta)
+			totals := tm.series[level][stream.name]
+			totals.DurationCount += uint64(math.Round(count))
+			totals.DurationSum += sum
+			tm.series[level][stream.name] = totals
+		}
+	}
+}
+
+// The stream for a data point, created on first sight
+func (tm *TraefikMetrics) stream(metricName, level string, attributes pcommon.Map) *metricStream {
+	raw := attributes.AsRaw()
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var id strings.Builder
+	id.WriteString(metricName)
+	for _, key := range keys {
+		fmt.Fprintf(&id, "|%s=%v", key, raw[key])
+	}
+
+	stream, ok := tm.streams[id.String()]
+	if !ok {
+		stream = &metricStream{level: level, name: attributeString(attributes, level)}
+		tm.streams[id.String()] = stream
+	}
+	if tm.series[level] == nil {
+		tm.series[level] = make(map[string]MetricTotals)
+	}
+	return stream
+}
+
+// The increase since the last data point. A cumulative stream that went
+// backwards or has a new start time was restarted and counts from zero.
+func (s *metricStream) advance(start pcommon.Timestamp, count, sum float64, delta bool) (float64, float64) {
+	if delta {
+		return count, sum
+	}
+	if start != s.start || count < s.count {
+		s.start, s.count, s.sum = start, 0, 0
+	}
+	countIncrease, sumIncrease := count-s.count, sum-s.sum
+	s.count, s.sum = count, sum
+	return countIncrease, sumIncrease
+}
+
+func attributeString(attributes pcommon.Map, key string) string {
+	if value, ok := attributes.Get(key); ok {
+		return value.AsString()
+	}
+	return ""
+}
+
+type TraefikSeries struct {
+	Name string `json:"name"`
+	MetricTotals
+	AvgDurationMs float64 `json:"avgDurationMs"`
+}
+
+type TraefikMetricsReport struct {
+	Exports     uint64          `json:"exports"`
+	LastExport  string          `json:"lastExport,omitempty"`
+	Entrypoints []TraefikSeries `json:"entrypoints"`
+	Routers     []TraefikSeries `json:"routers"`
+	Services    []TraefikSeries `json:"services"`
+}
+
+// Totals since Traefik started, as its metrics report them
+func (tm *TraefikMetrics) Report() TraefikMetricsReport {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	report := TraefikMetricsReport{
+		Exports:     tm.exports,
+		Entrypoints: seriesList(tm.series["entrypoint"]),
+		Routers:     seriesList(tm.series["router"]),
+		Services:    seriesList(tm.series["service"]),
+	}
+	if !tm.lastExport.IsZero() {
+		report.LastExport = tm.lastExport.UTC().Format(time.RFC3339)
+	}
+	return report
+}
+
+func seriesList(series map[string]MetricTotals) []TraefikSeries {
+	list := make([]TraefikSeries, 0, len(series))
+	for name, totals := range series {
+		list = append(list, TraefikSeries{Name: name, MetricTotals: totals, AvgDurationMs: totals.AvgDurationMs()})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Traefik's and the dashboard's numbers for the same requests
+type MetricsComparisonRow struct {
+	Name              string  `json:"name"`
+	TraefikRequests   uint64  `json:"traefikRequests"`
+	DashboardRequests uint64  `json:"dashboardRequests"`
+	DifferencePercent float64 `json:"differencePercent"` // Dashboard relative to Traefik
+	TraefikErrors     uint64  `json:"traefikErrors"`
+	DashboardErrors   uint64  `json:"dashboardErrors"`
+	TraefikAvgMs      float64 `json:"traefikAvgMs"`
+	DashboardAvgMs    float64 `json:"dashboardAvgMs"`
+}
+
+type MetricsComparison struct {
+	From     string                 `json:"from"`
+	To       string                 `json:"to"`
+	Total    MetricsComparisonRow   `json:"total"` // All entrypoints against every counted entry
+	Services []MetricsComparisonRow `json:"services"`
+}
+
+func compareTotals(name string, traefik, dashboard MetricTotals) MetricsComparisonRow {
+	row := MetricsComparisonRow{
+		Name:              name,
+		TraefikRequests:   traefik.Requests,
+		DashboardRequests: dashboard.Requests,
+		TraefikErrors:     traefik.Errors,
+		DashboardErrors:   dashboard.Errors,
+		TraefikAvgMs:      traefik.AvgDurationMs(),
+		DashboardAvgMs:    dashboard.AvgDurationMs(),
+	}
+	if traefik.Requests > 0 {
+		row.DifferencePercent = math.Round((float64(dashboard.Requests)-float64(traefik.Requests))/float64(traefik.Requests)*1000) / 10
+	}
+	return row
+}
+
+// Compare what Traefik and the dashboard counted between the oldest sample
+// inside window and the latest one
+func (tm *TraefikMetrics) Compare(window time.Duration) (MetricsComparison, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if len(tm.samples) < 2 {
+		return MetricsComparison{}, fmt.Errorf("%d metric exports received; at least two are needed", len(tm.samples))
+	}
+	latest := tm.samples[len(tm.samples)-1]
+	first := len(tm.samples) - 2
+	for first > 0 && latest.time.Sub(tm.samples[first-1].time) <= window {
+		first--
+	}
+	earliest := tm.samples[first]
+
+	comparison := MetricsComparison{
+		From:     earliest.time.UTC().Format(time.RFC3339),
+		To:       latest.time.UTC().Format(time.RFC3339),
+		Services: []MetricsComparisonRow{},
+	}
+	var traefikTotal, dashboardTotal MetricTotals
+	for name, totals := range latest.traefik["entrypoint"] {
+		traefikTotal = traefikTotal.add(totals.Since(earliest.traefik["entrypoint"][name]))
+	}
+	for name, totals := range latest.dashboard {
+		dashboardTotal = dashboardTotal.add(totals.Since(earliest.dashboard[name]))
+	}
+	comparison.Total = compareTotals("total", traefikTotal, dashboardTotal)
+
+	for name, totals := range latest.traefik["service"] {
+		traefik := totals.Since(earliest.traefik["service"][name])
+		dashboard := latest.dashboard[name].Since(earliest.dashboard[name])
+		if traefik.Requests > 0 || dashboard.Requests > 0 {
+			comparison.Services = append(comparison.Services, compareTotals(name, traefik, dashboard))
+		}
+	}
+	sort.Slice(comparison.Services, func(i, j int) bool { return comparison.Services[i].Name < comparison.Services[j].Name })
+	return comparison, nil
+}
+
+func (t MetricTotals) add(other MetricTotals) MetricTotals {
+	return MetricTotals{
+		Requests:      t.Requests + other.Requests,
+		Errors:        t.Errors + other.Errors,
+		DurationCount: t.DurationCount + other.DurationCount,
+		DurationSum:   t.DurationSum + other.DurationSum,
+	}
+}
+
+func getTraefikMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, traefikMetrics.Report())
+}
+
+// ?window= is a duration such as 15m, up to the hour of samples kept
+func compareTraefikMetrics(c *gin.Context) {
+	window := DEFAULT_METRICS_COMPARE_WINDOW
+	if value := c.Query("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "window must be a positive duration such as 15m"})
+			return
+		}
+		window = parsed
+	}
+	comparison, err := traefikMetrics.Compare(window)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, comparison)
+}
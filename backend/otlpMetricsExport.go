@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const DEFAULT_OTLP_METRICS_INTERVAL = 60 * time.Second
+
+// Pushes the dashboard's aggregated metrics to an OTLP collector. Counters
+// and the latency histogram use cumulative temporality; rates, error rates
+// and latency percentiles are gauges over the last push interval.
+type OTLPMetricsExporter struct {
+	endpoint    string
+	protocol    string // "http" or "grpc"
+	headers     map[string]string
+	interval    time.Duration
+	serviceName string
+	httpClient  *http.Client
+	grpcConn    *grpc.ClientConn
+	grpcClient  pmetricotlp.GRPCClient
+	previous    map[string]*ServiceMetrics
+	stop        chan struct{}
+}
+
+var otlpMetricsExporter *OTLPMetricsExporter
+
+// Create the exporter from OTLP_METRICS_ENDPOINT; nil when unset. For HTTP
+// the endpoint is the collector base URL (http://collector:4318), for gRPC
+// a host:port (collector:4317).
+func NewOTLPMetricsExporter() (*OTLPMetricsExporter, error) {
+	endpoint := os.Getenv("OTLP_METRICS_ENDPOINT")
+	if endpoint == "" {
+		return nil, nil
+	}
+
+	interval := time.Duration(GetEnvInt("OTLP_METRICS_INTERVAL_SECONDS", int(DEFAULT_OTLP_METRICS_INTERVAL/time.Second))) * time.Second
+	if interval < time.Second {
+		return nil, fmt.Errorf("OTLP_METRICS_INTERVAL_SECONDS must be at least 1")
+	}
+
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("OTLP_METRICS_HEADERS"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid OTLP_METRICS_HEADERS entry: %s", pair)
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	e := &OTLPMetricsExporter{
+		endpoint:    strings.TrimRight(endpoint, "/"),
+		protocol:    GetEnvString("OTLP_METRICS_PROTOCOL", "http"),
+		headers:     headers,
+		interval:    interval,
+		serviceName: GetEnvString("OTLP_METRICS_SERVICE_NAME", "traefik-log-dashboard"),
+		stop:        make(chan struct{}),
+	}
+
+	switch e.protocol {
+	case "http":
+		if !strings.HasPrefix(e.endpoint, "http://") && !strings.HasPrefix(e.endpoint, "https://") {
+			return nil, fmt.Errorf("OTLP_METRICS_ENDPOINT must be an http(s) URL for the http protocol")
+		}
+		e.httpClient = &http.Client{Timeout: 10 * time.Second}
+	case "grpc":
+		creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+		if GetEnvBool("OTLP_METRICS_INSECURE", false) {
+			creds = insecure.NewCredentials()
+		}
+		conn, err := grpc.Dial(e.endpoint, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP gRPC client: %w", err)
+		}
+		e.grpcConn = conn
+		e.grpcClient = pmetricotlp.NewGRPCClient(conn)
+	default:
+		return nil, fmt.Errorf("OTLP_METRICS_PROTOCOL must be http or grpc")
+	}
+
+	return e, nil
+}
+
+func (e *OTLPMetricsExporter) Start() {
+	otlpLog.Info("Exporting OTLP metrics", "protocol", e.protocol, "endpoint", redactURL(e.endpoint), "interval", e.interval)
+	go func() {
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := e.export(now); err != nil {
+					otlpLog.Warn("Metrics export failed", "error", err)
+				}
+			case <-e.stop:
+				if e.grpcConn != nil {
+					e.grpcConn.Close()
+				}
+				return
+			}
+		}
+	}()
+}
+
+func (e *OTLPMetricsExporter) Stop() {
+	close(e.stop)
+}
+
+func (e *OTLPMetricsExporter) export(now time.Time) error {
+	snapshot := metrics.Snapshot()
+	request := pmetricotlp.NewExportRequestFromMetrics(e.build(snapshot, now))
+	e.previous = snapshot.Services
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if e.grpcClient != nil {
+		if len(e.headers) > 0 {
+			ctx = metadata.NewOutgoingContext(ctx, metadata.New(e.headers))
+		}
+		_, err := e.grpcClient.Export(ctx, request)
+		return err
+	}
+
+	body, err := request.MarshalProto()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint+"/v1/metrics", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	return doNotifyRequest(e.httpClient, req)
+}
+
+// Build the OTLP payload for a snapshot
+func (e *OTLPMetricsExporter) build(s MetricsSnapshot, now time.Time) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", e.serviceName)
+	sm := rm.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName("traefik-log-dashboard")
+
+	start := pcommon.NewTimestampFromTime(s.StartTime)
+	timestamp := pcommon.NewTimestampFromTime(now)
+
+	requests := sm.Metrics().AppendEmpty()
+	requests.SetName("traefik.requests")
+	requests.SetDescription("Requests seen in Traefik access logs")
+	requests.SetUnit("{request}")
+	requestsSum := requests.SetEmptySum()
+	requestsSum.SetIsMonotonic(true)
+	requestsSum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+
+	bytesSent := sm.Metrics().AppendEmpty()
+	bytesSent.SetName("traefik.response.size")
+	bytesSent.SetDescription("Response bytes sent")
+	bytesSent.SetUnit("By")
+	bytesSum := bytesSent.SetEmptySum()
+	bytesSum.SetIsMonotonic(true)
+	bytesSum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+
+	duration := sm.Metrics().AppendEmpty()
+	duration.SetName("traefik.request.duration")
+	duration.SetDescription("Request latency")
+	duration.SetUnit("s")
+	histogram := duration.SetEmptyHistogram()
+	histogram.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+
+	rate := sm.Metrics().AppendEmpty()
+	rate.SetName("traefik.request.rate")
+	rate.SetDescription("Requests per second over the last export interval")
+	rate.SetUnit("{request}/s")
+	rateGauge := rate.SetEmptyGauge()
+
+	errorRate := sm.Metrics().AppendEmpty()
+	errorRate.SetName("traefik.error_rate")
+	errorRate.SetDescription("Share of 5xx responses over the last export interval")
+	errorRate.SetUnit("1")
+	errorGauge := errorRate.SetEmptyGauge()
+
+	percentiles := sm.Metrics().AppendEmpty()
+	percentiles.SetName("traefik.request.latency")
+	percentiles.SetDescription("Estimated latency percentiles over the last export interval")
+	percentiles.SetUnit("s")
+	percentileGauge := percentiles.SetEmptyGauge()
+
+	for _, name := range s.ServiceNames() {
+		current := s.Services[name]
+
+		for status, count := range current.Requests {
+			dp := requestsSum.DataPoints().AppendEmpty()
+			dp.Attributes().PutStr("service", name)
+			dp.Attributes().PutInt("http.response.status_code", int64(status))
+			dp.SetStartTimestamp(start)
+			dp.SetTimestamp(timestamp)
+			dp.SetIntValue(int64(count))
+		}
+
+		dp := bytesSum.DataPoints().AppendEmpty()
+		dp.Attributes().PutStr("service", name)
+		dp.SetStartTimestamp(start)
+		dp.SetTimestamp(timestamp)
+		dp.SetIntValue(int64(current.Bytes))
+
+		hp := histogram.DataPoints().AppendEmpty()
+		hp.Attributes().PutStr("service", name)
+		hp.SetStartTimestamp(start)
+		hp.SetTimestamp(timestamp)
+		hp.SetCount(current.LatencyCount)
+		hp.SetSum(current.LatencySum)
+		hp.ExplicitBounds().FromRaw(LATENCY_BUCKETS)
+		hp.BucketCounts().FromRaw(current.LatencyBuckets)
+
+		delta := current.Since(e.previous[name])
+		total, errors := delta.Totals()
+
+		gp := rateGauge.DataPoints().AppendEmpty()
+		gp.Attributes().PutStr("service", name)
+		gp.SetTimestamp(timestamp)
+		gp.SetDoubleValue(float64(total) / e.interval.Seconds())
+
+		ep := errorGauge.DataPoints().AppendEmpty()
+		ep.Attributes().PutStr("service", name)
+		ep.SetTimestamp(timestamp)
+		if total > 0 {
+			ep.SetDoubleValue(float64(errors) / float64(total))
+		} else {
+			ep.SetDoubleValue(0)
+		}
+
+		for _, q := range []float64{0.5, 0.95, 0.99} {
+			pp := percentileGauge.DataPoints().AppendEmpty()
+			pp.Attributes().PutStr("service", name)
+			pp.Attributes().PutDouble("quantile", q)
+			pp.SetTimestamp(timestamp)
+			pp.SetDoubleValue(delta.LatencyQuantile(q))
+		}
+	}
+
+	return md
+}
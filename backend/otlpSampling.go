@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+const OTLP_SAMPLE_PRECISION = 1 << 56 // Trace IDs are sampled on their rightmost 56 bits
+
+type OTLPSamplingConfig struct {
+	Percent   float64    `json:"percent"`   // Successful traces kept, 0-100; 100 keeps all
+	DropRules []DropRule `json:"dropRules"` // Same rules as INGEST_DROP_RULES, for OTLP data only
+}
+
+// Decides which OTLP spans and log records enter the pipeline at all, so
+// high-volume tracing doesn't crowd out the access logs. Records matching a
+// drop rule (health check routes) are discarded. Of the rest, errors (an
+// error span status or a status >= 400) are always kept and a percentage
+// of the others by trace ID, so a trace is kept or dropped as a whole.
+// Unlike INGEST_SAMPLE_RATE, what is dropped here never reaches stats.
+type OTLPSampler struct {
+	mu        sync.Mutex
+	config    OTLPSamplingConfig
+	threshold atomic.Uint64 // Keep trace IDs below; OTLP_SAMPLE_PRECISION keeps all
+	dropRules atomic.Pointer[[]compiledDropRule]
+	sampled   atomic.Int64
+	filtered  atomic.Int64
+}
+
+var otlpSampler = newOTLPSampler()
+
+func newOTLPSampler() *OTLPSampler {
+	s := &OTLPSampler{config: OTLPSamplingConfig{Percent: 100}}
+	s.threshold.Store(OTLP_SAMPLE_PRECISION)
+	return s
+}
+
+// Read the OTLP sampling settings from OTLP_SAMPLE_PERCENT and
+// OTLP_DROP_RULES (a JSON array of drop rules)
+func GetOTLPSamplingConfigFromEnv() (OTLPSamplingConfig, error) {
+	config := OTLPSamplingConfig{Percent: 100}
+	if value := os.Getenv("OTLP_SAMPLE_PERCENT"); value != "" {
+		percent, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return config, fmt.Errorf("OTLP_SAMPLE_PERCENT must be a number between 0 and 100")
+		}
+		config.Percent = percent
+	}
+	if value := os.Getenv("OTLP_DROP_RULES"); value != "" {
+		if err := json.Unmarshal([]byte(value), &config.DropRules); err != nil {
+			return config, fmt.Errorf("OTLP_DROP_RULES must be a JSON array of rules: %w", err)
+		}
+	}
+	return config, config.Validate()
+}
+
+func (c OTLPSamplingConfig) Validate() error {
+	if c.Percent < 0 || c.Percent > 100 {
+		return fmt.Errorf("OTLP sample percent must be between 0 and 100")
+	}
+	if _, err := compileDropRules(c.DropRules); err != nil {
+		return fmt.Errorf("invalid OTLP drop rules: %w", err)
+	}
+	return nil
+}
+
+func (s *OTLPSampler) Config() OTLPSamplingConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config
+}
+
+func (s *OTLPSampler) SetConfig(config OTLPSamplingConfig) error {
+	rules, err := compileDropRules(config.DropRules)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+	s.dropRules.Store(&rules)
+	s.threshold.Store(uint64(config.Percent / 100 * OTLP_SAMPLE_PRECISION))
+	return nil
+}
+
+// Check whether an entry converted from OTLP should be processed, counting
+// it as dropped if not
+func (s *OTLPSampler) Keep(entry *LogEntry) bool {
+	if rules := s.dropRules.Load(); rules != nil {
+		for i := range *rules {
+			if (*rules)[i].matches(entry) {
+				s.filtered.Add(1)
+				return false
+			}
+		}
+	}
+	if entry.Status >= 400 || (entry.Span != nil && entry.Span.Status == "Error") {
+		return true
+	}
+
+	threshold := s.threshold.Load()
+	if threshold >= OTLP_SAMPLE_PRECISION {
+		return true
+	}
+	if traceSampleValue(entry.TraceId) < threshold {
+		return true
+	}
+	s.sampled.Add(1)
+	return false
+}
+
+// Where a trace falls in the sampling range: the rightmost 56 bits of its ID,
+// random in W3C trace IDs. Records without a usable ID get a random value.
+func traceSampleValue(traceId string) uint64 {
+	if len(traceId) == 32 {
+		if value, err := strconv.ParseUint(traceId[18:], 16, 64); err == nil {
+			return value
+		}
+	}
+	return uint64(rand.Int63n(OTLP_SAMPLE_PRECISION))
+}
+
+// OTLP records dropped so far by reason
+func (s *OTLPSampler) Dropped() map[string]int64 {
+	return map[string]int64{
+		INGEST_DROP_SAMPLED:  s.sampled.Load(),
+		INGEST_DROP_FILTERED: s.filtered.Load(),
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DEFAULT_PARSE_ERROR_SAMPLES      = 20
+	PARSE_ERROR_SAMPLE_MAX_LENGTH    = 2048
+	PARSE_ERROR_BROADCAST_PER_SECOND = 20 // Keeps a source full of garbage from flooding debug clients
+)
+
+// Rejected lines of one source: totals by reason and the latest samples
+type ParseErrorReport struct {
+	Source    string            `json:"source"`
+	Total     uint64            `json:"total"`
+	Reasons   map[string]uint64 `json:"reasons"`
+	LastError string            `json:"lastError,omitempty"`
+	Samples   []RawLine         `json:"samples"` // Newest first
+}
+
+// Counts and samples lines the parser rejects, per source
+type ParseErrorTracker struct {
+	mu          sync.Mutex
+	sources     map[string]*ParseErrorReport
+	sampleSize  int
+	windowStart time.Time
+	windowCount int
+	rate        rateCounter // Rejected lines across all sources
+}
+
+var parseErrorTracker = &ParseErrorTracker{
+	sources:    make(map[string]*ParseErrorReport),
+	sampleSize: DEFAULT_PARSE_ERROR_SAMPLES,
+}
+
+// Group reasons by their kind, dropping per-line details ("invalid JSON: ...")
+func parseErrorKind(reason string) string {
+	kind, _, _ := strings.Cut(reason, ":")
+	return kind
+}
+
+func (t *ParseErrorTracker) SetSampleSize(size int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if size > 0 {
+		t.sampleSize = size
+	}
+}
+
+// Record a rejected line; returns whether it should be broadcast to debug
+// clients under the rate cap
+func (t *ParseErrorTracker) Record(source, line, reason string) (RawLine, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) > PARSE_ERROR_SAMPLE_MAX_LENGTH {
+		line = line[:PARSE_ERROR_SAMPLE_MAX_LENGTH]
+	}
+	now := time.Now()
+	sample := RawLine{
+		Source: source,
+		Line:   line,
+		Reason: reason,
+		Time:   now.Format(time.RFC3339Nano),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report, ok := t.sources[source]
+	if !ok {
+		report = &ParseErrorReport{Source: source, Reasons: make(map[string]uint64)}
+		t.sources[source] = report
+	}
+	report.Total++
+	t.rate.Add(1)
+	report.Reasons[parseErrorKind(reason)]++
+	report.LastError = sample.Time
+	report.Samples = append([]RawLine{sample}, report.Samples...)
+	if len(report.Samples) > t.sampleSize {
+		report.Samples = report.Samples[:t.sampleSize]
+	}
+
+	if now.Sub(t.windowStart) >= time.Second {
+		t.windowStart = now
+		t.windowCount = 0
+	}
+	if t.windowCount >= PARSE_ERROR_BROADCAST_PER_SECOND {
+		return sample, false
+	}
+	t.windowCount++
+	return sample, true
+}
+
+// Get a copy of a source's report; sources without errors get an empty one
+func (t *ParseErrorTracker) Report(source string) ParseErrorReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report, ok := t.sources[source]
+	if !ok {
+		return ParseErrorReport{Source: source, Reasons: map[string]uint64{}, Samples: []RawLine{}}
+	}
+	reasons := make(map[string]uint64, len(report.Reasons))
+	for reason, count := range report.Reasons {
+		reasons[reason] = count
+	}
+	samples := make([]RawLine, len(report.Samples))
+	copy(samples, report.Samples)
+	return ParseErrorReport{
+		Source:    report.Source,
+		Total:     report.Total,
+		Reasons:   reasons,
+		LastError: report.LastError,
+		Samples:   samples,
+	}
+}
+
+// Forget a source's errors, e.g. after it was reset
+func (t *ParseErrorTracker) Reset(source string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.sources, source)
+}
+
+// Stream a rejected line to clients subscribed to the parse error channel
+func broadcastParseError(sample RawLine) {
+	tenant := tenancy.TenantForSource(sample.Source)
+
+	wsClientsMux.RLock()
+	clientList := make([]*WebSocketClient, 0, len(wsClients))
+	for client := range wsClients {
+		if client.IsHealthy() && (client.tenant == "" || client.tenant == tenant) {
+			clientList = append(clientList, client)
+		}
+	}
+	wsClientsMux.RUnlock()
+
+	for _, client := range clientList {
+		client.SendEvent(WS_CHANNEL_PARSE_ERRORS, "parseError", sample)
+	}
+}
+
+func getSourceErrors(c *gin.Context) {
+	status := findSourceStatus(c.Param("id"))
+	if status == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown source"})
+		return
+	}
+	if tenant := requestTenant(c); tenant != "" && status.Tenant != tenant {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown source"})
+		return
+	}
+
+	report := parseErrorTracker.Report(status.Path)
+	c.JSON(http.StatusOK, gin.H{
+		"id":          status.ID,
+		"path":        status.Path,
+		"linesParsed": status.LinesParsed,
+		"parseErrors": status.ParseErrors,
+		"total":       report.Total,
+		"reasons":     report.Reasons,
+		"lastError":   report.LastError,
+		"samples":     report.Samples,
+	})
+}
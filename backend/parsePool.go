@@ -0,0 +1,94 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+)
+
+const (
+	PARSE_BATCH_SIZE     = 1000 // Lines read before a batch is handed to the pool
+	PARSE_POOL_MIN_CHUNK = 64   // Smaller batches are decoded by the caller
+)
+
+// A line decoded by decodeLine: the entry, or why the line was rejected
+type decodedLine struct {
+	entry  *LogEntry
+	reason string
+}
+
+// Bounded pool of workers that decode log lines. Readers hand it batches and
+// get the results back in input order, so the ordered part of parsing
+// (sequencing, storing, broadcasting) still runs serially per source while
+// JSON decoding for all sources shares every core.
+type ParsePool struct {
+	workers int
+	jobs    chan parseJob
+}
+
+type parseJob struct {
+	lp      *LogParser
+	source  string
+	lines   []string
+	results []decodedLine
+	done    *sync.WaitGroup
+}
+
+// Shared by every source; nil decodes on the calling goroutine
+var parsePool *ParsePool
+
+// Create the pool from PARSE_WORKERS (default: one per CPU); nil when set to 1
+func NewParsePool() *ParsePool {
+	workers := GetEnvInt("PARSE_WORKERS", runtime.NumCPU())
+	if workers <= 1 {
+		return nil
+	}
+	p := &ParsePool{workers: workers, jobs: make(chan parseJob, workers)}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	parserLog.Info("Parsing log lines in parallel", "workers", workers)
+	return p
+}
+
+func (p *ParsePool) work() {
+	for job := range p.jobs {
+		for i, line := range job.lines {
+			job.results[i] = job.lp.decodeLine(job.source, line)
+		}
+		job.done.Done()
+	}
+}
+
+// Decode lines, split into chunks across the workers; results are in the
+// same order as lines
+func (p *ParsePool) Decode(lp *LogParser, source string, lines []string) []decodedLine {
+	results := make([]decodedLine, len(lines))
+	if p == nil || len(lines) < 2*PARSE_POOL_MIN_CHUNK {
+		for i, line := range lines {
+			results[i] = lp.decodeLine(source, line)
+		}
+		return results
+	}
+
+	chunk := max((len(lines)+p.workers-1)/p.workers, PARSE_POOL_MIN_CHUNK)
+	var done sync.WaitGroup
+	for start := 0; start < len(lines); start += chunk {
+		end := min(start+chunk, len(lines))
+		done.Add(1)
+		p.jobs <- parseJob{lp: lp, source: source, lines: lines[start:end], results: results[start:end], done: &done}
+	}
+	done.Wait()
+	return results
+}
+
+// Drop blank lines, reusing the slice
+func nonEmptyLines(lines []string) []string {
+	kept := lines[:0]
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
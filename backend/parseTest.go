@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DEFAULT_PARSE_TEST_SOURCE = "parse-test"
+	PARSE_TEST_MAX_BYTES      = 1 << 20
+	PARSE_TEST_MAX_LINES      = 1000
+)
+
+// Traefik's default access log format, which the dashboard can't read
+var commonLogFormat = regexp.MustCompile(`^\S+ \S+ \S+ \[[^\]]+\] "`)
+
+type ParseTestRequest struct {
+	Lines  []string `json:"lines"`
+	Source string   `json:"source"` // Source name used for tenant mapping
+}
+
+type ParseTestResult struct {
+	Line     int       `json:"line"`
+	Accepted bool      `json:"accepted"`
+	Entry    *LogEntry `json:"entry,omitempty"`
+	Reason   string    `json:"reason,omitempty"`   // As recorded for the source's parse errors
+	Details  []string  `json:"details,omitempty"`  // What is wrong with a rejected line
+	Warnings []string  `json:"warnings,omitempty"` // Defaults filled in for an accepted line
+}
+
+type ParseTestResponse struct {
+	Source   string            `json:"source"`
+	Accepted int               `json:"accepted"`
+	Rejected int               `json:"rejected"`
+	Results  []ParseTestResult `json:"results"`
+}
+
+// Decode lines the way a watched file's are, without storing, counting or
+// broadcasting anything
+func (lp *LogParser) ParseTest(source string, lines []string) ParseTestResponse {
+	response := ParseTestResponse{Source: source, Results: make([]ParseTestResult, 0, len(lines))}
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		result := ParseTestResult{Line: i + 1}
+		decoded := lp.decodeLine(source, line)
+		if decoded.entry == nil {
+			response.Rejected++
+			result.Reason = decoded.reason
+			result.Details = explainRejection(line)
+		} else {
+			response.Accepted++
+			entry := *decoded.entry
+			putLogEntry(decoded.entry)
+			result.Accepted = true
+			result.Entry = &entry
+			result.Warnings = parseTestWarnings(line, &entry)
+		}
+		response.Results = append(response.Results, result)
+	}
+	return response
+}
+
+func explainRejection(line string) []string {
+	line = strings.TrimSpace(line)
+	var raw RawLogEntry
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		var details []string
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			details = append(details, fmt.Sprintf("JSON syntax error at byte %d", syntax.Offset))
+		}
+		switch {
+		case commonLogFormat.MatchString(line):
+			details = append(details, "this looks like Common Log Format; set Traefik's accessLog.format to json")
+		case !strings.HasPrefix(line, "{"):
+			details = append(details, "each line must be one JSON object")
+		}
+		return details
+	}
+
+	var details []string
+	if !raw.Time.present {
+		details = append(details, `the "time" field is missing`)
+	}
+	if !raw.DownstreamStatus.present && !raw.RequestMethod.present {
+		if raw.Level.isString {
+			details = append(details, fmt.Sprintf("lines without DownstreamStatus or RequestMethod are only kept at error or warn level, got %q", raw.Level.str))
+		} else {
+			details = append(details, "neither DownstreamStatus nor RequestMethod is present; is this Traefik's own log rather than its access log?")
+		}
+	}
+	return details
+}
+
+// Fields that were missing or unusable and what was used instead
+func parseTestWarnings(line string, entry *LogEntry) []string {
+	var raw RawLogEntry
+	if json.Unmarshal([]byte(strings.TrimSpace(line)), &raw) != nil {
+		return nil
+	}
+	if !raw.DownstreamStatus.present && !raw.RequestMethod.present {
+		return nil // A Traefik error/warn line; the request fields don't apply
+	}
+
+	var warnings []string
+	if !raw.Time.isString {
+		warnings = append(warnings, `"time" is not a string; the time it was read is used`)
+	} else if _, err := time.Parse(time.RFC3339, raw.Time.str); err != nil {
+		warnings = append(warnings, fmt.Sprintf(`"time" %q is not RFC 3339; time filters and timelines ignore the entry`, raw.Time.str))
+	}
+	if !raw.RequestMethod.present {
+		warnings = append(warnings, "RequestMethod is missing; GET is assumed")
+	}
+	if !raw.DownstreamStatus.present {
+		warnings = append(warnings, "DownstreamStatus is missing; the status is 0")
+	}
+	if !raw.Duration.present {
+		warnings = append(warnings, "Duration is missing; the response time is 0")
+	}
+	if entry.ClientIP == "unknown" {
+		warnings = append(warnings, "ClientAddr is missing; the client IP is unknown")
+	} else if _, ok := parseClientAddr(entry.ClientIP); !entry.clientAddr.IsValid() && !ok {
+		warnings = append(warnings, fmt.Sprintf("ClientAddr %q is not an IP address; it can't be geolocated", raw.ClientAddr.String("")))
+	}
+	if !raw.ServiceName.present {
+		warnings = append(warnings, "ServiceName is missing; the service is unknown")
+	}
+	if !raw.RouterName.present {
+		warnings = append(warnings, "RouterName is missing; the router is unknown")
+	}
+	if rule, ok := ingestControl.MatchDropRule(entry); ok {
+		warnings = append(warnings, fmt.Sprintf("matches drop rule %q; it would not be stored or counted", rule))
+	}
+	return warnings
+}
+
+// Dry-run parse of log lines sent as {"lines":[...],"source":"..."} or as
+// a plain text body, one line each (?source= names the source)
+func parseTest(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, PARSE_TEST_MAX_BYTES)
+	request := ParseTestRequest{Source: c.Query("source")}
+
+	if strings.HasPrefix(c.ContentType(), "application/json") {
+		if err := json.NewDecoder(c.Request.Body).Decode(&request); err != nil {
+			c.JSON(parseTestBodyStatus(err), gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+	} else {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(parseTestBodyStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		request.Lines = strings.Split(strings.TrimRight(string(body), "\r\n"), "\n")
+	}
+
+	if len(request.Lines) > PARSE_TEST_MAX_LINES {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("At most %d lines can be tested at once", PARSE_TEST_MAX_LINES)})
+		return
+	}
+	if request.Source = strings.TrimSpace(request.Source); request.Source == "" {
+		request.Source = DEFAULT_PARSE_TEST_SOURCE
+	}
+	c.JSON(http.StatusOK, logParser.ParseTest(request.Source, request.Lines))
+}
+
+func parseTestBodyStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
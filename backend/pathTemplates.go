@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// A path grouping rule: every match of Pattern in a path is replaced by
+// Template, which can refer to capture groups ($1)
+type PathTemplateRule struct {
+	Pattern  string `json:"pattern"`
+	Template string `json:"template"`
+}
+
+type PathTemplateConfig struct {
+	Rules []PathTemplateRule `json:"rules"`
+	Auto  bool               `json:"auto"` // Collapse numeric, UUID and hash segments
+}
+
+type compiledPathRule struct {
+	pattern  *regexp.Regexp
+	template string
+}
+
+// Groups request paths into endpoints (/users/42/orders becomes
+// /users/:id/orders) for the per-path stats and alert summaries, which
+// would otherwise get a key per ID. Entries keep their raw path; the
+// template is stored beside it.
+type PathTemplater struct {
+	rules []compiledPathRule
+	auto  bool
+}
+
+var pathTemplater atomic.Pointer[PathTemplater]
+
+// Read PATH_TEMPLATE_RULES (a JSON array of rules, applied in order) and
+// PATH_AUTO_TEMPLATE
+func GetPathTemplateConfigFromEnv() (PathTemplateConfig, error) {
+	config := PathTemplateConfig{Auto: GetEnvBool("PATH_AUTO_TEMPLATE", true)}
+	if value := os.Getenv("PATH_TEMPLATE_RULES"); value != "" {
+		if err := json.Unmarshal([]byte(value), &config.Rules); err != nil {
+			return config, fmt.Errorf("PATH_TEMPLATE_RULES must be a JSON array of rules: %w", err)
+		}
+	}
+	_, err := NewPathTemplater(config)
+	return config, err
+}
+
+func NewPathTemplater(config PathTemplateConfig) (*PathTemplater, error) {
+	t := &PathTemplater{auto: config.Auto}
+	for i, rule := range config.Rules {
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("path template rule %d has no pattern", i+1)
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("path template rule %d: invalid pattern: %w", i+1, err)
+		}
+		t.rules = append(t.rules, compiledPathRule{pattern: pattern, template: rule.Template})
+	}
+	return t, nil
+}
+
+func SetPathTemplateConfig(config PathTemplateConfig) error {
+	t, err := NewPathTemplater(config)
+	if err != nil {
+		return err
+	}
+	pathTemplater.Store(t)
+	return nil
+}
+
+// The endpoint a request path belongs to: the path without its query,
+// rewritten by the rules and then by automatic collapsing
+func (t *PathTemplater) Template(path string) string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	for _, rule := range t.rules {
+		if rule.pattern.MatchString(path) {
+			path = rule.pattern.ReplaceAllString(path, rule.template)
+		}
+	}
+	if t.auto {
+		path = collapsePathSegments(path)
+	}
+	return path
+}
+
+// Replace path segments that look like identifiers with placeholders:
+// numbers with :id, UUIDs with :uuid, long hex strings (hashes, object
+// IDs) with :hash. Paths without any are returned as they are.
+func collapsePathSegments(path string) string {
+	var sb strings.Builder
+	last := 0 // End of the part of path already copied to sb
+	for start := 0; start < len(path); {
+		end := strings.IndexByte(path[start:], '/')
+		if end < 0 {
+			end = len(path)
+		} else {
+			end += start
+		}
+		if placeholder := segmentPlaceholder(path[start:end]); placeholder != "" {
+			if sb.Len() == 0 {
+				sb.Grow(len(path))
+			}
+			sb.WriteString(path[last:start])
+			sb.WriteString(placeholder)
+			last = end
+		}
+		start = end + 1
+	}
+	if last == 0 {
+		return path
+	}
+	sb.WriteString(path[last:])
+	return sb.String()
+}
+
+func segmentPlaceholder(segment string) string {
+	if segment == "" {
+		return ""
+	}
+	digits, hex := 0, 0
+	for i := 0; i < len(segment); i++ {
+		switch c := segment[i]; {
+		case c >= '0' && c <= '9':
+			digits++
+			hex++
+		case (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F'):
+			hex++
+		case c == '-' && len(segment) == 36 && (i == 8 || i == 13 || i == 18 || i == 23):
+		default:
+			return ""
+		}
+	}
+	switch {
+	case digits == len(segment):
+		return ":id"
+	case len(segment) == 36 && hex == 32:
+		return ":uuid"
+	case hex == len(segment) && len(segment) >= 16 && digits > 0:
+		return ":hash"
+	}
+	return ""
+}
+
+// The template of a path under the current rules
+func templatePath(path string) string {
+	if t := pathTemplater.Load(); t != nil {
+		return t.Template(path)
+	}
+	return path
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ntfy priorities (1-5) and tags (emoji shortcodes) by severity
+var (
+	ntfyPriorities = map[string]int{SEVERITY_CRITICAL: 5, SEVERITY_WARNING: 4, SEVERITY_INFO: 3}
+	ntfyTags       = map[string]string{SEVERITY_CRITICAL: "rotating_light", SEVERITY_WARNING: "warning", SEVERITY_INFO: "white_check_mark"}
+)
+
+// Gotify priorities (0-10) by severity; 8+ typically triggers a loud alert
+var gotifyPriorities = map[string]int{SEVERITY_CRITICAL: 8, SEVERITY_WARNING: 5, SEVERITY_INFO: 2}
+
+// Publishes notifications to an ntfy topic (ntfy.sh or self-hosted)
+type NtfyNotifier struct {
+	topicURL string
+	token    string
+	client   *http.Client
+}
+
+// Create the notifier from NTFY_URL (full topic URL) and optional NTFY_TOKEN;
+// nil when unset
+func NewNtfyNotifier() (*NtfyNotifier, error) {
+	topicURL := os.Getenv("NTFY_URL")
+	if topicURL == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(topicURL, "http://") && !strings.HasPrefix(topicURL, "https://") {
+		return nil, fmt.Errorf("invalid NTFY_URL: %s", redactURL(topicURL))
+	}
+	return &NtfyNotifier{
+		topicURL: strings.TrimRight(topicURL, "/"),
+		token:    os.Getenv("NTFY_TOKEN"),
+		client:   &http.Client{},
+	}, nil
+}
+
+func (n *NtfyNotifier) Name() string {
+	return "ntfy " + redactURL(n.topicURL)
+}
+
+func (n *NtfyNotifier) Send(ctx context.Context, notification Notification) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.topicURL, strings.NewReader(formatPlainText(notification)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", notification.Title)
+	req.Header.Set("Priority", strconv.Itoa(ntfyPriorities[notification.Severity]))
+	if tag := ntfyTags[notification.Severity]; tag != "" {
+		req.Header.Set("Tags", tag)
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+	return doNotifyRequest(n.client, req)
+}
+
+// Pushes notifications to a Gotify server as an application message
+type GotifyNotifier struct {
+	serverURL string
+	token     string
+	client    *http.Client
+}
+
+// Create the notifier from GOTIFY_URL and GOTIFY_TOKEN (an application
+// token); nil when unset
+func NewGotifyNotifier() (*GotifyNotifier, error) {
+	serverURL := os.Getenv("GOTIFY_URL")
+	token := os.Getenv("GOTIFY_TOKEN")
+	if serverURL == "" && token == "" {
+		return nil, nil
+	}
+	if serverURL == "" || token == "" {
+		return nil, fmt.Errorf("GOTIFY_URL and GOTIFY_TOKEN must both be set")
+	}
+	if !strings.HasPrefix(serverURL, "http://") && !strings.HasPrefix(serverURL, "https://") {
+		return nil, fmt.Errorf("invalid GOTIFY_URL: %s", redactURL(serverURL))
+	}
+	return &GotifyNotifier{
+		serverURL: strings.TrimRight(serverURL, "/"),
+		token:     token,
+		client:    &http.Client{},
+	}, nil
+}
+
+func (g *GotifyNotifier) Name() string {
+	return "gotify " + redactURL(g.serverURL)
+}
+
+func (g *GotifyNotifier) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"title":    n.Title,
+		"message":  formatPlainText(n),
+		"priority": gotifyPriorities[n.Severity],
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.serverURL+"/message", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", g.token)
+	return doNotifyRequest(g.client, req)
+}
+
+// Render a notification as plain text for push services that show a title
+// separately
+func formatPlainText(n Notification) string {
+	var sb strings.Builder
+	sb.WriteString(n.Message)
+	if n.Service != "" {
+		fmt.Fprintf(&sb, "\nService: %s", n.Service)
+	}
+	if n.Tenant != "" {
+		fmt.Fprintf(&sb, "\nTenant: %s", n.Tenant)
+	}
+	if paths, ok := n.Details["topPaths"].([]PathErrorCount); ok && len(paths) > 0 {
+		sb.WriteString("\nTop paths:")
+		for _, p := range paths {
+			fmt.Fprintf(&sb, "\n- %s (%d)", truncate(p.Path, 80), p.Errors)
+		}
+	}
+	if file, ok := n.Details["file"]; ok {
+		fmt.Fprintf(&sb, "\nFile: %v", file)
+	}
+	return sb.String()
+}
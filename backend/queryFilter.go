@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A single term of the filter query DSL, e.g. "service:api", "-status:5xx", "path:/admin*"
+type queryTerm struct {
+	field  string
+	value  string
+	negate bool
+}
+
+var queryFields = map[string]bool{
+	"service": true,
+	"router":  true,
+	"status":  true,
+	"method":  true,
+	"host":    true,
+	"path":    true,
+	"ip":      true,
+	"country": true,
+	"source":  true,
+}
+
+// Parse a query like `service:api status:5xx -path:/health*`. Terms are
+// ANDed; a leading "-" negates a term and bare words match the path.
+// Captured headers are fields too: `request_x-request-id:abc*`.
+func parseQuery(query string) ([]queryTerm, error) {
+	var terms []queryTerm
+	for _, token := range strings.Fields(query) {
+		term := queryTerm{}
+		if strings.HasPrefix(token, "-") && len(token) > 1 {
+			term.negate = true
+			token = token[1:]
+		}
+
+		if idx := strings.Index(token, ":"); idx > 0 {
+			term.field = strings.ToLower(token[:idx])
+			term.value = token[idx+1:]
+		} else {
+			term.field = "path"
+			term.value = "*" + token + "*"
+		}
+
+		if !queryFields[term.field] && !isHeaderField(term.field) {
+			return nil, fmt.Errorf("unknown query field: %s", term.field)
+		}
+		if term.value == "" {
+			return nil, fmt.Errorf("empty value for query field: %s", term.field)
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// Check whether a log entry satisfies every term
+func matchesQuery(log *LogEntry, terms []queryTerm) bool {
+	for _, term := range terms {
+		if term.matches(log) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (t queryTerm) matches(log *LogEntry) bool {
+	switch t.field {
+	case "service":
+		return matchPattern(t.value, log.ServiceName) || (log.ServiceRaw != "" && matchPattern(t.value, log.ServiceRaw))
+	case "router":
+		return matchPattern(t.value, log.RouterName) || (log.RouterRaw != "" && matchPattern(t.value, log.RouterRaw))
+	case "status":
+		return matchStatus(t.value, log.Status)
+	case "method":
+		return strings.EqualFold(t.value, log.Method)
+	case "host":
+		return matchPattern(t.value, log.RequestHost)
+	case "path":
+		return matchPattern(t.value, log.Path)
+	case "ip":
+		return matchPattern(t.value, log.ClientIP)
+	case "country":
+		if log.Country == nil {
+			return false
+		}
+		return strings.EqualFold(t.value, *log.Country) ||
+			(log.CountryCode != nil && strings.EqualFold(t.value, *log.CountryCode))
+	case "source":
+		return t.value == log.DataSource
+	}
+	if value, ok := headerValue(log, t.field); ok {
+		return matchPattern(t.value, value)
+	}
+	return false
+}
+
+// Match a value against an exact string or a wildcard pattern where "*"
+// matches any run of characters and "?" matches one character
+func matchPattern(pattern, value string) bool {
+	if !strings.ContainsAny(pattern, "*?") {
+		return pattern == value
+	}
+
+	p, v := 0, 0
+	starP, starV := -1, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case p < len(pattern) && pattern[p] == '*':
+			starP, starV = p, v
+			p++
+		case starP >= 0:
+			starV++
+			p, v = starP+1, starV
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// Match a status code against an exact code ("404") or a class ("5xx")
+func matchStatus(value string, status int) bool {
+	if class, ok := parseStatusClass(value); ok {
+		return status/100 == class
+	}
+	code, err := strconv.Atoi(value)
+	return err == nil && code == status
+}
+
+// Parse a status class like "5xx" into its leading digit
+func parseStatusClass(value string) (int, bool) {
+	value = strings.ToLower(value)
+	if len(value) != 3 || !strings.HasSuffix(value, "xx") || value[0] < '1' || value[0] > '5' {
+		return 0, false
+	}
+	return int(value[0] - '0'), true
+}
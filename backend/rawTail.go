@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// A raw log line as read from a source, with the parser's verdict. Streamed
+// to debug clients to diagnose why lines are rejected.
+type RawLine struct {
+	Source   string `json:"source"`
+	Line     string `json:"line"`
+	Accepted bool   `json:"accepted"`
+	Reason   string `json:"reason,omitempty"`
+	Time     string `json:"time"`
+}
+
+func (lp *LogParser) AddRawListener(ch chan RawLine) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.rawListeners = append(lp.rawListeners, ch)
+}
+
+func (lp *LogParser) RemoveRawListener(ch chan RawLine) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	for i, listener := range lp.rawListeners {
+		if listener == ch {
+			lp.rawListeners = append(lp.rawListeners[:i], lp.rawListeners[i+1:]...)
+			break
+		}
+	}
+}
+
+func (lp *LogParser) notifyRawListeners(source, line string, accepted bool, reason string) {
+	lp.mu.RLock()
+	if len(lp.rawListeners) == 0 {
+		lp.mu.RUnlock()
+		return
+	}
+	listeners := make([]chan RawLine, len(lp.rawListeners))
+	copy(listeners, lp.rawListeners)
+	lp.mu.RUnlock()
+
+	rawLine := RawLine{
+		Source:   source,
+		Line:     strings.TrimRight(line, "\r\n"),
+		Accepted: accepted,
+		Reason:   reason,
+		Time:     time.Now().Format(time.RFC3339Nano),
+	}
+	for _, listener := range listeners {
+		select {
+		case listener <- rawLine:
+		default:
+			// Don't block parsing on a slow debug client
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import "time"
+
+const (
+	DEFAULT_GEO_TRACKED_IPS = 50000
+	GEO_TRACKED_IPS_MAX_AGE = time.Hour
+)
+
+// Set of recently seen keys with bounded memory. Keys are held in two
+// generations: when the current one reaches the limit or maxAge, the
+// previous generation is dropped wholesale and the current one takes its
+// place. Keys seen again are carried into the current generation, so only
+// keys that went a whole generation unseen are forgotten, and at most
+// 2*limit keys are held. Not safe for concurrent use.
+type RecentSet struct {
+	current  map[string]struct{}
+	previous map[string]struct{}
+	limit    int
+	maxAge   time.Duration
+	rotated  time.Time
+}
+
+func NewRecentSet(limit int, maxAge time.Duration) *RecentSet {
+	s := &RecentSet{limit: max(limit, 1), maxAge: maxAge}
+	s.Reset()
+	return s
+}
+
+// Add a key; returns false if it was already in the set
+func (s *RecentSet) Add(key string) bool {
+	if _, ok := s.current[key]; ok {
+		return false
+	}
+	_, seen := s.previous[key]
+
+	if len(s.current) >= s.limit || (s.maxAge > 0 && time.Since(s.rotated) >= s.maxAge) {
+		s.previous = s.current
+		s.current = make(map[string]struct{}, len(s.previous))
+		s.rotated = time.Now()
+	}
+	s.current[key] = struct{}{}
+	return !seen
+}
+
+// Count the keys held, including the previous generation
+func (s *RecentSet) Len() int {
+	return len(s.current) + len(s.previous)
+}
+
+func (s *RecentSet) Reset() {
+	s.current = make(map[string]struct{})
+	s.previous = make(map[string]struct{})
+	s.rotated = time.Now()
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_REMOTE_LOG_POLL_INTERVAL = 2 * time.Second
+	REMOTE_LOG_INITIAL_BYTES         = 256 * 1024
+	REMOTE_LOG_MAX_READ_BYTES        = 4 * 1024 * 1024 // Per poll, so a large backlog is caught up gradually
+
+	SOURCE_STATE_UNREACHABLE = "unreachable"
+)
+
+// Check whether a log source is a URL rather than a local path
+func isRemoteSource(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// Tails a log file served over HTTP(S), e.g. by a file-server sidecar, using
+// Range requests. HEAD polling with the ETag finds new data cheaply; a file
+// that got smaller was rotated or truncated and is read from the start.
+type RemoteTailer struct {
+	url      string
+	name     string // URL without credentials, used in logs, status and as the entry source
+	parser   *LogParser
+	client   *http.Client
+	interval time.Duration
+	stop     chan struct{}
+
+	mu          sync.Mutex
+	offset      int64
+	size        int64
+	etag        string
+	partial     string
+	discarding  bool // Skipping the rest of a line over MAX_LOG_LINE_BYTES
+	state       string
+	lastError   string
+	lastRead    time.Time
+	linesParsed uint64
+	parseErrors uint64
+}
+
+func NewRemoteTailer(rawURL string, parser *LogParser) *RemoteTailer {
+	interval := time.Duration(GetEnvInt("REMOTE_LOG_POLL_INTERVAL_SECONDS", int(DEFAULT_REMOTE_LOG_POLL_INTERVAL/time.Second))) * time.Second
+	if interval < time.Second {
+		interval = DEFAULT_REMOTE_LOG_POLL_INTERVAL
+	}
+	name := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		name = u.Redacted()
+	}
+	return &RemoteTailer{
+		url:      rawURL,
+		name:     name,
+		parser:   parser,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		interval: interval,
+		stop:     make(chan struct{}),
+		state:    SOURCE_STATE_ACTIVE,
+	}
+}
+
+// Load the recent tail of the file, then poll for appended data
+func (rt *RemoteTailer) Start(initialLines int) {
+	if err := rt.loadRecent(initialLines); err != nil {
+		watcherLog.Error("Error loading recent logs", "source", rt.name, "error", err)
+		rt.setError(err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(rt.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := rt.poll(); err != nil {
+					rt.setError(err)
+				}
+			case <-rt.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (rt *RemoteTailer) Stop() {
+	close(rt.stop)
+}
+
+func (rt *RemoteTailer) setError(err error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.lastError != err.Error() {
+		watcherLog.Warn("Remote tail failed", "source", rt.name, "error", err)
+	}
+	rt.state = SOURCE_STATE_UNREACHABLE
+	rt.lastError = err.Error()
+}
+
+// Fetch the file's size and ETag; changed is false when the server answered
+// 304 to our previous ETag
+func (rt *RemoteTailer) head() (size int64, etag string, changed bool, err error) {
+	req, err := http.NewRequest(http.MethodHead, rt.url, nil)
+	if err != nil {
+		return 0, "", false, err
+	}
+	rt.mu.Lock()
+	if rt.etag != "" {
+		req.Header.Set("If-None-Match", rt.etag)
+	}
+	rt.mu.Unlock()
+
+	resp, err := rt.client.Do(req)
+	if err != nil {
+		return 0, "", false, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return 0, "", false, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return 0, "", false, fmt.Errorf("not found")
+	case resp.StatusCode != http.StatusOK:
+		return 0, "", false, fmt.Errorf("unexpected status %s", resp.Status)
+	case resp.ContentLength < 0:
+		return 0, "", false, fmt.Errorf("server did not report the file size")
+	}
+	return resp.ContentLength, resp.Header.Get("ETag"), true, nil
+}
+
+// Fetch bytes [start, end) of the file
+func (rt *RemoteTailer) fetch(start, end int64) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, rt.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+
+	resp, err := rt.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body io.Reader = resp.Body
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// Range unsupported: skip to the requested offset ourselves
+		if _, err := io.CopyN(io.Discard, resp.Body, start); err != nil {
+			return nil, err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(body, end-start))
+}
+
+func (rt *RemoteTailer) loadRecent(maxLines int) error {
+	size, etag, _, err := rt.head()
+	if err != nil {
+		return err
+	}
+
+	if maxLines == 0 {
+		rt.mu.Lock()
+		rt.offset, rt.size, rt.etag = size, size, etag
+		rt.mu.Unlock()
+		return nil
+	}
+
+	if maxLines == INITIAL_LOAD_ALL {
+		return rt.loadAll(size, etag)
+	}
+
+	// Deeper history needs a bigger window, assuming ~512 bytes per line
+	window := int64(REMOTE_LOG_INITIAL_BYTES)
+	if int64(maxLines)*512 > window {
+		window = int64(maxLines) * 512
+	}
+	start := size - window
+	if start < 0 {
+		start = 0
+	}
+	data, err := rt.fetch(start, size)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	if start > 0 && len(lines) > 0 {
+		lines = lines[1:] // Starts mid-line
+	}
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+	validLines := 0
+	for _, ok := range rt.parser.parseLines(rt.name, nonEmptyLines(lines), false) {
+		if ok {
+			validLines++
+		}
+	}
+	parserLog.Info("Loaded log entries", "source", rt.name, "accepted", validLines, "lines", len(lines))
+
+	rt.mu.Lock()
+	rt.offset = size
+	rt.size = size
+	rt.etag = etag
+	rt.mu.Unlock()
+	return nil
+}
+
+// Read the whole file in pieces of at most REMOTE_LOG_MAX_READ_BYTES, as
+// poll catches up on a backlog
+func (rt *RemoteTailer) loadAll(size int64, etag string) error {
+	validLines := 0
+	for offset := int64(0); offset < size; {
+		end := offset + REMOTE_LOG_MAX_READ_BYTES
+		if end > size {
+			end = size
+		}
+		data, err := rt.fetch(offset, end)
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			break // Got smaller meanwhile; poll will notice
+		}
+		validLines += rt.consume(data, false)
+		offset += int64(len(data))
+	}
+	parserLog.Info("Loaded log entries", "source", rt.name, "accepted", validLines, "bytes", size)
+
+	rt.mu.Lock()
+	rt.size = size
+	rt.etag = etag
+	rt.mu.Unlock()
+	return nil
+}
+
+func (rt *RemoteTailer) poll() error {
+	size, etag, changed, err := rt.head()
+	if err != nil {
+		return err
+	}
+
+	rt.mu.Lock()
+	rt.state = SOURCE_STATE_ACTIVE
+	rt.lastError = ""
+	if !changed {
+		rt.mu.Unlock()
+		return nil
+	}
+	if size < rt.offset {
+		watcherLog.Info("Remote log got smaller, reading from the beginning", "source", rt.name)
+		rt.offset = 0
+		rt.partial = ""
+		rt.discarding = false
+	}
+	rt.size = size
+	rt.etag = etag
+	offset := rt.offset
+	rt.mu.Unlock()
+
+	if size <= offset {
+		return nil
+	}
+	end := size
+	if end-offset > REMOTE_LOG_MAX_READ_BYTES {
+		end = offset + REMOTE_LOG_MAX_READ_BYTES
+		// Not caught up yet, so don't let the ETag hide the rest
+		rt.mu.Lock()
+		rt.etag = ""
+		rt.mu.Unlock()
+	}
+	data, err := rt.fetch(offset, end)
+	if err != nil {
+		return err
+	}
+	rt.consume(data, true)
+	return nil
+}
+
+// Parse complete lines, keeping a trailing fragment until its newline arrives
+// unless it is already too long to keep. Returns the number of lines accepted.
+func (rt *RemoteTailer) consume(data []byte, emit bool) int {
+	if len(data) == 0 {
+		return 0
+	}
+
+	rt.mu.Lock()
+	rt.offset += int64(len(data))
+	rt.lastRead = time.Now()
+	buffered := string(data)
+	if rt.discarding {
+		cut := strings.IndexByte(buffered, '\n')
+		if cut < 0 {
+			rt.mu.Unlock()
+			return 0
+		}
+		rt.discarding = false
+		buffered = buffered[cut+1:]
+	}
+	buffered = rt.partial + buffered
+	rt.partial = ""
+	cut := strings.LastIndexByte(buffered, '\n')
+	if tail := buffered[cut+1:]; len(tail) > MAX_LOG_LINE_BYTES {
+		rt.discardLongLineLocked(tail, false)
+	} else {
+		rt.partial = strings.Clone(tail) // Don't pin the whole read
+	}
+	if cut < 0 {
+		rt.mu.Unlock()
+		return 0
+	}
+
+	var lines []string
+	for _, line := range nonEmptyLines(strings.Split(buffered[:cut], "\n")) {
+		if len(line) > MAX_LOG_LINE_BYTES {
+			rt.discardLongLineLocked(line, true)
+			continue
+		}
+		lines = append(lines, line)
+	}
+	rt.mu.Unlock()
+
+	parsed := uint64(len(lines))
+	var rejected uint64
+	for _, ok := range rt.parser.parseLines(rt.name, lines, emit) {
+		if !ok {
+			rejected++
+		}
+	}
+
+	rt.mu.Lock()
+	rt.linesParsed += parsed
+	rt.parseErrors += rejected
+	rt.mu.Unlock()
+	return int(parsed - rejected)
+}
+
+// Drop a line over MAX_LOG_LINE_BYTES and count it as a parse error; an
+// incomplete line's rest is skipped up to its newline. Caller holds rt.mu.
+func (rt *RemoteTailer) discardLongLineLocked(line string, complete bool) {
+	rt.partial = ""
+	rt.discarding = !complete
+	rt.parseErrors++
+	watcherLog.Warn("Discarding overlong log line", "source", rt.name, "maxBytes", MAX_LOG_LINE_BYTES)
+	if sample, broadcast := parseErrorTracker.Record(rt.name, line, "line longer than 1 MiB"); broadcast {
+		broadcastParseError(sample)
+	}
+}
+
+func (rt *RemoteTailer) Status() SourceStatus {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	status := SourceStatus{
+		ID:          sourceID(rt.name),
+		Path:        rt.name,
+		Tenant:      tenancy.TenantForSource(rt.name),
+		State:       rt.state,
+		Mode:        "http",
+		Offset:      rt.offset,
+		Size:        rt.size,
+		LinesParsed: rt.linesParsed,
+		ParseErrors: rt.parseErrors,
+		Error:       rt.lastError,
+	}
+	if !rt.lastRead.IsZero() {
+		status.LastRead = rt.lastRead.Format(time.RFC3339)
+	}
+	if status.Size > status.Offset {
+		status.Lag = status.Size - status.Offset
+	}
+	return status
+}
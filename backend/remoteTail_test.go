@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A log file served with Range support; ranges records the Range headers
+type remoteLog struct {
+	mu     sync.Mutex
+	data   []byte
+	ranges []string
+}
+
+func (l *remoteLog) append(s string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.data = append(l.data, s...)
+}
+
+func (l *remoteLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	data := bytes.Clone(l.data)
+	if rng := r.Header.Get("Range"); rng != "" {
+		l.ranges = append(l.ranges, rng)
+	}
+	l.mu.Unlock()
+	http.ServeContent(w, r, "access.log", time.Time{}, bytes.NewReader(data))
+}
+
+func newTestTailer(t *testing.T, log *remoteLog) *RemoteTailer {
+	t.Helper()
+	server := httptest.NewServer(log)
+	t.Cleanup(server.Close)
+	return NewRemoteTailer(server.URL+"/access.log", NewLogParser())
+}
+
+func remotePaths(rt *RemoteTailer) []string {
+	var paths []string
+	rt.parser.eachMatch(rt.parser.logsSnapshot.Load(), Filters{}, math.MaxUint64, func(entry *LogEntry) bool {
+		paths = append(paths, entry.Path)
+		return true
+	})
+	return paths
+}
+
+func TestRemoteTailerDiscardsOverlongLine(t *testing.T) {
+	log := &remoteLog{}
+	rt := newTestTailer(t, log)
+	if err := rt.loadRecent(0); err != nil {
+		t.Fatal(err)
+	}
+
+	junk := strings.Repeat("x", MAX_LOG_LINE_BYTES/2+1)
+	for i := 0; i < 2; i++ {
+		log.append(junk)
+		if err := rt.poll(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rt.partial != "" || !rt.discarding {
+		t.Fatalf("kept %d bytes of an overlong line", len(rt.partial))
+	}
+
+	// The rest of the line is skipped up to its newline
+	log.append(junk + "\n" + accessLine("/next") + "\n")
+	if err := rt.poll(); err != nil {
+		t.Fatal(err)
+	}
+	if got := remotePaths(rt); len(got) != 1 || got[0] != "/next" {
+		t.Errorf("parsed paths = %v, want [/next]", got)
+	}
+	if rt.parseErrors != 1 || rt.linesParsed != 1 {
+		t.Errorf("parsed %d lines with %d errors, want 1 and 1", rt.linesParsed, rt.parseErrors)
+	}
+}
+
+func TestRemoteTailerLoadAllInPieces(t *testing.T) {
+	log := &remoteLog{}
+	var lines int
+	for len(log.data) < REMOTE_LOG_MAX_READ_BYTES+REMOTE_LOG_MAX_READ_BYTES/2 {
+		log.append(accessLine(fmt.Sprintf("/%d", lines)) + "\n")
+		lines++
+	}
+	rt := newTestTailer(t, log)
+	if err := rt.loadRecent(INITIAL_LOAD_ALL); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(log.ranges) != 2 {
+		t.Errorf("ranges = %v, want two pieces", log.ranges)
+	}
+	// A line spans the boundary between the pieces
+	if rt.linesParsed != uint64(lines) || rt.parseErrors != 0 {
+		t.Errorf("parsed %d lines with %d errors, want %d and 0", rt.linesParsed, rt.parseErrors, lines)
+	}
+	if rt.offset != int64(len(log.data)) || rt.partial != "" {
+		t.Errorf("offset = %d with %d bytes pending, want %d", rt.offset, len(rt.partial), len(log.data))
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 in points
+const (
+	PDF_PAGE_WIDTH  = 595.0
+	PDF_PAGE_HEIGHT = 842.0
+	PDF_MARGIN      = 50.0
+)
+
+// Helvetica glyph widths in thousandths of the font size; other characters
+// count as a digit. Only used to right-align numbers.
+var pdfGlyphWidths = map[rune]float64{
+	' ': 278, ',': 278, '.': 278, '-': 333, '%': 889, '/': 278, ':': 278,
+	'm': 833, 's': 500, 'K': 667, 'M': 833, 'G': 778, 'B': 667,
+}
+
+// A minimal PDF writer for reports: text in the standard Helvetica fonts and
+// filled rectangles, laid out top to bottom on A4 pages. The standard fonts
+// need no embedding, so text is limited to Latin-1.
+type pdfDocument struct {
+	pages []*bytes.Buffer // Content streams
+	y     float64         // Baseline of the next line on the last page
+}
+
+type pdfColumn struct {
+	title string
+	x     float64 // Left edge, or right edge when right-aligned
+	right bool
+}
+
+func newPDFDocument() *pdfDocument {
+	d := &pdfDocument{}
+	d.newPage()
+	return d
+}
+
+func (d *pdfDocument) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = PDF_PAGE_HEIGHT - PDF_MARGIN
+}
+
+// Start a new page unless height points are left on this one
+func (d *pdfDocument) reserve(height float64) {
+	if d.y-height < PDF_MARGIN {
+		d.newPage()
+	}
+}
+
+func (d *pdfDocument) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+func (d *pdfDocument) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfEscape(s))
+}
+
+func (d *pdfDocument) rightText(x, y, size float64, bold bool, s string) {
+	d.text(x-pdfTextWidth(s, size), y, size, bold, s)
+}
+
+func (d *pdfDocument) rect(x, y, width, height float64, r, g, b float64) {
+	fmt.Fprintf(d.page(), "%.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f 0 g\n", r, g, b, x, y, width, height)
+}
+
+func (d *pdfDocument) heading(s string, size float64) {
+	d.reserve(size + 30)
+	d.y -= size * 0.6
+	d.text(PDF_MARGIN, d.y, size, true, s)
+	d.y -= size
+}
+
+// A paragraph wrapped to the page width
+func (d *pdfDocument) paragraph(s string, size float64) {
+	perLine := int((PDF_PAGE_WIDTH - 2*PDF_MARGIN) / (size * 0.5))
+	line := ""
+	for _, word := range strings.Fields(s) {
+		if line != "" && len(line)+1+len(word) > perLine {
+			d.line(line, size)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		d.line(line, size)
+	}
+	d.y -= size * 0.5
+}
+
+func (d *pdfDocument) line(s string, size float64) {
+	d.reserve(size * 1.4)
+	d.text(PDF_MARGIN, d.y, size, false, s)
+	d.y -= size * 1.4
+}
+
+// A table with a bold header row, repeated on every page it spans; columns
+// without titles get no header. decorate, when set, draws beside each row.
+func (d *pdfDocument) table(columns []pdfColumn, rows [][]string, decorate func(row int, y float64)) {
+	const size, height = 9.0, 14.0
+	header := func() {
+		if columns[0].title == "" {
+			return
+		}
+		for _, column := range columns {
+			if column.right {
+				d.rightText(column.x, d.y, size, true, column.title)
+			} else {
+				d.text(column.x, d.y, size, true, column.title)
+			}
+		}
+		d.rect(PDF_MARGIN, d.y-4, PDF_PAGE_WIDTH-2*PDF_MARGIN, 0.5, 0.8, 0.8, 0.8)
+		d.y -= height
+	}
+	d.reserve(2 * height)
+	header()
+	for i, row := range rows {
+		if d.y-height < PDF_MARGIN {
+			d.newPage()
+			header()
+		}
+		for j, cell := range row {
+			if columns[j].right {
+				d.rightText(columns[j].x, d.y, size, false, cell)
+			} else {
+				d.text(columns[j].x, d.y, size, false, cell)
+			}
+		}
+		if decorate != nil {
+			decorate(i, d.y)
+		}
+		d.y -= height
+	}
+	d.y -= height / 2
+}
+
+// Assemble the catalog, fonts, pages and cross-reference table
+func (d *pdfDocument) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Objects 1-4 are the catalog, page tree and fonts; each page is
+	// followed by its content stream
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			PDF_PAGE_WIDTH, PDF_PAGE_HEIGHT, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// Encode a string as a Latin-1 PDF string literal body
+func pdfEscape(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			sb.WriteByte('\\')
+			sb.WriteByte(byte(r))
+		case r == '…':
+			sb.WriteByte(0x85) // WinAnsiEncoding's ellipsis
+		case r < 0x20 || (r >= 0x7f && r < 0xa0) || r > 0xff:
+			sb.WriteByte('?')
+		default:
+			sb.WriteByte(byte(r))
+		}
+	}
+	return sb.String()
+}
+
+func pdfTextWidth(s string, size float64) float64 {
+	width := 0.0
+	for _, r := range s {
+		if w, ok := pdfGlyphWidths[r]; ok {
+			width += w
+		} else {
+			width += 556
+		}
+	}
+	return width * size / 1000
+}
+
+// Render the report as a PDF with the same sections as the HTML version
+func (r *Report) PDF() []byte {
+	d := newPDFDocument()
+	d.heading(r.Title(), 18)
+	d.paragraph(fmt.Sprintf("%s to %s, generated %s",
+		r.From.Format("Jan 2, 2006 15:04 MST"), r.To.Format("Jan 2, 2006 15:04 MST"), r.GeneratedAt.Format("Jan 2, 2006 15:04 MST")), 9)
+	if r.Partial {
+		d.paragraph(fmt.Sprintf("The log buffer only reaches back to %s, so earlier traffic in this period is missing.",
+			r.CoveredFrom.In(r.From.Location()).Format("Jan 2, 2006 15:04 MST")), 9)
+	}
+
+	d.heading("Summary", 13)
+	d.table([]pdfColumn{{"", PDF_MARGIN, false}, {"", 300, true}}, [][]string{
+		{"Requests", formatCount(r.Requests)},
+		{"Unique visitors", formatCount(r.UniqueVisitors)},
+		{"5xx errors", fmt.Sprintf("%s (%s%%)", formatCount(r.Requests5xx), formatFloat(r.ErrorRate))},
+		{"4xx responses", formatCount(r.Requests4xx)},
+		{"Average / p95 response time", fmt.Sprintf("%s / %s ms", formatFloat(r.AvgResponseTime), formatFloat(r.P95ResponseTime))},
+		{"Transferred", formatByteSize(r.Bytes)},
+	}, nil)
+
+	d.heading("Traffic", 13)
+	unit := "Hour"
+	if r.dailyBuckets() {
+		unit = "Day"
+	}
+	rows := make([][]string, len(r.Traffic))
+	for i, bucket := range r.Traffic {
+		rows[i] = []string{r.bucketLabel(bucket), formatCount(bucket.Requests), formatCount(bucket.Errors)}
+	}
+	busiest := r.busiestBucket()
+	d.table([]pdfColumn{{unit, PDF_MARGIN, false}, {"Requests", 200, true}, {"5xx", 250, true}}, rows, func(row int, y float64) {
+		if requests := r.Traffic[row].Requests; requests > 0 {
+			width := (PDF_PAGE_WIDTH - PDF_MARGIN - 270) * float64(requests) / float64(busiest)
+			d.rect(270, y-1, width, 8, 0.23, 0.51, 0.96)
+		}
+	})
+
+	d.heading("Services and SLO status", 13)
+	d.paragraph(fmt.Sprintf("Objective %s: %d of %d services met it.", r.SLO.target(), r.SLO.Met, len(r.Services)), 9)
+	if len(r.Services) > 0 {
+		rows := make([][]string, len(r.Services))
+		for i, service := range r.Services {
+			status := "met"
+			if !service.SLOMet {
+				status = "missed"
+			}
+			rows[i] = []string{truncate(service.Name, 40), formatCount(service.Requests), formatCount(service.Errors),
+				formatFloat(service.Availability) + "%", formatFloat(service.P95ResponseTime) + " ms", status}
+		}
+		d.table([]pdfColumn{{"Service", PDF_MARGIN, false}, {"Requests", 300, true}, {"5xx", 345, true},
+			{"Availability", 420, true}, {"p95", 485, true}, {"SLO", 500, false}}, rows, nil)
+	}
+
+	d.heading("Top paths", 13)
+	if len(r.Paths) > 0 {
+		rows := make([][]string, len(r.Paths))
+		for i, path := range r.Paths {
+			rows[i] = []string{truncate(path.Path, 70), formatCount(path.Count)}
+		}
+		d.table([]pdfColumn{{"Path", PDF_MARGIN, false}, {"Requests", PDF_PAGE_WIDTH - PDF_MARGIN, true}}, rows, nil)
+	} else {
+		d.paragraph("No requests in this period.", 9)
+	}
+
+	d.heading("Geography", 13)
+	if len(r.Countries) > 0 {
+		rows := make([][]string, len(r.Countries))
+		for i, country := range r.Countries {
+			rows[i] = []string{fmt.Sprintf("%s (%s)", country.Country, country.CountryCode), formatCount(country.Requests), formatFloat(country.Share) + "%"}
+		}
+		d.table([]pdfColumn{{"Country", PDF_MARGIN, false}, {"Requests", 300, true}, {"Share", 380, true}}, rows, nil)
+	} else {
+		d.paragraph("No geolocated requests in this period.", 9)
+	}
+	return d.Bytes()
+}
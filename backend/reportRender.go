@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Title shared by the notification and the rendered report
+func (r *Report) Title() string {
+	period := "Daily"
+	if r.Period == REPORT_WEEKLY {
+		period = "Weekly"
+	}
+	return fmt.Sprintf("%s report, %s", period, r.rangeText())
+}
+
+// The period covered, as dates when it spans whole days
+func (r *Report) rangeText() string {
+	from, to := r.From, r.To
+	if !atMidnight(from) || !atMidnight(to) {
+		return fmt.Sprintf("%s - %s", from.Format("Jan 2 15:04"), to.Format("Jan 2 15:04 MST"))
+	}
+	last := to.Add(-time.Second)
+	if last.Year() == from.Year() && last.YearDay() == from.YearDay() {
+		return from.Format("Jan 2, 2006")
+	}
+	return fmt.Sprintf("%s - %s", from.Format("Jan 2"), last.Format("Jan 2, 2006"))
+}
+
+func atMidnight(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+}
+
+// A few lines of plain text for chat and push notifiers
+func (r *Report) Summary(link string) string {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("%s requests from %s visitors, %s%% 5xx, p95 %s ms",
+		formatCount(r.Requests), formatCount(r.UniqueVisitors), formatFloat(r.ErrorRate), formatFloat(r.P95ResponseTime)))
+	if len(r.Services) > 0 {
+		var top []string
+		for _, service := range r.Services[:min(3, len(r.Services))] {
+			top = append(top, fmt.Sprintf("%s (%s)", service.Name, formatCount(service.Requests)))
+		}
+		lines = append(lines, "Top services: "+strings.Join(top, ", "))
+	}
+	if len(r.Countries) > 0 {
+		var top []string
+		for _, country := range r.Countries[:min(3, len(r.Countries))] {
+			top = append(top, fmt.Sprintf("%s %s%%", country.Country, formatFloat(country.Share)))
+		}
+		lines = append(lines, "Top countries: "+strings.Join(top, ", "))
+	}
+	if len(r.Services) > 0 {
+		slo := fmt.Sprintf("SLO %s: %d of %d services met", r.SLO.target(), r.SLO.Met, len(r.Services))
+		if len(r.SLO.Missed) > 0 {
+			slo += ", missed by " + strings.Join(r.SLO.Missed, ", ")
+		}
+		lines = append(lines, slo)
+	}
+	if r.Partial {
+		lines = append(lines, fmt.Sprintf("Buffered logs only cover this period from %s", r.CoveredFrom.In(r.From.Location()).Format("Jan 2 15:04")))
+	}
+	if link != "" {
+		lines = append(lines, "Full report: "+link)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// The objective, e.g. "(99.9% availability, p95 <= 500 ms)"
+func (s ReportSLO) target() string {
+	target := formatFloat(s.Availability) + "% availability"
+	if s.LatencyMs > 0 {
+		target += ", p95 <= " + formatFloat(s.LatencyMs) + " ms"
+	}
+	return "(" + target + ")"
+}
+
+// 12345 as "12,345"
+func formatCount(n int) string {
+	if n < 0 {
+		return "-" + formatCount(-n)
+	}
+	digits := strconv.Itoa(n)
+	var sb strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(digit)
+	}
+	return sb.String()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// 1536 as "1.5 KB"
+func formatByteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for n/div >= unit && exp < 4 {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTP"[exp])
+}
+
+// Traffic buckets are hours in daily reports and days in weekly ones
+func (r *Report) dailyBuckets() bool {
+	return len(r.Traffic) > 1 && r.Traffic[1].Start.Sub(r.Traffic[0].Start) >= 24*time.Hour
+}
+
+func (r *Report) bucketLabel(bucket ReportBucket) string {
+	if r.dailyBuckets() {
+		return bucket.Start.Format("Mon Jan 2")
+	}
+	return bucket.Start.Format("15:04")
+}
+
+func (r *Report) busiestBucket() int {
+	busiest := 0
+	for _, bucket := range r.Traffic {
+		busiest = max(busiest, bucket.Requests)
+	}
+	return busiest
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"count":   formatCount,
+	"float":   formatFloat,
+	"bytes":   formatByteSize,
+	"pct":     func(part, total int) string { return formatFloat(percent(part, total)) },
+	"instant": func(t time.Time) string { return t.Format("Jan 2, 2006 15:04 MST") },
+	// Templates can't call unexported methods
+	"dailyBuckets": (*Report).dailyBuckets,
+	"bucketLabel":  (*Report).bucketLabel,
+	"sloTarget":    ReportSLO.target,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Report.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2937; max-width: 880px; margin: 2rem auto; padding: 0 1rem; }
+h1 { font-size: 1.5rem; margin-bottom: .25rem; }
+h2 { font-size: 1.1rem; margin-top: 2rem; border-bottom: 1px solid #e5e7eb; padding-bottom: .25rem; }
+.muted { color: #6b7280; font-size: .9rem; }
+.warning { background: #fef3c7; border: 1px solid #f59e0b; padding: .5rem .75rem; border-radius: 4px; }
+.cards { display: flex; flex-wrap: wrap; gap: .75rem; margin-top: 1rem; }
+.card { border: 1px solid #e5e7eb; border-radius: 6px; padding: .75rem 1rem; min-width: 130px; }
+.card b { display: block; font-size: 1.3rem; }
+table { border-collapse: collapse; width: 100%; font-size: .9rem; }
+th, td { text-align: left; padding: .35rem .5rem; border-bottom: 1px solid #f3f4f6; }
+td.num, th.num { text-align: right; font-variant-numeric: tabular-nums; }
+.bar { background: #3b82f6; height: .8rem; display: inline-block; vertical-align: middle; }
+.met { color: #059669; font-weight: 600; }
+.missed { color: #dc2626; font-weight: 600; }
+</style>
+</head>
+<body>
+{{with .Report}}
+<h1>{{.Title}}</h1>
+<div class="muted">{{instant .From}} to {{instant .To}} · generated {{instant .GeneratedAt}}</div>
+{{if .Partial}}<p class="warning">The log buffer only reaches back to {{instant .CoveredFrom}}, so earlier traffic in this period is missing.</p>{{end}}
+
+<div class="cards">
+<div class="card">Requests<b>{{count .Requests}}</b></div>
+<div class="card">Visitors<b>{{count .UniqueVisitors}}</b></div>
+<div class="card">5xx errors<b>{{count .Requests5xx}}</b><span class="muted">{{float .ErrorRate}}%</span></div>
+<div class="card">4xx responses<b>{{count .Requests4xx}}</b></div>
+<div class="card">Avg / p95<b>{{float .AvgResponseTime}} / {{float .P95ResponseTime}} ms</b></div>
+<div class="card">Transferred<b>{{bytes .Bytes}}</b></div>
+</div>
+
+<h2>Traffic</h2>
+<table>
+<tr><th>{{if dailyBuckets .}}Day{{else}}Hour{{end}}</th><th class="num">Requests</th><th class="num">5xx</th><th></th></tr>
+{{range .Traffic}}<tr><td>{{bucketLabel $.Report .}}</td><td class="num">{{count .Requests}}</td><td class="num">{{count .Errors}}</td><td style="width:45%">{{if $.Busiest}}<span class="bar" style="width:{{pct .Requests $.Busiest}}%"></span>{{end}}</td></tr>
+{{end}}</table>
+
+<h2>Services and SLO status</h2>
+<p class="muted">Objective {{sloTarget .SLO}}: {{.SLO.Met}} of {{len .Services}} services met it.</p>
+{{if .Services}}<table>
+<tr><th>Service</th><th class="num">Requests</th><th class="num">5xx</th><th class="num">Availability</th><th class="num">p95</th><th>SLO</th></tr>
+{{range .Services}}<tr><td>{{.Name}}</td><td class="num">{{count .Requests}}</td><td class="num">{{count .Errors}}</td><td class="num">{{float .Availability}}%</td><td class="num">{{float .P95ResponseTime}} ms</td><td>{{if .SLOMet}}<span class="met">met</span>{{else}}<span class="missed">missed</span>{{end}}</td></tr>
+{{end}}</table>{{else}}<p class="muted">No service traffic in this period.</p>{{end}}
+
+<h2>Top paths</h2>
+{{if .Paths}}<table>
+<tr><th>Path</th><th class="num">Requests</th></tr>
+{{range .Paths}}<tr><td>{{.Path}}</td><td class="num">{{count .Count}}</td></tr>
+{{end}}</table>{{else}}<p class="muted">No requests in this period.</p>{{end}}
+
+<h2>Geography</h2>
+{{if .Countries}}<table>
+<tr><th>Country</th><th class="num">Requests</th><th class="num">Share</th></tr>
+{{range .Countries}}<tr><td>{{.Country}} ({{.CountryCode}})</td><td class="num">{{count .Requests}}</td><td class="num">{{float .Share}}%</td></tr>
+{{end}}</table>{{else}}<p class="muted">No geolocated requests in this period.</p>{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// Render the report as a standalone HTML page
+func (r *Report) HTML() ([]byte, error) {
+	var buf bytes.Buffer
+	err := reportTemplate.Execute(&buf, struct {
+		Report  *Report
+		Busiest int
+	}{r, r.busiestBucket()})
+	return buf.Bytes(), err
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	REPORT_DAILY  = "daily"
+	REPORT_WEEKLY = "weekly"
+
+	DEFAULT_REPORT_TIME             = "08:00"
+	DEFAULT_REPORT_HISTORY          = 30
+	DEFAULT_REPORT_SLO_AVAILABILITY = 99.9
+	REPORT_TOP_ITEMS                = 10
+)
+
+var reportPeriods = map[string]time.Duration{
+	REPORT_DAILY:  24 * time.Hour,
+	REPORT_WEEKLY: 7 * 24 * time.Hour,
+}
+
+type ReportConfig struct {
+	Schedules       []string // Periods delivered automatically; empty for on-demand only
+	At              time.Duration
+	Weekday         time.Weekday // Weekly reports go out on this day
+	Location        *time.Location
+	Format          string  // html or pdf, for links in notifications
+	History         int     // Reports kept for download
+	SLOAvailability float64 // Percent of requests without a 5xx
+	SLOLatencyMs    float64 // p95 target; 0 disables it
+	DashboardURL    string
+}
+
+// Read REPORT_SCHEDULE ("daily,weekly"), REPORT_TIME, REPORT_WEEKDAY,
+// REPORT_TIMEZONE, REPORT_FORMAT, REPORT_HISTORY, the REPORT_SLO_* targets
+// and DASHBOARD_URL
+func GetReportConfigFromEnv() (ReportConfig, error) {
+	config := ReportConfig{
+		Schedules:       []string{},
+		Weekday:         time.Monday,
+		Location:        time.Local,
+		Format:          strings.ToLower(GetEnvString("REPORT_FORMAT", "html")),
+		History:         GetEnvInt("REPORT_HISTORY", DEFAULT_REPORT_HISTORY),
+		SLOAvailability: DEFAULT_REPORT_SLO_AVAILABILITY,
+		DashboardURL:    strings.TrimRight(os.Getenv("DASHBOARD_URL"), "/"),
+	}
+	for _, period := range strings.Split(os.Getenv("REPORT_SCHEDULE"), ",") {
+		period = strings.ToLower(strings.TrimSpace(period))
+		if period == "" {
+			continue
+		}
+		if _, ok := reportPeriods[period]; !ok {
+			return config, fmt.Errorf("REPORT_SCHEDULE entries must be daily or weekly, got %q", period)
+		}
+		config.Schedules = append(config.Schedules, period)
+	}
+
+	var err error
+	if config.At, err = parseClock(GetEnvString("REPORT_TIME", DEFAULT_REPORT_TIME)); err != nil {
+		return config, fmt.Errorf("REPORT_TIME: %w", err)
+	}
+	if value := os.Getenv("REPORT_WEEKDAY"); value != "" {
+		found := false
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(value, day.String()) {
+				config.Weekday, found = day, true
+			}
+		}
+		if !found {
+			return config, fmt.Errorf("REPORT_WEEKDAY must be a day name such as monday")
+		}
+	}
+	if value := os.Getenv("REPORT_TIMEZONE"); value != "" {
+		if config.Location, err = time.LoadLocation(value); err != nil {
+			return config, fmt.Errorf("invalid REPORT_TIMEZONE: %w", err)
+		}
+	}
+	if config.Format != "html" && config.Format != "pdf" {
+		return config, fmt.Errorf("REPORT_FORMAT must be html or pdf")
+	}
+	if config.History < 1 {
+		return config, fmt.Errorf("REPORT_HISTORY must be at least 1")
+	}
+	if value := os.Getenv("REPORT_SLO_AVAILABILITY"); value != "" {
+		if config.SLOAvailability, err = strconv.ParseFloat(value, 64); err != nil || config.SLOAvailability <= 0 || config.SLOAvailability > 100 {
+			return config, fmt.Errorf("REPORT_SLO_AVAILABILITY must be a percentage above 0")
+		}
+	}
+	if value := os.Getenv("REPORT_SLO_P95_MS"); value != "" {
+		if config.SLOLatencyMs, err = strconv.ParseFloat(value, 64); err != nil || config.SLOLatencyMs < 0 {
+			return config, fmt.Errorf("REPORT_SLO_P95_MS must be a positive number of milliseconds")
+		}
+	}
+	return config, nil
+}
+
+// Traffic, errors, top services, geo highlights and SLO status over a
+// period, computed from the buffered entries
+type Report struct {
+	ID          string    `json:"id"`
+	Period      string    `json:"period"` // daily or weekly
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	// The buffer no longer held the start of the period; figures cover
+	// CoveredFrom onwards
+	Partial     bool      `json:"partial"`
+	CoveredFrom time.Time `json:"coveredFrom"`
+
+	Requests        int     `json:"requests"`
+	Requests4xx     int     `json:"requests4xx"`
+	Requests5xx     int     `json:"requests5xx"`
+	ErrorRate       float64 `json:"errorRate"` // Percent of requests with a 5xx
+	AvgResponseTime float64 `json:"avgResponseTime"`
+	P95ResponseTime float64 `json:"p95ResponseTime"`
+	Bytes           int64   `json:"bytes"`
+	UniqueVisitors  int     `json:"uniqueVisitors"`
+
+	Traffic   []ReportBucket  `json:"traffic"` // Hourly for daily reports, daily for weekly ones
+	Services  []ReportService `json:"services"`
+	Paths     []PathCount     `json:"topPaths"`
+	Countries []ReportCountry `json:"countries"`
+	SLO       ReportSLO       `json:"slo"`
+}
+
+type ReportBucket struct {
+	Start    time.Time `json:"start"`
+	Requests int       `json:"requests"`
+	Errors   int       `json:"errors"` // 5xx
+}
+
+type ReportService struct {
+	Name            string  `json:"name"`
+	Requests        int     `json:"requests"`
+	Errors          int     `json:"errors"` // 5xx
+	Availability    float64 `json:"availability"`
+	P95ResponseTime float64 `json:"p95ResponseTime"`
+	SLOMet          bool    `json:"sloMet"`
+}
+
+type ReportCountry struct {
+	Country     string  `json:"country"`
+	CountryCode string  `json:"countryCode"`
+	Requests    int     `json:"requests"`
+	Share       float64 `json:"share"` // Percent of geolocated requests
+}
+
+type ReportSLO struct {
+	Availability float64  `json:"availability"` // Target percent
+	LatencyMs    float64  `json:"latencyMs,omitempty"`
+	Met          int      `json:"met"`
+	Missed       []string `json:"missed"` // Services below target
+}
+
+// Builds reports on demand and on REPORT_SCHEDULE, keeps the most recent
+// ones for /api/reports and sends scheduled ones to the notifiers
+type Reporter struct {
+	config  ReportConfig
+	mu      sync.Mutex
+	reports []*Report // Oldest first
+	stop    chan struct{}
+}
+
+var reporter *Reporter
+
+func NewReporter() (*Reporter, error) {
+	config, err := GetReportConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return &Reporter{config: config, stop: make(chan struct{})}, nil
+}
+
+// Deliver scheduled reports; does nothing without REPORT_SCHEDULE
+func (r *Reporter) Start() {
+	if len(r.config.Schedules) == 0 {
+		return
+	}
+	appLog.Info("Scheduled reports enabled", "periods", strings.Join(r.config.Schedules, ","), "next", r.nextRun(time.Now()).Format(time.RFC3339))
+	go func() {
+		for {
+			next := r.nextRun(time.Now())
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-timer.C:
+				r.runScheduled(next)
+			case <-r.stop:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (r *Reporter) Stop() {
+	close(r.stop)
+}
+
+// The next REPORT_TIME after now
+func (r *Reporter) nextRun(now time.Time) time.Time {
+	local := now.In(r.config.Location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, r.config.Location)
+	next := midnight.Add(r.config.At)
+	if !next.After(now) {
+		next = time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, r.config.Location).Add(r.config.At)
+	}
+	return next
+}
+
+// Build and send the reports due at a scheduled run; they cover whole days
+// up to the run's midnight
+func (r *Reporter) runScheduled(at time.Time) {
+	local := at.In(r.config.Location)
+	to := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, r.config.Location)
+	for _, period := range r.config.Schedules {
+		if period == REPORT_WEEKLY && local.Weekday() != r.config.Weekday {
+			continue
+		}
+		days := int(reportPeriods[period] / (24 * time.Hour))
+		from := time.Date(to.Year(), to.Month(), to.Day()-days, 0, 0, 0, 0, r.config.Location)
+		report := r.generate(period, from, to)
+		appLog.Info("Sending scheduled report", "id", report.ID, "requests", report.Requests)
+		r.deliver(report)
+	}
+}
+
+// Build a report and keep it for download
+func (r *Reporter) generate(period string, from, to time.Time) *Report {
+	report := buildReport(period, from, to, r.config)
+	r.mu.Lock()
+	r.reports = append(r.reports, report)
+	if len(r.reports) > r.config.History {
+		r.reports = r.reports[len(r.reports)-r.config.History:]
+	}
+	r.mu.Unlock()
+	return report
+}
+
+func (r *Reporter) find(id string) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, report := range r.reports {
+		if report.ID == id {
+			return report
+		}
+	}
+	return nil
+}
+
+// Send a report to the notifiers: a text summary with a download link, and
+// the whole report in the details for webhooks
+func (r *Reporter) deliver(report *Report) {
+	severity := SEVERITY_INFO
+	if len(report.SLO.Missed) > 0 {
+		severity = SEVERITY_WARNING
+	}
+	link := ""
+	if r.config.DashboardURL != "" {
+		link = fmt.Sprintf("%s/api/reports/%s?format=%s", r.config.DashboardURL, report.ID, r.config.Format)
+	}
+	notify(Notification{
+		Kind:     NOTIFY_KIND_REPORT,
+		Event:    "summaryReport",
+		Severity: severity,
+		Title:    report.Title(),
+		Message:  report.Summary(link),
+		Details:  map[string]interface{}{"report": report, "url": link},
+	})
+}
+
+// Aggregate the buffered entries logged in [from, to)
+func buildReport(period string, from, to time.Time, config ReportConfig) *Report {
+	report := &Report{
+		ID:          fmt.Sprintf("%s-%s", period, to.UTC().Format("20060102-150405")),
+		Period:      period,
+		From:        from,
+		To:          to,
+		GeneratedAt: time.Now(),
+		SLO:         ReportSLO{Availability: config.SLOAvailability, LatencyMs: config.SLOLatencyMs, Missed: []string{}},
+	}
+
+	// Hourly buckets for a day, daily ones for longer periods
+	step := time.Hour
+	if to.Sub(from) > 24*time.Hour {
+		step = 24 * time.Hour
+	}
+	for start := from; start.Before(to); start = start.Add(step) {
+		report.Traffic = append(report.Traffic, ReportBucket{Start: start})
+	}
+
+	type serviceTotals struct {
+		requests, errors int
+		responseTimes    *TDigest
+	}
+	services := make(map[string]*serviceTotals)
+	paths := make(map[string]int)
+	countries := make(map[string]int)
+	visitors := make(map[string]bool)
+	responseTimes := NewTDigest(DEFAULT_TDIGEST_COMPRESSION)
+	totalResponseTime := 0.0
+	var oldest time.Time
+
+	logParser.eachMatch(logParser.logsSnapshot.Load(), Filters{}, math.MaxUint64, func(log *LogEntry) bool {
+		timestamp, err := time.Parse(time.RFC3339, log.Timestamp)
+		if err != nil {
+			return true
+		}
+		if oldest.IsZero() || timestamp.Before(oldest) {
+			oldest = timestamp
+		}
+		if timestamp.Before(from) || !timestamp.Before(to) {
+			return true
+		}
+
+		report.Requests++
+		isError := log.Status >= 500
+		switch log.Status / 100 {
+		case 4:
+			report.Requests4xx++
+		case 5:
+			report.Requests5xx++
+		}
+		report.Bytes += int64(log.Size)
+		totalResponseTime += log.ResponseTime
+		responseTimes.Add(log.ResponseTime)
+		if log.ClientIP != "" && log.ClientIP != "unknown" {
+			visitors[log.ClientIP] = true
+		}
+		if bucket := int(timestamp.Sub(from) / step); bucket < len(report.Traffic) {
+			report.Traffic[bucket].Requests++
+			if isError {
+				report.Traffic[bucket].Errors++
+			}
+		}
+		if log.ServiceName != "" && log.ServiceName != "unknown" {
+			totals := services[log.ServiceName]
+			if totals == nil {
+				totals = &serviceTotals{responseTimes: NewTDigest(DEFAULT_TDIGEST_COMPRESSION)}
+				services[log.ServiceName] = totals
+			}
+			totals.requests++
+			if isError {
+				totals.errors++
+			}
+			totals.responseTimes.Add(log.ResponseTime)
+		}
+		if log.PathTemplate != "" {
+			paths[log.PathTemplate]++
+		}
+		if log.Country != nil && log.CountryCode != nil {
+			countries[fmt.Sprintf("%s|%s", *log.CountryCode, *log.Country)]++
+		}
+		return true
+	})
+
+	report.CoveredFrom = from
+	if oldest.After(from) {
+		report.Partial, report.CoveredFrom = true, oldest
+	}
+	report.UniqueVisitors = len(visitors)
+	if report.Requests > 0 {
+		report.ErrorRate = percent(report.Requests5xx, report.Requests)
+		report.AvgResponseTime = math.Round(totalResponseTime/float64(report.Requests)*100) / 100
+		report.P95ResponseTime = math.Round(responseTimes.Quantile(0.95)*100) / 100
+	}
+
+	for name, totals := range services {
+		service := ReportService{
+			Name:            name,
+			Requests:        totals.requests,
+			Errors:          totals.errors,
+			Availability:    100 - percent(totals.errors, totals.requests),
+			P95ResponseTime: math.Round(totals.responseTimes.Quantile(0.95)*100) / 100,
+		}
+		service.SLOMet = service.Availability >= config.SLOAvailability &&
+			(config.SLOLatencyMs == 0 || service.P95ResponseTime <= config.SLOLatencyMs)
+		if service.SLOMet {
+			report.SLO.Met++
+		} else {
+			report.SLO.Missed = append(report.SLO.Missed, name)
+		}
+		report.Services = append(report.Services, service)
+	}
+	sort.Slice(report.Services, func(i, j int) bool {
+		if report.Services[i].Requests != report.Services[j].Requests {
+			return report.Services[i].Requests > report.Services[j].Requests
+		}
+		return report.Services[i].Name < report.Services[j].Name
+	})
+	sort.Strings(report.SLO.Missed)
+
+	report.Paths = getTopItems(paths, REPORT_TOP_ITEMS, func(k string, v int) PathCount {
+		return PathCount{Path: k, Count: v}
+	})
+	located := 0
+	for _, count := range countries {
+		located += count
+	}
+	for _, country := range countryCounts(countries) {
+		if len(report.Countries) == REPORT_TOP_ITEMS {
+			break
+		}
+		report.Countries = append(report.Countries, ReportCountry{
+			Country:     country.Country,
+			CountryCode: country.CountryCode,
+			Requests:    country.Count,
+			Share:       percent(country.Count, located),
+		})
+	}
+	return report
+}
+
+// part as a percentage of total, rounded to two decimals
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(total)*10000) / 100
+}
+
+// Handler for GET /api/reports: the reports kept for download, newest first
+func listReports(c *gin.Context) {
+	reporter.mu.Lock()
+	reports := make([]gin.H, 0, len(reporter.reports))
+	for i := len(reporter.reports) - 1; i >= 0; i-- {
+		report := reporter.reports[i]
+		reports = append(reports, gin.H{
+			"id":          report.ID,
+			"period":      report.Period,
+			"from":        report.From,
+			"to":          report.To,
+			"generatedAt": report.GeneratedAt,
+			"requests":    report.Requests,
+			"partial":     report.Partial,
+		})
+	}
+	reporter.mu.Unlock()
+	c.JSON(http.StatusOK, gin.H{"reports": reports, "schedule": reporter.config.Schedules})
+}
+
+// Handler for POST /api/reports?period=daily: build a report over the
+// period ending now, and send it to the notifiers with notify=true
+func createReport(c *gin.Context) {
+	period := c.DefaultQuery("period", REPORT_DAILY)
+	length, ok := reportPeriods[period]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be daily or weekly"})
+		return
+	}
+	to := time.Now().In(reporter.config.Location).Truncate(time.Second)
+	report := reporter.generate(period, to.Add(-length), to)
+	if c.Query("notify") == "true" {
+		reporter.deliver(report)
+	}
+	c.JSON(http.StatusCreated, report)
+}
+
+// Handler for GET /api/reports/:id?format=html|pdf|json
+func getReport(c *gin.Context) {
+	report := reporter.find(c.Param("id"))
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	filename := "traefik-report-" + report.ID
+	switch format := c.DefaultQuery("format", "html"); format {
+	case "html":
+		body, err := report.HTML()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", body)
+	case "pdf":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".pdf"))
+		c.Data(http.StatusOK, "application/pdf", report.PDF())
+	case "json":
+		c.JSON(http.StatusOK, report)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be html, pdf or json"})
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Tunables that can be changed at runtime through /api/admin/config
+type RuntimeConfig struct {
+	MaxLogs                 int                 `json:"maxLogs"`
+	StatsIntervalSeconds    int                 `json:"statsIntervalSeconds"`
+	GeoStatsIntervalSeconds int                 `json:"geoStatsIntervalSeconds"`
+	Geo                     GeoRuntimeConfig    `json:"geo"`
+	DefaultFilters          DefaultFilters      `json:"defaultFilters"`
+	Ingest                  IngestRuntimeConfig `json:"ingest"`
+	OTLPSampling            OTLPSamplingConfig  `json:"otlpSampling"`
+	InitialLoad             InitialLoadConfig   `json:"initialLoad"`
+	Buffers                 BufferSizes         `json:"buffers"`
+}
+
+type GeoRuntimeConfig struct {
+	UseMaxMind           bool `json:"useMaxMind"`
+	FallbackToOnline     bool `json:"fallbackToOnline"`
+	MaxRequestsPerMinute int  `json:"maxRequestsPerMinute"`
+}
+
+// Filters applied to queries that don't set them explicitly
+type DefaultFilters struct {
+	HideUnknown    bool `json:"hideUnknown"`
+	HidePrivateIPs bool `json:"hidePrivateIPs"`
+}
+
+// Partial update for RuntimeConfig; nil fields are left unchanged
+type RuntimeConfigPatch struct {
+	MaxLogs                 *int `json:"maxLogs"`
+	StatsIntervalSeconds    *int `json:"statsIntervalSeconds"`
+	GeoStatsIntervalSeconds *int `json:"geoStatsIntervalSeconds"`
+	Geo                     *struct {
+		UseMaxMind           *bool `json:"useMaxMind"`
+		FallbackToOnline     *bool `json:"fallbackToOnline"`
+		MaxRequestsPerMinute *int  `json:"maxRequestsPerMinute"`
+	} `json:"geo"`
+	DefaultFilters *struct {
+		HideUnknown    *bool `json:"hideUnknown"`
+		HidePrivateIPs *bool `json:"hidePrivateIPs"`
+	} `json:"defaultFilters"`
+	Ingest *struct {
+		MaxPerSecond *int        `json:"maxPerSecond"`
+		SampleRate   *int        `json:"sampleRate"`
+		DropRules    *[]DropRule `json:"dropRules"` // Replaces all rules when set
+	} `json:"ingest"`
+	OTLPSampling *struct {
+		Percent   *float64    `json:"percent"`
+		DropRules *[]DropRule `json:"dropRules"` // Replaces all rules when set
+	} `json:"otlpSampling"`
+	InitialLoad *struct {
+		Lines   *int           `json:"lines"`
+		Sources map[string]int `json:"sources"` // Replaces all overrides when set
+	} `json:"initialLoad"`
+	Buffers *struct {
+		Listener *int `json:"listener"`
+		WSSend   *int `json:"wsSend"`
+	} `json:"buffers"`
+}
+
+var (
+	runtimeConfigMu         sync.RWMutex
+	statsIntervalSeconds    = 10
+	geoStatsIntervalSeconds = 15
+	defaultFilters          DefaultFilters
+)
+
+// Get the current runtime configuration from its owning components
+func GetRuntimeConfig() RuntimeConfig {
+	maxmindConfig := GetMaxMindConfig()
+
+	runtimeConfigMu.RLock()
+	defer runtimeConfigMu.RUnlock()
+
+	return RuntimeConfig{
+		MaxLogs:                 logParser.GetMaxLogs(),
+		StatsIntervalSeconds:    statsIntervalSeconds,
+		GeoStatsIntervalSeconds: geoStatsIntervalSeconds,
+		Geo: GeoRuntimeConfig{
+			UseMaxMind:           maxmindConfig.Enabled,
+			FallbackToOnline:     maxmindConfig.FallbackToOnline,
+			MaxRequestsPerMinute: GetMaxRequestsPerMinute(),
+		},
+		DefaultFilters: defaultFilters,
+		Ingest:         ingestControl.Config(),
+		OTLPSampling:   otlpSampler.Config(),
+		InitialLoad:    GetInitialLoadConfig(),
+		Buffers:        GetBufferSizes(),
+	}
+}
+
+// Get the WebSocket push intervals
+func GetPushIntervals() (stats, geoStats time.Duration) {
+	runtimeConfigMu.RLock()
+	defer runtimeConfigMu.RUnlock()
+	return time.Duration(statsIntervalSeconds) * time.Second, time.Duration(geoStatsIntervalSeconds) * time.Second
+}
+
+// Get the filters applied when a query doesn't set them
+func GetDefaultFilters() DefaultFilters {
+	runtimeConfigMu.RLock()
+	defer runtimeConfigMu.RUnlock()
+	return defaultFilters
+}
+
+// Validate the whole patch, then apply it. Nothing is changed if validation fails.
+func ApplyRuntimeConfigPatch(patch RuntimeConfigPatch) (RuntimeConfig, error) {
+	next := GetRuntimeConfig()
+
+	if patch.MaxLogs != nil {
+		if *patch.MaxLogs < MIN_MAX_LOGS || *patch.MaxLogs > MAX_MAX_LOGS {
+			return RuntimeConfig{}, fmt.Errorf("maxLogs must be between %d and %d", MIN_MAX_LOGS, MAX_MAX_LOGS)
+		}
+		next.MaxLogs = *patch.MaxLogs
+	}
+	if patch.StatsIntervalSeconds != nil {
+		if *patch.StatsIntervalSeconds < 1 || *patch.StatsIntervalSeconds > 3600 {
+			return RuntimeConfig{}, fmt.Errorf("statsIntervalSeconds must be between 1 and 3600")
+		}
+		next.StatsIntervalSeconds = *patch.StatsIntervalSeconds
+	}
+	if patch.GeoStatsIntervalSeconds != nil {
+		if *patch.GeoStatsIntervalSeconds < 1 || *patch.GeoStatsIntervalSeconds > 3600 {
+			return RuntimeConfig{}, fmt.Errorf("geoStatsIntervalSeconds must be between 1 and 3600")
+		}
+		next.GeoStatsIntervalSeconds = *patch.GeoStatsIntervalSeconds
+	}
+	if patch.Geo != nil {
+		if patch.Geo.UseMaxMind != nil {
+			next.Geo.UseMaxMind = *patch.Geo.UseMaxMind
+		}
+		if patch.Geo.FallbackToOnline != nil {
+			next.Geo.FallbackToOnline = *patch.Geo.FallbackToOnline
+		}
+		if patch.Geo.MaxRequestsPerMinute != nil {
+			if *patch.Geo.MaxRequestsPerMinute < 1 || *patch.Geo.MaxRequestsPerMinute > 1000 {
+				return RuntimeConfig{}, fmt.Errorf("geo.maxRequestsPerMinute must be between 1 and 1000")
+			}
+			next.Geo.MaxRequestsPerMinute = *patch.Geo.MaxRequestsPerMinute
+		}
+	}
+	if patch.DefaultFilters != nil {
+		if patch.DefaultFilters.HideUnknown != nil {
+			next.DefaultFilters.HideUnknown = *patch.DefaultFilters.HideUnknown
+		}
+		if patch.DefaultFilters.HidePrivateIPs != nil {
+			next.DefaultFilters.HidePrivateIPs = *patch.DefaultFilters.HidePrivateIPs
+		}
+	}
+
+	if patch.Ingest != nil {
+		if patch.Ingest.MaxPerSecond != nil {
+			if *patch.Ingest.MaxPerSecond < 0 {
+				return RuntimeConfig{}, fmt.Errorf("ingest.maxPerSecond must be 0 (unlimited) or more")
+			}
+			next.Ingest.MaxPerSecond = *patch.Ingest.MaxPerSecond
+		}
+		if patch.Ingest.SampleRate != nil {
+			if *patch.Ingest.SampleRate < 0 || *patch.Ingest.SampleRate > 10000 {
+				return RuntimeConfig{}, fmt.Errorf("ingest.sampleRate must be between 0 and 10000")
+			}
+			next.Ingest.SampleRate = *patch.Ingest.SampleRate
+		}
+		if patch.Ingest.DropRules != nil {
+			if _, err := compileDropRules(*patch.Ingest.DropRules); err != nil {
+				return RuntimeConfig{}, fmt.Errorf("ingest.dropRules: %w", err)
+			}
+			next.Ingest.DropRules = *patch.Ingest.DropRules
+		}
+	}
+
+	if patch.OTLPSampling != nil {
+		if patch.OTLPSampling.Percent != nil {
+			next.OTLPSampling.Percent = *patch.OTLPSampling.Percent
+		}
+		if patch.OTLPSampling.DropRules != nil {
+			next.OTLPSampling.DropRules = *patch.OTLPSampling.DropRules
+		}
+		if err := next.OTLPSampling.Validate(); err != nil {
+			return RuntimeConfig{}, fmt.Errorf("otlpSampling: %w", err)
+		}
+	}
+
+	if patch.InitialLoad != nil {
+		if patch.InitialLoad.Lines != nil {
+			if *patch.InitialLoad.Lines < INITIAL_LOAD_ALL {
+				return RuntimeConfig{}, fmt.Errorf("initialLoad.lines must be -1 (entire file) or more")
+			}
+			next.InitialLoad.Lines = *patch.InitialLoad.Lines
+		}
+		if patch.InitialLoad.Sources != nil {
+			for source, lines := range patch.InitialLoad.Sources {
+				if lines < INITIAL_LOAD_ALL {
+					return RuntimeConfig{}, fmt.Errorf("initialLoad.sources[%s] must be -1 (entire file) or more", source)
+				}
+			}
+			next.InitialLoad.Sources = patch.InitialLoad.Sources
+		}
+	}
+
+	if patch.Buffers != nil {
+		if patch.Buffers.Listener != nil {
+			next.Buffers.Listener = *patch.Buffers.Listener
+		}
+		if patch.Buffers.WSSend != nil {
+			next.Buffers.WSSend = *patch.Buffers.WSSend
+		}
+		if err := validateBufferSizes(next.Buffers); err != nil {
+			return RuntimeConfig{}, err
+		}
+	}
+
+	// Geo settings go first since enabling MaxMind can still fail on load
+	if patch.Geo != nil {
+		if err := SetGeoProviderSettings(next.Geo.UseMaxMind, next.Geo.FallbackToOnline, next.Geo.MaxRequestsPerMinute); err != nil {
+			return RuntimeConfig{}, err
+		}
+	}
+
+	if patch.MaxLogs != nil {
+		logParser.SetMaxLogs(next.MaxLogs)
+	}
+	if patch.Ingest != nil {
+		ingestControl.SetConfig(next.Ingest)
+	}
+	if patch.OTLPSampling != nil {
+		otlpSampler.SetConfig(next.OTLPSampling)
+	}
+	if patch.InitialLoad != nil {
+		// Used for sources attached from now on
+		SetInitialLoadConfig(next.InitialLoad)
+	}
+	if patch.Buffers != nil {
+		// Used for clients that connect from now on
+		SetBufferSizes(next.Buffers)
+	}
+
+	// The broadcast hub picks up interval changes on its next tick
+	runtimeConfigMu.Lock()
+	statsIntervalSeconds = next.StatsIntervalSeconds
+	geoStatsIntervalSeconds = next.GeoStatsIntervalSeconds
+	defaultFilters = next.DefaultFilters
+	runtimeConfigMu.Unlock()
+
+	appLog.Info("Runtime configuration updated", "config", next)
+	return next, nil
+}
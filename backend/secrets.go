@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Sensitive settings that can also be supplied as a mounted file through a
+// <NAME>_FILE variable (Docker/Kubernetes secrets). BASIC_AUTH_USERS is not
+// listed because BASIC_AUTH_USERS_FILE already names an htpasswd file.
+var secretEnvVars = []string{
+	"API_AUTH_TOKEN",
+	"OIDC_CLIENT_SECRET",
+	"MAXMIND_LICENSE_KEY",
+	"TICKET_SECRET",
+	"DISCORD_WEBHOOK_URL",
+	"TELEGRAM_BOT_TOKEN",
+	"NTFY_TOKEN",
+	"GOTIFY_TOKEN",
+	"INFLUX_TOKEN",
+	"LOKI_PASSWORD",
+	"MQTT_PASSWORD",
+	"OTLP_AUTH_TOKENS",
+	"TRAEFIK_API_PASSWORD",
+	"TRAEFIK_API_TOKEN",
+}
+
+// Resolve <NAME>_FILE variables into their plain counterparts so the rest of
+// the code keeps reading os.Getenv. Setting both forms is an error.
+func loadSecretFiles() error {
+	for _, name := range secretEnvVars {
+		path := os.Getenv(name + "_FILE")
+		if path == "" {
+			continue
+		}
+		if os.Getenv(name) != "" {
+			return fmt.Errorf("both %s and %s_FILE are set", name, name)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s_FILE: %w", name, err)
+		}
+		// Secret files usually end with a newline that isn't part of the value
+		value := strings.TrimRight(string(data), "\r\n")
+		if value == "" {
+			return fmt.Errorf("%s_FILE %s is empty", name, path)
+		}
+
+		os.Setenv(name, value)
+		appLog.Info("Loaded secret from file", "name", name)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RATE_WINDOW_SECONDS     = 60
+	QUEUE_SATURATED_PERCENT = 90 // A queue this full is about to drop or block
+)
+
+// Counts events, keeping per-second counts for the last minute to report
+// recent rates
+type rateCounter struct {
+	mu      sync.Mutex
+	total   uint64
+	buckets [RATE_WINDOW_SECONDS + 1]uint64 // The last full minute and the current second
+	newest  int64                           // Unix second of the newest bucket
+}
+
+func (r *rateCounter) Add(n uint64) {
+	now := time.Now().Unix()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.advance(now)
+	r.buckets[now%int64(len(r.buckets))] += n
+	r.total += n
+}
+
+// Clear the buckets of seconds that passed without events
+func (r *rateCounter) advance(now int64) {
+	size := int64(len(r.buckets))
+	if now-r.newest >= size {
+		r.buckets = [RATE_WINDOW_SECONDS + 1]uint64{}
+	} else {
+		for second := r.newest + 1; second <= now; second++ {
+			r.buckets[second%size] = 0
+		}
+	}
+	if now > r.newest {
+		r.newest = now
+	}
+}
+
+// Events per second over the last complete seconds (at most a minute) and
+// the total so far
+func (r *rateCounter) Rate(seconds int) (float64, uint64) {
+	if seconds > RATE_WINDOW_SECONDS {
+		seconds = RATE_WINDOW_SECONDS
+	}
+	now := time.Now().Unix()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.advance(now)
+	var sum uint64
+	for second := now - int64(seconds); second < now; second++ {
+		sum += r.buckets[second%int64(len(r.buckets))]
+	}
+	return float64(sum) / float64(seconds), r.total
+}
+
+// The numbers that tell whether the backend keeps up with its input
+type SelfStats struct {
+	UptimeSeconds int64              `json:"uptimeSeconds"`
+	Ingestion     IngestionSelfStats `json:"ingestion"`
+	ParseErrors   RateSelfStats      `json:"parseErrors"`
+	Buffer        BufferSelfStats    `json:"buffer"`
+	Geo           GeoSelfStats       `json:"geo"`
+	Listeners     ListenerSelfStats  `json:"listeners"`
+	ParsePool     *QueueDepth        `json:"parsePool,omitempty"`
+	Runtime       RuntimeSelfStats   `json:"runtime"`
+}
+
+type IngestionSelfStats struct {
+	PerSecond10s float64           `json:"perSecond10s"`
+	PerSecond1m  float64           `json:"perSecond1m"`
+	Total        uint64            `json:"total"`
+	Dropped      map[string]uint64 `json:"dropped"`    // Sampled out, over the rate cap or matching a drop rule, by reason
+	LagSeconds   float64           `json:"lagSeconds"` // Latest entry's age when it was ingested
+}
+
+type RateSelfStats struct {
+	PerSecond1m float64 `json:"perSecond1m"`
+	Total       uint64  `json:"total"`
+}
+
+type BufferSelfStats struct {
+	Entries     int     `json:"entries"`
+	Capacity    int     `json:"capacity"`
+	FillPercent float64 `json:"fillPercent"`
+}
+
+type GeoSelfStats struct {
+	QueueDepth      int `json:"queueDepth"`
+	RetryQueueDepth int `json:"retryQueueDepth"`
+}
+
+// Channels feeding WebSocket clients; entries are dropped for a listener
+// whose channel is full
+type ListenerSelfStats struct {
+	Count              int     `json:"count"`
+	Saturated          int     `json:"saturated"` // At least QUEUE_SATURATED_PERCENT full
+	MaxFillPercent     float64 `json:"maxFillPercent"`
+	DroppedPerSecond1m float64 `json:"droppedPerSecond1m"`
+	DroppedTotal       uint64  `json:"droppedTotal"`
+}
+
+type RuntimeSelfStats struct {
+	Goroutines  int    `json:"goroutines"`
+	HeapAlloc   uint64 `json:"heapAlloc"`
+	HeapInuse   uint64 `json:"heapInuse"`
+	Sys         uint64 `json:"sys"`
+	MemoryLimit int64  `json:"memoryLimit,omitempty"`
+	GCCount     uint32 `json:"gcCount"`
+}
+
+func GetSelfStats() SelfStats {
+	snapshot := metrics.Snapshot()
+	stats := SelfStats{
+		UptimeSeconds: int64(time.Since(snapshot.StartTime).Seconds()),
+		Geo: GeoSelfStats{
+			QueueDepth:      snapshot.GeoQueueDepth,
+			RetryQueueDepth: snapshot.GeoRetryQueueDepth,
+		},
+		Runtime: RuntimeSelfStats{
+			Goroutines:  snapshot.Goroutines,
+			HeapAlloc:   snapshot.Memory.HeapAlloc,
+			HeapInuse:   snapshot.Memory.HeapInuse,
+			Sys:         snapshot.Memory.Sys,
+			MemoryLimit: goMemoryLimit(),
+			GCCount:     snapshot.Memory.NumGC,
+		},
+	}
+
+	stats.Ingestion.PerSecond10s, _ = metrics.ingestRate.Rate(10)
+	stats.Ingestion.PerSecond1m, stats.Ingestion.Total = metrics.ingestRate.Rate(RATE_WINDOW_SECONDS)
+	stats.Ingestion.Dropped = snapshot.IngestDropped
+	stats.Ingestion.LagSeconds = snapshot.IngestionLag
+	stats.ParseErrors.PerSecond1m, stats.ParseErrors.Total = parseErrorTracker.rate.Rate(RATE_WINDOW_SECONDS)
+
+	stats.Buffer.Entries = snapshot.LogsInMemory
+	stats.Buffer.Capacity = logParser.EffectiveMaxLogs()
+	stats.Buffer.FillPercent = percentOf(stats.Buffer.Entries, stats.Buffer.Capacity)
+
+	for _, depth := range logParser.listenerDepths() {
+		if depth.Name != "entries" {
+			continue
+		}
+		stats.Listeners.Count++
+		fill := percentOf(depth.Length, depth.Capacity)
+		if fill >= QUEUE_SATURATED_PERCENT {
+			stats.Listeners.Saturated++
+		}
+		if fill > stats.Listeners.MaxFillPercent {
+			stats.Listeners.MaxFillPercent = fill
+		}
+	}
+	stats.Listeners.DroppedPerSecond1m, stats.Listeners.DroppedTotal = logParser.listenerDrops.Rate(RATE_WINDOW_SECONDS)
+
+	if parsePool != nil {
+		depth := queueDepth("parsePool", parsePool.jobs)
+		stats.ParsePool = &depth
+	}
+	return stats
+}
+
+func percentOf(part, whole int) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(whole)
+}
+
+func getSelfStats(c *gin.Context) {
+	c.JSON(http.StatusOK, GetSelfStats())
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	SOURCE_STATE_ACTIVE  = "active"
+	SOURCE_STATE_MISSING = "missing" // Nothing at the path and no file open
+	SOURCE_STATE_ROTATED = "rotated" // Still finishing a file that was moved away
+)
+
+// Ingestion progress of one watched log file
+type SourceStatus struct {
+	ID          string `json:"id"`
+	Path        string `json:"path"`
+	Target      string `json:"target,omitempty"` // Resolved file when Path is a symlink
+	Tenant      string `json:"tenant,omitempty"`
+	State       string `json:"state"`
+	Mode        string `json:"mode"` // fsnotify, poll or http
+	Offset      int64  `json:"offset"`
+	Size        int64  `json:"size"`
+	Lag         int64  `json:"lag"` // Bytes written but not read yet
+	LastRead    string `json:"lastRead,omitempty"`
+	LinesParsed uint64 `json:"linesParsed"`
+	ParseErrors uint64 `json:"parseErrors"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Stable short identifier of a source for use in URLs
+func sourceID(path string) string {
+	sum := sha1.Sum([]byte(path))
+	return hex.EncodeToString(sum[:6])
+}
+
+func (fw *FileWatcher) Status() SourceStatus {
+	info, statErr := os.Stat(fw.filePath)
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	status := SourceStatus{
+		ID:          sourceID(fw.filePath),
+		Path:        fw.filePath,
+		Tenant:      tenancy.TenantForSource(fw.filePath),
+		Mode:        "fsnotify",
+		Offset:      fw.lastPos,
+		LinesParsed: fw.linesParsed,
+		ParseErrors: fw.parseErrors,
+	}
+	if fw.PollOnly() {
+		status.Mode = "poll"
+	}
+	if fw.target != "" && fw.target != filepath.Clean(fw.filePath) {
+		status.Target = fw.target
+	}
+	if !fw.lastRead.IsZero() {
+		status.LastRead = fw.lastRead.Format(time.RFC3339)
+	}
+
+	switch {
+	case fw.file != nil && (statErr != nil || (fw.fileInfo != nil && !os.SameFile(fw.fileInfo, info))):
+		status.State = SOURCE_STATE_ROTATED
+		// Report progress through the file still being read
+		if openInfo, err := fw.file.Stat(); err == nil {
+			status.Size = openInfo.Size()
+		}
+	case statErr != nil:
+		status.State = SOURCE_STATE_MISSING
+	default:
+		status.State = SOURCE_STATE_ACTIVE
+		status.Size = info.Size()
+	}
+
+	if status.Size > status.Offset {
+		status.Lag = status.Size - status.Offset
+	}
+	return status
+}
+
+// Get the status of every watched log file
+func (lp *LogParser) SourceStatuses() []SourceStatus {
+	lp.sourcesMu.Lock()
+	watchers := make([]*FileWatcher, len(lp.fileWatchers))
+	copy(watchers, lp.fileWatchers)
+	tailers := make([]*RemoteTailer, len(lp.remoteTailers))
+	copy(tailers, lp.remoteTailers)
+	lp.sourcesMu.Unlock()
+
+	statuses := make([]SourceStatus, 0, len(watchers)+len(tailers))
+	for _, fw := range watchers {
+		statuses = append(statuses, fw.Status())
+	}
+	for _, rt := range tailers {
+		statuses = append(statuses, rt.Status())
+	}
+	return statuses
+}
+
+func getSources(c *gin.Context) {
+	tenant := requestTenant(c)
+	sources := make([]SourceStatus, 0)
+	for _, status := range logParser.SourceStatuses() {
+		if tenant == "" || status.Tenant == tenant {
+			sources = append(sources, status)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"sources": sources})
+}
+
+// Look up a source by its ID
+func findSourceStatus(id string) *SourceStatus {
+	for _, s := range logParser.SourceStatuses() {
+		if s.ID == id {
+			return &s
+		}
+	}
+	return nil
+}
+
+// Re-read a watched file, or a byte/time range of it, through the parser
+func backfillSource(c *gin.Context) {
+	status := findSourceStatus(c.Param("id"))
+	if status == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown source"})
+		return
+	}
+	if status.Mode == "http" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Backfill is only supported for local files"})
+		return
+	}
+
+	var req BackfillRange
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.ToByte > 0 && req.ToByte < req.FromByte {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "toByte must not be before fromByte"})
+		return
+	}
+
+	result, err := logParser.BackfillSource(status.Path, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	broadcastSystemEvent("logSourceBackfilled", map[string]interface{}{"file": status.Path, "accepted": result.Accepted})
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DEFAULT_STATS_CACHE_INTERVAL    = time.Second
+	DEFAULT_STATS_CACHE_MAX_ENTRIES = 1000
+)
+
+// Computed stats snapshots shared by every reader (WebSocket pushes, REST
+// pollers) until they go stale: after the interval, after maxEntries newly
+// counted entries, or right away when the stats are reset. Snapshots are
+// immutable and swapped atomically, so reading a fresh one takes no lock;
+// while one reader recomputes a stale snapshot, the others keep getting the
+// previous one instead of waiting. Their maps must be treated as read-only.
+type StatsCache struct {
+	interval   atomic.Int64 // Nanoseconds; 0 disables caching
+	maxEntries atomic.Uint64
+	stats      sync.Map // Tenant ("" for global) -> *cachedValue[Stats]
+	geo        cachedValue[GeoStats]
+
+	updates atomic.Uint64 // Entries counted into the stats
+	epoch   atomic.Uint64 // Bumped when the stats are reset
+	version func() uint64 // The parser's data version
+}
+
+type cachedValue[T any] struct {
+	refresh  sync.Mutex // Held while computing, so only one reader recomputes
+	snapshot atomic.Pointer[statsSnapshot[T]]
+}
+
+type statsSnapshot[T any] struct {
+	value    T
+	computed time.Time
+	updates  uint64
+	epoch    uint64
+	version  uint64
+}
+
+func NewStatsCache(version func() uint64, interval time.Duration, maxEntries int) *StatsCache {
+	cache := &StatsCache{version: version}
+	cache.Configure(interval, maxEntries)
+	return cache
+}
+
+// Change how long snapshots live; interval 0 turns the cache off
+func (c *StatsCache) Configure(interval time.Duration, maxEntries int) {
+	c.interval.Store(int64(max(interval, 0)))
+	c.maxEntries.Store(uint64(max(maxEntries, 1)))
+}
+
+// Record that an entry was counted into the stats
+func (c *StatsCache) Counted() {
+	c.updates.Add(1)
+}
+
+// Drop every snapshot, e.g. after the stats were reset or rebuilt
+func (c *StatsCache) Invalidate() {
+	c.epoch.Add(1)
+}
+
+func (c *StatsCache) entry(tenant string) *cachedValue[Stats] {
+	if entry, ok := c.stats.Load(tenant); ok {
+		return entry.(*cachedValue[Stats])
+	}
+	entry, _ := c.stats.LoadOrStore(tenant, &cachedValue[Stats]{})
+	return entry.(*cachedValue[Stats])
+}
+
+func (c *StatsCache) Stats(tenant string, compute func() Stats) Stats {
+	return cachedGet(c, c.entry(tenant), compute)
+}
+
+func (c *StatsCache) GeoStats(compute func() GeoStats) GeoStats {
+	return cachedGet(c, &c.geo, compute)
+}
+
+// Return the cached value, recomputing it first if it is stale
+func cachedGet[T any](c *StatsCache, entry *cachedValue[T], compute func() T) T {
+	interval := time.Duration(c.interval.Load())
+	if interval == 0 {
+		return compute()
+	}
+
+	fresh := func(snapshot *statsSnapshot[T]) bool {
+		return snapshot != nil && snapshot.epoch == c.epoch.Load() &&
+			c.updates.Load()-snapshot.updates < c.maxEntries.Load() &&
+			time.Since(snapshot.computed) < interval
+	}
+	current := entry.snapshot.Load()
+	if fresh(current) {
+		return current.value
+	}
+
+	// Someone else is already recomputing: serve the previous snapshot,
+	// unless this is the first one and there is nothing to serve yet
+	if !entry.refresh.TryLock() {
+		if current != nil && current.epoch == c.epoch.Load() {
+			return current.value
+		}
+		entry.refresh.Lock()
+	}
+	defer entry.refresh.Unlock()
+	if current = entry.snapshot.Load(); fresh(current) {
+		return current.value
+	}
+
+	next := &statsSnapshot[T]{
+		computed: time.Now(),
+		updates:  c.updates.Load(),
+		epoch:    c.epoch.Load(),
+		version:  c.version(),
+	}
+	next.value = compute()
+	entry.snapshot.Store(next)
+	return next.value
+}
+
+// Check whether a snapshot predates the current data version, so responses
+// built from it must not carry validators for that version
+func (c *StatsCache) Behind(tenant string) bool {
+	entry, ok := c.stats.Load(tenant)
+	return ok && cachedBehind(c, entry.(*cachedValue[Stats]))
+}
+
+func (c *StatsCache) GeoBehind() bool {
+	return cachedBehind(c, &c.geo)
+}
+
+func cachedBehind[T any](c *StatsCache, entry *cachedValue[T]) bool {
+	snapshot := entry.snapshot.Load()
+	return snapshot != nil && snapshot.version != c.version()
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// A compute function returning how many times it ran
+func countingStats() (func() Stats, *atomic.Int64) {
+	var calls atomic.Int64
+	return func() Stats {
+		return Stats{TotalRequests: int(calls.Add(1))}
+	}, &calls
+}
+
+func TestStatsCacheInvalidation(t *testing.T) {
+	var version atomic.Uint64
+	for _, tt := range []struct {
+		name  string
+		stale func(c *StatsCache)
+	}{
+		{"interval", func(c *StatsCache) { time.Sleep(30 * time.Millisecond) }},
+		{"entries", func(c *StatsCache) {
+			for i := 0; i < 5; i++ {
+				c.Counted()
+			}
+		}},
+		{"reset", func(c *StatsCache) { c.Invalidate() }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			interval := time.Hour
+			if tt.name == "interval" {
+				interval = 20 * time.Millisecond
+			}
+			c := NewStatsCache(version.Load, interval, 5)
+			compute, calls := countingStats()
+
+			if got := c.Stats("", compute).TotalRequests; got != 1 {
+				t.Fatalf("first read = %d", got)
+			}
+			// Short of every limit the snapshot is shared
+			for i := 0; i < 4; i++ {
+				c.Counted()
+			}
+			if got := c.Stats("", compute).TotalRequests; got != 1 {
+				t.Fatalf("fresh snapshot recomputed (%d)", got)
+			}
+
+			tt.stale(c)
+			if got := c.Stats("", compute).TotalRequests; got != 2 {
+				t.Errorf("stale snapshot served (%d)", got)
+			}
+			if got := c.Stats("", compute).TotalRequests; got != 2 || calls.Load() != 2 {
+				t.Errorf("new snapshot not cached (%d, %d computes)", got, calls.Load())
+			}
+		})
+	}
+}
+
+func TestStatsCacheDisabled(t *testing.T) {
+	c := NewStatsCache(func() uint64 { return 0 }, 0, 5)
+	compute, calls := countingStats()
+	for i := 0; i < 3; i++ {
+		c.Stats("", compute)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("%d computes with caching off, want 3", calls.Load())
+	}
+
+	// Reconfiguring turns it back on
+	c.Configure(time.Hour, 5)
+	c.Stats("", compute)
+	c.Stats("", compute)
+	if calls.Load() != 4 {
+		t.Errorf("%d computes after enabling the cache, want 4", calls.Load())
+	}
+}
+
+func TestStatsCacheTenants(t *testing.T) {
+	var version atomic.Uint64
+	c := NewStatsCache(version.Load, time.Hour, 1000)
+	for _, tenant := range []string{"", "acme", "globex"} {
+		c.Stats(tenant, func() Stats { return Stats{Services: map[string]int{tenant: 1}} })
+	}
+
+	// Each tenant keeps its own snapshot
+	for _, tenant := range []string{"", "acme", "globex"} {
+		stats := c.Stats(tenant, func() Stats {
+			t.Errorf("%q recomputed", tenant)
+			return Stats{}
+		})
+		if len(stats.Services) != 1 || stats.Services[tenant] != 1 {
+			t.Errorf("%q served %v", tenant, stats.Services)
+		}
+	}
+
+	// A new data version makes every snapshot behind, a reset drops them all
+	if c.Behind("acme") || c.Behind("initech") {
+		t.Error("snapshot behind before any change")
+	}
+	version.Add(1)
+	if !c.Behind("acme") || !c.Behind("") || c.Behind("initech") {
+		t.Error("snapshots not behind after the data changed")
+	}
+	c.Invalidate()
+	for _, tenant := range []string{"", "acme", "globex"} {
+		computed := false
+		c.Stats(tenant, func() Stats {
+			computed = true
+			return Stats{}
+		})
+		if !computed {
+			t.Errorf("%q served a snapshot from before the reset", tenant)
+		}
+	}
+	if c.Behind("acme") {
+		t.Error("recomputed snapshot still behind")
+	}
+}
+
+func TestStatsCacheServesPreviousWhileRecomputing(t *testing.T) {
+	c := NewStatsCache(func() uint64 { return 0 }, time.Hour, 1)
+	c.Stats("", func() Stats { return Stats{TotalRequests: 1} })
+	c.Counted()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan Stats)
+	go func() {
+		done <- c.Stats("", func() Stats {
+			close(started)
+			<-release
+			return Stats{TotalRequests: 2}
+		})
+	}()
+	<-started
+	if got := c.Stats("", func() Stats { return Stats{TotalRequests: 3} }).TotalRequests; got != 1 {
+		t.Errorf("concurrent reader got %d, want the previous snapshot", got)
+	}
+
+	// After a reset there is nothing valid to serve, so readers wait
+	c.Invalidate()
+	waited := make(chan Stats)
+	go func() {
+		waited <- c.Stats("", func() Stats { return Stats{TotalRequests: 4} })
+	}()
+	select {
+	case stats := <-waited:
+		t.Fatalf("reader did not wait after the reset (%d)", stats.TotalRequests)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	if got := (<-done).TotalRequests; got != 2 {
+		t.Errorf("recomputing reader got %d", got)
+	}
+	if got := (<-waited).TotalRequests; got != 4 {
+		t.Errorf("waiting reader got %d, want a snapshot from after the reset", got)
+	}
+}
+
+func TestScopedStatsCache(t *testing.T) {
+	newTenantTestRouter(t)
+	logParser.statsCache.Configure(time.Hour, 1000)
+	expect := func(tenant string, total int, services ...string) {
+		t.Helper()
+		stats := logParser.GetScopedStats(tenant)
+		if stats.TotalRequests != total || len(stats.Services) != len(services) {
+			t.Fatalf("%q stats: %d requests, services %v", tenant, stats.TotalRequests, stats.Services)
+		}
+		for _, service := range services {
+			if stats.Services[service] == 0 {
+				t.Fatalf("%q stats missing %s: %v", tenant, service, stats.Services)
+			}
+		}
+	}
+	expect("", 3, "acme@docker", "globex@docker", "untenanted@docker")
+	expect("acme", 1, "acme@docker")
+	expect("globex", 1, "globex@docker")
+
+	// New entries stay hidden until the entry limit is reached
+	var acmeLog string
+	for _, fw := range logParser.fileWatchers {
+		if strings.Contains(fw.filePath, "acme") {
+			acmeLog = fw.filePath
+		}
+	}
+	logParser.statsCache.Configure(time.Hour, 3)
+	for i := 0; i < 2; i++ {
+		logParser.parseLine(acmeLog, fmt.Sprintf(`{"RequestPath":"/acme/%d","ServiceName":"acme@docker","DownstreamStatus":200,"time":"2026-10-15T10:00:01Z"}`, i), false)
+	}
+	expect("acme", 1, "acme@docker")
+	logParser.parseLine(acmeLog, `{"RequestPath":"/acme/2","ServiceName":"acme@docker","DownstreamStatus":200,"time":"2026-10-15T10:00:02Z"}`, false)
+	expect("", 6, "acme@docker", "globex@docker", "untenanted@docker")
+	expect("acme", 4, "acme@docker")
+	expect("globex", 1, "globex@docker")
+
+	// Resetting a source is seen right away, by every tenant
+	logParser.ResetSource(acmeLog)
+	expect("", 2, "globex@docker", "untenanted@docker")
+	expect("acme", 0)
+	expect("globex", 1, "globex@docker")
+	logParser.ClearLogs()
+	expect("", 0)
+	expect("globex", 0)
+}
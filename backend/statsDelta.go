@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Compute the fields of next that differ from prev, keyed by JSON name.
+// Map fields are diffed per key; keys that disappeared are sent as null.
+func diffStats(prev, next *Stats) map[string]interface{} {
+	delta := make(map[string]interface{})
+
+	prevValue := reflect.ValueOf(prev).Elem()
+	nextValue := reflect.ValueOf(next).Elem()
+	statsType := nextValue.Type()
+
+	for i := 0; i < statsType.NumField(); i++ {
+		name := strings.Split(statsType.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		prevField := prevValue.Field(i)
+		nextField := nextValue.Field(i)
+
+		if nextField.Kind() == reflect.Map {
+			// Between null and an empty map there are no keys to diff
+			if prevField.IsNil() != nextField.IsNil() {
+				delta[name] = nextField.Interface()
+				continue
+			}
+			if changes := diffMap(prevField, nextField); len(changes) > 0 {
+				delta[name] = changes
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(prevField.Interface(), nextField.Interface()) {
+			delta[name] = nextField.Interface()
+		}
+	}
+
+	return delta
+}
+
+// Diff two maps of the same type, returning changed and removed entries with
+// keys formatted as strings for JSON/MessagePack
+func diffMap(prev, next reflect.Value) map[string]interface{} {
+	changes := make(map[string]interface{})
+
+	iter := next.MapRange()
+	for iter.Next() {
+		prevEntry := reflect.Value{}
+		if !prev.IsNil() {
+			prevEntry = prev.MapIndex(iter.Key())
+		}
+		if !prevEntry.IsValid() || !reflect.DeepEqual(prevEntry.Interface(), iter.Value().Interface()) {
+			changes[fmt.Sprint(iter.Key().Interface())] = iter.Value().Interface()
+		}
+	}
+
+	if !prev.IsNil() {
+		iter = prev.MapRange()
+		for iter.Next() {
+			if next.IsNil() || !next.MapIndex(iter.Key()).IsValid() {
+				changes[fmt.Sprint(iter.Key().Interface())] = nil
+			}
+		}
+	}
+
+	return changes
+}
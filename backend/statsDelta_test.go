@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// Round-trip through JSON, as the stats and deltas go over the wire
+func jsonObject(t *testing.T, value interface{}) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		t.Fatal(err)
+	}
+	return object
+}
+
+// Apply a statsDelta the way clients do: objects are merged per key with
+// null removing the key, everything else is replaced
+func applyStatsDelta(stats, delta map[string]interface{}) {
+	for field, value := range delta {
+		changes, isMap := value.(map[string]interface{})
+		current, _ := stats[field].(map[string]interface{})
+		if !isMap {
+			stats[field] = value
+			continue
+		}
+		if current == nil {
+			current = make(map[string]interface{})
+			stats[field] = current
+		}
+		for key, entry := range changes {
+			if entry == nil {
+				delete(current, key)
+			} else {
+				current[key] = entry
+			}
+		}
+	}
+}
+
+func checkStatsDelta(t *testing.T, prev, next Stats) map[string]interface{} {
+	t.Helper()
+	delta := jsonObject(t, diffStats(&prev, &next))
+	applied := jsonObject(t, prev)
+	applyStatsDelta(applied, delta)
+	if want := jsonObject(t, next); !reflect.DeepEqual(applied, want) {
+		t.Fatalf("delta %v\napplied: %v\nwant:    %v", delta, applied, want)
+	}
+	return delta
+}
+
+func TestStatsDeltaApplies(t *testing.T) {
+	prev := Stats{
+		TotalRequests: 10,
+		StatusCodes:   map[int]int{200: 8, 404: 1, 502: 1},
+		Services:      map[string]int{"api@docker": 9, "web@docker": 1},
+		Methods:       map[string]int{"GET": 10},
+		TopIPs:        []IPCount{{IP: "203.0.113.7", Count: 10}},
+		DataSources:   map[string]int{"logfile": 10},
+	}
+	next := Stats{
+		TotalRequests: 12,
+		StatusCodes:   map[int]int{200: 11, 502: 1},
+		Services:      map[string]int{"api@docker": 11, "admin@docker": 1},
+		Routers:       map[string]int{"api@docker": 12},
+		Methods:       map[string]int{"GET": 10},
+		TopIPs:        []IPCount{{IP: "203.0.113.7", Count: 11}, {IP: "198.51.100.2", Count: 1}},
+		DataSources:   map[string]int{},
+	}
+
+	delta := checkStatsDelta(t, prev, next)
+	// Only what changed is sent, and removed keys are sent as null
+	want := map[string]interface{}{
+		"totalRequests": 12.0,
+		"statusCodes":   map[string]interface{}{"200": 11.0, "404": nil},
+		"services":      map[string]interface{}{"api@docker": 11.0, "web@docker": nil, "admin@docker": 1.0},
+		"routers":       map[string]interface{}{"api@docker": 12.0},
+		"topIPs":        []interface{}{map[string]interface{}{"ip": "203.0.113.7", "count": 11.0}, map[string]interface{}{"ip": "198.51.100.2", "count": 1.0}},
+		"dataSources":   map[string]interface{}{"logfile": nil},
+	}
+	if !reflect.DeepEqual(delta, want) {
+		t.Errorf("delta = %v\nwant %v", delta, want)
+	}
+
+	if delta := diffStats(&next, &next); len(delta) != 0 {
+		t.Errorf("unchanged stats produced %v", delta)
+	}
+	// Maps going from null to empty and back are sent whole
+	checkStatsDelta(t, Stats{}, next)
+	checkStatsDelta(t, next, Stats{})
+}
+
+func TestStatsDeltaFromParser(t *testing.T) {
+	lp := NewLogParser()
+	lp.statsCache.Configure(0, 1)
+	line := func(service, method string, status int, ip string) string {
+		return fmt.Sprintf(`{"ClientAddr":"%s:51234","RequestMethod":%q,"RequestPath":"/%s","DownstreamStatus":%d,"Duration":%d,"ServiceName":"%s@docker","RouterName":"%s@docker","time":"2026-10-15T10:00:00Z"}`,
+			ip, method, service, status, status*1000, service, service)
+	}
+	parse := func(source, line string) {
+		t.Helper()
+		if !lp.parseLine(source, line, false) {
+			t.Fatalf("line rejected: %s", line)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		parse("api.log", line("api", "GET", 200, "203.0.113.7"))
+		parse("web.log", line("web", "POST", 404, "198.51.100.2"))
+	}
+	prev := lp.GetStats()
+
+	// New keys, changed counts and keys dropped with the source they came from
+	parse("api.log", line("api", "DELETE", 500, "192.0.2.1"))
+	parse("admin.log", line("admin", "PUT", 302, "192.0.2.9"))
+	lp.ResetSource("web.log")
+	next := lp.GetStats()
+
+	delta := checkStatsDelta(t, prev, next)
+	for field, key := range map[string]string{"services": "web@docker", "methods": "POST", "statusCodes": "404"} {
+		changes, _ := delta[field].(map[string]interface{})
+		if value, ok := changes[key]; !ok || value != nil {
+			t.Errorf("%s.%s not removed in %v", field, key, delta[field])
+		}
+	}
+
+	// And back from a cleared parser
+	lp.ClearLogs()
+	checkStatsDelta(t, next, lp.GetStats())
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	STATSD_MAX_PACKET     = 1432 // Fits a typical MTU without fragmentation
+	STATSD_QUEUE_SIZE     = 10000
+	STATSD_FLUSH_INTERVAL = time.Second
+	STATSD_GAUGE_INTERVAL = 10 * time.Second
+)
+
+var statsdNameEscaper = strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", " ", "_", "#", "_", ",", "_")
+
+// Emits per-request counters and timers over UDP in StatsD or DogStatsD
+// format. Lines are batched into packets and dropped rather than blocking
+// ingestion when the queue is full.
+type StatsDEmitter struct {
+	conn      net.Conn
+	prefix    string
+	dogstatsd bool
+	tags      []string // Constant DogStatsD tags
+	queue     chan string
+	stop      chan struct{}
+}
+
+var statsdEmitter *StatsDEmitter
+
+// Create the emitter from STATSD_ADDR; nil when unset
+func NewStatsDEmitter() (*StatsDEmitter, error) {
+	addr := os.Getenv("STATSD_ADDR")
+	if addr == "" {
+		return nil, nil
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid STATSD_ADDR: %w", err)
+	}
+
+	var tags []string
+	for _, tag := range strings.Split(os.Getenv("STATSD_TAGS"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	prefix := GetEnvString("STATSD_PREFIX", "traefik")
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+
+	e := &StatsDEmitter{
+		conn:      conn,
+		prefix:    prefix,
+		dogstatsd: GetEnvBool("STATSD_DOGSTATSD", false),
+		tags:      tags,
+		queue:     make(chan string, STATSD_QUEUE_SIZE),
+		stop:      make(chan struct{}),
+	}
+	go e.run()
+	appLog.Info("Emitting StatsD metrics", "address", addr, "dogstatsd", e.dogstatsd)
+	return e, nil
+}
+
+// Format a metric line. Plain StatsD has no tags, so tag values are folded
+// into the metric name instead (traefik.requests.<service>.<status>).
+func (e *StatsDEmitter) line(name, value, kind string, tags map[string]string, order []string) string {
+	if e.dogstatsd {
+		all := append([]string(nil), e.tags...)
+		for _, key := range order {
+			all = append(all, key+":"+statsdNameEscaper.Replace(tags[key]))
+		}
+		if len(all) == 0 {
+			return fmt.Sprintf("%s%s:%s|%s", e.prefix, name, value, kind)
+		}
+		return fmt.Sprintf("%s%s:%s|%s|#%s", e.prefix, name, value, kind, strings.Join(all, ","))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(e.prefix)
+	sb.WriteString(name)
+	for _, key := range order {
+		sb.WriteByte('.')
+		sb.WriteString(statsdNameEscaper.Replace(tags[key]))
+	}
+	return fmt.Sprintf("%s:%s|%s", sb.String(), value, kind)
+}
+
+func (e *StatsDEmitter) enqueue(line string) {
+	select {
+	case e.queue <- line:
+	default:
+		// Metrics are best effort; never slow down ingestion
+	}
+}
+
+// Emit metrics for one ingested request
+func (e *StatsDEmitter) Observe(entry *LogEntry) {
+	service := entry.ServiceName
+	if service == "" {
+		service = "unknown"
+	}
+	tags := map[string]string{
+		"service": service,
+		"status":  fmt.Sprintf("%dxx", entry.Status/100),
+	}
+	order := []string{"service", "status"}
+
+	e.enqueue(e.line("requests", "1", "c", tags, order))
+	e.enqueue(e.line("request.duration", fmt.Sprintf("%g", entry.ResponseTime), "ms", tags, order[:1]))
+	if entry.Size > 0 {
+		e.enqueue(e.line("response.bytes", fmt.Sprintf("%d", entry.Size), "c", tags, order[:1]))
+	}
+}
+
+// Batch queued lines into packets and send periodic dashboard gauges
+func (e *StatsDEmitter) run() {
+	flush := time.NewTicker(STATSD_FLUSH_INTERVAL)
+	gauges := time.NewTicker(STATSD_GAUGE_INTERVAL)
+	defer flush.Stop()
+	defer gauges.Stop()
+
+	var packet bytes.Buffer
+	send := func() {
+		if packet.Len() == 0 {
+			return
+		}
+		// UDP write errors (e.g. no agent listening) are expected and ignored
+		e.conn.Write(packet.Bytes())
+		packet.Reset()
+	}
+
+	for {
+		select {
+		case line := <-e.queue:
+			if packet.Len() > 0 && packet.Len()+1+len(line) > STATSD_MAX_PACKET {
+				send()
+			}
+			if packet.Len() > 0 {
+				packet.WriteByte('\n')
+			}
+			packet.WriteString(line)
+		case <-flush.C:
+			send()
+		case <-gauges.C:
+			e.emitGauges()
+		case <-e.stop:
+			send()
+			e.conn.Close()
+			return
+		}
+	}
+}
+
+func (e *StatsDEmitter) emitGauges() {
+	s := metrics.Snapshot()
+	for name, value := range map[string]string{
+		"websocket.clients": fmt.Sprintf("%d", s.WebSocketClients),
+		"geo.queue_depth":   fmt.Sprintf("%d", s.GeoQueueDepth),
+		"ingestion.lag_ms":  fmt.Sprintf("%d", int64(s.IngestionLag*1000)),
+		"logs.in_memory":    fmt.Sprintf("%d", s.LogsInMemory),
+	} {
+		e.enqueue(e.line(name, value, "g", nil, nil))
+	}
+}
+
+func (e *StatsDEmitter) Stop() {
+	close(e.stop)
+}
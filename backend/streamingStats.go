@@ -0,0 +1,259 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Exact counts kept in descending order as they are incremented, so the top
+// N is read without sorting. Entries with the same count are contiguous;
+// incrementing swaps the entry with the first of its group, which keeps the
+// order in O(1) per increment (the "stream summary" layout).
+type TopCounter struct {
+	order []topCount     // Highest count first
+	index map[string]int // Key -> position in order
+	first map[int]int    // Count -> position of the first entry with it
+}
+
+type topCount struct {
+	Key   string
+	Count int
+}
+
+func NewTopCounter() *TopCounter {
+	return &TopCounter{index: make(map[string]int), first: make(map[int]int)}
+}
+
+func (t *TopCounter) Inc(key string) {
+	pos, ok := t.index[key]
+	if !ok {
+		// New keys have the lowest count and go last
+		t.order = append(t.order, topCount{Key: key, Count: 1})
+		pos = len(t.order) - 1
+		t.index[key] = pos
+		if _, ok := t.first[1]; !ok {
+			t.first[1] = pos
+		}
+		return
+	}
+
+	count := t.order[pos].Count
+	head := t.first[count]
+	if head != pos {
+		t.order[head], t.order[pos] = t.order[pos], t.order[head]
+		t.index[t.order[pos].Key] = pos
+		t.index[key] = head
+	}
+	t.order[head].Count++
+
+	if head+1 < len(t.order) && t.order[head+1].Count == count {
+		t.first[count] = head + 1
+	} else {
+		delete(t.first, count)
+	}
+	if _, ok := t.first[count+1]; !ok {
+		t.first[count+1] = head
+	}
+}
+
+func (t *TopCounter) Add(key string, n int) {
+	for i := 0; i < n; i++ {
+		t.Inc(key)
+	}
+}
+
+func (t *TopCounter) Len() int {
+	return len(t.order)
+}
+
+// Drop every key beyond the keep highest counts; returns how many were
+// dropped. The index maps are rebuilt since Go maps never shrink.
+func (t *TopCounter) Trim(keep int) int {
+	keep = max(keep, 0)
+	if len(t.order) <= keep {
+		return 0
+	}
+	dropped := len(t.order) - keep
+	order := make([]topCount, keep)
+	copy(order, t.order)
+	t.order = order
+	t.index = make(map[string]int, keep)
+	t.first = make(map[int]int)
+	for pos, item := range order {
+		t.index[item.Key] = pos
+		if _, ok := t.first[item.Count]; !ok {
+			t.first[item.Count] = pos
+		}
+	}
+	return dropped
+}
+
+// Get up to limit keys with the highest counts (all of them when limit <= 0)
+func (t *TopCounter) Top(limit int) []topCount {
+	if limit <= 0 || limit > len(t.order) {
+		limit = len(t.order)
+	}
+	top := make([]topCount, limit)
+	copy(top, t.order[:limit])
+	return top
+}
+
+// Convert the top entries, like getTopItems does for plain maps
+func topCounts[T any](t *TopCounter, limit int, converter func(string, int) T) []T {
+	top := t.Top(limit)
+	result := make([]T, 0, len(top))
+	for _, item := range top {
+		result = append(result, converter(item.Key, item.Count))
+	}
+	return result
+}
+
+// Mean of the last N values with a running sum
+type RecentMean struct {
+	values []float64
+	next   int
+	sum    float64
+}
+
+func NewRecentMean(size int) *RecentMean {
+	return &RecentMean{values: make([]float64, 0, size)}
+}
+
+func (m *RecentMean) Add(value float64) {
+	if len(m.values) < cap(m.values) {
+		m.values = append(m.values, value)
+		m.sum += value
+		return
+	}
+	m.sum += value - m.values[m.next]
+	m.values[m.next] = value
+	m.next = (m.next + 1) % len(m.values)
+	if m.next == 0 {
+		// Re-add from scratch once per lap so float error can't build up
+		m.sum = 0
+		for _, v := range m.values {
+			m.sum += v
+		}
+	}
+}
+
+func (m *RecentMean) Mean() float64 {
+	if len(m.values) == 0 {
+		return 0
+	}
+	return m.sum / float64(len(m.values))
+}
+
+// Merging t-digest: approximate quantiles of a stream in constant memory,
+// most accurate at the tails (p95/p99) where it matters for latency
+type TDigest struct {
+	compression float64
+	centroids   []centroid // Sorted by mean
+	buffer      []float64  // Values not merged into centroids yet
+	count       float64
+	min, max    float64
+}
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+const (
+	DEFAULT_TDIGEST_COMPRESSION = 200
+	RECENT_RESPONSE_TIME_WINDOW = 100
+)
+
+func NewTDigest(compression float64) *TDigest {
+	return &TDigest{compression: compression, min: math.Inf(1), max: math.Inf(-1)}
+}
+
+func (t *TDigest) Add(value float64) {
+	t.buffer = append(t.buffer, value)
+	t.count++
+	t.min = math.Min(t.min, value)
+	t.max = math.Max(t.max, value)
+	if len(t.buffer) >= int(t.compression)*5 {
+		t.centroids = t.merged()
+		t.buffer = t.buffer[:0]
+	}
+}
+
+func (t *TDigest) Count() int {
+	return int(t.count)
+}
+
+// Merge buffered values into the centroids without modifying the digest, so
+// quantiles can be read under a read lock
+func (t *TDigest) merged() []centroid {
+	if len(t.buffer) == 0 {
+		return t.centroids
+	}
+	all := make([]centroid, 0, len(t.centroids)+len(t.buffer))
+	all = append(all, t.centroids...)
+	for _, value := range t.buffer {
+		all = append(all, centroid{mean: value, weight: 1})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+
+	// k1 scale function: a centroid may span one unit of k, which keeps
+	// centroids small near q=0 and q=1
+	scale := func(q float64) float64 {
+		return t.compression / (2 * math.Pi) * math.Asin(2*math.Min(q, 1)-1)
+	}
+
+	result := make([]centroid, 0, int(t.compression))
+	current := all[0]
+	seen := 0.0
+	kLeft := scale(0)
+	for _, next := range all[1:] {
+		if scale((seen+current.weight+next.weight)/t.count)-kLeft <= 1 {
+			weight := current.weight + next.weight
+			current.mean += (next.mean - current.mean) * next.weight / weight
+			current.weight = weight
+			continue
+		}
+		result = append(result, current)
+		seen += current.weight
+		kLeft = scale(seen / t.count)
+		current = next
+	}
+	return append(result, current)
+}
+
+// Estimate the value at quantile q (0..1)
+func (t *TDigest) Quantile(q float64) float64 {
+	if t.count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return t.min
+	}
+	if q >= 1 {
+		return t.max
+	}
+	centroids := t.merged()
+	if len(centroids) == 1 {
+		return centroids[0].mean
+	}
+
+	// Interpolate between centroid midpoints, anchored at min and max
+	target := q * t.count
+	prevMean, prevPos := t.min, 0.0
+	seen := 0.0
+	for _, c := range centroids {
+		mid := seen + c.weight/2
+		if target < mid {
+			if mid == prevPos {
+				return c.mean
+			}
+			return prevMean + (c.mean-prevMean)*(target-prevPos)/(mid-prevPos)
+		}
+		prevMean, prevPos = c.mean, mid
+		seen += c.weight
+	}
+	if t.count == prevPos {
+		return t.max
+	}
+	return prevMean + (t.max-prevMean)*(target-prevPos)/(t.count-prevPos)
+}
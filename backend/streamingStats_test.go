@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// Check the counts match exactly and the stream-summary invariants hold
+func checkTopCounter(t *testing.T, counter *TopCounter, exact map[string]int) {
+	t.Helper()
+	if counter.Len() != len(exact) {
+		t.Fatalf("%d keys, want %d", counter.Len(), len(exact))
+	}
+	top := counter.Top(0)
+	for pos, item := range top {
+		if item.Count != exact[item.Key] {
+			t.Fatalf("%s counted %d, want %d", item.Key, item.Count, exact[item.Key])
+		}
+		if pos > 0 && top[pos-1].Count < item.Count {
+			t.Fatalf("%s (%d) ranked below %s (%d)", item.Key, item.Count, top[pos-1].Key, top[pos-1].Count)
+		}
+		if counter.index[item.Key] != pos {
+			t.Fatalf("%s indexed at %d, stored at %d", item.Key, counter.index[item.Key], pos)
+		}
+		if (pos == 0 || top[pos-1].Count != item.Count) && counter.first[item.Count] != pos {
+			t.Fatalf("count %d starts at %d, recorded %d", item.Count, pos, counter.first[item.Count])
+		}
+	}
+	if len(counter.first) > len(top) {
+		t.Fatalf("%d count groups for %d keys", len(counter.first), len(top))
+	}
+}
+
+// The limit highest exact counts; ties may be ranked in any order, so the
+// expected top N is compared as counts plus the set of keys strictly above
+// the cutoff
+func checkTop(t *testing.T, counter *TopCounter, exact map[string]int, limit int) {
+	t.Helper()
+	counts := make([]int, 0, len(exact))
+	for _, count := range exact {
+		counts = append(counts, count)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+	top := counter.Top(limit)
+	if len(top) != min(limit, len(counts)) {
+		t.Fatalf("top %d returned %d keys", limit, len(top))
+	}
+	for i, item := range top {
+		if item.Count != counts[i] {
+			t.Fatalf("top[%d] = %s (%d), want count %d", i, item.Key, item.Count, counts[i])
+		}
+	}
+	if len(top) == 0 {
+		return
+	}
+	cutoff := top[len(top)-1].Count
+	returned := make(map[string]bool, len(top))
+	for _, item := range top {
+		returned[item.Key] = true
+	}
+	for key, count := range exact {
+		if count > cutoff && !returned[key] {
+			t.Fatalf("%s (%d) missing from the top %d", key, count, limit)
+		}
+	}
+}
+
+func TestTopCounterMatchesExactCounts(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(rng, 1.2, 1, 2000)
+	counter := NewTopCounter()
+	exact := make(map[string]int)
+	for i := 0; i < 50000; i++ {
+		key := fmt.Sprintf("key-%d", zipf.Uint64())
+		if i%1000 == 0 {
+			n := 1 + rng.Intn(50)
+			counter.Add(key, n)
+			exact[key] += n
+			continue
+		}
+		counter.Inc(key)
+		exact[key]++
+	}
+	checkTopCounter(t, counter, exact)
+	for _, limit := range []int{1, 10, 100, len(exact) + 5} {
+		checkTop(t, counter, exact, limit)
+	}
+}
+
+func TestTopCounterTrim(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	zipf := rand.NewZipf(rng, 1.1, 1, 5000)
+	counter := NewTopCounter()
+	exact := make(map[string]int)
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 20000; i++ {
+			key := fmt.Sprintf("key-%d", zipf.Uint64())
+			counter.Inc(key)
+			exact[key]++
+		}
+
+		keep := 100
+		expected := counter.Top(keep)
+		if dropped := counter.Trim(keep); dropped != len(exact)-keep {
+			t.Fatalf("dropped %d keys, want %d", dropped, len(exact)-keep)
+		}
+		// The kept keys are the highest counts, in the same order
+		top := counter.Top(0)
+		if len(top) != keep {
+			t.Fatalf("%d keys kept, want %d", len(top), keep)
+		}
+		for i := range top {
+			if top[i] != expected[i] {
+				t.Fatalf("top[%d] = %v after trimming, want %v", i, top[i], expected[i])
+			}
+		}
+		checkTop(t, counter, exact, keep)
+
+		// Dropped keys start over if seen again
+		kept := make(map[string]int, keep)
+		for _, item := range top {
+			kept[item.Key] = exact[item.Key]
+		}
+		exact = kept
+		checkTopCounter(t, counter, exact)
+	}
+
+	if dropped := counter.Trim(1000); dropped != 0 {
+		t.Errorf("trimming below the limit dropped %d keys", dropped)
+	}
+	if counter.Trim(-1); counter.Len() != 0 {
+		t.Errorf("%d keys left after trimming to nothing", counter.Len())
+	}
+	counter.Inc("again")
+	checkTopCounter(t, counter, map[string]int{"again": 1})
+}
+
+// Fraction of sorted values below value, the rank the estimate actually has
+func rankOf(sorted []float64, value float64) float64 {
+	below := sort.SearchFloat64s(sorted, value)
+	above := sort.Search(len(sorted), func(i int) bool { return sorted[i] > value })
+	return (float64(below) + float64(above)) / 2 / float64(len(sorted))
+}
+
+func TestTDigestQuantiles(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	distributions := map[string]func() float64{
+		"uniform":     func() float64 { return rng.Float64() * 1000 },
+		"exponential": func() float64 { return rng.ExpFloat64() * 50 },
+		"lognormal":   func() float64 { return math.Exp(rng.NormFloat64()*1.5 + 3) },
+		"bimodal": func() float64 {
+			if rng.Intn(10) == 0 {
+				return 2000 + rng.NormFloat64()*100
+			}
+			return 20 + rng.NormFloat64()*5
+		},
+	}
+	// Rank error allowed at each quantile; the k1 scale keeps the tails tight
+	quantiles := []struct{ q, tolerance float64 }{
+		{0.001, 0.0005}, {0.01, 0.002}, {0.1, 0.005}, {0.25, 0.01}, {0.5, 0.01},
+		{0.75, 0.01}, {0.9, 0.005}, {0.95, 0.003}, {0.99, 0.002}, {0.999, 0.0005},
+	}
+
+	for name, next := range distributions {
+		for _, n := range []int{500, 100000} {
+			t.Run(fmt.Sprintf("%s/%d", name, n), func(t *testing.T) {
+				digest := NewTDigest(DEFAULT_TDIGEST_COMPRESSION)
+				values := make([]float64, n)
+				for i := range values {
+					values[i] = next()
+					digest.Add(values[i])
+				}
+				sort.Float64s(values)
+
+				if digest.Count() != n {
+					t.Errorf("count = %d, want %d", digest.Count(), n)
+				}
+				if digest.Quantile(0) != values[0] || digest.Quantile(1) != values[n-1] {
+					t.Errorf("range = %v..%v, want %v..%v", digest.Quantile(0), digest.Quantile(1), values[0], values[n-1])
+				}
+				if centroids := len(digest.merged()); centroids > DEFAULT_TDIGEST_COMPRESSION {
+					t.Errorf("%d centroids", centroids)
+				}
+				for _, quantile := range quantiles {
+					estimate := digest.Quantile(quantile.q)
+					// A few hundred values are short of one merge, so only
+					// the rank granularity limits them
+					tolerance := math.Max(quantile.tolerance, 1/float64(n))
+					if rank := rankOf(values, estimate); math.Abs(rank-quantile.q) > tolerance {
+						t.Errorf("p%v = %.3f has rank %.4f (exact %.3f)", quantile.q*100, estimate, rank, values[int(quantile.q*float64(n))])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestTDigestSmall(t *testing.T) {
+	digest := NewTDigest(DEFAULT_TDIGEST_COMPRESSION)
+	if digest.Quantile(0.5) != 0 {
+		t.Errorf("empty digest returned %v", digest.Quantile(0.5))
+	}
+	digest.Add(42)
+	for _, q := range []float64{0, 0.5, 0.99, 1} {
+		if got := digest.Quantile(q); got != 42 {
+			t.Errorf("single value quantile %v = %v", q, got)
+		}
+	}
+	digest.Add(10)
+	if got := digest.Quantile(0.5); got < 10 || got > 42 {
+		t.Errorf("median of 10 and 42 = %v", got)
+	}
+
+	// Reading quantiles must not merge the buffer
+	if len(digest.buffer) != 2 || len(digest.centroids) != 0 {
+		t.Errorf("reading modified the digest: %d buffered, %d centroids", len(digest.buffer), len(digest.centroids))
+	}
+}
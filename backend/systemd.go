@@ -0,0 +1,146 @@
+package main
+
+import (
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const DEFAULT_SYSTEMD_WATCHDOG = 30 * time.Second // WatchdogSec in generated units
+
+// Tell systemd about a state change (READY=1, STOPPING=1, WATCHDOG=1,
+// STATUS=...) when running as a Type=notify service; a no-op otherwise
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	// Abstract namespace sockets are given with a leading @
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		appLog.Warn("Cannot reach systemd notify socket", "error", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		appLog.Warn("Cannot notify systemd", "state", state, "error", err)
+	}
+}
+
+// Interval systemd expects watchdog pings at, from WATCHDOG_USEC; zero when
+// the watchdog is off or meant for another process
+func sdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Ping the systemd watchdog at half its interval while the parser still
+// responds, so a wedged process is restarted rather than left serving
+// stale data
+func startSystemdWatchdog() {
+	interval := sdWatchdogInterval()
+	if interval == 0 {
+		return
+	}
+	appLog.Info("systemd watchdog enabled", "interval", interval)
+	go func() {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+		for range ticker.C {
+			if parserResponsive(interval / 4) {
+				sdNotify("WATCHDOG=1")
+			} else {
+				appLog.Warn("Parser locks unavailable, skipping systemd watchdog ping", "waited", interval/4)
+			}
+		}
+	}()
+}
+
+// Whether the parser's locks can be taken within timeout. A probe stuck
+// behind a deadlock is left behind; the watchdog restarts the process soon.
+func parserResponsive(timeout time.Duration) bool {
+	if logParser == nil {
+		return true
+	}
+	done := make(chan struct{})
+	go func() {
+		logParser.mu.RLock()
+		logParser.mu.RUnlock()
+		logParser.statsMu.Lock()
+		logParser.statsMu.Unlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Settings for a generated unit file
+type SystemdUnit struct {
+	Binary          string
+	ConfigFile      string
+	User            string
+	WorkingDir      string
+	WatchdogSeconds int
+	StopSeconds     int      // Drain timeout plus time to exit
+	ReadWritePaths  []string // Writable despite ProtectSystem=strict (blocklist file, ...)
+}
+
+var systemdUnitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description=Traefik Log Dashboard
+Documentation=https://github.com/hhftechnology/traefik-log-dashboard
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+# Ready once the API is listening; SIGHUP reloads the config file
+Type=notify
+NotifyAccess=main
+ExecStart={{.Binary}} serve
+ExecReload=/bin/kill -HUP $MAINPID
+Environment=CONFIG_FILE={{.ConfigFile}}
+{{- if .WorkingDir}}
+WorkingDirectory={{.WorkingDir}}
+{{- end}}
+User={{.User}}
+Restart=on-failure
+RestartSec=5
+{{- if .WatchdogSeconds}}
+# Restarted if it stops responding
+WatchdogSec={{.WatchdogSeconds}}
+{{- end}}
+# SIGTERM drains: clients and forwarders get the tail of the logs first
+KillSignal=SIGTERM
+TimeoutStopSec={{.StopSeconds}}
+
+NoNewPrivileges=true
+ProtectSystem=strict
+ProtectHome=true
+PrivateTmp=true
+{{- range .ReadWritePaths}}
+ReadWritePaths={{.}}
+{{- end}}
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+func (u SystemdUnit) Write(w io.Writer) error {
+	return systemdUnitTemplate.Execute(w, u)
+}
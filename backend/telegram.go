@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const TELEGRAM_API_URL = "https://api.telegram.org"
+
+var telegramSeverityIcons = map[string]string{
+	SEVERITY_CRITICAL: "🔴",
+	SEVERITY_WARNING:  "🟡",
+	SEVERITY_INFO:     "🟢",
+}
+
+// A daily time range, possibly wrapping past midnight (22:00-07:00)
+type QuietHours struct {
+	Start    time.Duration // Offset from midnight
+	End      time.Duration
+	Location *time.Location
+}
+
+// Parse "HH:MM-HH:MM" in the given IANA timezone (local time when empty)
+func ParseQuietHours(value, timezone string) (*QuietHours, error) {
+	if value == "" {
+		return nil, nil
+	}
+	startText, endText, ok := strings.Cut(value, "-")
+	if !ok {
+		return nil, fmt.Errorf("quiet hours must look like 22:00-07:00")
+	}
+	start, err := parseClock(startText)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(endText)
+	if err != nil {
+		return nil, err
+	}
+
+	location := time.Local
+	if timezone != "" {
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid quiet hours timezone: %w", err)
+		}
+	}
+	return &QuietHours{Start: start, End: end, Location: location}, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Check whether t falls inside the quiet hours
+func (q *QuietHours) Contains(t time.Time) bool {
+	if q == nil || q.Start == q.End {
+		return false
+	}
+	local := t.In(q.Location)
+	offset := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
+
+// Sends notifications through the Telegram Bot API. During quiet hours
+// messages are still delivered, but silently.
+type TelegramNotifier struct {
+	token      string
+	chatID     string
+	quietHours *QuietHours
+	client     *http.Client
+}
+
+// Create the notifier from TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID; nil when unset
+func NewTelegramNotifier() (*TelegramNotifier, error) {
+	token := os.Getenv("TELEGRAM_BOT_TOKEN")
+	chatID := os.Getenv("TELEGRAM_CHAT_ID")
+	if token == "" && chatID == "" {
+		return nil, nil
+	}
+	if token == "" || chatID == "" {
+		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID must both be set")
+	}
+
+	quietHours, err := ParseQuietHours(os.Getenv("TELEGRAM_QUIET_HOURS"), os.Getenv("TELEGRAM_QUIET_TIMEZONE"))
+	if err != nil {
+		return nil, fmt.Errorf("TELEGRAM_QUIET_HOURS: %w", err)
+	}
+
+	return &TelegramNotifier{
+		token:      token,
+		chatID:     chatID,
+		quietHours: quietHours,
+		client:     &http.Client{},
+	}, nil
+}
+
+func (t *TelegramNotifier) Name() string {
+	return "telegram"
+}
+
+func (t *TelegramNotifier) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"chat_id":                  t.chatID,
+		"text":                     formatTelegramMessage(n),
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+		"disable_notification":     t.quietHours.Contains(time.Now()),
+	})
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/bot%s/sendMessage", TELEGRAM_API_URL, t.token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doNotifyRequest(t.client, req)
+}
+
+func formatTelegramMessage(n Notification) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s <b>%s</b>\n%s", telegramSeverityIcons[n.Severity], html.EscapeString(n.Title), html.EscapeString(n.Message))
+
+	if n.Service != "" {
+		fmt.Fprintf(&sb, "\n\n<b>Service:</b> %s", html.EscapeString(n.Service))
+	}
+	if n.Tenant != "" {
+		fmt.Fprintf(&sb, "\n<b>Tenant:</b> %s", html.EscapeString(n.Tenant))
+	}
+	if paths, ok := n.Details["topPaths"].([]PathErrorCount); ok && len(paths) > 0 {
+		sb.WriteString("\n<b>Top paths:</b>")
+		for _, p := range paths {
+			fmt.Fprintf(&sb, "\n• <code>%s</code> (%d)", html.EscapeString(truncate(p.Path, 80)), p.Errors)
+		}
+	}
+	if file, ok := n.Details["file"]; ok {
+		fmt.Fprintf(&sb, "\n<b>File:</b> <code>%s</code>", html.EscapeString(fmt.Sprint(file)))
+	}
+	return sb.String()
+}
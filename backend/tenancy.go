@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context key holding the tenant a request is scoped to
+const TENANT_KEY = "tenant"
+
+// A named tenant owning a set of log sources, with API tokens scoped to it
+type Tenant struct {
+	Name string `json:"name"`
+	// Log file paths or wildcard patterns; a trailing "/" matches a directory
+	Sources []string `json:"sources"`
+	// OTLP service name patterns assigned to this tenant
+	OTLPServices []string `json:"otlpServices"`
+	// Bearer tokens that only see this tenant's data
+	Tokens []string `json:"tokens"`
+	// Overrides for API_RATE_LIMIT_PER_MINUTE and API_DAILY_QUOTA on this tenant's tokens
+	RateLimitPerMinute int `json:"rateLimitPerMinute,omitempty"`
+	DailyQuota         int `json:"dailyQuota,omitempty"`
+}
+
+// Tenancy layer loaded from TENANTS_FILE. Entries from sources no tenant
+// claims stay untenanted and are only visible to global users.
+type Tenancy struct {
+	Tenants []Tenant `json:"tenants"`
+}
+
+var tenancy *Tenancy
+
+// Load tenants from the JSON file named by TENANTS_FILE; nil when unset
+func LoadTenancy() (*Tenancy, error) {
+	path := os.Getenv("TENANTS_FILE")
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file: %w", err)
+	}
+
+	var t Tenancy
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants file: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, tenant := range t.Tenants {
+		if tenant.Name == "" {
+			return nil, fmt.Errorf("tenant without a name in %s", path)
+		}
+		if seen[tenant.Name] {
+			return nil, fmt.Errorf("duplicate tenant %q in %s", tenant.Name, path)
+		}
+		seen[tenant.Name] = true
+	}
+
+	appLog.Info("Multi-tenancy enabled", "tenants", len(t.Tenants))
+	return &t, nil
+}
+
+func (t *Tenancy) Enabled() bool {
+	return t != nil && len(t.Tenants) > 0
+}
+
+// Get the tenant owning a log file
+func (t *Tenancy) TenantForSource(source string) string {
+	if !t.Enabled() {
+		return ""
+	}
+	for _, tenant := range t.Tenants {
+		for _, pattern := range tenant.Sources {
+			if matchSourcePattern(pattern, source) {
+				return tenant.Name
+			}
+		}
+	}
+	return ""
+}
+
+// Get the tenant owning an OTLP service
+func (t *Tenancy) TenantForService(service string) string {
+	if !t.Enabled() {
+		return ""
+	}
+	for _, tenant := range t.Tenants {
+		for _, pattern := range tenant.OTLPServices {
+			if matchPattern(pattern, service) {
+				return tenant.Name
+			}
+		}
+	}
+	return ""
+}
+
+// Get the tenant a bearer token is scoped to
+func (t *Tenancy) TenantForToken(token string) (string, bool) {
+	if !t.Enabled() || token == "" {
+		return "", false
+	}
+	for _, tenant := range t.Tenants {
+		for _, candidate := range tenant.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+				return tenant.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Get the tenant the request is scoped to, empty for global access
+func requestTenant(c *gin.Context) string {
+	return c.GetString(TENANT_KEY)
+}
+
+// Restrict filters to the request's tenant. Global users may still pick a
+// tenant themselves through the tenant filter.
+func scopeFilters(c *gin.Context, filters *Filters) {
+	if tenant := requestTenant(c); tenant != "" {
+		filters.Tenant = tenant
+	}
+}
+
+// Middleware rejecting tenant-scoped requests on endpoints that expose or
+// change global state
+func requireGlobalAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if requestTenant(c) != "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Not available to tenant-scoped tokens"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Get stats, restricted to a tenant when one is given
+func (lp *LogParser) GetScopedStats(tenant string) Stats {
+	if tenant == "" {
+		return lp.GetStats()
+	}
+	return lp.statsCache.Stats(tenant, func() Stats {
+		stats, _ := lp.GetFilteredStats(Filters{Tenant: tenant})
+		return stats
+	})
+}
+
+// Get geo stats, restricted to a tenant when one is given
+func (lp *LogParser) GetScopedGeoStats(tenant string) GeoStats {
+	if tenant == "" {
+		return lp.GetGeoStats()
+	}
+	stats := lp.GetScopedStats(tenant)
+	return geoStatsFromStats(&stats)
+}
+
+// Build geo stats from a (filtered) stats snapshot
+func geoStatsFromStats(stats *Stats) GeoStats {
+	return GeoStats{
+		Countries:              stats.TopCountries,
+		TotalCountries:         len(stats.TopCountries),
+		GeoProcessingRemaining: stats.GeoProcessingRemaining,
+	}
+}
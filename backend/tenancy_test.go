@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+// The router over a buffer holding entries of tenants acme and globex and of
+// a source no tenant claims, all in one trace
+func newTenantTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("LOG_POLL_ONLY_SOURCES", "*")
+	t.Setenv("API_AUTH_TOKEN", "api-secret")
+	savedParser, savedTenancy, savedWSAuth, savedWriter := logParser, tenancy, wsAuth, gin.DefaultWriter
+	t.Cleanup(func() {
+		logParser, tenancy, wsAuth, gin.DefaultWriter = savedParser, savedTenancy, savedWSAuth, savedWriter
+	})
+
+	tenancy = &Tenancy{Tenants: []Tenant{
+		{Name: "acme", Sources: []string{filepath.Join(dir, "acme") + "/"}, Tokens: []string{"acme-secret"}},
+		{Name: "globex", Sources: []string{filepath.Join(dir, "globex") + "/"}, Tokens: []string{"globex-secret"}},
+	}}
+	wsAuth = NewWSAuth()
+	logParser = NewLogParser()
+	for i, source := range []string{"acme", "globex", "untenanted"} {
+		file := filepath.Join(dir, source, "access.log")
+		if source == "untenanted" {
+			file = filepath.Join(dir, "access.log")
+		}
+		line := fmt.Sprintf(`{"ClientAddr":"203.0.113.7:51234","RequestMethod":"GET","RequestPath":"/%s/page","DownstreamStatus":200,"Duration":1000000,"ServiceName":"%s@docker","RouterName":"%s@docker","TraceId":%q,"SpanId":"00f067aa0ba902b%d","time":"2026-10-15T10:00:00Z"}`,
+			source, source, source, testTraceID, i)
+		if !logParser.parseLine(file, line, false) {
+			t.Fatalf("line from %s rejected", source)
+		}
+		fw, err := NewFileWatcher(file, logParser)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logParser.fileWatchers = append(logParser.fileWatchers, fw)
+	}
+
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	return newRouter()
+}
+
+func serveTenantRequest(r *gin.Engine, method, target, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.RemoteAddr = "127.0.0.1:40000"
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestTenantTokenIsScoped(t *testing.T) {
+	r := newTenantTestRouter(t)
+	batch := `[{"id":"a","type":"aggregate","groupBy":"service","filters":{"tenant":"globex"}},{"id":"s","type":"stats"}]`
+	for _, tt := range []struct {
+		method, path, body string
+	}{
+		{"GET", "/api/logs", ""},
+		{"GET", "/api/logs?tenant=globex", ""},
+		{"GET", "/api/stats", ""},
+		{"GET", "/api/stats?tenant=globex", ""},
+		{"POST", "/api/batch", batch},
+		{"GET", "/api/export", ""},
+		{"GET", "/api/export?tenant=globex&format=csv", ""},
+		{"GET", "/api/sources", ""},
+		{"GET", "/api/traces/" + testTraceID, ""},
+		{"GET", "/api/traces/" + testTraceID + "?tenant=globex", ""},
+		{"GET", "/api/traces/" + testTraceID + "/waterfall", ""},
+		{"GET", "/api/services", ""},
+		{"GET", "/api/facets?fields=service,tenant", ""},
+	} {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := serveTenantRequest(r, tt.method, tt.path, "acme-secret", tt.body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			body := w.Body.String()
+			if !strings.Contains(body, "acme") {
+				t.Errorf("response has none of the tenant's data: %s", body)
+			}
+			for _, other := range []string{"globex", "untenanted"} {
+				if strings.Contains(body, other) {
+					t.Errorf("response leaks %s data: %s", other, body)
+				}
+			}
+		})
+	}
+
+	// The global API token sees every tenant
+	if body := serveTenantRequest(r, "GET", "/api/logs", "api-secret", "").Body.String(); !strings.Contains(body, "globex") {
+		t.Errorf("global request misses other tenants: %s", body)
+	}
+}
+
+func TestTenantTokenRequired(t *testing.T) {
+	r := newTenantTestRouter(t)
+	if w := serveTenantRequest(r, "GET", "/api/logs", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status %d, want 401", w.Code)
+	}
+	if w := serveTenantRequest(r, "GET", "/api/logs", "wrong-secret", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("unknown token: status %d, want 401", w.Code)
+	}
+}
+
+// Every route behind requireGlobalAccess, including the admin group and the
+// debug endpoints
+var globalRoutes = []string{
+	"GET /api/geo-processing-status",
+	"POST /api/set-log-file",
+	"POST /api/set-log-files",
+	"POST /api/reset-log-source",
+	"POST /api/sources/:id/backfill",
+	"GET /api/otlp/status",
+	"POST /api/otlp/start",
+	"POST /api/otlp/stop",
+	"GET /api/otlp/stats",
+	"GET /api/otlp/metrics",
+	"GET /api/otlp/metrics/compare",
+	"GET /api/traefik/routers",
+	"GET /api/traefik/services",
+	"GET /api/traefik/middlewares",
+	"GET /api/docker/services",
+	"GET /api/docker/stacks",
+	"GET /api/reports",
+	"POST /api/reports",
+	"GET /api/reports/:id",
+	"GET /api/maxmind/config",
+	"POST /api/maxmind/reload",
+	"POST /api/maxmind/test",
+	"GET /api/config/validate",
+	"GET /api/diagnostics",
+	"GET /api/admin/config",
+	"PATCH /api/admin/config",
+	"POST /api/admin/reload",
+	"POST /api/admin/import",
+	"POST /api/admin/drain",
+	"GET /api/admin/usage",
+	"GET /api/admin/blocklist",
+	"DELETE /api/admin/blocklist/:ip",
+	"GET /api/websocket/status",
+	"DELETE /api/admin/websocket/clients/:id",
+	"GET /metrics",
+	"GET /api/self-stats",
+	"GET /api/debug/runtime",
+	"GET /debug/pprof/",
+	"GET /debug/pprof/:name",
+}
+
+func TestTenantTokenForbiddenOnGlobalRoutes(t *testing.T) {
+	t.Setenv("DEBUG_ENDPOINTS_ENABLED", "true")
+	r := newTenantTestRouter(t)
+	registered := make(map[string]bool)
+	for _, route := range r.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+
+	for _, route := range globalRoutes {
+		if !registered[route] {
+			t.Errorf("%s is not registered", route)
+			continue
+		}
+		method, path, _ := strings.Cut(route, " ")
+		path = strings.NewReplacer(":id", "x", ":ip", "203.0.113.7", ":name", "heap").Replace(path)
+		w := serveTenantRequest(r, method, path, "acme-secret", "")
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "tenant-scoped") {
+			t.Errorf("%s: status %d (%s), want 403 for tenant tokens", route, w.Code, w.Body)
+		}
+	}
+}
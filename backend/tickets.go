@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	TICKET_SCOPE_WS     = "ws"
+	TICKET_SCOPE_EXPORT = "export"
+
+	DEFAULT_TICKET_TTL = 15 * time.Minute
+	MAX_TICKET_TTL     = 24 * time.Hour
+	MIN_TICKET_SECRET  = 32
+)
+
+// Claims carried by a signed ticket. Export tickets pin the query string so
+// a shared link can't be widened to other filters.
+type TicketClaims struct {
+	Scope     string `json:"scope"`
+	Tenant    string `json:"tenant,omitempty"`
+	Query     string `json:"query,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Issues and verifies stateless HMAC-SHA256 tickets. Unlike the single-use
+// WebSocket tokens, a ticket can be reused by anyone holding it until it
+// expires, so links can be shared within a team.
+type TicketSigner struct {
+	key []byte
+}
+
+var ticketSigner *TicketSigner
+
+// Create the signer from TICKET_SECRET. Without it a random key is used and
+// tickets stop working after a restart.
+func NewTicketSigner() (*TicketSigner, error) {
+	secret := os.Getenv("TICKET_SECRET")
+	if secret == "" {
+		key := make([]byte, MIN_TICKET_SECRET)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		return &TicketSigner{key: key}, nil
+	}
+	if len(secret) < MIN_TICKET_SECRET {
+		return nil, fmt.Errorf("TICKET_SECRET must be at least %d characters", MIN_TICKET_SECRET)
+	}
+	appLog.Info("Signed tickets use the configured TICKET_SECRET")
+	return &TicketSigner{key: []byte(secret)}, nil
+}
+
+// Sign claims into "<payload>.<signature>", both base64url encoded
+func (s *TicketSigner) Sign(claims TicketClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded)), nil
+}
+
+// Verify a ticket's signature, expiry and scope
+func (s *TicketSigner) Verify(ticket, scope string) (TicketClaims, error) {
+	var claims TicketClaims
+
+	encoded, signature, ok := strings.Cut(ticket, ".")
+	if !ok {
+		return claims, errors.New("malformed ticket")
+	}
+	given, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(given, s.mac(encoded)) {
+		return claims, errors.New("invalid ticket signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return claims, errors.New("malformed ticket")
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, errors.New("malformed ticket")
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return claims, errors.New("ticket expired")
+	}
+	if claims.Scope != scope {
+		return claims, fmt.Errorf("ticket is not valid for %s", scope)
+	}
+	return claims, nil
+}
+
+func (s *TicketSigner) mac(data string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Check whether a request presents a ticket on an endpoint that accepts one;
+// the handler verifies it
+func hasTicket(c *gin.Context) bool {
+	path := c.Request.URL.Path
+	return (path == "/ws" || path == "/api/export") && requestTicket(c) != ""
+}
+
+// Read the ticket without c.Query: gin caches the query on first use, and
+// exportLogs replaces it with the query pinned in the ticket
+func requestTicket(c *gin.Context) string {
+	return c.Request.URL.Query().Get("ticket")
+}
+
+type ticketRequest struct {
+	Scope      string `json:"scope"`
+	TTLSeconds int    `json:"ttlSeconds"`
+	// Export filters as a query string, e.g. "service=api&format=csv"
+	Query string `json:"query"`
+}
+
+func issueTicket(c *gin.Context) {
+	var req ticketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	ttl := DEFAULT_TICKET_TTL
+	if req.TTLSeconds != 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+		if ttl < 0 || ttl > MAX_TICKET_TTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("ttlSeconds must be between 1 and %d", int(MAX_TICKET_TTL/time.Second))})
+			return
+		}
+	}
+
+	var path string
+	switch req.Scope {
+	case TICKET_SCOPE_WS:
+		path = "/ws"
+		req.Query = ""
+	case TICKET_SCOPE_EXPORT:
+		path = "/api/export"
+		req.Query = strings.TrimPrefix(req.Query, "?")
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be ws or export"})
+		return
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	ticket, err := ticketSigner.Sign(TicketClaims{
+		Scope:     req.Scope,
+		Tenant:    requestTenant(c),
+		Query:     req.Query,
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue ticket"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ticket":    ticket,
+		"expiresAt": expiresAt.Format(time.RFC3339),
+		"url":       path + "?ticket=" + ticket,
+	})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func newTestTicketSigner(t *testing.T) *TicketSigner {
+	t.Helper()
+	t.Setenv("TICKET_SECRET", strings.Repeat("s", MIN_TICKET_SECRET))
+	signer, err := NewTicketSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := ticketSigner
+	ticketSigner = signer
+	t.Cleanup(func() { ticketSigner = saved })
+	return signer
+}
+
+func TestTicketVerify(t *testing.T) {
+	signer := newTestTicketSigner(t)
+	sign := func(claims TicketClaims) string {
+		ticket, err := signer.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ticket
+	}
+	expiresAt := time.Now().Add(time.Minute).Unix()
+	valid := sign(TicketClaims{Scope: TICKET_SCOPE_EXPORT, Tenant: "acme", Query: "format=csv", ExpiresAt: expiresAt})
+
+	// Another tenant's claims under the valid ticket's signature
+	payload, _ := json.Marshal(TicketClaims{Scope: TICKET_SCOPE_EXPORT, Tenant: "globex", Query: "format=csv", ExpiresAt: expiresAt})
+	_, signature, _ := strings.Cut(valid, ".")
+	tampered := base64.RawURLEncoding.EncodeToString(payload) + "." + signature
+
+	// Signed with a random key, as after a restart without TICKET_SECRET
+	t.Setenv("TICKET_SECRET", "")
+	random, err := NewTicketSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned, _ := random.Sign(TicketClaims{Scope: TICKET_SCOPE_EXPORT, ExpiresAt: expiresAt})
+
+	for _, tt := range []struct {
+		name, ticket, scope, err string
+	}{
+		{"valid", valid, TICKET_SCOPE_EXPORT, ""},
+		{"tampered payload", tampered, TICKET_SCOPE_EXPORT, "invalid ticket signature"},
+		{"tampered signature", valid[:len(valid)-2] + "AA", TICKET_SCOPE_EXPORT, "invalid ticket signature"},
+		{"other key", unsigned, TICKET_SCOPE_EXPORT, "invalid ticket signature"},
+		{"wrong scope", valid, TICKET_SCOPE_WS, "not valid for ws"},
+		{"expired", sign(TicketClaims{Scope: TICKET_SCOPE_EXPORT, ExpiresAt: time.Now().Unix() - 1}), TICKET_SCOPE_EXPORT, "ticket expired"},
+		{"malformed", "no-signature", TICKET_SCOPE_EXPORT, "malformed ticket"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := signer.Verify(tt.ticket, tt.scope)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("rejected: %v", err)
+				}
+				if claims.Scope != tt.scope {
+					t.Errorf("scope = %q", claims.Scope)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// Issue a ticket through the API with a tenant token
+func issueTestTicket(t *testing.T, server *httptest.Server, body string) string {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/tickets", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer acme-secret")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var issued struct {
+		Ticket string `json:"ticket"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&issued); err != nil || issued.Ticket == "" {
+		t.Fatalf("no ticket issued (status %d): %v", resp.StatusCode, err)
+	}
+	return issued.Ticket
+}
+
+func TestTicketRoutes(t *testing.T) {
+	newTestTicketSigner(t)
+	server := httptest.NewServer(newTenantTestRouter(t))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?ticket="
+
+	// The export ticket carries the tenant; the pinned query can't be
+	// widened and the tenant filter can't be overridden
+	export := issueTestTicket(t, server, `{"scope":"export","query":"tenant=globex&format=csv"}`)
+	for _, query := range []string{"", "&tenant=untenanted&format=ndjson"} {
+		resp, err := http.Get(server.URL + "/api/export?ticket=" + export + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("export status %d: %v", resp.StatusCode, err)
+		}
+		if got := string(body); !strings.Contains(got, "/acme/page") || strings.Contains(got, "globex") || strings.Contains(got, "untenanted") {
+			t.Errorf("export%s not scoped to the ticket's tenant: %s", query, got)
+		}
+		if !strings.HasPrefix(resp.Header.Get("Content-Disposition"), `attachment; filename="traefik-logs-`) || !strings.Contains(resp.Header.Get("Content-Disposition"), ".csv") {
+			t.Errorf("export%s ignored the pinned format: %s", query, resp.Header.Get("Content-Disposition"))
+		}
+	}
+
+	// An export ticket doesn't open the stream
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL+export, nil); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("export ticket opened /ws: %v", err)
+	}
+
+	// A WebSocket ticket does, scoped to its tenant, and can't export
+	stream := issueTestTicket(t, server, `{"scope":"ws"}`)
+	if resp, err := http.Get(server.URL + "/api/export?ticket=" + stream); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("WebSocket ticket exported logs: %v", err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+stream, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	wsClientsMux.RLock()
+	defer wsClientsMux.RUnlock()
+	for client := range wsClients {
+		if client.tenant != "acme" {
+			t.Errorf("client scoped to %q, want acme", client.tenant)
+		}
+	}
+}
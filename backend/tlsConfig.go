@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const CERT_RELOAD_CHECK_INTERVAL = 10 * time.Second // How often handshakes look for renewed files
+
+// TLS settings for a listener, read from <PREFIX>TLS_CERT_FILE,
+// <PREFIX>TLS_KEY_FILE and <PREFIX>TLS_CLIENT_CA_FILE. Setting a client CA
+// turns on mutual TLS: clients must present a certificate signed by it.
+type TLSSettings struct {
+	CertFile     string `json:"certFile,omitempty"`
+	KeyFile      string `json:"keyFile,omitempty"`
+	ClientCAFile string `json:"clientCaFile,omitempty"`
+}
+
+func GetTLSSettings(prefix string) TLSSettings {
+	return TLSSettings{
+		CertFile:     os.Getenv(prefix + "TLS_CERT_FILE"),
+		KeyFile:      os.Getenv(prefix + "TLS_KEY_FILE"),
+		ClientCAFile: os.Getenv(prefix + "TLS_CLIENT_CA_FILE"),
+	}
+}
+
+func (s TLSSettings) Enabled() bool {
+	return s.CertFile != "" && s.KeyFile != ""
+}
+
+func (s TLSSettings) MutualTLS() bool {
+	return s.ClientCAFile != ""
+}
+
+// Build the server TLS config, or nil when TLS is not configured
+func (s TLSSettings) ServerConfig() (*tls.Config, error) {
+	if !s.Enabled() {
+		if s.MutualTLS() || s.CertFile != "" || s.KeyFile != "" {
+			return nil, fmt.Errorf("TLS requires both a certificate and a key file")
+		}
+		return nil, nil
+	}
+
+	reloader, err := newCertReloader(s.CertFile, s.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+
+	if s.MutualTLS() {
+		caPEM, err := os.ReadFile(s.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", s.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// Serves a certificate pair, loading it again once the files change so
+// renewed certificates (cert-manager, certbot) are used without a restart.
+// A pair that fails to load keeps the previous one in use.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	cert, modTime, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	r.cert, r.modTime, r.checkedAt = cert, modTime, time.Now()
+	return r, nil
+}
+
+func (r *certReloader) load() (*tls.Certificate, time.Time, error) {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	return &cert, modTime, nil
+}
+
+// The later of the two files' modification times
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checkedAt) < CERT_RELOAD_CHECK_INTERVAL {
+		return r.cert, nil
+	}
+	r.checkedAt = time.Now()
+
+	if modTime, err := r.latestModTime(); err != nil || modTime.Equal(r.modTime) {
+		return r.cert, nil
+	}
+	cert, modTime, err := r.load()
+	if err != nil {
+		// Files may be mid-rotation; the next check tries again
+		appLog.Warn("Keeping previous TLS certificate", "certFile", r.certFile, "error", err)
+		return r.cert, nil
+	}
+	r.cert, r.modTime = cert, modTime
+	appLog.Info("Reloaded TLS certificate", "certFile", r.certFile)
+	return r.cert, nil
+}
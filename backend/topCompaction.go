@@ -0,0 +1,50 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	TOP_LIST_SIZE                   = 10 // Entries in each top list of the stats
+	TOP_COMPACTION_FACTOR           = 10 // Keys kept per top list entry when compacting
+	DEFAULT_TOP_COMPACTION_INTERVAL = time.Minute
+)
+
+// Periodically trim the top lists keyed by client IP, request address,
+// request host and path template, which otherwise grow by one key per distinct value ever
+// seen. Each keeps its TOP_LIST_SIZE*TOP_COMPACTION_FACTOR highest counts;
+// a dropped key that comes back starts counting again from one, which can
+// only matter for the top entries if it was already close to them.
+func (lp *LogParser) StartTopCompaction(interval time.Duration) {
+	if interval <= 0 {
+		parserLog.Info("Top list compaction disabled")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if dropped := lp.compactTopCounters(TOP_LIST_SIZE * TOP_COMPACTION_FACTOR); dropped > 0 {
+					parserLog.Debug("Compacted top lists", "droppedKeys", dropped)
+				}
+			case <-lp.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+func (lp *LogParser) compactTopCounters(keep int) int {
+	lp.statsMu.Lock()
+	defer lp.statsMu.Unlock()
+	return lp.topIPs.Trim(keep) + lp.topRequestAddrs.Trim(keep) + lp.topRequestHosts.Trim(keep) + lp.topPaths.Trim(keep)
+}
+
+// Count the keys held by the compacted top lists
+func (lp *LogParser) topCounterKeys() int {
+	lp.statsMu.RLock()
+	defer lp.statsMu.RUnlock()
+	return lp.topIPs.Len() + lp.topRequestAddrs.Len() + lp.topRequestHosts.Len() + lp.topPaths.Len()
+}
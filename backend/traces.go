@@ -0,0 +1,275 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const MAX_SPAN_EVENTS = 32 // Events kept per OTLP span
+
+// What an OTLP span adds to its entry
+type SpanInfo struct {
+	Name          string      `json:"name"`
+	Kind          string      `json:"kind"`   // Server, Client, Internal, ...
+	Status        string      `json:"status"` // Unset, Ok or Error
+	StatusMessage string      `json:"statusMessage,omitempty"`
+	Events        []SpanEvent `json:"events,omitempty"`
+	DroppedEvents int         `json:"droppedEvents,omitempty"`
+}
+
+type SpanEvent struct {
+	Name       string                 `json:"name"`
+	Time       string                 `json:"time"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// One span of a trace with the entries recorded for it: an access log line
+// and the OTLP span of the same request share a span ID and end up together
+type TraceSpan struct {
+	SpanId       string       `json:"spanId,omitempty"` // Empty for entries logged without one
+	ParentSpanId string       `json:"parentSpanId,omitempty"`
+	ServiceName  string       `json:"serviceName"`
+	Start        string       `json:"start"`
+	DurationMs   float64      `json:"durationMs"`
+	Entries      []LogEntry   `json:"entries"` // Oldest first
+	Children     []*TraceSpan `json:"children,omitempty"`
+
+	start time.Time
+}
+
+// All buffered entries of one trace as a tree of spans. Spans whose parent
+// isn't buffered (never recorded, or already evicted) are roots.
+type TraceView struct {
+	TraceId    string       `json:"traceId"`
+	Entries    int          `json:"entries"`
+	Services   []string     `json:"services"` // In order of first appearance
+	Start      string       `json:"start"`
+	End        string       `json:"end"`
+	DurationMs float64      `json:"durationMs"`
+	Retries    int          `json:"retries"` // Sum of RetryAttempts
+	Spans      []*TraceSpan `json:"spans"`   // Roots, oldest first
+
+	start time.Time
+}
+
+// When an entry's request started: StartUTC keeps Traefik's sub-second
+// precision, the timestamp is the fallback
+func entryStart(entry *LogEntry) time.Time {
+	if start, err := time.Parse(time.RFC3339Nano, entry.StartUTC); err == nil {
+		return start
+	}
+	start, _ := time.Parse(time.RFC3339, entry.Timestamp)
+	return start
+}
+
+// Build the trace view from the entries visible under the filters; nil when
+// none carries the trace ID
+func (lp *LogParser) GetTrace(traceId string, filters Filters) *TraceView {
+	var entries []LogEntry
+	lp.eachMatch(lp.logsSnapshot.Load(), filters, math.MaxUint64, func(entry *LogEntry) bool {
+		if strings.EqualFold(entry.TraceId, traceId) {
+			entries = append(entries, *entry)
+		}
+		return true
+	})
+	if len(entries) == 0 {
+		return nil
+	}
+	// Snapshots are newest first; ties keep ingestion order
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entryStart(&entries[i]).Before(entryStart(&entries[j])) ||
+			(entryStart(&entries[i]).Equal(entryStart(&entries[j])) && entries[i].Seq < entries[j].Seq)
+	})
+
+	view := &TraceView{TraceId: strings.ToLower(traceId), Entries: len(entries), Services: []string{}, Spans: []*TraceSpan{}}
+	spans := make(map[string]*TraceSpan)
+	var ordered []*TraceSpan
+	seenServices := make(map[string]bool)
+	var first, last time.Time
+	for i := range entries {
+		entry := entries[i]
+		start := entryStart(&entry)
+		end := start.Add(time.Duration(entry.Duration))
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if end.After(last) {
+			last = end
+		}
+		view.Retries += entry.RetryAttempts
+		if entry.ServiceName != "" && !seenServices[entry.ServiceName] {
+			seenServices[entry.ServiceName] = true
+			view.Services = append(view.Services, entry.ServiceName)
+		}
+
+		span := spans[entry.SpanId]
+		if span == nil || entry.SpanId == "" {
+			span = &TraceSpan{SpanId: entry.SpanId, ServiceName: entry.ServiceName, start: start, Start: start.UTC().Format(time.RFC3339Nano)}
+			ordered = append(ordered, span)
+			if entry.SpanId != "" {
+				spans[entry.SpanId] = span
+			}
+		}
+		if span.ParentSpanId == "" {
+			span.ParentSpanId = entry.ParentSpanId
+		}
+		if ms := float64(entry.Duration) / 1e6; ms > span.DurationMs {
+			span.DurationMs = ms
+		}
+		span.Entries = append(span.Entries, entry)
+	}
+
+	// ordered is by start time, so children come out oldest first too
+	for _, span := range ordered {
+		if parent := spans[span.ParentSpanId]; parent != nil && parent != span {
+			parent.Children = append(parent.Children, span)
+		} else {
+			view.Spans = append(view.Spans, span)
+		}
+	}
+
+	view.start = first
+	view.Start = first.UTC().Format(time.RFC3339Nano)
+	view.End = last.UTC().Format(time.RFC3339Nano)
+	view.DurationMs = float64(last.Sub(first)) / float64(time.Millisecond)
+	return view
+}
+
+// Handler for /api/traces/:traceId
+func getTrace(c *gin.Context) {
+	if view := lookupTrace(c); view != nil {
+		c.JSON(http.StatusOK, view)
+	}
+}
+
+// The trace named by the request, within its tenant; nil when an error
+// response was written
+func lookupTrace(c *gin.Context) *TraceView {
+	traceId := c.Param("traceId")
+	if !validTraceID(traceId) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "traceId must be 32 hex characters"})
+		return nil
+	}
+	filters := Filters{Tenant: c.Query("tenant")}
+	scopeFilters(c, &filters)
+
+	view := logParser.GetTrace(traceId, filters)
+	if view == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No buffered entries for this trace"})
+	}
+	return view
+}
+
+// One bar of a trace waterfall
+type WaterfallRow struct {
+	SpanId        string           `json:"spanId,omitempty"`
+	ParentSpanId  string           `json:"parentSpanId,omitempty"`
+	Depth         int              `json:"depth"`
+	Name          string           `json:"name"` // Span name, or method and path for log lines
+	Kind          string           `json:"kind,omitempty"`
+	ServiceName   string           `json:"serviceName"`
+	OffsetMs      float64          `json:"offsetMs"` // From the start of the trace
+	DurationMs    float64          `json:"durationMs"`
+	HTTPStatus    int              `json:"httpStatus,omitempty"`
+	Status        string           `json:"status"` // The span's status; Unset for log lines
+	StatusMessage string           `json:"statusMessage,omitempty"`
+	Error         bool             `json:"error"` // Error status or a 5xx response
+	Retries       int              `json:"retries,omitempty"`
+	Events        []WaterfallEvent `json:"events,omitempty"`
+	Entries       int              `json:"entries"`
+}
+
+type WaterfallEvent struct {
+	Name       string                 `json:"name"`
+	OffsetMs   float64                `json:"offsetMs"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+type Waterfall struct {
+	TraceId    string         `json:"traceId"`
+	Start      string         `json:"start"`
+	DurationMs float64        `json:"durationMs"`
+	Rows       []WaterfallRow `json:"rows"` // Depth first, children after their parent
+}
+
+// Flatten a trace view into waterfall rows
+func (view *TraceView) Waterfall() Waterfall {
+	waterfall := Waterfall{TraceId: view.TraceId, Start: view.Start, DurationMs: view.DurationMs, Rows: []WaterfallRow{}}
+	offset := func(at time.Time) float64 {
+		return float64(at.Sub(view.start)) / float64(time.Millisecond)
+	}
+
+	var walk func(span *TraceSpan, depth int)
+	walk = func(span *TraceSpan, depth int) {
+		row := WaterfallRow{
+			SpanId:       span.SpanId,
+			ParentSpanId: span.ParentSpanId,
+			Depth:        depth,
+			ServiceName:  span.ServiceName,
+			OffsetMs:     offset(span.start),
+			DurationMs:   span.DurationMs,
+			Status:       "Unset",
+			Entries:      len(span.Entries),
+		}
+		// The access log line has the response Traefik sent; spans only
+		// have what their attributes say
+		statusEntry := &span.Entries[0]
+		for i := range span.Entries {
+			entry := &span.Entries[i]
+			if entry.DataSource != "otlp" && statusEntry.DataSource == "otlp" {
+				statusEntry = entry
+			}
+			row.Retries += entry.RetryAttempts
+			if entry.Span == nil || row.Kind != "" {
+				continue
+			}
+			row.Name = entry.Span.Name
+			row.Kind = entry.Span.Kind
+			row.Status = entry.Span.Status
+			row.StatusMessage = entry.Span.StatusMessage
+			for _, event := range entry.Span.Events {
+				at, _ := time.Parse(time.RFC3339Nano, event.Time)
+				row.Events = append(row.Events, WaterfallEvent{Name: event.Name, OffsetMs: offset(at), Attributes: event.Attributes})
+			}
+		}
+		row.HTTPStatus = statusEntry.Status
+		if row.Name == "" {
+			row.Name = statusEntry.Method + " " + statusEntry.Path
+		}
+		row.Error = row.Status == "Error" || row.HTTPStatus >= 500
+		waterfall.Rows = append(waterfall.Rows, row)
+
+		for _, child := range span.Children {
+			walk(child, depth+1)
+		}
+	}
+	for _, span := range view.Spans {
+		walk(span, 0)
+	}
+	return waterfall
+}
+
+// Handler for /api/traces/:traceId/waterfall
+func getTraceWaterfall(c *gin.Context) {
+	if view := lookupTrace(c); view != nil {
+		c.JSON(http.StatusOK, view.Waterfall())
+	}
+}
+
+// W3C trace IDs: 16 bytes as hex, not all zero
+func validTraceID(id string) bool {
+	if len(id) != 32 || strings.Trim(id, "0") == "" {
+		return false
+	}
+	for _, ch := range id {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", ch) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DEFAULT_TRAEFIK_API_POLL_INTERVAL = 30 * time.Second
+	TRAEFIK_API_PAGE_SIZE             = 100
+	TRAEFIK_API_MAX_PAGES             = 100
+)
+
+// A router as Traefik's API reports it
+type TraefikRouter struct {
+	Name        string                 `json:"name"`
+	Provider    string                 `json:"provider"`
+	Rule        string                 `json:"rule"`
+	EntryPoints []string               `json:"entryPoints"`
+	Middlewares []string               `json:"middlewares,omitempty"`
+	Service     string                 `json:"service"`
+	Priority    int64                  `json:"priority,omitempty"`
+	TLS         map[string]interface{} `json:"tls,omitempty"`
+	Status      string                 `json:"status"` // enabled, disabled or warning
+	Error       []string               `json:"error,omitempty"`
+}
+
+type TraefikService struct {
+	Name         string            `json:"name"`
+	Provider     string            `json:"provider"`
+	Type         string            `json:"type"`
+	Status       string            `json:"status"`
+	ServerStatus map[string]string `json:"serverStatus,omitempty"` // Server URL to UP or DOWN, with a health check
+	UsedBy       []string          `json:"usedBy,omitempty"`
+}
+
+type TraefikMiddleware struct {
+	Name     string   `json:"name"`
+	Provider string   `json:"provider"`
+	Type     string   `json:"type"`
+	Status   string   `json:"status"`
+	UsedBy   []string `json:"usedBy,omitempty"`
+	Error    []string `json:"error,omitempty"`
+}
+
+// The definitions from the last sync; kept when a later sync fails
+type traefikAPIState struct {
+	routers     map[string]TraefikRouter
+	services    map[string]TraefikService
+	middlewares map[string]TraefikMiddleware
+	syncedAt    time.Time
+	lastError   string
+}
+
+// Polls Traefik's API for router, service and middleware definitions, so
+// traffic can be shown with the rule, entrypoints and backend health behind
+// it, and traffic logged for routers Traefik no longer has is flagged.
+type TraefikAPIClient struct {
+	url      string
+	username string
+	password string
+	token    string
+	interval time.Duration
+	client   *http.Client
+	stop     chan struct{}
+	state    atomic.Pointer[traefikAPIState]
+	flagged  map[string]bool // Unknown routers already reported; poll goroutine only
+}
+
+var traefikAPI *TraefikAPIClient
+
+// Create the client from TRAEFIK_API_URL (e.g. http://traefik:8080); nil
+// when unset. Credentials are TRAEFIK_API_USERNAME/TRAEFIK_API_PASSWORD for
+// basic auth or TRAEFIK_API_TOKEN for a bearer token.
+func NewTraefikAPIClient() (*TraefikAPIClient, error) {
+	apiURL := os.Getenv("TRAEFIK_API_URL")
+	if apiURL == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(apiURL, "http://") && !strings.HasPrefix(apiURL, "https://") {
+		return nil, fmt.Errorf("invalid TRAEFIK_API_URL: %s", redactURL(apiURL))
+	}
+	c := &TraefikAPIClient{
+		url:      strings.TrimSuffix(strings.TrimRight(apiURL, "/"), "/api"),
+		username: os.Getenv("TRAEFIK_API_USERNAME"),
+		password: os.Getenv("TRAEFIK_API_PASSWORD"),
+		token:    os.Getenv("TRAEFIK_API_TOKEN"),
+		interval: time.Duration(GetEnvInt("TRAEFIK_API_POLL_INTERVAL_SECONDS", int(DEFAULT_TRAEFIK_API_POLL_INTERVAL/time.Second))) * time.Second,
+		stop:     make(chan struct{}),
+		flagged:  make(map[string]bool),
+	}
+	if c.interval < 5*time.Second {
+		return nil, fmt.Errorf("TRAEFIK_API_POLL_INTERVAL_SECONDS must be at least 5")
+	}
+	if c.token != "" && c.username != "" {
+		return nil, fmt.Errorf("set either TRAEFIK_API_TOKEN or TRAEFIK_API_USERNAME, not both")
+	}
+	if (c.username == "") != (c.password == "") {
+		return nil, fmt.Errorf("TRAEFIK_API_USERNAME and TRAEFIK_API_PASSWORD must be set together")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if GetEnvBool("TRAEFIK_API_INSECURE", false) {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	c.client = &http.Client{Timeout: 10 * time.Second, Transport: transport}
+	c.state.Store(&traefikAPIState{})
+	return c, nil
+}
+
+func (c *TraefikAPIClient) Start() {
+	appLog.Info("Syncing definitions from the Traefik API", "url", redactURL(c.url), "interval", c.interval)
+	go func() {
+		c.sync()
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.sync()
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (c *TraefikAPIClient) Stop() {
+	close(c.stop)
+}
+
+func (c *TraefikAPIClient) sync() {
+	state := &traefikAPIState{
+		routers:     make(map[string]TraefikRouter),
+		services:    make(map[string]TraefikService),
+		middlewares: make(map[string]TraefikMiddleware),
+	}
+	var routers []TraefikRouter
+	var services []TraefikService
+	var middlewares []TraefikMiddleware
+	err := c.fetch("/api/http/routers", &routers)
+	if err == nil {
+		err = c.fetch("/api/http/services", &services)
+	}
+	if err == nil {
+		err = c.fetch("/api/http/middlewares", &middlewares)
+	}
+	if err != nil {
+		// Keep serving the last definitions
+		previous := *c.state.Load()
+		if previous.lastError == "" {
+			appLog.Warn("Traefik API sync failed", "error", err)
+		}
+		previous.lastError = err.Error()
+		c.state.Store(&previous)
+		return
+	}
+
+	for _, router := range routers {
+		state.routers[router.Name] = router
+	}
+	for _, service := range services {
+		state.services[service.Name] = service
+	}
+	for _, middleware := range middlewares {
+		state.middlewares[middleware.Name] = middleware
+	}
+	state.syncedAt = time.Now()
+	if c.state.Load().lastError != "" {
+		appLog.Info("Traefik API sync recovered", "routers", len(routers), "services", len(services))
+	}
+	c.state.Store(state)
+	c.flagUnknownRouters(state)
+}
+
+// Read every page of a list endpoint. Traefik's X-Next-Page header names
+// the next page, or 1 after the last.
+func (c *TraefikAPIClient) fetch(path string, out interface{}) error {
+	var all []json.RawMessage
+	for page := 1; page <= TRAEFIK_API_MAX_PAGES; {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s%s?per_page=%d&page=%d", c.url, path, TRAEFIK_API_PAGE_SIZE, page), nil)
+		if err != nil {
+			cancel()
+			return err
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		} else if c.username != "" {
+			req.SetBasicAuth(c.username, c.password)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			cancel()
+			// Transport errors quote the URL, which may embed credentials
+			if urlErr, ok := err.(*url.Error); ok {
+				return fmt.Errorf("%s %s: %w", urlErr.Op, redactURL(urlErr.URL), urlErr.Err)
+			}
+			return err
+		}
+		var items []json.RawMessage
+		if resp.StatusCode != http.StatusOK {
+			snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+			err = fmt.Errorf("%s: HTTP %d: %s", path, resp.StatusCode, strings.TrimSpace(string(snippet)))
+		} else if err = json.NewDecoder(resp.Body).Decode(&items); err != nil {
+			err = fmt.Errorf("%s: %w", path, err)
+		}
+		resp.Body.Close()
+		cancel()
+		if err != nil {
+			return err
+		}
+		all = append(all, items...)
+
+		next, _ := strconv.Atoi(resp.Header.Get("X-Next-Page"))
+		if next <= page {
+			break
+		}
+		page = next
+	}
+	data, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Requests per router and service in the buffer, by their original names
+func bufferedTrafficByName() (routers, services map[string]int) {
+	routers = make(map[string]int)
+	services = make(map[string]int)
+	logParser.eachMatch(logParser.logsSnapshot.Load(), Filters{}, math.MaxUint64, func(entry *LogEntry) bool {
+		router, service := entry.RouterName, entry.ServiceName
+		if entry.RouterRaw != "" {
+			router = entry.RouterRaw
+		}
+		if entry.ServiceRaw != "" {
+			service = entry.ServiceRaw
+		}
+		routers[router]++
+		services[service]++
+		return true
+	})
+	return routers, services
+}
+
+// Router names in the access log are name@provider; other values (OTLP
+// http.route attributes, "unknown") are no Traefik routers
+func (state *traefikAPIState) unknownRouters(traffic map[string]int) []RouterCount {
+	unknown := []RouterCount{}
+	if state.syncedAt.IsZero() {
+		return unknown
+	}
+	for router, count := range traffic {
+		if _, ok := state.routers[router]; !ok && strings.Contains(router, "@") {
+			unknown = append(unknown, RouterCount{Router: router, Count: count})
+		}
+	}
+	sort.Slice(unknown, func(i, j int) bool {
+		if unknown[i].Count != unknown[j].Count {
+			return unknown[i].Count > unknown[j].Count
+		}
+		return unknown[i].Router < unknown[j].Router
+	})
+	return unknown
+}
+
+// Report each router with buffered traffic that Traefik doesn't define
+// (removed, renamed, or served by another instance) once, until it shows up
+// again
+func (c *TraefikAPIClient) flagUnknownRouters(state *traefikAPIState) {
+	traffic, _ := bufferedTrafficByName()
+	unknown := make(map[string]bool)
+	for _, router := range state.unknownRouters(traffic) {
+		unknown[router.Router] = true
+		if c.flagged[router.Router] {
+			continue
+		}
+		c.flagged[router.Router] = true
+		appLog.Warn("Traffic logged for a router the Traefik API doesn't know", "router", router.Router, "requests", router.Count)
+		notifySystemEvent("unknownRouterTraffic", SEVERITY_WARNING, "Traffic for an unknown router",
+			fmt.Sprintf("%d buffered requests went through %s, which Traefik's API doesn't list", router.Count, router.Router),
+			map[string]interface{}{"router": router.Router, "requests": router.Count})
+	}
+	for router := range c.flagged {
+		if !unknown[router] {
+			delete(c.flagged, router)
+		}
+	}
+}
+
+// A router's definition with its buffered traffic and backend health
+type TraefikRouterView struct {
+	TraefikRouter
+	Requests      int    `json:"requests"`
+	ServiceHealth string `json:"serviceHealth,omitempty"`
+}
+
+type TraefikServiceView struct {
+	TraefikService
+	Requests int    `json:"requests"`
+	Health   string `json:"health,omitempty"`
+}
+
+// up when every server passes its health check, degraded when some do,
+// down when none does; empty without a health check
+func (s TraefikService) health() string {
+	up, down := 0, 0
+	for _, status := range s.ServerStatus {
+		if strings.EqualFold(status, "UP") {
+			up++
+		} else {
+			down++
+		}
+	}
+	switch {
+	case up+down == 0:
+		return ""
+	case down == 0:
+		return "up"
+	case up == 0:
+		return "down"
+	}
+	return "degraded"
+}
+
+// The synced state, or nil after writing an error when the integration is off
+func traefikAPIStateFor(c *gin.Context) *traefikAPIState {
+	if traefikAPI == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Traefik API integration is not configured (TRAEFIK_API_URL)"})
+		return nil
+	}
+	return traefikAPI.state.Load()
+}
+
+func (state *traefikAPIState) meta() gin.H {
+	meta := gin.H{"syncedAt": nil}
+	if !state.syncedAt.IsZero() {
+		meta["syncedAt"] = state.syncedAt.Format(time.RFC3339)
+	}
+	if state.lastError != "" {
+		meta["error"] = state.lastError
+	}
+	return meta
+}
+
+// Handler for /api/traefik/routers
+func getTraefikRouters(c *gin.Context) {
+	state := traefikAPIStateFor(c)
+	if state == nil {
+		return
+	}
+	routerTraffic, _ := bufferedTrafficByName()
+	routers := make([]TraefikRouterView, 0, len(state.routers))
+	for name, router := range state.routers {
+		view := TraefikRouterView{TraefikRouter: router, Requests: routerTraffic[name]}
+		if service, ok := state.services[router.Service]; ok {
+			view.ServiceHealth = service.health()
+		} else if service, ok := state.services[router.Service+"@"+router.Provider]; ok {
+			// Routers name services of their own provider without the suffix
+			view.ServiceHealth = service.health()
+		}
+		routers = append(routers, view)
+	}
+	sort.Slice(routers, func(i, j int) bool { return routers[i].Name < routers[j].Name })
+
+	response := state.meta()
+	response["routers"] = routers
+	response["unknownRouters"] = state.unknownRouters(routerTraffic)
+	c.JSON(http.StatusOK, response)
+}
+
+// Handler for /api/traefik/services
+func getTraefikServices(c *gin.Context) {
+	state := traefikAPIStateFor(c)
+	if state == nil {
+		return
+	}
+	_, serviceTraffic := bufferedTrafficByName()
+	services := make([]TraefikServiceView, 0, len(state.services))
+	for name, service := range state.services {
+		services = append(services, TraefikServiceView{TraefikService: service, Requests: serviceTraffic[name], Health: service.health()})
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+
+	response := state.meta()
+	response["services"] = services
+	c.JSON(http.StatusOK, response)
+}
+
+// Handler for /api/traefik/middlewares
+func getTraefikMiddlewares(c *gin.Context) {
+	state := traefikAPIStateFor(c)
+	if state == nil {
+		return
+	}
+	middlewares := make([]TraefikMiddleware, 0, len(state.middlewares))
+	for _, middleware := range state.middlewares {
+		middlewares = append(middlewares, middleware)
+	}
+	sort.Slice(middlewares, func(i, j int) bool { return middlewares[i].Name < middlewares[j].Name })
+
+	response := state.meta()
+	response["middlewares"] = middlewares
+	c.JSON(http.StatusOK, response)
+}
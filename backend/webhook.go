@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// POSTs notifications as JSON to a URL. The body is the notification itself
+// unless a template is configured.
+type WebhookNotifier struct {
+	url      string
+	template *template.Template
+	headers  map[string]string
+	client   *http.Client
+}
+
+// Template helpers; json renders a value as a JSON literal so strings are
+// escaped correctly inside templated payloads
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"upper": strings.ToUpper,
+}
+
+// Build a notifier for every URL in WEBHOOK_URLS. WEBHOOK_TEMPLATE (or
+// WEBHOOK_TEMPLATE_FILE) is a Go text/template rendered with the
+// Notification; WEBHOOK_HEADERS adds "Name: value" pairs separated by ";".
+func NewWebhookNotifiers() ([]*WebhookNotifier, error) {
+	urls := os.Getenv("WEBHOOK_URLS")
+	if urls == "" {
+		return nil, nil
+	}
+
+	text := os.Getenv("WEBHOOK_TEMPLATE")
+	if path := os.Getenv("WEBHOOK_TEMPLATE_FILE"); path != "" {
+		if text != "" {
+			return nil, fmt.Errorf("both WEBHOOK_TEMPLATE and WEBHOOK_TEMPLATE_FILE are set")
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhook template: %w", err)
+		}
+		text = string(data)
+	}
+
+	var tmpl *template.Template
+	if text != "" {
+		var err error
+		if tmpl, err = template.New("webhook").Funcs(webhookTemplateFuncs).Parse(text); err != nil {
+			return nil, fmt.Errorf("invalid webhook template: %w", err)
+		}
+	}
+
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("WEBHOOK_HEADERS"), ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid WEBHOOK_HEADERS entry: %s", pair)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	var notifiers []*WebhookNotifier
+	for _, target := range strings.Split(urls, ",") {
+		target = strings.TrimSpace(target)
+		if target == "" {
+			continue
+		}
+		if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+			return nil, fmt.Errorf("invalid webhook URL: %s", redactURL(target))
+		}
+		notifiers = append(notifiers, &WebhookNotifier{
+			url:      target,
+			template: tmpl,
+			headers:  headers,
+			client:   &http.Client{},
+		})
+	}
+	return notifiers, nil
+}
+
+func (w *WebhookNotifier) Name() string {
+	return "webhook " + redactURL(w.url)
+}
+
+func (w *WebhookNotifier) Send(ctx context.Context, n Notification) error {
+	body, err := w.render(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+	return doNotifyRequest(w.client, req)
+}
+
+// Render the payload, checking that templated output is valid JSON
+func (w *WebhookNotifier) render(n Notification) ([]byte, error) {
+	if w.template == nil {
+		return json.Marshal(n)
+	}
+	var buf bytes.Buffer
+	if err := w.template.Execute(&buf, n); err != nil {
+		return nil, fmt.Errorf("webhook template failed: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("webhook template did not produce valid JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+// Perform a notification request, treating any non-2xx status as an error
+func doNotifyRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		// Transport errors quote the URL, which may embed a token
+		if urlErr, ok := err.(*url.Error); ok {
+			return fmt.Errorf("%s %s: %w", urlErr.Op, redactURL(urlErr.URL), urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
+
+// Strip the path and query from a URL for logging; webhook URLs often embed secrets
+func redactURL(raw string) string {
+	scheme, rest, ok := strings.Cut(raw, "://")
+	if !ok {
+		return "[invalid url]"
+	}
+	host, _, _ := strings.Cut(rest, "/")
+	if _, after, found := strings.Cut(host, "@"); found {
+		host = after
+	}
+	return scheme + "://" + host
+}
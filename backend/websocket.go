@@ -2,7 +2,7 @@ package main
 
 import (
 	"encoding/json"
-	"log"
+	"sort"
 	"sync"
 	"time"
 
@@ -14,33 +14,142 @@ type WebSocketMessage struct {
 	Data   interface{} `json:"data,omitempty"`
 	Params interface{} `json:"params,omitempty"`
 	Stats  *Stats      `json:"stats,omitempty"`
+	Seq    uint64      `json:"seq,omitempty"` // Sequence of the newest log entry carried
 }
 
+// Limits for per-client handshake options
+const (
+	DEFAULT_WS_INITIAL_LOGS = 1000
+	MAX_WS_INITIAL_LOGS     = 10000
+	MIN_WS_PUSH_INTERVAL    = time.Second
+	MAX_WS_PUSH_INTERVAL    = time.Hour
+	MAX_WS_LABEL_LENGTH     = 64
+)
+
+// Stream channels a client can subscribe to
+const (
+	WS_CHANNEL_LOGS          = "logs"
+	WS_CHANNEL_STATS         = "stats"
+	WS_CHANNEL_GEO_STATS     = "geoStats"
+	WS_CHANNEL_SYSTEM_EVENTS = "systemEvents"
+	WS_CHANNEL_ALERTS        = "alerts"
+	WS_CHANNEL_RAW_LINES     = "rawLines"    // Debug tail of unparsed lines, opt-in only
+	WS_CHANNEL_PARSE_ERRORS  = "parseErrors" // Rejected lines with the reason, rate capped
+)
+
+var wsChannels = map[string]bool{
+	WS_CHANNEL_LOGS:          true,
+	WS_CHANNEL_STATS:         true,
+	WS_CHANNEL_GEO_STATS:     true,
+	WS_CHANNEL_SYSTEM_EVENTS: true,
+	WS_CHANNEL_ALERTS:        true,
+	WS_CHANNEL_RAW_LINES:     true,
+	WS_CHANNEL_PARSE_ERRORS:  true,
+}
+
+// Channels new clients start with; matches what older frontends expect
+var wsDefaultChannels = []string{WS_CHANNEL_LOGS, WS_CHANNEL_STATS, WS_CHANNEL_GEO_STATS}
+
 type WebSocketClient struct {
 	conn       *websocket.Conn
-	send       chan []byte
+	send       chan wsFrame
 	logParser  *LogParser
 	logChan    chan LogEntry
 	clientID   string
+	label      string // Human-readable name supplied by the client
+	tenant     string // Tenant the client is scoped to, empty for global access
 	closeChan  chan struct{}
 	closeOnce  sync.Once
 	mu         sync.Mutex
 	lastPing   time.Time
 	isClosing  bool
+
+	// Server-side filter for newLog messages, nil when unset
+	filter *Filters
+
+	// Negotiated message encoding ("json" or "msgpack")
+	encoding string
+
+	// Protocol version and capabilities from the hello handshake; clients
+	// that never say hello stay on version 1 with no capabilities
+	protocolVersion int
+	capabilities    map[string]bool
+
+	// newLog coalescing: flush every batchInterval or batchMaxSize entries,
+	// disabled when batchInterval is zero or the client didn't negotiate batching
+	batchInterval time.Duration
+	batchMaxSize  int
+
+	// Delta stats mode: periodic stats are sent as diffs against lastStats
+	// and newLog messages no longer bundle the full stats
+	deltaStats bool
+	lastStats  *Stats
+
+	// Subscribed stream channels
+	channels map[string]bool
+
+	// Raw line tail, registered with the parser only while subscribed
+	rawChan      chan RawLine
+	rawListening bool
+
+	// Resume handshake: replay entries after resumeFrom instead of a full reload
+	resumeFrom *uint64
+	// Newest sequence already delivered, used to drop duplicates from logChan
+	deliveredSeq uint64
+
+	// Per-client push intervals requested in the handshake; zero falls back
+	// to the runtime defaults
+	statsPeriod    time.Duration
+	geoStatsPeriod time.Duration
+	// When the broadcast hub last pushed stats/geo stats to this client
+	lastStatsPush time.Time
+	lastGeoPush   time.Time
+	// Number of logs sent in the initial snapshot
+	initialLogCount int
+
+	// Serializes enqueuers so log frames can be evicted from a full send buffer
+	sendMu sync.Mutex
+	// Log frames dropped because the client couldn't keep up
+	droppedFrames uint64
+}
+
+// An encoded message waiting in a client's send buffer
+type wsFrame struct {
+	data []byte
+	// Log frames may be dropped when the client falls behind
+	isLog   bool
+	msgType string
 }
 
 func NewWebSocketClient(conn *websocket.Conn, logParser *LogParser) *WebSocketClient {
 	clientID := time.Now().Format("20060102-150405") + "-" + conn.RemoteAddr().String()
-	log.Printf("[WebSocket] New client connected: %s", clientID)
+	wsLog.Info("Client connected", "client", clientID)
+
+	channels := make(map[string]bool, len(wsDefaultChannels))
+	for _, channel := range wsDefaultChannels {
+		channels[channel] = true
+	}
+	buffers := GetBufferSizes()
 	
 	return &WebSocketClient{
 		conn:      conn,
-		send:      make(chan []byte, 256),
+		send:      make(chan wsFrame, buffers.WSSend),
 		logParser: logParser,
-		logChan:   make(chan LogEntry, 100),
+		logChan:   make(chan LogEntry, buffers.Listener),
+		rawChan:   make(chan RawLine, buffers.Listener),
 		clientID:  clientID,
 		closeChan: make(chan struct{}),
 		lastPing:  time.Now(),
+		encoding:  negotiatedEncoding(conn.Subprotocol()),
+		protocolVersion: WS_MIN_PROTOCOL_VERSION,
+		capabilities:    make(map[string]bool),
+		batchInterval: time.Duration(GetEnvInt("WS_BATCH_INTERVAL_MS", 0)) * time.Millisecond,
+		batchMaxSize:  GetEnvInt("WS_BATCH_MAX_SIZE", 100),
+		channels:      channels,
+		initialLogCount: DEFAULT_WS_INITIAL_LOGS,
+		// The initial data includes stats, so the first pushes are a full interval away
+		lastStatsPush: time.Now(),
+		lastGeoPush:   time.Now(),
 	}
 }
 
@@ -49,7 +158,7 @@ func (c *WebSocketClient) Start() {
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("[WebSocket] WritePump panic recovered: %v", r)
+				wsLog.Error("Write pump panic recovered", "client", c.clientID, "panic", r)
 			}
 			removeWSClient(c)
 			c.Close()
@@ -60,7 +169,7 @@ func (c *WebSocketClient) Start() {
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("[WebSocket] ReadPump panic recovered: %v", r)
+				wsLog.Error("Read pump panic recovered", "client", c.clientID, "panic", r)
 			}
 			removeWSClient(c)
 			c.Close()
@@ -71,7 +180,7 @@ func (c *WebSocketClient) Start() {
 
 func (c *WebSocketClient) Close() {
 	c.closeOnce.Do(func() {
-		log.Printf("[WebSocket] Closing client %s", c.clientID)
+		wsLog.Info("Closing client", "client", c.clientID)
 		
 		c.mu.Lock()
 		c.isClosing = true
@@ -79,9 +188,12 @@ func (c *WebSocketClient) Close() {
 		
 		close(c.closeChan)
 		c.logParser.RemoveListener(c.logChan)
+		c.logParser.RemoveRawListener(c.rawChan)
 		
-		// Close send channel
+		// Close send channel once no enqueue is in progress
+		c.sendMu.Lock()
 		close(c.send)
+		c.sendMu.Unlock()
 		
 		// Close WebSocket connection
 		c.conn.Close()
@@ -105,21 +217,21 @@ func (c *WebSocketClient) ReadPump() {
 		case <-c.closeChan:
 			return
 		default:
-			_, message, err := c.conn.ReadMessage()
+			frameType, message, err := c.conn.ReadMessage()
 			if err != nil {
 				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					log.Printf("[WebSocket] Client %s error: %v", c.clientID, err)
+					wsLog.Warn("Client connection error", "client", c.clientID, "error", err)
 				}
 				return
 			}
 
 			var msg WebSocketMessage
-			if err := json.Unmarshal(message, &msg); err != nil {
-				log.Printf("[WebSocket] Client %s message parse error: %v", c.clientID, err)
+			if err := decodeWSMessage(frameType, message, &msg); err != nil {
+				wsLog.Warn("Failed to parse client message", "client", c.clientID, "error", err)
 				continue
 			}
 
-			log.Printf("[WebSocket] Client %s sent message type: %s", c.clientID, msg.Type)
+			wsLog.Debug("Received client message", "client", c.clientID, "type", msg.Type)
 			c.handleMessage(msg)
 		}
 	}
@@ -127,24 +239,31 @@ func (c *WebSocketClient) ReadPump() {
 
 func (c *WebSocketClient) WritePump() {
 	ticker := time.NewTicker(54 * time.Second)
-	statsInterval := time.NewTicker(10 * time.Second)
-	geoStatsInterval := time.NewTicker(15 * time.Second)
+
+	// Batch flush ticker; a nil channel never fires when batching is off
+	var batchFlush <-chan time.Time
+	if c.batchInterval > 0 {
+		batchTicker := time.NewTicker(c.batchInterval)
+		defer batchTicker.Stop()
+		batchFlush = batchTicker.C
+	}
+	pending := make([]LogEntry, 0, c.batchMaxSize)
 	
 	defer func() {
 		ticker.Stop()
-		statsInterval.Stop()
-		geoStatsInterval.Stop()
 		c.Close()
 	}()
 
+	// Subscribe to new logs before taking the snapshot so nothing falls in
+	// between; duplicates are dropped by sequence number
+	c.logParser.AddListener(c.logChan)
+	wsLog.Debug("Client subscribed to log updates", "client", c.clientID)
+
 	// Send initial data
-	log.Printf("[WebSocket] Sending initial data to client %s", c.clientID)
+	wsLog.Debug("Sending initial data", "client", c.clientID)
 	c.sendInitialData()
 
-	// Subscribe to new logs
-	c.logParser.AddListener(c.logChan)
-	log.Printf("[WebSocket] Client %s subscribed to log updates", c.clientID)
-
+	frameType := wsFrameType(c.encoding)
 	messageCount := 0
 	for {
 		select {
@@ -158,22 +277,25 @@ func (c *WebSocketClient) WritePump() {
 			}
 
 			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
-				log.Printf("[WebSocket] Client %s write error: %v", c.clientID, err)
+			if err := c.conn.WriteMessage(frameType, message.data); err != nil {
+				wsLog.Warn("Write to client failed", "client", c.clientID, "error", err)
 				return
 			}
 			
 			messageCount++
 			if messageCount%100 == 0 {
-				log.Printf("[WebSocket] Client %s sent %d messages", c.clientID, messageCount)
+				wsLog.Debug("Messages sent to client", "client", c.clientID, "messages", messageCount)
 			}
 
 			// Drain send channel to prevent blocking (batch send)
 			n := len(c.send)
 			for i := 0; i < n; i++ {
 				select {
-				case msg := <-c.send:
-					if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				case msg, ok := <-c.send:
+					if !ok {
+						return
+					}
+					if err := c.conn.WriteMessage(frameType, msg.data); err != nil {
 						return
 					}
 					messageCount++
@@ -188,26 +310,36 @@ func (c *WebSocketClient) WritePump() {
 				return
 			default:
 				if logEntry.ID == "CLEAR" {
-					log.Printf("[WebSocket] Sending clear signal to client %s", c.clientID)
+					wsLog.Debug("Sending clear signal", "client", c.clientID)
+					pending = pending[:0]
+				} else if logEntry.Seq <= c.getDeliveredSeq() {
+					continue
+				} else if !c.IsSubscribed(WS_CHANNEL_LOGS) || !c.wantsLog(&logEntry) {
+					continue
+				} else if c.batchInterval > 0 && c.hasCapability(WS_CAP_BATCHING) {
+					pending = append(pending, logEntry)
+					if len(pending) >= c.batchMaxSize {
+						c.sendNewLogBatch(pending)
+						pending = make([]LogEntry, 0, c.batchMaxSize)
+					}
+					continue
 				}
 				c.sendNewLogWithStats(logEntry)
 			}
 
-		case <-statsInterval.C:
-			select {
-			case <-c.closeChan:
-				return
-			default:
-				c.sendStats()
+		case rawLine := <-c.rawChan:
+			if c.tenant != "" && tenancy.TenantForSource(rawLine.Source) != c.tenant {
+				continue
 			}
+			c.sendMessage(WebSocketMessage{
+				Type: "rawLine",
+				Data: rawLine,
+			})
 
-		case <-geoStatsInterval.C:
-			select {
-			case <-c.closeChan:
-				return
-			default:
-				c.sendGeoStats()
-				c.sendGeoProcessingStatus()
+		case <-batchFlush:
+			if len(pending) > 0 {
+				c.sendNewLogBatch(pending)
+				pending = make([]LogEntry, 0, c.batchMaxSize)
 			}
 
 		case <-ticker.C:
@@ -217,7 +349,7 @@ func (c *WebSocketClient) WritePump() {
 			default:
 				c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 				if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					log.Printf("[WebSocket] Client %s ping error: %v", c.clientID, err)
+					wsLog.Warn("Ping to client failed", "client", c.clientID, "error", err)
 					return
 				}
 			}
@@ -227,24 +359,25 @@ func (c *WebSocketClient) WritePump() {
 
 func (c *WebSocketClient) sendInitialData() {
 	// Send initial stats
-	log.Printf("[WebSocket] Sending initial stats to client %s", c.clientID)
-	c.sendStats()
+	if c.IsSubscribed(WS_CHANNEL_STATS) {
+		wsLog.Debug("Sending initial stats", "client", c.clientID)
+		c.sendStats()
+	}
 
-	// Send recent logs - INCREASED FROM 50 TO 1000
-	result := c.logParser.GetLogs(LogsParams{Page: 1, Limit: 1000})
-	log.Printf("[WebSocket] Sending %d initial logs to client %s", len(result.Logs), c.clientID)
-	c.sendMessage(WebSocketMessage{
-		Type: "logs",
-		Data: result.Logs,
-	})
+	// Replay missed entries for a resuming client, otherwise send recent logs
+	if c.IsSubscribed(WS_CHANNEL_LOGS) && !c.replayMissedLogs() {
+		c.sendRecentLogs()
+	}
 
 	// Send initial geo stats
-	c.sendGeoStats()
-	c.sendGeoProcessingStatus()
+	if c.IsSubscribed(WS_CHANNEL_GEO_STATS) {
+		c.sendGeoStats()
+		c.sendGeoProcessingStatus()
+	}
 }
 
 func (c *WebSocketClient) handleMessage(msg WebSocketMessage) {
-	log.Printf("[WebSocket] Client %s handling message: %s", c.clientID, msg.Type)
+	wsLog.Debug("Handling client message", "client", c.clientID, "type", msg.Type)
 	
 	switch msg.Type {
 	case "getLogs":
@@ -254,28 +387,121 @@ func (c *WebSocketClient) handleMessage(msg WebSocketMessage) {
 				json.Unmarshal(p, &params)
 			}
 		}
+		if c.tenant != "" {
+			params.Filters.Tenant = c.tenant
+		}
 		result := c.logParser.GetLogs(params)
-		log.Printf("[WebSocket] Client %s requested logs, sending %d logs", c.clientID, len(result.Logs))
+		wsLog.Debug("Client requested logs", "client", c.clientID, "logs", len(result.Logs))
 		c.sendMessage(WebSocketMessage{
 			Type: "logs",
 			Data: result,
 		})
 
 	case "getStats":
-		log.Printf("[WebSocket] Client %s requested stats", c.clientID)
-		c.sendStats()
+		wsLog.Debug("Client requested stats", "client", c.clientID)
+		c.sendFullStats()
+
+	case "hello":
+		c.handleHello(msg)
+
+	case "setStatsMode":
+		var params struct {
+			Mode string `json:"mode"` // "full" or "delta"
+		}
+		if msg.Params != nil {
+			if p, err := json.Marshal(msg.Params); err == nil {
+				json.Unmarshal(p, &params)
+			}
+		}
+		c.mu.Lock()
+		c.deltaStats = params.Mode == "delta"
+		c.mu.Unlock()
+		wsLog.Debug("Client set stats mode", "client", c.clientID, "mode", params.Mode)
+		// Send a full baseline that subsequent deltas apply to
+		c.sendFullStats()
 
 	case "getGeoStats":
-		log.Printf("[WebSocket] Client %s requested geo stats", c.clientID)
+		wsLog.Debug("Client requested geo stats", "client", c.clientID)
 		c.sendGeoStats()
 		
+	case "setFilter":
+		var filter Filters
+		if msg.Params != nil {
+			if p, err := json.Marshal(msg.Params); err == nil {
+				json.Unmarshal(p, &filter)
+			}
+		}
+		if err := filter.Compile(); err != nil {
+			wsLog.Warn("Client sent invalid filter", "client", c.clientID, "error", err)
+			c.sendMessage(WebSocketMessage{
+				Type: "error",
+				Data: map[string]interface{}{
+					"request": msg.Type,
+					"error":   err.Error(),
+				},
+			})
+			return
+		}
+		c.mu.Lock()
+		if filter.IsEmpty() {
+			c.filter = nil
+		} else {
+			c.filter = &filter
+		}
+		c.mu.Unlock()
+		wsLog.Debug("Client set subscription filter", "client", c.clientID, "filter", filter)
+		c.sendMessage(WebSocketMessage{
+			Type: "filterSet",
+			Data: filter,
+		})
+
+	case "clearFilter":
+		c.mu.Lock()
+		c.filter = nil
+		c.mu.Unlock()
+		wsLog.Debug("Client cleared subscription filter", "client", c.clientID)
+		c.sendMessage(WebSocketMessage{
+			Type: "filterSet",
+			Data: Filters{},
+		})
+
+	case "subscribe", "unsubscribe":
+		var params struct {
+			Channels []string `json:"channels"`
+		}
+		if msg.Params != nil {
+			if p, err := json.Marshal(msg.Params); err == nil {
+				json.Unmarshal(p, &params)
+			}
+		}
+		var added []string
+		c.mu.Lock()
+		for _, channel := range params.Channels {
+			if !wsChannels[channel] {
+				continue
+			}
+			if msg.Type == "subscribe" {
+				if !c.channels[channel] {
+					added = append(added, channel)
+				}
+				c.channels[channel] = true
+			} else {
+				delete(c.channels, channel)
+			}
+		}
+		c.mu.Unlock()
+		wsLog.Debug("Client changed channel subscriptions", "client", c.clientID, "type", msg.Type, "channels", params.Channels)
+		c.syncRawListener()
+		c.sendSubscriptions()
+		c.sendChannelSnapshots(added)
+
 	case "refreshGeoData":
-		log.Printf("[WebSocket] Client %s requested geo data refresh", c.clientID)
+		wsLog.Debug("Client requested geo data refresh", "client", c.clientID)
 		c.sendGeoStats()
 		c.sendStats()
 		
 	default:
-		log.Printf("[WebSocket] Client %s sent unknown message type: %s", c.clientID, msg.Type)
+		wsLog.Warn("Client sent unknown message type", "client", c.clientID, "type", msg.Type)
 	}
 }
 
@@ -287,32 +513,157 @@ func (c *WebSocketClient) sendMessage(msg WebSocketMessage) {
 	}
 	c.mu.Unlock()
 
-	data, err := json.Marshal(msg)
+	data, err := encodeWSMessage(c.encoding, msg)
 	if err != nil {
-		log.Printf("[WebSocket] Client %s marshal error: %v", c.clientID, err)
+		wsLog.Error("Failed to marshal message", "client", c.clientID, "error", err)
+		return
+	}
+
+	isLog := msg.Type == "newLog" || msg.Type == "newLogs" || msg.Type == "rawLine"
+	c.enqueue(wsFrame{data: data, isLog: isLog, msgType: msg.Type})
+}
+
+// Queue a frame for the write pump without ever waiting, as it runs on the
+// hub goroutine. When the buffer is full a new snapshot replaces the stale
+// one still queued, then the oldest log frames are dropped until the new
+// frame fits and the client is told how many it missed; other frames are
+// never dropped this way. A client that still has no room is disconnected.
+func (c *WebSocketClient) enqueue(frame wsFrame) {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	c.mu.Lock()
+	closing := c.isClosing
+	c.mu.Unlock()
+	if closing {
 		return
 	}
 
 	select {
-	case c.send <- data:
-		// Message sent successfully
-	case <-time.After(time.Second):
-		log.Printf("[WebSocket] Client %s send timeout, dropping message type: %s", c.clientID, msg.Type)
-	case <-c.closeChan:
-		// Client is closing
+	case c.send <- frame:
+		return
+	default:
 	}
+
+	// Buffer is full: pull everything out, minus superseded snapshots. The
+	// write pump may be taking frames concurrently, so never block here
+	queued := make([]wsFrame, 0, cap(c.send))
+drain:
+	for {
+		select {
+		case f := <-c.send:
+			if !frame.supersedes(f) {
+				queued = append(queued, f)
+			}
+		default:
+			break drain
+		}
+	}
+
+	// Make room for the new frame and the dropped notice from the front
+	dropped := 0
+	if len(queued) >= cap(c.send) {
+		excess := len(queued) + 2 - cap(c.send)
+		kept := queued[:0]
+		for _, f := range queued {
+			if f.isLog && dropped < excess {
+				dropped++
+				continue
+			}
+			kept = append(kept, f)
+		}
+		queued = kept
+	}
+	for _, f := range queued {
+		c.send <- f
+	}
+
+	// Still no room for another log frame: drop the new one too
+	room := cap(c.send) - len(c.send)
+	if frame.isLog && room < 1+min(dropped, 1) {
+		dropped++
+		frame = wsFrame{}
+	}
+
+	if dropped > 0 {
+		c.mu.Lock()
+		c.droppedFrames += uint64(dropped)
+		total := c.droppedFrames
+		c.mu.Unlock()
+
+		wsLog.Warn("Client is falling behind, dropped log frames", "client", c.clientID, "dropped", dropped, "droppedTotal", total)
+		// Leave the new frame its slot; without room the next notice
+		// carries the total
+		if frame.data != nil {
+			room--
+		}
+		if notice, err := encodeWSMessage(c.encoding, WebSocketMessage{
+			Type: "dropped",
+			Data: map[string]interface{}{
+				"dropped":      dropped,
+				"totalDropped": total,
+			},
+		}); err == nil && room > 0 {
+			c.send <- wsFrame{data: notice, msgType: "dropped"}
+		}
+	}
+
+	if frame.data != nil {
+		c.sendFrameLocked(frame)
+	}
+}
+
+// Whether a newer frame makes a queued one redundant: a snapshot replaces the
+// previous one of the same type, and full stats also replace pending deltas
+func (f wsFrame) supersedes(queued wsFrame) bool {
+	switch f.msgType {
+	case "stats":
+		return queued.msgType == "stats" || queued.msgType == "statsDelta"
+	case "geoStats", "geoProcessingStatus":
+		return queued.msgType == f.msgType
+	}
+	return false
 }
 
+// Queue a frame if it fits, otherwise disconnect the client as too slow to
+// keep up; caller must hold c.sendMu
+func (c *WebSocketClient) sendFrameLocked(frame wsFrame) {
+	select {
+	case c.send <- frame:
+		return
+	default:
+	}
+
+	wsLog.Warn("Send buffer full, disconnecting slow client", "client", c.clientID, "type", frame.msgType, "buffered", len(c.send))
+	// Stop further enqueues now; Close takes sendMu, so it can't run here
+	c.mu.Lock()
+	c.isClosing = true
+	c.mu.Unlock()
+	go c.closeWith(websocket.CloseTryAgainLater, "client too slow")
+}
+
+// Send stats now, as a diff against the last sent stats in delta mode
 func (c *WebSocketClient) sendStats() {
-	stats := c.logParser.GetStats()
-	c.sendMessage(WebSocketMessage{
-		Type: "stats",
-		Data: stats,
-	})
+	stats := c.logParser.GetScopedStats(c.tenant)
+	c.pushStats(&stats, newHubFrame(WebSocketMessage{Type: "stats", Data: stats}))
+}
+
+// Send the full stats object and reset the delta baseline
+func (c *WebSocketClient) sendFullStats() {
+	c.mu.Lock()
+	c.lastStats = nil
+	c.mu.Unlock()
+	c.sendStats()
+}
+
+func (c *WebSocketClient) isDeltaStats() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deltaStats
 }
 
 func (c *WebSocketClient) sendGeoStats() {
-	geoStats := c.logParser.GetGeoStats()
+	geoStats := c.logParser.GetScopedGeoStats(c.tenant)
 	c.sendMessage(WebSocketMessage{
 		Type: "geoStats",
 		Data: geoStats,
@@ -320,19 +671,24 @@ func (c *WebSocketClient) sendGeoStats() {
 }
 
 func (c *WebSocketClient) sendGeoProcessingStatus() {
-	stats := c.logParser.GetStats()
+	stats := c.logParser.GetScopedStats(c.tenant)
+	c.sendMessage(geoProcessingStatusMessage(&stats))
+}
+
+// Build the geoProcessingStatus message from a stats snapshot
+func geoProcessingStatusMessage(stats *Stats) WebSocketMessage {
 	cacheStats := GetGeoCacheStats()
 
-	c.sendMessage(WebSocketMessage{
+	return WebSocketMessage{
 		Type: "geoProcessingStatus",
 		Data: map[string]interface{}{
 			"geoProcessingRemaining": stats.GeoProcessingRemaining,
 			"cachedLocations":        cacheStats.Keys,
 			"totalCountries":         len(stats.Countries),
-			"isProcessing":           c.logParser.IsProcessingGeo(),
+			"isProcessing":           logParser.IsProcessingGeo(),
 			"maxmindConfig":          cacheStats.MaxMindConfig,
 		},
-	})
+	}
 }
 
 func (c *WebSocketClient) sendNewLogWithStats(log LogEntry) {
@@ -343,31 +699,87 @@ func (c *WebSocketClient) sendNewLogWithStats(log LogEntry) {
 			Data: nil,
 		})
 		// Also send fresh stats and logs after clear
-		c.sendStats()
-		result := c.logParser.GetLogs(LogsParams{Page: 1, Limit: 1000}) // INCREASED FROM 50 TO 1000
+		if c.IsSubscribed(WS_CHANNEL_STATS) {
+			c.sendFullStats()
+		}
+		if c.IsSubscribed(WS_CHANNEL_LOGS) {
+			c.sendRecentLogs()
+		}
+		return
+	}
+
+	// Delta clients get stats from the periodic diffs instead
+	if c.isDeltaStats() || !c.IsSubscribed(WS_CHANNEL_STATS) {
 		c.sendMessage(WebSocketMessage{
-			Type: "logs",
-			Data: result.Logs,
+			Type: "newLog",
+			Data: log,
+			Seq:  log.Seq,
 		})
 		return
 	}
 
 	// Get current stats - this will include the impact of the new log
-	currentStats := c.logParser.GetStats()
+	currentStats := c.logParser.GetScopedStats(c.tenant)
 
 	// Send new log message with bundled stats for real-time updates
 	c.sendMessage(WebSocketMessage{
 		Type:  "newLog",
 		Data:  log,
 		Stats: &currentStats,
+		Seq:   log.Seq,
+	})
+}
+
+// Send coalesced entries as one newLogs frame with a single stats snapshot
+func (c *WebSocketClient) sendNewLogBatch(logs []LogEntry) {
+	seq := logs[len(logs)-1].Seq
+
+	if c.isDeltaStats() || !c.IsSubscribed(WS_CHANNEL_STATS) {
+		c.sendMessage(WebSocketMessage{
+			Type: "newLogs",
+			Data: logs,
+			Seq:  seq,
+		})
+		return
+	}
+
+	currentStats := c.logParser.GetScopedStats(c.tenant)
+
+	c.sendMessage(WebSocketMessage{
+		Type:  "newLogs",
+		Data:  logs,
+		Stats: &currentStats,
+		Seq:   seq,
 	})
 }
 
+// Check the entry against the client's subscription filter
+func (c *WebSocketClient) wantsLog(logEntry *LogEntry) bool {
+	if c.tenant != "" && logEntry.Tenant != c.tenant {
+		return false
+	}
+
+	c.mu.Lock()
+	filter := c.filter
+	c.mu.Unlock()
+
+	if filter == nil {
+		return true
+	}
+	return c.logParser.matchesFilters(logEntry, *filter)
+}
+
 // Enhanced method to force refresh geo data
 func (c *WebSocketClient) ForceGeoRefresh() {
-	log.Printf("[WebSocket] Forcing geo data refresh for client %s", c.clientID)
+	if !c.IsSubscribed(WS_CHANNEL_GEO_STATS) {
+		return
+	}
+
+	wsLog.Debug("Forcing geo data refresh", "client", c.clientID)
 	c.sendGeoStats()
-	c.sendStats()
+	if c.IsSubscribed(WS_CHANNEL_STATS) {
+		c.sendStats()
+	}
 	
 	// Send a special message to trigger immediate map update on frontend
 	c.sendMessage(WebSocketMessage{
@@ -379,6 +791,222 @@ func (c *WebSocketClient) ForceGeoRefresh() {
 	})
 }
 
+// Send the most recent logs as a full snapshot
+func (c *WebSocketClient) sendRecentLogs() {
+	result := c.logParser.GetLogs(LogsParams{Page: 1, Limit: c.initialLogCount, Filters: Filters{Tenant: c.tenant}})
+	wsLog.Debug("Sending initial logs", "client", c.clientID, "logs", len(result.Logs))
+
+	var seq uint64
+	if len(result.Logs) > 0 {
+		seq = result.Logs[0].Seq
+	}
+	c.markDelivered(seq)
+
+	c.sendMessage(WebSocketMessage{
+		Type: "logs",
+		Data: result.Logs,
+		Seq:  seq,
+	})
+}
+
+// Record that entries up to seq have been sent to the client
+func (c *WebSocketClient) markDelivered(seq uint64) {
+	c.mu.Lock()
+	if seq > c.deliveredSeq {
+		c.deliveredSeq = seq
+	}
+	c.mu.Unlock()
+}
+
+func (c *WebSocketClient) getDeliveredSeq() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deliveredSeq
+}
+
+// Request a replay of entries after seq on the next initial data send
+func (c *WebSocketClient) SetResumeFrom(seq uint64) {
+	c.resumeFrom = &seq
+}
+
+// Replay entries after the client's resumeFrom sequence. Returns false when
+// the client didn't ask to resume or the gap is no longer in the buffer.
+func (c *WebSocketClient) replayMissedLogs() bool {
+	if c.resumeFrom == nil {
+		return false
+	}
+	resumeFrom := *c.resumeFrom
+	c.resumeFrom = nil
+
+	entries, ok := c.logParser.GetLogsSince(resumeFrom)
+	if !ok {
+		wsLog.Info("Client cannot resume, sending full reload", "client", c.clientID, "seq", resumeFrom)
+		c.sendMessage(WebSocketMessage{
+			Type: "resumeFailed",
+			Data: map[string]interface{}{
+				"resumeFrom": resumeFrom,
+				"currentSeq": c.logParser.CurrentSeq(),
+			},
+		})
+		return false
+	}
+
+	seq := resumeFrom
+	if len(entries) > 0 {
+		seq = entries[len(entries)-1].Seq
+	}
+	c.markDelivered(seq)
+
+	if c.tenant != "" {
+		visible := entries[:0]
+		for _, entry := range entries {
+			if entry.Tenant == c.tenant {
+				visible = append(visible, entry)
+			}
+		}
+		entries = visible
+	}
+
+	wsLog.Debug("Replaying entries to client", "client", c.clientID, "entries", len(entries), "seq", resumeFrom)
+	c.sendMessage(WebSocketMessage{
+		Type: "replay",
+		Data: entries,
+		Seq:  seq,
+	})
+	return true
+}
+
+// Register or unregister the raw line listener to match the subscription
+func (c *WebSocketClient) syncRawListener() {
+	c.mu.Lock()
+	want := c.channels[WS_CHANNEL_RAW_LINES] && !c.isClosing
+	changed := want != c.rawListening
+	c.rawListening = want
+	c.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if want {
+		c.logParser.AddRawListener(c.rawChan)
+	} else {
+		c.logParser.RemoveRawListener(c.rawChan)
+	}
+}
+
+// Check whether the client is subscribed to a channel
+func (c *WebSocketClient) IsSubscribed(channel string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.channels[channel]
+}
+
+// Get the subscribed channels in a stable order
+func (c *WebSocketClient) subscriptions() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	channels := make([]string, 0, len(c.channels))
+	for channel := range c.channels {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+func (c *WebSocketClient) sendSubscriptions() {
+	c.sendMessage(WebSocketMessage{
+		Type: "subscriptions",
+		Data: map[string]interface{}{
+			"channels": c.subscriptions(),
+		},
+	})
+}
+
+// Send the current state of newly subscribed data channels
+func (c *WebSocketClient) sendChannelSnapshots(channels []string) {
+	for _, channel := range channels {
+		switch channel {
+		case WS_CHANNEL_STATS:
+			c.sendFullStats()
+		case WS_CHANNEL_GEO_STATS:
+			c.sendGeoStats()
+			c.sendGeoProcessingStatus()
+		case WS_CHANNEL_LOGS:
+			c.sendRecentLogs()
+		}
+	}
+}
+
+// Send an event on a channel if the client is subscribed to it
+func (c *WebSocketClient) SendEvent(channel, eventType string, data interface{}) {
+	if !c.IsSubscribed(channel) {
+		return
+	}
+	c.sendMessage(WebSocketMessage{
+		Type: eventType,
+		Data: data,
+	})
+}
+
+// Scope the client to a tenant. Must be called before Start.
+func (c *WebSocketClient) SetTenant(tenant string) {
+	c.tenant = tenant
+}
+
+// Set the client's label. Must be called before Start.
+func (c *WebSocketClient) SetLabel(label string) {
+	c.label = label
+}
+
+// Close the connection with a close frame carrying the reason
+func (c *WebSocketClient) Disconnect(reason string) {
+	wsLog.Info("Disconnecting client", "client", c.clientID, "reason", reason)
+	c.closeWith(websocket.ClosePolicyViolation, reason)
+}
+
+// Close the connection because the server is going away, so the client
+// reconnects (to another instance) rather than treating it as an error
+func (c *WebSocketClient) GoAway(reason string) {
+	wsLog.Debug("Closing client, server going away", "client", c.clientID, "reason", reason)
+	c.closeWith(websocket.CloseGoingAway, reason)
+}
+
+func (c *WebSocketClient) closeWith(code int, reason string) {
+	c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(time.Second))
+	c.Close()
+}
+
+// Entries and frames not yet written to the connection
+func (c *WebSocketClient) pending() int {
+	return len(c.logChan) + len(c.send)
+}
+
+// Override the stats/geo push intervals for this client; zero keeps the
+// runtime default. Must be called before Start.
+func (c *WebSocketClient) SetPushIntervals(stats, geoStats time.Duration) {
+	c.statsPeriod = stats
+	c.geoStatsPeriod = geoStats
+}
+
+// Set the number of logs sent in the initial snapshot. Must be called before Start.
+func (c *WebSocketClient) SetInitialLogCount(n int) {
+	c.initialLogCount = n
+}
+
+// Get the effective push intervals, preferring the client's own
+func (c *WebSocketClient) pushIntervals() (stats, geoStats time.Duration) {
+	stats, geoStats = GetPushIntervals()
+	if c.statsPeriod > 0 {
+		stats = c.statsPeriod
+	}
+	if c.geoStatsPeriod > 0 {
+		geoStats = c.geoStatsPeriod
+	}
+	return stats, geoStats
+}
+
 // Health check method to verify client is still active
 func (c *WebSocketClient) IsHealthy() bool {
 	c.mu.Lock()
@@ -400,13 +1028,32 @@ func (c *WebSocketClient) IsHealthy() bool {
 func (c *WebSocketClient) GetInfo() map[string]interface{} {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+
+	channels := make([]string, 0, len(c.channels))
+	for channel := range c.channels {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	statsPeriod, geoStatsPeriod := c.pushIntervals()
 	
 	return map[string]interface{}{
 		"clientID":    c.clientID,
+		"label":       c.label,
+		"tenant":      c.tenant,
 		"remoteAddr":  c.conn.RemoteAddr().String(),
 		"sendChanLen": len(c.send),
+		"droppedFrames": c.droppedFrames,
 		"logChanLen":  len(c.logChan),
 		"lastPing":    c.lastPing.Format(time.RFC3339),
 		"isClosing":   c.isClosing,
+		"filter":      c.filter,
+		"encoding":    c.encoding,
+		"deltaStats":  c.deltaStats,
+		"protocolVersion": c.protocolVersion,
+		"capabilities":    c.capabilityListLocked(),
+		"channels":    channels,
+		"statsIntervalSeconds":    int(statsPeriod / time.Second),
+		"geoStatsIntervalSeconds": int(geoStatsPeriod / time.Second),
+		"initialLogCount":         c.initialLogCount,
 	}
 }
\ No newline at end of file
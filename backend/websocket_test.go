@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// A test frame named by its data: "L" log frames, "S" stats snapshots and
+// "Q" frames that are never dropped
+func testFrame(name string) wsFrame {
+	switch name[0] {
+	case 'L':
+		return wsFrame{data: []byte(name), isLog: true, msgType: "newLog"}
+	case 'S':
+		return wsFrame{data: []byte(name), msgType: "stats"}
+	}
+	return wsFrame{data: []byte(name), msgType: "subscriptions"}
+}
+
+// Names of the queued frames in order; dropped notices read "dropped:N"
+func queuedFrameNames(t *testing.T, c *WebSocketClient) string {
+	t.Helper()
+	var names []string
+	for len(c.send) > 0 {
+		frame := <-c.send
+		if frame.msgType != "dropped" {
+			names = append(names, string(frame.data))
+			continue
+		}
+		var notice struct {
+			Data struct {
+				Dropped int `json:"dropped"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(frame.data, &notice); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, fmt.Sprintf("dropped:%d", notice.Data.Dropped))
+	}
+	return strings.Join(names, " ")
+}
+
+func TestEnqueueFullBuffer(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		queued  string
+		frame   string
+		want    string
+		dropped uint64
+	}{
+		{"oldest logs make room", "L1 L2 Q1 L3 L4 L5", "L6", "Q1 L3 L4 L5 dropped:2 L6", 2},
+		{"for other frames too", "L1 Q1 L2 L3 L4 L5", "Q2", "Q1 L3 L4 L5 dropped:2 Q2", 2},
+		{"stats replace stale stats", "L1 S1 L2 L3 L4 L5", "S2", "L1 L2 L3 L4 L5 S2", 0},
+		{"too few logs, new log dropped", "Q1 Q2 Q3 Q4 Q5 L1", "L2", "Q1 Q2 Q3 Q4 Q5 dropped:2", 2},
+		{"no room for the notice", "Q1 Q2 Q3 Q4 Q5 Q6", "L1", "Q1 Q2 Q3 Q4 Q5 Q6", 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			queued := strings.Fields(tt.queued)
+			c := &WebSocketClient{clientID: "test", send: make(chan wsFrame, len(queued)), closeChan: make(chan struct{})}
+			for _, name := range queued {
+				c.send <- testFrame(name)
+			}
+
+			c.enqueue(testFrame(tt.frame))
+			if got := queuedFrameNames(t, c); got != tt.want {
+				t.Errorf("queued %q, want %q", got, tt.want)
+			}
+			if c.droppedFrames != tt.dropped {
+				t.Errorf("dropped %d frames, want %d", c.droppedFrames, tt.dropped)
+			}
+			if c.isClosing {
+				t.Error("client was disconnected")
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+)
+
+const DEFAULT_WS_TOKEN_TTL = 60 * time.Second
+
+// WebSocket handshake authentication. When API_AUTH_TOKEN is set, clients
+// exchange it for a short-lived, single-use token via POST /api/ws/token and
+// pass that as ?token= on /ws. Without it the stream stays open as before.
+type WSAuth struct {
+	apiToken       string
+	tokenTTL       time.Duration
+	allowedOrigins map[string]bool
+	tokens         *cache.Cache
+	consumeMu      sync.Mutex // Makes lookup and delete in ConsumeToken atomic
+}
+
+var wsAuth *WSAuth
+
+func NewWSAuth() *WSAuth {
+	ttl := time.Duration(GetEnvInt("WS_TOKEN_TTL_SECONDS", int(DEFAULT_WS_TOKEN_TTL/time.Second))) * time.Second
+	if ttl <= 0 {
+		ttl = DEFAULT_WS_TOKEN_TTL
+	}
+
+	var allowedOrigins map[string]bool
+	if origins := os.Getenv("WS_ALLOWED_ORIGINS"); origins != "" {
+		allowedOrigins = make(map[string]bool)
+		for _, origin := range strings.Split(origins, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				allowedOrigins[strings.ToLower(origin)] = true
+			}
+		}
+	}
+
+	auth := &WSAuth{
+		apiToken:       os.Getenv("API_AUTH_TOKEN"),
+		tokenTTL:       ttl,
+		allowedOrigins: allowedOrigins,
+		tokens:         cache.New(ttl, time.Minute),
+	}
+	if auth.Enabled() {
+		wsLog.Info("WebSocket authentication enabled", "tokenTTL", ttl)
+	}
+	return auth
+}
+
+// Check whether /ws requires a token
+func (a *WSAuth) Enabled() bool {
+	return a.apiToken != ""
+}
+
+// Check whether a request carries the API token as a Bearer credential
+func (a *WSAuth) authorizeAPIRequest(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	given := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(a.apiToken)) == 1
+}
+
+// An issued WebSocket token and the tenant it's scoped to
+type wsToken struct {
+	expiresAt time.Time
+	tenant    string
+}
+
+// Issue a new single-use WebSocket token, scoped to a tenant if given
+func (a *WSAuth) IssueToken(tenant string) (string, time.Time, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(buf)
+	expiresAt := time.Now().Add(a.tokenTTL)
+	a.tokens.Set(token, wsToken{expiresAt: expiresAt, tenant: tenant}, cache.DefaultExpiration)
+	return token, expiresAt, nil
+}
+
+// Validate and consume a WebSocket token, returning its tenant
+func (a *WSAuth) ConsumeToken(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	// Two handshakes racing with the same token must not both find it
+	a.consumeMu.Lock()
+	value, found := a.tokens.Get(token)
+	if found {
+		a.tokens.Delete(token)
+	}
+	a.consumeMu.Unlock()
+	if !found {
+		return "", false
+	}
+	issued := value.(wsToken)
+	return issued.tenant, time.Now().Before(issued.expiresAt)
+}
+
+// Check the handshake Origin against WS_ALLOWED_ORIGINS; any origin is
+// accepted when the list is unset. Requests without an Origin header come
+// from non-browser clients and are allowed.
+func (a *WSAuth) CheckOrigin(r *http.Request) bool {
+	if a.allowedOrigins == nil {
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if a.allowedOrigins["*"] || a.allowedOrigins[strings.ToLower(origin)] {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && a.allowedOrigins[strings.ToLower(u.Host)] {
+		return true
+	}
+	wsLog.Warn("Rejected connection", "origin", origin)
+	return false
+}
+
+// Middleware requiring the API token when authentication is enabled. Requests
+// that passed user authentication (basic auth or OIDC) are let through too.
+func requireAPIToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if wsAuth.Enabled() && !wsAuth.authorizeAPIRequest(c.Request) && c.GetString(AUTH_USER_KEY) == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func issueWSToken(c *gin.Context) {
+	if !wsAuth.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "WebSocket authentication is not enabled"})
+		return
+	}
+
+	token, expiresAt, err := wsAuth.IssueToken(requestTenant(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":     token,
+		"expiresAt": expiresAt.Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func newTestWSAuth(t *testing.T) *WSAuth {
+	t.Helper()
+	t.Setenv("API_AUTH_TOKEN", "api-secret")
+	return NewWSAuth()
+}
+
+func TestWSTokenSingleUse(t *testing.T) {
+	auth := newTestWSAuth(t)
+	for i := 0; i < 200; i++ {
+		token, _, err := auth.IssueToken("")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Handshakes racing with the same token
+		var accepted atomic.Int32
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for j := 0; j < 16; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				if _, ok := auth.ConsumeToken(token); ok {
+					accepted.Add(1)
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+		if n := accepted.Load(); n != 1 {
+			t.Fatalf("token accepted %d times, want once", n)
+		}
+	}
+}
+
+func TestWSTokenExpiry(t *testing.T) {
+	auth := newTestWSAuth(t)
+	auth.tokenTTL = -time.Second
+	token, _, err := auth.IssueToken("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := auth.ConsumeToken(token); ok {
+		t.Error("expired token accepted")
+	}
+	if _, ok := auth.ConsumeToken("unknown"); ok {
+		t.Error("unknown token accepted")
+	}
+	if _, ok := auth.ConsumeToken(""); ok {
+		t.Error("empty token accepted")
+	}
+}
+
+func TestWSTokenCarriesTenant(t *testing.T) {
+	server := httptest.NewServer(newTenantTestRouter(t))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/ws/token", nil)
+	req.Header.Set("Authorization", "Bearer acme-secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var issued struct {
+		Token string `json:"token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&issued)
+	resp.Body.Close()
+	if err != nil || issued.Token == "" {
+		t.Fatalf("no token issued: %v", err)
+	}
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?token=" + issued.Token
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The initial logs are the tenant's only
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != "logs" {
+			continue
+		}
+		logs := string(msg.Data)
+		if !strings.Contains(logs, "/acme/page") || strings.Contains(logs, "globex") || strings.Contains(logs, "untenanted") {
+			t.Errorf("initial logs not scoped to the tenant: %s", logs)
+		}
+		break
+	}
+
+	wsClientsMux.RLock()
+	defer wsClientsMux.RUnlock()
+	if len(wsClients) != 1 {
+		t.Fatalf("%d clients registered, want 1", len(wsClients))
+	}
+	for client := range wsClients {
+		if client.tenant != "acme" {
+			t.Errorf("client scoped to %q, want acme", client.tenant)
+		}
+	}
+
+	// The token was used up by the handshake
+	if _, _, err := websocket.DefaultDialer.Dial(wsURL, nil); err == nil {
+		t.Error("token accepted twice")
+	}
+}
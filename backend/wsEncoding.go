@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// WebSocket subprotocols used to negotiate message encoding
+const (
+	WS_ENCODING_JSON    = "json"
+	WS_ENCODING_MSGPACK = "msgpack"
+)
+
+var wsSubprotocols = []string{WS_ENCODING_JSON, WS_ENCODING_MSGPACK}
+
+// Map the negotiated subprotocol to an encoding; clients that don't ask for
+// one get JSON
+func negotiatedEncoding(subprotocol string) string {
+	if subprotocol == WS_ENCODING_MSGPACK {
+		return WS_ENCODING_MSGPACK
+	}
+	return WS_ENCODING_JSON
+}
+
+// Encode a message for the wire. MessagePack reuses the json tags so both
+// encodings carry the same field names.
+func encodeWSMessage(encoding string, msg interface{}) ([]byte, error) {
+	if encoding != WS_ENCODING_MSGPACK {
+		return json.Marshal(msg)
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode an incoming frame based on its frame type
+func decodeWSMessage(frameType int, data []byte, msg interface{}) error {
+	if frameType != websocket.BinaryMessage {
+		return json.Unmarshal(data, msg)
+	}
+
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(msg)
+}
+
+// Get the WebSocket frame type used for an encoding
+func wsFrameType(encoding string) int {
+	if encoding == WS_ENCODING_MSGPACK {
+		return websocket.BinaryMessage
+	}
+	return websocket.TextMessage
+}
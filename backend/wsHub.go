@@ -0,0 +1,172 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Resolution of the broadcast hub; push intervals are whole seconds
+const HUB_TICK = time.Second
+
+var hubStop chan struct{}
+
+// A message encoded at most once per wire encoding and shared by all clients
+type hubFrame struct {
+	msg     WebSocketMessage
+	mu      sync.Mutex
+	encoded map[string][]byte
+}
+
+func newHubFrame(msg WebSocketMessage) *hubFrame {
+	return &hubFrame{msg: msg, encoded: make(map[string][]byte)}
+}
+
+func (f *hubFrame) bytes(encoding string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if data, ok := f.encoded[encoding]; ok {
+		return data, nil
+	}
+	data, err := encodeWSMessage(encoding, f.msg)
+	if err != nil {
+		return nil, err
+	}
+	f.encoded[encoding] = data
+	return data, nil
+}
+
+// Start the single goroutine that computes stats/geo payloads once per tick
+// and fans them out to every client that is due for a push
+func startBroadcastHub() {
+	hubStop = make(chan struct{})
+	ticker := time.NewTicker(HUB_TICK)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				broadcastPeriodicUpdates(now)
+			case <-hubStop:
+				return
+			}
+		}
+	}()
+}
+
+func broadcastPeriodicUpdates(now time.Time) {
+	wsClientsMux.RLock()
+	var statsDue, geoDue []*WebSocketClient
+	for client := range wsClients {
+		if !client.IsHealthy() {
+			continue
+		}
+		stats, geo := client.duePushes(now)
+		if stats {
+			statsDue = append(statsDue, client)
+		}
+		if geo {
+			geoDue = append(geoDue, client)
+		}
+	}
+	wsClientsMux.RUnlock()
+
+	if len(statsDue) == 0 && len(geoDue) == 0 {
+		return
+	}
+
+	// Stats are computed once per tenant scope ("" is global)
+	statsByTenant := make(map[string]*Stats)
+	scopedStats := func(tenant string) *Stats {
+		if stats, ok := statsByTenant[tenant]; ok {
+			return stats
+		}
+		stats := logParser.GetScopedStats(tenant)
+		statsByTenant[tenant] = &stats
+		return &stats
+	}
+
+	statsFrames := make(map[string]*hubFrame)
+	for _, client := range statsDue {
+		stats := scopedStats(client.tenant)
+		frame, ok := statsFrames[client.tenant]
+		if !ok {
+			frame = newHubFrame(WebSocketMessage{Type: "stats", Data: *stats})
+			statsFrames[client.tenant] = frame
+		}
+		client.pushStats(stats, frame)
+	}
+
+	geoFrames := make(map[string][2]*hubFrame)
+	for _, client := range geoDue {
+		frames, ok := geoFrames[client.tenant]
+		if !ok {
+			var geoStats GeoStats
+			if client.tenant == "" {
+				geoStats = logParser.GetGeoStats()
+			} else {
+				geoStats = geoStatsFromStats(scopedStats(client.tenant))
+			}
+			frames = [2]*hubFrame{
+				newHubFrame(WebSocketMessage{Type: "geoStats", Data: geoStats}),
+				newHubFrame(geoProcessingStatusMessage(scopedStats(client.tenant))),
+			}
+			geoFrames[client.tenant] = frames
+		}
+		client.pushFrame(frames[0])
+		client.pushFrame(frames[1])
+	}
+}
+
+// Report which periodic pushes the client is due for and mark them as sent.
+// Half a tick of slack keeps pushes from slipping a whole tick on jitter.
+func (c *WebSocketClient) duePushes(now time.Time) (stats, geo bool) {
+	statsPeriod, geoStatsPeriod := c.pushIntervals()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.channels[WS_CHANNEL_STATS] && now.Sub(c.lastStatsPush) >= statsPeriod-HUB_TICK/2 {
+		c.lastStatsPush = now
+		stats = true
+	}
+	if c.channels[WS_CHANNEL_GEO_STATS] && now.Sub(c.lastGeoPush) >= geoStatsPeriod-HUB_TICK/2 {
+		c.lastGeoPush = now
+		geo = true
+	}
+	return stats, geo
+}
+
+// Queue a shared frame in the client's encoding
+func (c *WebSocketClient) pushFrame(frame *hubFrame) {
+	data, err := frame.bytes(c.encoding)
+	if err != nil {
+		wsLog.Error("Failed to marshal message", "client", c.clientID, "error", err)
+		return
+	}
+	c.enqueue(wsFrame{data: data, msgType: frame.msg.Type})
+}
+
+// Push stats to the client: the shared full frame, or a diff against the
+// client's last stats in delta mode
+func (c *WebSocketClient) pushStats(stats *Stats, full *hubFrame) {
+	c.mu.Lock()
+	if !c.deltaStats || c.lastStats == nil {
+		c.lastStats = stats
+		c.mu.Unlock()
+		c.pushFrame(full)
+		return
+	}
+	delta := diffStats(c.lastStats, stats)
+	c.lastStats = stats
+	c.mu.Unlock()
+
+	if len(delta) == 0 {
+		return
+	}
+	c.sendMessage(WebSocketMessage{
+		Type: "statsDelta",
+		Data: delta,
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Stream protocol versions. Version 1 is the original protocol spoken by
+// clients that never send "hello"; version 2 adds capability negotiation.
+const (
+	WS_PROTOCOL_VERSION     = 2
+	WS_MIN_PROTOCOL_VERSION = 1
+)
+
+// Optional stream features negotiated in the hello handshake
+const (
+	WS_CAP_BINARY      = "binary"     // MessagePack frames (requires the msgpack subprotocol)
+	WS_CAP_DELTA_STATS = "deltaStats" // setStatsMode / statsDelta messages
+	WS_CAP_FILTERS     = "filters"    // setFilter / clearFilter messages
+	WS_CAP_BATCHING    = "batching"   // newLogs batches instead of single newLog messages
+	WS_CAP_RESUME      = "resume"     // seq numbers and ?resumeFrom replay
+	WS_CAP_CHANNELS    = "channels"   // subscribe / unsubscribe messages
+)
+
+var wsServerCapabilities = map[string]bool{
+	WS_CAP_BINARY:      true,
+	WS_CAP_DELTA_STATS: true,
+	WS_CAP_FILTERS:     true,
+	WS_CAP_BATCHING:    true,
+	WS_CAP_RESUME:      true,
+	WS_CAP_CHANNELS:    true,
+}
+
+type wsHelloParams struct {
+	Version      int      `json:"version"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// Handle a client's hello: settle on the highest common version and the
+// capabilities both sides support, then acknowledge
+func (c *WebSocketClient) handleHello(msg WebSocketMessage) {
+	var params wsHelloParams
+	if msg.Params != nil {
+		if p, err := json.Marshal(msg.Params); err == nil {
+			json.Unmarshal(p, &params)
+		}
+	}
+
+	version := params.Version
+	if version > WS_PROTOCOL_VERSION {
+		version = WS_PROTOCOL_VERSION
+	}
+	if version < WS_MIN_PROTOCOL_VERSION {
+		c.sendMessage(WebSocketMessage{
+			Type: "error",
+			Data: map[string]interface{}{
+				"message":    "Unsupported protocol version",
+				"minVersion": WS_MIN_PROTOCOL_VERSION,
+				"maxVersion": WS_PROTOCOL_VERSION,
+			},
+		})
+		return
+	}
+
+	capabilities := make(map[string]bool)
+	for _, capability := range params.Capabilities {
+		if !wsServerCapabilities[capability] {
+			continue
+		}
+		// Binary frames are fixed by the subprotocol chosen at upgrade time
+		if capability == WS_CAP_BINARY && c.encoding != WS_ENCODING_MSGPACK {
+			continue
+		}
+		capabilities[capability] = true
+	}
+
+	c.mu.Lock()
+	c.protocolVersion = version
+	c.capabilities = capabilities
+	c.mu.Unlock()
+
+	negotiated := c.negotiatedCapabilities()
+	wsLog.Debug("Client negotiated protocol", "client", c.clientID, "version", version, "capabilities", negotiated)
+
+	c.sendMessage(WebSocketMessage{
+		Type: "helloAck",
+		Data: map[string]interface{}{
+			"version":      version,
+			"minVersion":   WS_MIN_PROTOCOL_VERSION,
+			"maxVersion":   WS_PROTOCOL_VERSION,
+			"capabilities": negotiated,
+			"encoding":     c.encoding,
+		},
+	})
+}
+
+// Check whether a capability was negotiated
+func (c *WebSocketClient) hasCapability(capability string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.capabilities[capability]
+}
+
+// Get the negotiated capabilities in a stable order
+func (c *WebSocketClient) negotiatedCapabilities() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.capabilityListLocked()
+}
+
+func (c *WebSocketClient) capabilityListLocked() []string {
+	capabilities := make([]string, 0, len(c.capabilities))
+	for capability := range c.capabilities {
+		capabilities = append(capabilities, capability)
+	}
+	sort.Strings(capabilities)
+	return capabilities
+}